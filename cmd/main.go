@@ -19,6 +19,15 @@ func main() {
 	if err := godotenv.Load(); err != nil {
 		log.Println("No .env file found, relying on system environment variables")
 	}
+	config.LoadRedirectConfig()
+	config.LoadAuthServerConfig()
+	config.LoadKeyStoreConfig()
+	config.LoadSAMLConfig()
+	config.LoadLDAPConfig()
+	config.LoadDomainConfig()
+	config.LoadEmailConfig()
+	config.LoadInviteConfig()
+	config.LoadRelationConfig()
 
 	// Initialize database
 	if err := db.Connect(); err != nil {
@@ -32,6 +41,15 @@ func main() {
 	// Share the key manager with utils package
 	utils.SetKeyManager(services.GetKeyManager())
 
+	// Unverify domains whose verification token has been removed
+	go services.DomainService.StartReverification()
+
+	// Finish CSV invitation uploads interrupted by a restart
+	go services.BulkInviteService.ResumeJobs()
+
+	// Purge deleted relation tuples past their snapshot retention
+	go services.RelationService.StartPurge()
+
 	handlers := initializer.InitHandlers(services)
 
 	// Setup router with middleware
@@ -47,10 +65,22 @@ func main() {
 	routes.HealthRoutes(router)
 
 	// Register all routes
-	routes.AuthRoutes(router, handlers.AuthHandler, handlers.OAuthHandler, services.GetKeyManager(), repos.UserRepo)
-	routes.UserRoutes(router, handlers.UserHandler, services.GetKeyManager(), repos.UserRepo)
-	routes.TenantRoutes(router, handlers.TenantHandler, services.GetKeyManager(), repos.UserRepo)
-	routes.SecurityRoutes(router, handlers.SecurityHandler, services.GetKeyManager(), repos.UserRepo)
+	routes.AuthRoutes(router, handlers.AuthHandler, handlers.OAuthHandler, services.GetKeyManager(), repos.UserRepo, repos.SessionRepo)
+	routes.UserRoutes(router, handlers.UserHandler, services.GetKeyManager(), repos.UserRepo, repos.SessionRepo)
+	routes.TenantRoutes(router, handlers.TenantHandler, services.GetKeyManager(), repos.UserRepo, repos.SessionRepo)
+	routes.SecurityRoutes(router, handlers.SecurityHandler, services.GetKeyManager(), repos.UserRepo, repos.SessionRepo)
+	routes.OAuthServerRoutes(router, handlers.OAuthServerHandler, handlers.OAuthClientHandler, services.GetKeyManager(), repos.UserRepo, repos.SessionRepo)
+	routes.OIDCRoutes(router, handlers.OIDCHandler)
+	routes.KeyRoutes(router, handlers.KeyHandler, services.GetKeyManager(), repos.UserRepo, repos.SessionRepo)
+	routes.SAMLRoutes(router, handlers.SAMLHandler, services.GetKeyManager(), repos.UserRepo, repos.SessionRepo)
+	routes.SCIMRoutes(router, handlers.SCIMHandler, services.GetKeyManager(), repos.UserRepo, repos.SessionRepo)
+	routes.LDAPRoutes(router, handlers.LDAPHandler, services.GetKeyManager(), repos.UserRepo, repos.SessionRepo)
+	routes.DomainRoutes(router, handlers.DomainHandler, services.GetKeyManager(), repos.UserRepo, repos.SessionRepo)
+	routes.AutoJoinRoutes(router, handlers.AutoJoinHandler, services.GetKeyManager(), repos.UserRepo, repos.SessionRepo)
+	routes.InviteRoutes(router, handlers.InviteHandler, services.GetKeyManager(), repos.UserRepo, repos.SessionRepo)
+	routes.RoleRoutes(router, handlers.RoleHandler, services.GetKeyManager(), repos.UserRepo, repos.SessionRepo)
+	routes.GroupRoutes(router, handlers.GroupHandler, services.GetKeyManager(), repos.UserRepo, repos.SessionRepo)
+	routes.AuthzRoutes(router, handlers.AuthzHandler, handlers.RelationHandler)
 
 	// Start server
 	port := ":4000"
@@ -0,0 +1,41 @@
+package config
+
+import (
+	"os"
+	"time"
+)
+
+// DomainConfig holds the settings of tenant domain verification
+type DomainConfig struct {
+	// DNSResolver is the host:port of the DNS server TXT records are looked up on. Empty uses
+	// the system resolver.
+	DNSResolver string
+	// VerificationTTL is how long a verification token can be used
+	VerificationTTL time.Duration
+	// ReverifyInterval is how often verified domains are checked again
+	ReverifyInterval time.Duration
+	// ReverifyGracePeriod is how long a verified domain may fail checks before it is unverified
+	ReverifyGracePeriod time.Duration
+}
+
+var Domain DomainConfig
+
+// LoadDomainConfig reads the domain verification settings from the environment
+func LoadDomainConfig() {
+	Domain = DomainConfig{
+		DNSResolver:         os.Getenv("DOMAIN_DNS_RESOLVER"),
+		VerificationTTL:     7 * 24 * time.Hour,
+		ReverifyInterval:    24 * time.Hour,
+		ReverifyGracePeriod: 72 * time.Hour,
+	}
+
+	if ttl, err := time.ParseDuration(os.Getenv("DOMAIN_VERIFICATION_TTL")); err == nil && ttl > 0 {
+		Domain.VerificationTTL = ttl
+	}
+	if interval, err := time.ParseDuration(os.Getenv("DOMAIN_REVERIFY_INTERVAL")); err == nil && interval > 0 {
+		Domain.ReverifyInterval = interval
+	}
+	if grace, err := time.ParseDuration(os.Getenv("DOMAIN_REVERIFY_GRACE_PERIOD")); err == nil && grace >= 0 {
+		Domain.ReverifyGracePeriod = grace
+	}
+}
@@ -0,0 +1,33 @@
+package config
+
+import "os"
+
+// EmailConfig holds the SMTP server transactional email is sent through
+type EmailConfig struct {
+	// SMTPHost is the mail server. Empty logs emails instead of sending them.
+	SMTPHost     string
+	SMTPPort     string
+	SMTPUsername string
+	SMTPPassword string
+	// From is the sender address of every email
+	From string
+}
+
+var Email EmailConfig
+
+// LoadEmailConfig reads the SMTP settings from the environment
+func LoadEmailConfig() {
+	Email = EmailConfig{
+		SMTPHost:     os.Getenv("SMTP_HOST"),
+		SMTPPort:     os.Getenv("SMTP_PORT"),
+		SMTPUsername: os.Getenv("SMTP_USERNAME"),
+		SMTPPassword: os.Getenv("SMTP_PASSWORD"),
+		From:         os.Getenv("EMAIL_FROM"),
+	}
+	if Email.SMTPPort == "" {
+		Email.SMTPPort = "587"
+	}
+	if Email.From == "" {
+		Email.From = "no-reply@localhost"
+	}
+}
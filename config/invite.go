@@ -0,0 +1,36 @@
+package config
+
+import (
+	"os"
+	"time"
+)
+
+const defaultInviteAcceptURL = "http://localhost:3000/invite"
+
+// InviteConfig holds the settings of tenant invitations
+type InviteConfig struct {
+	// SigningKey signs invite tokens (base64url encoded, 32 bytes)
+	SigningKey string
+	// TTL is how long an invitation can be accepted after it is sent
+	TTL time.Duration
+	// AcceptURL is the frontend page invitation emails link to, with the token in ?token=
+	AcceptURL string
+}
+
+var Invite InviteConfig
+
+// LoadInviteConfig reads the invitation settings from the environment
+func LoadInviteConfig() {
+	Invite = InviteConfig{
+		SigningKey: os.Getenv("INVITE_SIGNING_KEY"),
+		TTL:        7 * 24 * time.Hour,
+		AcceptURL:  os.Getenv("INVITE_ACCEPT_URL"),
+	}
+
+	if ttl, err := time.ParseDuration(os.Getenv("INVITE_TTL")); err == nil && ttl > 0 {
+		Invite.TTL = ttl
+	}
+	if Invite.AcceptURL == "" {
+		Invite.AcceptURL = defaultInviteAcceptURL
+	}
+}
@@ -0,0 +1,63 @@
+package config
+
+import "os"
+
+// Signing key store backends
+const (
+	KeyStorePostgres = "postgres"
+	KeyStoreFile     = "file"
+	KeyStoreMemory   = "memory"
+)
+
+// Token signers
+const (
+	SignerLocal  = "local"
+	SignerRemote = "remote"
+)
+
+const defaultKeyStorePath = "./keys"
+
+// KeyStoreConfig holds where token signing keys are persisted
+type KeyStoreConfig struct {
+	// Store is one of postgres, file or memory. Memory keys are lost on restart.
+	Store string
+	// Path is the directory used by the file store
+	Path string
+	// EncryptionKey encrypts private keys at rest (base64url encoded, 32 bytes)
+	EncryptionKey string
+	// Algorithm signs tokens by default: RS256, ES256 or EdDSA
+	Algorithm string
+	// AdditionalAlgorithms get their own keys so clients can request them for id_tokens
+	AdditionalAlgorithms []string
+	// Signer is local, or remote to keep private keys in an external signing service
+	Signer string
+	// SignerURL is the base URL of the remote signing service
+	SignerURL string
+	// SignerToken authenticates requests to the remote signing service
+	SignerToken string
+}
+
+var Keys KeyStoreConfig
+
+// LoadKeyStoreConfig reads the signing key store settings from the environment
+func LoadKeyStoreConfig() {
+	Keys = KeyStoreConfig{
+		Store:                os.Getenv("JWT_KEYSTORE"),
+		Path:                 os.Getenv("JWT_KEYSTORE_PATH"),
+		EncryptionKey:        os.Getenv("JWT_KEY_ENCRYPTION_KEY"),
+		Algorithm:            os.Getenv("JWT_SIGNING_ALG"),
+		AdditionalAlgorithms: SplitList(os.Getenv("JWT_ADDITIONAL_SIGNING_ALGS")),
+		Signer:               os.Getenv("JWT_SIGNER"),
+		SignerURL:            os.Getenv("JWT_SIGNER_URL"),
+		SignerToken:          os.Getenv("JWT_SIGNER_TOKEN"),
+	}
+	if Keys.Signer == "" {
+		Keys.Signer = SignerLocal
+	}
+	if Keys.Store == "" {
+		Keys.Store = KeyStorePostgres
+	}
+	if Keys.Path == "" {
+		Keys.Path = defaultKeyStorePath
+	}
+}
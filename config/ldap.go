@@ -0,0 +1,28 @@
+package config
+
+import (
+	"os"
+	"time"
+)
+
+// LDAPConfig holds the settings of tenants' LDAP connectors
+type LDAPConfig struct {
+	// EncryptionKey encrypts bind passwords at rest (base64url encoded, 32 bytes)
+	EncryptionKey string
+	// Timeout bounds connecting to and each request against an LDAP server
+	Timeout time.Duration
+}
+
+var LDAP LDAPConfig
+
+// LoadLDAPConfig reads the LDAP connector settings from the environment
+func LoadLDAPConfig() {
+	LDAP = LDAPConfig{
+		EncryptionKey: os.Getenv("LDAP_ENCRYPTION_KEY"),
+		Timeout:       10 * time.Second,
+	}
+
+	if timeout, err := time.ParseDuration(os.Getenv("LDAP_TIMEOUT")); err == nil && timeout > 0 {
+		LDAP.Timeout = timeout
+	}
+}
@@ -0,0 +1,42 @@
+package config
+
+import (
+	"os"
+	"strings"
+)
+
+const (
+	defaultIssuer        = "http://localhost:4000"
+	defaultOAuthLoginURL = "http://localhost:3000/oauth/authorize"
+	defaultDeviceURL     = "http://localhost:3000/device"
+)
+
+// AuthServerConfig holds the settings of the built-in OAuth 2.0 authorization server
+type AuthServerConfig struct {
+	// Issuer is the public base URL of this service
+	Issuer string
+	// LoginURL is the frontend page that signs the user in and approves /oauth/authorize requests
+	LoginURL string
+	// DeviceVerificationURL is the frontend page where users enter device user codes
+	DeviceVerificationURL string
+}
+
+var AuthServer AuthServerConfig
+
+// LoadAuthServerConfig reads the authorization server settings from the environment
+func LoadAuthServerConfig() {
+	AuthServer = AuthServerConfig{
+		Issuer:                strings.TrimSuffix(os.Getenv("OAUTH_ISSUER"), "/"),
+		LoginURL:              os.Getenv("OAUTH_LOGIN_URL"),
+		DeviceVerificationURL: os.Getenv("OAUTH_DEVICE_VERIFICATION_URL"),
+	}
+	if AuthServer.Issuer == "" {
+		AuthServer.Issuer = defaultIssuer
+	}
+	if AuthServer.LoginURL == "" {
+		AuthServer.LoginURL = defaultOAuthLoginURL
+	}
+	if AuthServer.DeviceVerificationURL == "" {
+		AuthServer.DeviceVerificationURL = defaultDeviceURL
+	}
+}
@@ -0,0 +1,40 @@
+package config
+
+import (
+	"os"
+	"strings"
+)
+
+// defaultRedirectURI is used when no DEFAULT_REDIRECT_URI is configured.
+// The {tenant} placeholder is replaced with the slug of the tenant the user signed into.
+const defaultRedirectURI = "http://localhost:3000/{tenant}/callback"
+
+// RedirectConfig holds the deployment-wide post-login redirect settings
+type RedirectConfig struct {
+	AllowedURIs []string
+	DefaultURI  string
+}
+
+var Redirect RedirectConfig
+
+// LoadRedirectConfig reads the redirect allowlist from the environment
+func LoadRedirectConfig() {
+	Redirect = RedirectConfig{
+		AllowedURIs: SplitList(os.Getenv("ALLOWED_REDIRECT_URIS")),
+		DefaultURI:  os.Getenv("DEFAULT_REDIRECT_URI"),
+	}
+	if Redirect.DefaultURI == "" {
+		Redirect.DefaultURI = defaultRedirectURI
+	}
+}
+
+// SplitList splits a comma separated environment value, dropping empty entries
+func SplitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
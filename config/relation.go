@@ -0,0 +1,26 @@
+package config
+
+import (
+	"os"
+	"time"
+)
+
+// RelationConfig holds the settings of relationship-based authorization
+type RelationConfig struct {
+	// SnapshotRetention is how long deleted relation tuples are kept so that consistency tokens
+	// from before their deletion can still be read at exactly
+	SnapshotRetention time.Duration
+}
+
+var Relation RelationConfig
+
+// LoadRelationConfig reads the relationship-based authorization settings from the environment
+func LoadRelationConfig() {
+	Relation = RelationConfig{
+		SnapshotRetention: 24 * time.Hour,
+	}
+
+	if retention, err := time.ParseDuration(os.Getenv("RELATION_SNAPSHOT_RETENTION")); err == nil && retention > 0 {
+		Relation.SnapshotRetention = retention
+	}
+}
@@ -0,0 +1,21 @@
+package config
+
+import "os"
+
+// SAMLConfig holds the settings of the SAML 2.0 service provider
+type SAMLConfig struct {
+	// SPKeyPath is a PEM encoded RSA private key used to sign AuthnRequests
+	SPKeyPath string
+	// SPCertPath is the PEM encoded certificate for SPKeyPath, published in SP metadata
+	SPCertPath string
+}
+
+var SAML SAMLConfig
+
+// LoadSAMLConfig reads the SAML service provider settings from the environment
+func LoadSAMLConfig() {
+	SAML = SAMLConfig{
+		SPKeyPath:  os.Getenv("SAML_SP_KEY_PATH"),
+		SPCertPath: os.Getenv("SAML_SP_CERT_PATH"),
+	}
+}
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_oauth_states_expires_at;
+DROP TABLE IF EXISTS oauth_states;
+
+ALTER TABLE tenants
+DROP COLUMN allowed_redirect_uris;
@@ -0,0 +1,16 @@
+-- Per-tenant post-login redirect allowlist
+ALTER TABLE tenants
+ADD COLUMN allowed_redirect_uris TEXT[] NOT NULL DEFAULT '{}';
+
+-- In-flight provider logins
+CREATE TABLE IF NOT EXISTS oauth_states (
+    id UUID PRIMARY KEY,
+    state VARCHAR(255) NOT NULL UNIQUE,
+    provider VARCHAR(50) NOT NULL,
+    redirect_uri TEXT NOT NULL DEFAULT '',
+    tenant_id UUID REFERENCES tenants(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_oauth_states_expires_at ON oauth_states(expires_at);
@@ -0,0 +1,7 @@
+ALTER TABLE oauth_states
+DROP COLUMN code_challenge,
+DROP COLUMN code_challenge_method;
+
+ALTER TABLE pkce_challenges
+DROP COLUMN code_challenge_method,
+ADD COLUMN code_verifier VARCHAR(255) NOT NULL DEFAULT '';
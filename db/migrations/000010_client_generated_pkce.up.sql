@@ -0,0 +1,8 @@
+-- The code verifier now stays with the client; only its challenge is stored
+ALTER TABLE pkce_challenges
+DROP COLUMN code_verifier,
+ADD COLUMN code_challenge_method VARCHAR(10) NOT NULL DEFAULT 'S256';
+
+ALTER TABLE oauth_states
+ADD COLUMN code_challenge VARCHAR(128) NOT NULL DEFAULT '',
+ADD COLUMN code_challenge_method VARCHAR(10) NOT NULL DEFAULT 'plain';
@@ -0,0 +1,9 @@
+ALTER TABLE sessions
+DROP COLUMN scope,
+DROP COLUMN client_id;
+
+DROP INDEX IF EXISTS idx_oauth_authorization_codes_expires_at;
+DROP TABLE IF EXISTS oauth_authorization_codes;
+
+DROP INDEX IF EXISTS idx_oauth_clients_tenant_id;
+DROP TABLE IF EXISTS oauth_clients;
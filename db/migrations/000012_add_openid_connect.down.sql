@@ -0,0 +1,6 @@
+ALTER TABLE oauth_authorization_codes
+DROP COLUMN auth_time,
+DROP COLUMN nonce;
+
+ALTER TABLE users
+DROP COLUMN email_verified;
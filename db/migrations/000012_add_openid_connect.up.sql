@@ -0,0 +1,8 @@
+-- Whether the email address was confirmed, exposed as the email_verified claim
+ALTER TABLE users
+ADD COLUMN email_verified BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- OpenID Connect request parameters carried from /oauth/authorize to the id_token
+ALTER TABLE oauth_authorization_codes
+ADD COLUMN nonce VARCHAR(255) NOT NULL DEFAULT '',
+ADD COLUMN auth_time TIMESTAMP WITH TIME ZONE;
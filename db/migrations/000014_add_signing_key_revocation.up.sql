@@ -0,0 +1,3 @@
+-- Revoked keys stay listed until they expire so every instance learns about the revocation
+ALTER TABLE signing_keys
+ADD COLUMN revoked_at TIMESTAMP WITH TIME ZONE;
@@ -0,0 +1,5 @@
+ALTER TABLE oauth_clients
+DROP COLUMN signing_algorithm;
+
+ALTER TABLE signing_keys
+DROP COLUMN algorithm;
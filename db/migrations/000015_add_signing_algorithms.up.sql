@@ -0,0 +1,6 @@
+-- Existing keys are RSA keys
+ALTER TABLE signing_keys
+ADD COLUMN algorithm VARCHAR(10) NOT NULL DEFAULT 'RS256';
+
+ALTER TABLE oauth_clients
+ADD COLUMN signing_algorithm VARCHAR(10);
@@ -0,0 +1,18 @@
+-- Device authorization grant (RFC 8628). Device codes are stored by SHA-256 hash only.
+CREATE TABLE IF NOT EXISTS oauth_device_codes (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    device_code_hash VARCHAR(64) NOT NULL UNIQUE,
+    user_code VARCHAR(8) NOT NULL UNIQUE,
+    client_id VARCHAR(64) NOT NULL REFERENCES oauth_clients(client_id) ON DELETE CASCADE,
+    scope TEXT NOT NULL DEFAULT '',
+    status VARCHAR(16) NOT NULL DEFAULT 'pending',
+    user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+    tenant_id UUID REFERENCES tenants(id) ON DELETE CASCADE,
+    auth_time TIMESTAMP WITH TIME ZONE,
+    poll_interval INTEGER NOT NULL,
+    last_polled_at TIMESTAMP WITH TIME ZONE,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_oauth_device_codes_expires_at ON oauth_device_codes(expires_at);
@@ -0,0 +1,3 @@
+-- Audiences a client may exchange user tokens for (RFC 8693 token exchange)
+ALTER TABLE oauth_clients
+ADD COLUMN token_exchange_audiences TEXT[] NOT NULL DEFAULT '{}';
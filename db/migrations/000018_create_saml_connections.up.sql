@@ -0,0 +1,13 @@
+-- SAML 2.0 identity providers of enterprise tenants, one per tenant
+CREATE TABLE IF NOT EXISTS saml_connections (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id UUID NOT NULL UNIQUE REFERENCES tenants(id) ON DELETE CASCADE,
+    idp_entity_id TEXT NOT NULL,
+    idp_metadata_url TEXT NOT NULL DEFAULT '',
+    idp_metadata TEXT NOT NULL,
+    attribute_mapping JSONB NOT NULL DEFAULT '{}',
+    allow_idp_initiated BOOLEAN NOT NULL DEFAULT FALSE,
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
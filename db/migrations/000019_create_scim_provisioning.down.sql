@@ -0,0 +1,6 @@
+ALTER TABLE user_tenant_access
+    DROP COLUMN IF EXISTS external_id,
+    DROP COLUMN IF EXISTS active;
+
+DROP TABLE IF EXISTS scim_groups;
+DROP TABLE IF EXISTS scim_tokens;
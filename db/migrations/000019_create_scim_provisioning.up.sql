@@ -0,0 +1,28 @@
+-- Bearer tokens tenants' identity providers use to call the SCIM API
+CREATE TABLE IF NOT EXISTS scim_tokens (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    token_hash TEXT NOT NULL UNIQUE,
+    last_used_at TIMESTAMP WITH TIME ZONE,
+    revoked_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_scim_tokens_tenant_id ON scim_tokens(tenant_id);
+
+-- Groups provisioned over SCIM. Membership is stored as a role named after the group.
+CREATE TABLE IF NOT EXISTS scim_groups (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    display_name VARCHAR(255) NOT NULL,
+    external_id TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (tenant_id, display_name)
+);
+
+-- Deprovisioned members keep their access row but can no longer use the tenant
+ALTER TABLE user_tenant_access
+    ADD COLUMN IF NOT EXISTS active BOOLEAN NOT NULL DEFAULT TRUE,
+    ADD COLUMN IF NOT EXISTS external_id TEXT NOT NULL DEFAULT '';
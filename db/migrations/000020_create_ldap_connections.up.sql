@@ -0,0 +1,22 @@
+-- LDAP and Active Directory servers of on-premises tenants, one per tenant
+CREATE TABLE IF NOT EXISTS ldap_connections (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id UUID NOT NULL UNIQUE REFERENCES tenants(id) ON DELETE CASCADE,
+    url TEXT NOT NULL,
+    start_tls BOOLEAN NOT NULL DEFAULT FALSE,
+    insecure_skip_verify BOOLEAN NOT NULL DEFAULT FALSE,
+    root_ca TEXT NOT NULL DEFAULT '',
+    bind_dn TEXT NOT NULL,
+    -- Encrypted with LDAP_ENCRYPTION_KEY
+    bind_password_encrypted BYTEA,
+    user_search_base TEXT NOT NULL,
+    user_filter TEXT NOT NULL,
+    email_attribute VARCHAR(255) NOT NULL,
+    name_attribute VARCHAR(255) NOT NULL,
+    group_search_base TEXT NOT NULL DEFAULT '',
+    group_filter TEXT NOT NULL DEFAULT '',
+    group_roles JSONB NOT NULL DEFAULT '{}',
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
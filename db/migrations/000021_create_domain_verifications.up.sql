@@ -0,0 +1,20 @@
+-- Tenants' claims on email domains. A verified domain is copied to tenants.domain.
+CREATE TABLE IF NOT EXISTS domain_verifications (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    domain VARCHAR(255) NOT NULL,
+    method VARCHAR(20) NOT NULL,
+    token TEXT NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    verified_at TIMESTAMP WITH TIME ZONE,
+    last_checked_at TIMESTAMP WITH TIME ZONE,
+    last_error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (tenant_id, domain)
+);
+
+-- Re-verification scans verified domains by when they were last checked
+CREATE INDEX IF NOT EXISTS idx_domain_verifications_status_checked
+    ON domain_verifications(status, last_checked_at);
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS audit_logs;
+DROP TABLE IF EXISTS tenant_join_requests;
+
+ALTER TABLE tenants
+    DROP COLUMN IF EXISTS auto_join_role,
+    DROP COLUMN IF EXISTS auto_join_policy;
@@ -0,0 +1,23 @@
+-- Invitations to join a tenant, accepted with a signed token sent by email
+CREATE TABLE IF NOT EXISTS tenant_invites (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    email VARCHAR(255) NOT NULL,
+    role VARCHAR(50) NOT NULL,
+    status VARCHAR(50) NOT NULL DEFAULT 'pending',
+    expires_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE tenant_invites
+    ADD COLUMN IF NOT EXISTS invited_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    ADD COLUMN IF NOT EXISTS token_nonce VARCHAR(64) NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS send_count INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS last_sent_at TIMESTAMP,
+    ADD COLUMN IF NOT EXISTS accepted_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    ADD COLUMN IF NOT EXISTS responded_at TIMESTAMP;
+
+-- An email has at most one open invitation per tenant
+CREATE UNIQUE INDEX IF NOT EXISTS idx_tenant_invites_pending_email
+    ON tenant_invites(tenant_id, LOWER(email)) WHERE status = 'pending';
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS tenant_bulk_invite_jobs;
+
+ALTER TABLE tenant_invites DROP COLUMN IF EXISTS group_name;
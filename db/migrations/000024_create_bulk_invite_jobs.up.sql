@@ -0,0 +1,22 @@
+-- Group to put an invited user in once they accept, from bulk uploads
+ALTER TABLE tenant_invites ADD COLUMN IF NOT EXISTS group_name VARCHAR(255);
+
+-- CSV invitation uploads processed in the background
+CREATE TABLE IF NOT EXISTS tenant_bulk_invite_jobs (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'queued',
+    total INTEGER NOT NULL DEFAULT 0,
+    processed INTEGER NOT NULL DEFAULT 0,
+    invited INTEGER NOT NULL DEFAULT 0,
+    skipped INTEGER NOT NULL DEFAULT 0,
+    failed INTEGER NOT NULL DEFAULT 0,
+    rows JSONB,
+    results JSONB,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    completed_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_tenant_bulk_invite_jobs_tenant ON tenant_bulk_invite_jobs(tenant_id, created_at);
@@ -0,0 +1 @@
+ALTER TABLE tenant_roles DROP COLUMN IF EXISTS conditions;
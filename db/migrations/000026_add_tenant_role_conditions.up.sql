@@ -0,0 +1,2 @@
+-- Attribute conditions limiting where a custom role's permissions apply
+ALTER TABLE tenant_roles ADD COLUMN IF NOT EXISTS conditions JSONB;
@@ -0,0 +1,43 @@
+-- Namespaces of relationship-based authorization, configuring the relations of their objects
+CREATE TABLE IF NOT EXISTS relation_namespaces (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    name VARCHAR(64) NOT NULL,
+    relations JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (tenant_id, name)
+);
+
+-- Each write of a tenant's relation tuples advances its revision. Snapshots older than
+-- oldest_revision can no longer be read, as tuples deleted before it have been purged.
+CREATE TABLE IF NOT EXISTS relation_revisions (
+    tenant_id UUID PRIMARY KEY REFERENCES tenants(id) ON DELETE CASCADE,
+    revision BIGINT NOT NULL DEFAULT 0,
+    oldest_revision BIGINT NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Relation tuples object#relation@subject, kept after deletion until purged so that past
+-- revisions can be read
+CREATE TABLE IF NOT EXISTS relation_tuples (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    namespace VARCHAR(64) NOT NULL,
+    object_id VARCHAR(255) NOT NULL,
+    relation VARCHAR(64) NOT NULL,
+    subject_namespace VARCHAR(64) NOT NULL,
+    subject_object_id VARCHAR(255) NOT NULL,
+    subject_relation VARCHAR(64) NOT NULL DEFAULT '',
+    created_revision BIGINT NOT NULL,
+    deleted_revision BIGINT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_relation_tuples_live ON relation_tuples(
+    tenant_id, namespace, object_id, relation, subject_namespace, subject_object_id, subject_relation
+) WHERE deleted_revision IS NULL;
+CREATE INDEX IF NOT EXISTS idx_relation_tuples_object ON relation_tuples(tenant_id, namespace, object_id, relation);
+CREATE INDEX IF NOT EXISTS idx_relation_tuples_subject ON relation_tuples(tenant_id, subject_namespace, subject_object_id, subject_relation);
+CREATE INDEX IF NOT EXISTS idx_relation_tuples_deleted_at ON relation_tuples(deleted_at) WHERE deleted_at IS NOT NULL;
@@ -0,0 +1,5 @@
+DROP TRIGGER IF EXISTS remove_tenant_group_memberships_trigger ON user_tenant_access;
+DROP FUNCTION IF EXISTS remove_tenant_group_memberships();
+
+DROP TABLE IF EXISTS tenant_group_members;
+DROP TABLE IF EXISTS tenant_groups;
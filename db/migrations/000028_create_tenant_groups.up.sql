@@ -0,0 +1,44 @@
+-- Groups of a tenant's members. Members of a group, directly or through the groups nested in
+-- it, hold its roles.
+CREATE TABLE IF NOT EXISTS tenant_groups (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    roles TEXT[] NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_tenant_groups_name ON tenant_groups(tenant_id, LOWER(name));
+
+-- A member of a group is either a user or another group of the tenant
+CREATE TABLE IF NOT EXISTS tenant_group_members (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    group_id UUID NOT NULL REFERENCES tenant_groups(id) ON DELETE CASCADE,
+    user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+    member_group_id UUID REFERENCES tenant_groups(id) ON DELETE CASCADE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CHECK ((user_id IS NULL) <> (member_group_id IS NULL))
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_tenant_group_members_user ON tenant_group_members(group_id, user_id) WHERE user_id IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_tenant_group_members_group ON tenant_group_members(group_id, member_group_id) WHERE member_group_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_tenant_group_members_user_id ON tenant_group_members(user_id);
+CREATE INDEX IF NOT EXISTS idx_tenant_group_members_member_group_id ON tenant_group_members(member_group_id);
+
+-- Users leaving a tenant leave its groups
+CREATE OR REPLACE FUNCTION remove_tenant_group_memberships()
+RETURNS TRIGGER AS $$
+BEGIN
+    DELETE FROM tenant_group_members
+    WHERE user_id = OLD.user_id
+      AND group_id IN (SELECT id FROM tenant_groups WHERE tenant_id = OLD.tenant_id);
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER remove_tenant_group_memberships_trigger
+AFTER DELETE ON user_tenant_access
+FOR EACH ROW
+EXECUTE FUNCTION remove_tenant_group_memberships();
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_tenant_bulk_invite_jobs_unfinished;
+
+ALTER TABLE tenant_bulk_invite_jobs DROP COLUMN IF EXISTS claimed_by;
@@ -0,0 +1,5 @@
+-- Replica running a bulk invitation job, so an interrupted job is resumed by only one replica
+ALTER TABLE tenant_bulk_invite_jobs ADD COLUMN IF NOT EXISTS claimed_by UUID;
+
+CREATE INDEX IF NOT EXISTS idx_tenant_bulk_invite_jobs_unfinished ON tenant_bulk_invite_jobs(updated_at)
+    WHERE status IN ('queued', 'running');
@@ -0,0 +1 @@
+ALTER TABLE scim_tokens DROP COLUMN IF EXISTS roles;
@@ -0,0 +1,3 @@
+-- Roles a SCIM token may give users, directly or through groups named after them. Existing
+-- tokens can grant no roles until they are reissued.
+ALTER TABLE scim_tokens ADD COLUMN IF NOT EXISTS roles TEXT[] NOT NULL DEFAULT '{}';
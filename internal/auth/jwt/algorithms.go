@@ -0,0 +1,76 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// Supported token signing algorithms
+const (
+	AlgorithmRS256 = "RS256"
+	AlgorithmES256 = "ES256"
+	AlgorithmEdDSA = "EdDSA"
+)
+
+var ErrUnsupportedAlgorithm = errors.New("unsupported signing algorithm")
+
+// SupportedAlgorithms lists every algorithm a KeyManager can be configured with
+var SupportedAlgorithms = []string{AlgorithmRS256, AlgorithmES256, AlgorithmEdDSA}
+
+// IsSupportedAlgorithm reports whether keys can be generated for the algorithm
+func IsSupportedAlgorithm(algorithm string) bool {
+	return containsAlgorithm(SupportedAlgorithms, algorithm)
+}
+
+// signingMethod returns the JWT signing method for an algorithm
+func signingMethod(algorithm string) (jwt.SigningMethod, error) {
+	switch algorithm {
+	case AlgorithmRS256:
+		return jwt.SigningMethodRS256, nil
+	case AlgorithmES256:
+		return jwt.SigningMethodES256, nil
+	case AlgorithmEdDSA:
+		return jwt.SigningMethodEdDSA, nil
+	default:
+		return nil, ErrUnsupportedAlgorithm
+	}
+}
+
+// generateSigner creates a private key for the algorithm. rsaKeySize is only used for RS256.
+func generateSigner(algorithm string, rsaKeySize int) (crypto.Signer, error) {
+	switch algorithm {
+	case AlgorithmRS256:
+		return rsa.GenerateKey(rand.Reader, rsaKeySize)
+	case AlgorithmES256:
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case AlgorithmEdDSA:
+		_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+		return privateKey, err
+	default:
+		return nil, ErrUnsupportedAlgorithm
+	}
+}
+
+// algorithmMatchesKey reports whether a private key can sign, or a public key verify, with the
+// algorithm
+func algorithmMatchesKey(algorithm string, key crypto.PublicKey) bool {
+	switch key := key.(type) {
+	case *rsa.PrivateKey, *rsa.PublicKey:
+		return algorithm == AlgorithmRS256
+	case *ecdsa.PrivateKey:
+		return algorithm == AlgorithmES256 && key.Curve == elliptic.P256()
+	case *ecdsa.PublicKey:
+		return algorithm == AlgorithmES256 && key.Curve == elliptic.P256()
+	case ed25519.PrivateKey, ed25519.PublicKey:
+		return algorithm == AlgorithmEdDSA
+	default:
+		return false
+	}
+}
@@ -0,0 +1,61 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/x509"
+	"errors"
+)
+
+var ErrKeyDecryption = errors.New("failed to decrypt key")
+
+// newKeyCipher creates the AES-256-GCM cipher used to encrypt private keys at rest
+func newKeyCipher(encryptionKey []byte) (cipher.AEAD, error) {
+	if len(encryptionKey) != SecretKeyLength {
+		return nil, ErrInvalidKeyLength
+	}
+	block, err := aes.NewCipher(encryptionKey)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// sealPrivateKey encrypts a private key. The key ID is authenticated with it, so an encrypted
+// key cannot be swapped onto another key ID.
+func sealPrivateKey(aead cipher.AEAD, keyID string, privateKey crypto.Signer) ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return nil, ErrKeyEncoding
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, ErrKeyEncoding
+	}
+	return aead.Seal(nonce, nonce, der, []byte(keyID)), nil
+}
+
+// openPrivateKey decrypts a private key sealed by sealPrivateKey
+func openPrivateKey(aead cipher.AEAD, keyID string, sealed []byte) (crypto.Signer, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, ErrKeyDecryption
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	der, err := aead.Open(nil, nonce, ciphertext, []byte(keyID))
+	if err != nil {
+		return nil, ErrKeyDecryption
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, ErrKeyDecoding
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, ErrInvalidKey
+	}
+	return signer, nil
+}
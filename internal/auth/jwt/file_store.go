@@ -0,0 +1,168 @@
+package jwt
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	keyFileName  = "signing-keys.json"
+	lockFileName = "signing-keys.lock"
+
+	// staleLockAge is when a lock file left behind by a crashed process is ignored
+	staleLockAge = time.Minute
+)
+
+// fileKeyStore keeps encrypted keys in a JSON file. Replicas sharing the directory coordinate
+// rotation through an exclusive lock file.
+type fileKeyStore struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// NewFileKeyStore creates a key store in the given directory
+func NewFileKeyStore(dir string) (KeyStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &fileKeyStore{
+		dir: dir,
+	}, nil
+}
+
+func (s *fileKeyStore) LoadKeys() ([]*StoredKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	valid := make([]*StoredKey, 0, len(keys))
+	for _, key := range keys {
+		if key.ExpiresAt.After(now) {
+			valid = append(valid, key)
+		}
+	}
+	return valid, nil
+}
+
+func (s *fileKeyStore) SaveKey(key *StoredKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys, err := s.read()
+	if err != nil {
+		return err
+	}
+	return s.write(append(keys, key))
+}
+
+func (s *fileKeyStore) UpdateKey(key *StoredKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys, err := s.read()
+	if err != nil {
+		return err
+	}
+	for _, stored := range keys {
+		if stored.KeyID == key.KeyID {
+			stored.ActivatesAt = key.ActivatesAt
+			stored.ExpiresAt = key.ExpiresAt
+			stored.RevokedAt = key.RevokedAt
+			return s.write(keys)
+		}
+	}
+	return ErrKeyNotFound
+}
+
+func (s *fileKeyStore) DeleteExpiredKeys(before time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys, err := s.read()
+	if err != nil {
+		return err
+	}
+
+	kept := keys[:0]
+	for _, key := range keys {
+		if !key.ExpiresAt.Before(before) {
+			kept = append(kept, key)
+		}
+	}
+	return s.write(kept)
+}
+
+func (s *fileKeyStore) WithRotationLock(fn func() error) (bool, error) {
+	lockPath := filepath.Join(s.dir, lockFileName)
+
+	lock, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if errors.Is(err, os.ErrExist) {
+		info, statErr := os.Stat(lockPath)
+		if statErr != nil || time.Since(info.ModTime()) < staleLockAge {
+			return false, nil
+		}
+		// The previous holder crashed; take over its lock
+		if err := os.Remove(lockPath); err != nil {
+			return false, nil
+		}
+		lock, err = os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	}
+	if errors.Is(err, os.ErrExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	lock.Close()
+	defer os.Remove(lockPath)
+
+	return true, fn()
+}
+
+func (s *fileKeyStore) read() ([]*StoredKey, error) {
+	data, err := os.ReadFile(filepath.Join(s.dir, keyFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []*StoredKey
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return nil, ErrKeyDecoding
+	}
+	return keys, nil
+}
+
+// write replaces the key file atomically so readers never see a partial file
+func (s *fileKeyStore) write(keys []*StoredKey) error {
+	data, err := json.MarshalIndent(keys, "", "  ")
+	if err != nil {
+		return ErrKeyEncoding
+	}
+
+	tmp, err := os.CreateTemp(s.dir, keyFileName+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(s.dir, keyFileName))
+}
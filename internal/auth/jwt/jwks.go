@@ -0,0 +1,103 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+// JWK is a JSON Web Key (RFC 7517) describing an RSA, EC or OKP public key
+type JWK struct {
+	KeyType   string `json:"kty"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+	Curve     string `json:"crv,omitempty"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+	X         string `json:"x,omitempty"`
+	Y         string `json:"y,omitempty"`
+}
+
+// JWKSet is a JSON Web Key Set (RFC 7517 section 5)
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// jwksDocument is the serialized key set, cached until the next rotation
+type jwksDocument struct {
+	body []byte
+	etag string
+}
+
+// JWKS returns the serialized public key set, its ETag and the time it will next change
+func (m *KeyManager) JWKS() ([]byte, string, time.Time) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.jwks.body, m.jwks.etag, m.nextRotation
+}
+
+// refreshJWKS rebuilds the cached key set. Callers must hold the write lock.
+func (m *KeyManager) refreshJWKS() error {
+	set := JWKSet{Keys: []JWK{}}
+	now := time.Now()
+	for _, key := range m.keys {
+		// Keys of an external signer have no expiry; it stops listing them instead
+		if key.RevokedAt != nil || (!key.ExpiresAt.IsZero() && now.After(key.ExpiresAt)) {
+			continue
+		}
+		jwk, err := publicJWK(key)
+		if err != nil {
+			return err
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+
+	body, err := json.Marshal(set)
+	if err != nil {
+		return ErrKeyEncoding
+	}
+	sum := sha256.Sum256(body)
+	m.jwks = &jwksDocument{
+		body: body,
+		etag: fmt.Sprintf(`"%s"`, base64.RawURLEncoding.EncodeToString(sum[:16])),
+	}
+	return nil
+}
+
+// publicJWK encodes a key's public half (RFC 7518 section 6, RFC 8037 section 2)
+func publicJWK(key *KeyPair) (JWK, error) {
+	jwk := JWK{
+		Use:       "sig",
+		Algorithm: key.Algorithm,
+		KeyID:     key.KeyID,
+	}
+
+	switch publicKey := key.PublicKey.(type) {
+	case *rsa.PublicKey:
+		jwk.KeyType = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(publicKey.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(publicKey.E)).Bytes())
+	case *ecdsa.PublicKey:
+		// Coordinates are padded to the full size of the curve's field
+		size := (publicKey.Curve.Params().BitSize + 7) / 8
+		jwk.KeyType = "EC"
+		jwk.Curve = publicKey.Curve.Params().Name
+		jwk.X = base64.RawURLEncoding.EncodeToString(publicKey.X.FillBytes(make([]byte, size)))
+		jwk.Y = base64.RawURLEncoding.EncodeToString(publicKey.Y.FillBytes(make([]byte, size)))
+	case ed25519.PublicKey:
+		jwk.KeyType = "OKP"
+		jwk.Curve = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(publicKey)
+	default:
+		return JWK{}, ErrInvalidKey
+	}
+
+	return jwk, nil
+}
@@ -1,13 +1,16 @@
 package jwt
 
 import (
+	"crypto"
+	"crypto/cipher"
 	"crypto/rand"
-	"crypto/rsa"
 	"crypto/x509"
 	"encoding/base64"
 	"encoding/pem"
 	"errors"
 	"fmt"
+	"log"
+	"sort"
 	"sync"
 	"time"
 
@@ -20,6 +23,12 @@ const (
 
 	// KeyRotationInterval is the default interval for key rotation
 	KeyRotationInterval = 24 * time.Hour
+
+	// KeySyncInterval is the default interval for reloading keys from the store
+	KeySyncInterval = time.Minute
+
+	keyLockRetries    = 30
+	keyLockRetryDelay = time.Second
 )
 
 var (
@@ -27,46 +36,135 @@ var (
 	ErrGeneration  = errors.New("failed to generate key")
 	ErrKeyEncoding = errors.New("failed to encode key")
 	ErrKeyDecoding = errors.New("failed to decode key")
+	ErrKeyNotFound = errors.New("key not found")
 )
 
-// KeyPair represents an RSA key pair with metadata
+// KeyPair represents a signing key pair with metadata
 type KeyPair struct {
-	PrivateKey *rsa.PrivateKey
-	PublicKey  *rsa.PublicKey
-	KeyID      string // Unique identifier for the key pair
-	CreatedAt  time.Time
-	ExpiresAt  time.Time
+	PrivateKey  crypto.Signer
+	PublicKey   crypto.PublicKey
+	KeyID       string // Unique identifier for the key pair
+	Algorithm   string // The only algorithm the key signs and verifies with
+	CreatedAt   time.Time
+	ActivatesAt time.Time // When the key starts signing tokens
+	ExpiresAt   time.Time
+	RevokedAt   *time.Time // Revoked keys are never used for signing or verification
+}
+
+// KeyManagerConfig configures a KeyManager
+type KeyManagerConfig struct {
+	RotationInterval time.Duration
+	// KeySize is the RSA key size in bits
+	KeySize int
+	// Algorithm signs tokens unless a caller asks for another one. Defaults to RS256.
+	Algorithm string
+	// AdditionalAlgorithms get their own keys so individual clients can opt into them
+	AdditionalAlgorithms []string
+	// Store persists keys across restarts and replicas. Keys live in memory only when nil.
+	Store KeyStore
+	// EncryptionKey encrypts private keys at rest (32 bytes, AES-256-GCM)
+	EncryptionKey []byte
+	// SyncInterval is how often keys are reloaded from the store
+	SyncInterval time.Duration
+	// MaxTokenLifetime is the longest lifetime of any signed token. Keys stay verifiable
+	// for this long after they stop signing. Defaults to the rotation interval.
+	MaxTokenLifetime time.Duration
+	// Signer holds the private keys outside the process. The signer generates and rotates
+	// its own keys, so Store and EncryptionKey are not used.
+	Signer Signer
 }
 
-// KeyManager manages RSA key pairs with rotation
+// KeyManager manages signing key pairs with rotation. Each key is stored with the time it
+// activates, so every replica switches to a new key at the same moment. The next key is
+// generated one rotation ahead and published in the JWKS before it signs anything. Every
+// configured algorithm has its own active and next key.
 type KeyManager struct {
-	currentKey       *KeyPair
-	previousKey      *KeyPair
+	keys             []*KeyPair // Sorted by activation time
+	algorithms       []string   // The default algorithm comes first
+	signer           Signer
+	external         bool              // Keys are held by an external signer
+	externalActive   map[string]string // Active key ID per algorithm of the external signer
+	nextRotation     time.Time
+	jwks             *jwksDocument
 	rotationInterval time.Duration
 	keySize          int
+	store            KeyStore
+	cipher           cipher.AEAD
+	syncInterval     time.Duration
+	maxTokenLifetime time.Duration
 	mu               sync.RWMutex
 }
 
-// NewKeyManager creates a new KeyManager with the given parameters
+// NewKeyManager creates a new KeyManager with the given parameters, keeping keys in memory
 func NewKeyManager(rotationInterval time.Duration, keySize int) (*KeyManager, error) {
-	if rotationInterval == 0 {
-		rotationInterval = KeyRotationInterval
+	return NewKeyManagerWithConfig(KeyManagerConfig{
+		RotationInterval: rotationInterval,
+		KeySize:          keySize,
+	})
+}
+
+// NewKeyManagerWithConfig creates a KeyManager, loading its keys from the configured store
+func NewKeyManagerWithConfig(cfg KeyManagerConfig) (*KeyManager, error) {
+	if cfg.RotationInterval == 0 {
+		cfg.RotationInterval = KeyRotationInterval
+	}
+	if cfg.KeySize == 0 {
+		cfg.KeySize = DefaultKeySize
 	}
-	if keySize == 0 {
-		keySize = DefaultKeySize
+	if cfg.Algorithm == "" {
+		cfg.Algorithm = AlgorithmRS256
+	}
+	algorithms := []string{cfg.Algorithm}
+	for _, algorithm := range cfg.AdditionalAlgorithms {
+		if !containsAlgorithm(algorithms, algorithm) {
+			algorithms = append(algorithms, algorithm)
+		}
+	}
+	for _, algorithm := range algorithms {
+		if !IsSupportedAlgorithm(algorithm) {
+			return nil, fmt.Errorf("%w: %s", ErrUnsupportedAlgorithm, algorithm)
+		}
+	}
+	if cfg.SyncInterval == 0 {
+		cfg.SyncInterval = KeySyncInterval
+	}
+	if cfg.MaxTokenLifetime == 0 {
+		cfg.MaxTokenLifetime = cfg.RotationInterval
+	}
+	if cfg.Signer != nil {
+		return newExternalKeyManager(cfg, algorithms)
+	}
+	if cfg.Store == nil {
+		cfg.Store = NewMemoryKeyStore()
+		if cfg.EncryptionKey == nil {
+			encryptionKey, err := GenerateKey()
+			if err != nil {
+				return nil, err
+			}
+			cfg.EncryptionKey = encryptionKey
+		}
+	}
+
+	keyCipher, err := newKeyCipher(cfg.EncryptionKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key encryption key: %v", err)
 	}
 
 	manager := &KeyManager{
-		rotationInterval: rotationInterval,
-		keySize:          keySize,
+		algorithms:       algorithms,
+		rotationInterval: cfg.RotationInterval,
+		keySize:          cfg.KeySize,
+		store:            cfg.Store,
+		cipher:           keyCipher,
+		syncInterval:     cfg.SyncInterval,
+		maxTokenLifetime: cfg.MaxTokenLifetime,
 	}
+	manager.signer = &localSigner{manager: manager}
 
-	// Generate initial key pair synchronously
-	initialKey, err := manager.GenerateKeyPair()
-	if err != nil {
-		return nil, fmt.Errorf("failed to generate initial key pair: %v", err)
+	// Load or generate the initial keys synchronously
+	if err := manager.ensureKeys(); err != nil {
+		return nil, fmt.Errorf("failed to initialize signing keys: %v", err)
 	}
-	manager.currentKey = initialKey
 
 	// Start key rotation goroutine
 	go manager.startKeyRotation()
@@ -74,34 +172,70 @@ func NewKeyManager(rotationInterval time.Duration, keySize int) (*KeyManager, er
 	return manager, nil
 }
 
-// GenerateKeyPair generates a new RSA key pair
-func (m *KeyManager) GenerateKeyPair() (*KeyPair, error) {
-	privateKey, err := rsa.GenerateKey(rand.Reader, m.keySize)
+// newExternalKeyManager creates a KeyManager that signs through an external signer and
+// only keeps its public keys
+func newExternalKeyManager(cfg KeyManagerConfig, algorithms []string) (*KeyManager, error) {
+	manager := &KeyManager{
+		algorithms:   algorithms,
+		signer:       cfg.Signer,
+		external:     true,
+		syncInterval: cfg.SyncInterval,
+	}
+
+	if err := manager.loadSignerKeys(); err != nil {
+		return nil, fmt.Errorf("failed to load keys from signer: %v", err)
+	}
+
+	go manager.startKeyRotation()
+
+	return manager, nil
+}
+
+// GenerateKeyPair generates a new key pair for the algorithm that activates immediately
+func (m *KeyManager) GenerateKeyPair(algorithm string) (*KeyPair, error) {
+	privateKey, err := generateSigner(algorithm, m.keySize)
+	if err == ErrUnsupportedAlgorithm {
+		return nil, err
+	}
 	if err != nil {
 		return nil, ErrGeneration
 	}
 
+	now := time.Now()
 	return &KeyPair{
-		PrivateKey: privateKey,
-		PublicKey:  &privateKey.PublicKey,
-		KeyID:      generateKeyID(),
-		CreatedAt:  time.Now(),
-		ExpiresAt:  time.Now().Add(m.rotationInterval * 2),
+		PrivateKey:  privateKey,
+		PublicKey:   privateKey.Public(),
+		KeyID:       generateKeyID(),
+		Algorithm:   algorithm,
+		CreatedAt:   now,
+		ActivatesAt: now,
+		ExpiresAt:   m.keyExpiry(now),
 	}, nil
 }
 
-// GetCurrentPrivateKey returns the current private key
-func (m *KeyManager) GetCurrentPrivateKey() *rsa.PrivateKey {
+// Algorithms returns the algorithms tokens can be signed with, the default first
+func (m *KeyManager) Algorithms() []string {
+	return append([]string(nil), m.algorithms...)
+}
+
+// SupportsAlgorithm reports whether the manager has keys for the algorithm
+func (m *KeyManager) SupportsAlgorithm(algorithm string) bool {
+	return containsAlgorithm(m.algorithms, algorithm)
+}
+
+// GetCurrentPrivateKey returns the current private key of the default algorithm. It is nil
+// when an external signer holds the keys.
+func (m *KeyManager) GetCurrentPrivateKey() crypto.Signer {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
-	return m.currentKey.PrivateKey
+	return m.currentKey().PrivateKey
 }
 
-// GetCurrentPublicKey returns the current public key
-func (m *KeyManager) GetCurrentPublicKey() *rsa.PublicKey {
+// GetCurrentPublicKey returns the current public key of the default algorithm
+func (m *KeyManager) GetCurrentPublicKey() crypto.PublicKey {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
-	return m.currentKey.PublicKey
+	return m.currentKey().PublicKey
 }
 
 // GetKeyPairByID returns the key pair matching the given ID
@@ -109,40 +243,332 @@ func (m *KeyManager) GetKeyPairByID(keyID string) *KeyPair {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
-	if m.currentKey.KeyID == keyID {
-		return m.currentKey
+	for _, key := range m.keys {
+		if key.KeyID == keyID && key.RevokedAt == nil {
+			return key
+		}
+	}
+	return nil
+}
+
+// currentKey returns the key signing with the default algorithm. Callers must hold the lock.
+func (m *KeyManager) currentKey() *KeyPair {
+	return m.signingKey(m.algorithms[0])
+}
+
+// signingKey returns the key signing with the algorithm. Callers must hold the lock.
+func (m *KeyManager) signingKey(algorithm string) *KeyPair {
+	if m.external {
+		for _, key := range m.keys {
+			if key.KeyID == m.externalActive[algorithm] {
+				return key
+			}
+		}
+		return nil
 	}
-	if m.previousKey != nil && m.previousKey.KeyID == keyID {
-		return m.previousKey
+	return activeKeyAt(m.keys, algorithm, time.Now())
+}
+
+// activeKeyAt returns the key signing with the algorithm at the given time: the last one to
+// activate. The keys it retired never sign again, so there is none while it is revoked.
+func activeKeyAt(keys []*KeyPair, algorithm string, at time.Time) *KeyPair {
+	var active *KeyPair
+	for _, key := range keys {
+		if key.ActivatesAt.After(at) {
+			break
+		}
+		if key.Algorithm == algorithm {
+			active = key
+		}
+	}
+	if active != nil && active.RevokedAt != nil {
+		return nil
+	}
+	return active
+}
+
+// pendingKeyAt returns the first key of the algorithm that activates after the given time
+func pendingKeyAt(keys []*KeyPair, algorithm string, at time.Time) *KeyPair {
+	for _, key := range keys {
+		if key.ActivatesAt.After(at) && key.Algorithm == algorithm && key.RevokedAt == nil {
+			return key
+		}
 	}
 	return nil
 }
 
-// rotateKeys generates a new key pair and rotates the existing ones
-func (m *KeyManager) rotateKeys() error {
-	newKeyPair, err := m.GenerateKeyPair()
+func containsAlgorithm(algorithms []string, algorithm string) bool {
+	for _, candidate := range algorithms {
+		if candidate == algorithm {
+			return true
+		}
+	}
+	return false
+}
+
+// keyExpiry is when a key activating at the given time can be dropped: it signs for up to a
+// rotation interval (plus a sync interval of delay), and the last token it signs must stay
+// verifiable for the longest token lifetime
+func (m *KeyManager) keyExpiry(activatesAt time.Time) time.Time {
+	return activatesAt.Add(m.rotationInterval + m.syncInterval + m.maxTokenLifetime)
+}
+
+// ensureKeys makes sure there is an active key and a pre-published next key. Only the
+// replica holding the rotation lock generates keys; the others load them from the store.
+func (m *KeyManager) ensureKeys() error {
+	for attempt := 0; ; attempt++ {
+		if err := m.loadKeys(); err != nil {
+			return err
+		}
+		if !m.needsKeys(time.Now()) {
+			return nil
+		}
+
+		acquired, err := m.store.WithRotationLock(func() error {
+			// Another replica may have generated the keys while we were waiting
+			if err := m.loadKeys(); err != nil {
+				return err
+			}
+			return m.generateMissingKeys()
+		})
+		if err != nil {
+			return err
+		}
+		if acquired {
+			return nil
+		}
+
+		// Another replica is rotating. Keep using our keys if we have any, otherwise
+		// wait for it to save the new ones.
+		m.mu.RLock()
+		hasKey := m.currentKey() != nil
+		m.mu.RUnlock()
+		if hasKey {
+			return nil
+		}
+		if attempt >= keyLockRetries {
+			return errors.New("timed out waiting for another instance to generate signing keys")
+		}
+		time.Sleep(keyLockRetryDelay)
+	}
+}
+
+func (m *KeyManager) needsKeys(at time.Time) bool {
+	for _, algorithm := range m.algorithms {
+		if m.needsKeysFor(algorithm, at) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *KeyManager) needsKeysFor(algorithm string, at time.Time) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return activeKeyAt(m.keys, algorithm, at) == nil || pendingKeyAt(m.keys, algorithm, at) == nil
+}
+
+// generateMissingKeys creates the active and next keys that do not exist yet. Callers must
+// hold the rotation lock.
+func (m *KeyManager) generateMissingKeys() error {
+	now := time.Now()
+	for _, algorithm := range m.algorithms {
+		for m.needsKeysFor(algorithm, now) {
+			m.mu.RLock()
+			active := activeKeyAt(m.keys, algorithm, now)
+			m.mu.RUnlock()
+
+			// The next key takes over one interval after the current one activated. After
+			// downtime that moment may have passed, in which case it activates right away.
+			activatesAt := now
+			if active != nil {
+				activatesAt = active.ActivatesAt.Add(m.rotationInterval)
+				if activatesAt.Before(now) {
+					activatesAt = now
+				}
+			}
+
+			if err := m.createKey(algorithm, activatesAt); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := m.store.DeleteExpiredKeys(now); err != nil {
+		log.Printf("Failed to delete expired signing keys: %v", err)
+	}
+
+	return m.loadKeys()
+}
+
+// createKey generates and stores a key activating at the given time. Callers must hold the
+// rotation lock.
+func (m *KeyManager) createKey(algorithm string, activatesAt time.Time) error {
+	key, err := m.GenerateKeyPair(algorithm)
 	if err != nil {
 		return err
 	}
+	key.ActivatesAt = activatesAt
+	key.ExpiresAt = m.keyExpiry(activatesAt)
+
+	sealed, err := sealPrivateKey(m.cipher, key.KeyID, key.PrivateKey)
+	if err != nil {
+		return err
+	}
+	if err := m.store.SaveKey(&StoredKey{
+		KeyID:               key.KeyID,
+		Algorithm:           key.Algorithm,
+		EncryptedPrivateKey: sealed,
+		CreatedAt:           key.CreatedAt,
+		ActivatesAt:         key.ActivatesAt,
+		ExpiresAt:           key.ExpiresAt,
+	}); err != nil {
+		return fmt.Errorf("failed to save signing key: %v", err)
+	}
+
+	m.mu.Lock()
+	m.keys = append(m.keys, key)
+	sortKeys(m.keys)
+	m.mu.Unlock()
+
+	return nil
+}
+
+// loadKeys replaces the in-memory keys with the unexpired keys in the store
+func (m *KeyManager) loadKeys() error {
+	stored, err := m.store.LoadKeys()
+	if err != nil {
+		return fmt.Errorf("failed to load signing keys: %v", err)
+	}
 
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	m.previousKey = m.currentKey
-	m.currentKey = newKeyPair
+	// Keys that are already loaded don't need to be decrypted again
+	known := make(map[string]*KeyPair, len(m.keys))
+	for _, key := range m.keys {
+		known[key.KeyID] = key
+	}
 
-	return nil
+	now := time.Now()
+	keys := make([]*KeyPair, 0, len(stored))
+	for _, storedKey := range stored {
+		if !storedKey.ExpiresAt.After(now) {
+			continue
+		}
+
+		// Keys stored before other algorithms were supported are RSA keys
+		algorithm := storedKey.Algorithm
+		if algorithm == "" {
+			algorithm = AlgorithmRS256
+		}
+
+		key := &KeyPair{
+			KeyID:       storedKey.KeyID,
+			Algorithm:   algorithm,
+			CreatedAt:   storedKey.CreatedAt,
+			ActivatesAt: storedKey.ActivatesAt,
+			ExpiresAt:   storedKey.ExpiresAt,
+			RevokedAt:   storedKey.RevokedAt,
+		}
+		keys = append(keys, key)
+
+		// Revoked private keys are never decrypted again
+		if key.RevokedAt != nil {
+			continue
+		}
+		if loaded, ok := known[storedKey.KeyID]; ok && loaded.PrivateKey != nil {
+			key.PrivateKey = loaded.PrivateKey
+			key.PublicKey = loaded.PublicKey
+			continue
+		}
+
+		privateKey, err := openPrivateKey(m.cipher, storedKey.KeyID, storedKey.EncryptedPrivateKey)
+		if err != nil {
+			return fmt.Errorf("failed to decrypt signing key %s: %v", storedKey.KeyID, err)
+		}
+		if !algorithmMatchesKey(algorithm, privateKey) {
+			return fmt.Errorf("signing key %s is not a %s key", storedKey.KeyID, algorithm)
+		}
+		key.PrivateKey = privateKey
+		key.PublicKey = privateKey.Public()
+	}
+	sortKeys(keys)
+
+	m.keys = keys
+	m.nextRotation = now.Add(m.syncInterval)
+	for _, algorithm := range m.algorithms {
+		if next := pendingKeyAt(keys, algorithm, now); next != nil && next.ActivatesAt.Before(m.nextRotation) {
+			m.nextRotation = next.ActivatesAt
+		}
+	}
+
+	return m.refreshJWKS()
 }
 
-// startKeyRotation starts the key rotation goroutine
+// loadSignerKeys replaces the in-memory keys with the public keys of the external signer.
+// The signer decides when keys rotate; keys it no longer lists stop verifying.
+func (m *KeyManager) loadSignerKeys() error {
+	signerKeys, err := m.signer.Keys()
+	if err != nil {
+		return err
+	}
+
+	keys := make([]*KeyPair, 0, len(signerKeys))
+	active := make(map[string]string, len(m.algorithms))
+	for _, signerKey := range signerKeys {
+		if !IsSupportedAlgorithm(signerKey.Algorithm) {
+			continue
+		}
+		keys = append(keys, &KeyPair{
+			PublicKey: signerKey.PublicKey,
+			KeyID:     signerKey.KeyID,
+			Algorithm: signerKey.Algorithm,
+		})
+		if signerKey.Active {
+			active[signerKey.Algorithm] = signerKey.KeyID
+		}
+	}
+	for _, algorithm := range m.algorithms {
+		if active[algorithm] == "" {
+			return fmt.Errorf("signer has no active %s key", algorithm)
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.keys = keys
+	m.externalActive = active
+	m.nextRotation = time.Now().Add(m.syncInterval)
+
+	return m.refreshJWKS()
+}
+
+// sortKeys orders keys by activation time. Of keys activating together the newest comes
+// last, so it is the one that signs.
+func sortKeys(keys []*KeyPair) {
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].ActivatesAt.Equal(keys[j].ActivatesAt) {
+			return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+		}
+		return keys[i].ActivatesAt.Before(keys[j].ActivatesAt)
+	})
+}
+
+// startKeyRotation periodically picks up keys generated by other replicas and rotates
+// when the next key activates
 func (m *KeyManager) startKeyRotation() {
-	ticker := time.NewTicker(m.rotationInterval)
+	ticker := time.NewTicker(m.syncInterval)
 	defer ticker.Stop()
 
 	for range ticker.C {
-		if err := m.rotateKeys(); err != nil {
-			// TODO: Add proper error logging
-			continue
+		syncKeys := m.ensureKeys
+		if m.external {
+			syncKeys = m.loadSignerKeys
+		}
+		if err := syncKeys(); err != nil {
+			log.Printf("Failed to sync signing keys: %v", err)
 		}
 	}
 }
@@ -152,21 +578,21 @@ func (m *KeyManager) ExportPublicKeyPEM() ([]byte, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
-	pubKeyBytes, err := x509.MarshalPKIXPublicKey(m.currentKey.PublicKey)
+	pubKeyBytes, err := x509.MarshalPKIXPublicKey(m.currentKey().PublicKey)
 	if err != nil {
 		return nil, ErrKeyEncoding
 	}
 
 	pemBlock := &pem.Block{
-		Type:  "RSA PUBLIC KEY",
+		Type:  "PUBLIC KEY",
 		Bytes: pubKeyBytes,
 	}
 
 	return pem.EncodeToMemory(pemBlock), nil
 }
 
-// ImportPublicKeyPEM imports a public key from PEM format
-func ImportPublicKeyPEM(pemData []byte) (*rsa.PublicKey, error) {
+// ImportPublicKeyPEM imports an RSA, ECDSA or Ed25519 public key from PEM format
+func ImportPublicKeyPEM(pemData []byte) (crypto.PublicKey, error) {
 	block, _ := pem.Decode(pemData)
 	if block == nil {
 		return nil, ErrKeyDecoding
@@ -177,12 +603,7 @@ func ImportPublicKeyPEM(pemData []byte) (*rsa.PublicKey, error) {
 		return nil, ErrKeyDecoding
 	}
 
-	rsaPub, ok := pub.(*rsa.PublicKey)
-	if !ok {
-		return nil, ErrInvalidKey
-	}
-
-	return rsaPub, nil
+	return pub, nil
 }
 
 // generateKeyID generates a unique identifier for a key pair
@@ -204,56 +625,61 @@ func generateKeyID() string {
 func (m *KeyManager) GetCurrentKeyID() string {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
-	return m.currentKey.KeyID
+	return m.currentKey().KeyID
 }
 
-// SignToken signs a token with the current key
+// SignToken signs a token with the current key of the default algorithm
 func (m *KeyManager) SignToken(claims jwt.Claims) (string, error) {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
+	return m.SignTokenWithAlgorithm(claims, m.algorithms[0])
+}
 
-	if m.currentKey == nil {
-		return "", ErrInvalidKey
+// SignTokenWithAlgorithm signs a token with the current key of the given algorithm
+func (m *KeyManager) SignTokenWithAlgorithm(claims jwt.Claims, algorithm string) (string, error) {
+	method, err := signingMethod(algorithm)
+	if err != nil {
+		return "", err
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
-	token.Header["kid"] = m.currentKey.KeyID
+	m.mu.RLock()
+	currentKey := m.signingKey(algorithm)
+	m.mu.RUnlock()
+	if currentKey == nil {
+		return "", ErrInvalidKey
+	}
 
-	return token.SignedString(m.currentKey.PrivateKey)
-}
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = currentKey.KeyID
 
-// VerifyToken verifies a token and unmarshals its claims
-func (m *KeyManager) VerifyToken(tokenString string, claims jwt.Claims) error {
-	var err error
-	parser := jwt.Parser{
-		ValidMethods: []string{jwt.SigningMethodRS256.Name},
+	// The signer produces the signature, so the private key may live outside the process
+	signingInput, err := token.SigningString()
+	if err != nil {
+		return "", err
 	}
-
-	// First parse without validation to get the key ID
-	var token *jwt.Token
-	token, err = parser.Parse(tokenString, nil)
+	signature, err := m.signer.Sign(currentKey.KeyID, algorithm, []byte(signingInput))
 	if err != nil {
-		return ErrInvalidKey
+		return "", err
 	}
 
-	// Get key ID from token header
-	var ok bool
-	var keyID string
-	if keyID, ok = token.Header["kid"].(string); !ok {
-		return ErrInvalidKey
-	}
+	return signingInput + "." + jwt.EncodeSegment(signature), nil
+}
 
-	// Get the key pair for this key ID
-	keyPair := m.GetKeyPairByID(keyID)
-	if keyPair == nil {
-		return ErrInvalidKey
+// VerifyToken verifies a token and unmarshals its claims. The token must be signed with the
+// algorithm its key is bound to, so a key cannot be used with a different algorithm.
+func (m *KeyManager) VerifyToken(tokenString string, claims jwt.Claims) error {
+	parser := jwt.Parser{
+		ValidMethods: SupportedAlgorithms,
 	}
 
-	// Parse and validate token with the correct public key
-	_, err = jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-		var isRSA bool
-		if _, isRSA = token.Method.(*jwt.SigningMethodRSA); !isRSA {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	// Select the public key by the key ID in the token header
+	_, err := parser.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		keyID, ok := token.Header["kid"].(string)
+		if !ok {
+			return nil, ErrInvalidKey
+		}
+
+		keyPair := m.GetKeyPairByID(keyID)
+		if keyPair == nil || token.Method.Alg() != keyPair.Algorithm {
+			return nil, ErrInvalidKey
 		}
 		return keyPair.PublicKey, nil
 	})
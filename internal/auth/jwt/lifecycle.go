@@ -0,0 +1,182 @@
+package jwt
+
+import (
+	"errors"
+	"log"
+	"time"
+)
+
+// KeyState is the lifecycle state of a signing key
+type KeyState string
+
+const (
+	// KeyStatePending keys are published in the JWKS but do not sign yet
+	KeyStatePending KeyState = "pending"
+	// KeyStateActive is the key currently signing tokens
+	KeyStateActive KeyState = "active"
+	// KeyStateRetired keys no longer sign but still verify the tokens they signed
+	KeyStateRetired KeyState = "retired"
+	// KeyStateRevoked keys neither sign nor verify
+	KeyStateRevoked KeyState = "revoked"
+)
+
+var ErrRotationInProgress = errors.New("another instance is rotating keys")
+
+// KeyInfo describes a signing key without its key material
+type KeyInfo struct {
+	KeyID       string     `json:"kid"`
+	Algorithm   string     `json:"alg"`
+	State       KeyState   `json:"state"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	ActivatesAt time.Time  `json:"activatesAt"`
+	ExpiresAt   time.Time  `json:"expiresAt"`
+	RevokedAt   *time.Time `json:"revokedAt,omitempty"`
+}
+
+// Keys lists every unexpired key and its state
+func (m *KeyManager) Keys() []KeyInfo {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	now := time.Now()
+	current := make(map[*KeyPair]bool, len(m.algorithms))
+	for _, algorithm := range m.algorithms {
+		if key := m.signingKey(algorithm); key != nil {
+			current[key] = true
+		}
+	}
+	infos := make([]KeyInfo, 0, len(m.keys))
+	for _, key := range m.keys {
+		state := KeyStateRetired
+		switch {
+		case key.RevokedAt != nil:
+			state = KeyStateRevoked
+		case key.ActivatesAt.After(now):
+			state = KeyStatePending
+		case current[key]:
+			state = KeyStateActive
+		}
+
+		infos = append(infos, KeyInfo{
+			KeyID:       key.KeyID,
+			Algorithm:   key.Algorithm,
+			State:       state,
+			CreatedAt:   key.CreatedAt,
+			ActivatesAt: key.ActivatesAt,
+			ExpiresAt:   key.ExpiresAt,
+			RevokedAt:   key.RevokedAt,
+		})
+	}
+	return infos
+}
+
+// RotateNow activates the pending key of every algorithm immediately and publishes new
+// pending keys. The pending keys are used because verifiers may already have them from the JWKS.
+func (m *KeyManager) RotateNow() error {
+	if m.external {
+		return ErrExternalSigner
+	}
+	return m.withRotationLock(func() error {
+		now := time.Now()
+
+		m.mu.RLock()
+		var pending []*KeyPair
+		for _, algorithm := range m.algorithms {
+			if key := pendingKeyAt(m.keys, algorithm, now); key != nil {
+				pending = append(pending, key)
+			}
+		}
+		m.mu.RUnlock()
+
+		for _, key := range pending {
+			expiresAt := m.keyExpiry(now)
+			if key.ExpiresAt.After(expiresAt) {
+				expiresAt = key.ExpiresAt
+			}
+			if err := m.store.UpdateKey(&StoredKey{
+				KeyID:       key.KeyID,
+				ActivatesAt: now,
+				ExpiresAt:   expiresAt,
+			}); err != nil {
+				return err
+			}
+		}
+		if len(pending) > 0 {
+			if err := m.loadKeys(); err != nil {
+				return err
+			}
+		}
+
+		log.Printf("Rotated signing keys")
+		return m.generateMissingKeys()
+	})
+}
+
+// RevokeKey revokes a key after a compromise. Tokens it signed fail verification on every
+// instance once they reload their keys. A revoked active key is replaced by a freshly
+// generated key, which is stored first so that no instance is ever left without one; the
+// pending key is not promoted, and the keys the revoked key retired stay retired.
+func (m *KeyManager) RevokeKey(keyID string) error {
+	if m.external {
+		return ErrExternalSigner
+	}
+	return m.withRotationLock(func() error {
+		m.mu.RLock()
+		var target *KeyPair
+		for _, key := range m.keys {
+			if key.KeyID == keyID {
+				target = key
+				break
+			}
+		}
+		active := target != nil && m.signingKey(target.Algorithm) == target
+		m.mu.RUnlock()
+
+		if target == nil {
+			return ErrKeyNotFound
+		}
+		if target.RevokedAt != nil {
+			return nil
+		}
+
+		if active {
+			if err := m.createKey(target.Algorithm, time.Now()); err != nil {
+				return err
+			}
+		}
+
+		now := time.Now()
+		if err := m.store.UpdateKey(&StoredKey{
+			KeyID:       target.KeyID,
+			ActivatesAt: target.ActivatesAt,
+			ExpiresAt:   target.ExpiresAt,
+			RevokedAt:   &now,
+		}); err != nil {
+			return err
+		}
+		if err := m.loadKeys(); err != nil {
+			return err
+		}
+
+		log.Printf("Revoked signing key %s", keyID)
+		return m.generateMissingKeys()
+	})
+}
+
+// withRotationLock runs fn with the latest keys while holding the rotation lock, waiting
+// for another instance to finish rotating if necessary
+func (m *KeyManager) withRotationLock(fn func() error) error {
+	for attempt := 0; attempt <= keyLockRetries; attempt++ {
+		acquired, err := m.store.WithRotationLock(func() error {
+			if err := m.loadKeys(); err != nil {
+				return err
+			}
+			return fn()
+		})
+		if err != nil || acquired {
+			return err
+		}
+		time.Sleep(keyLockRetryDelay)
+	}
+	return ErrRotationInProgress
+}
@@ -0,0 +1,222 @@
+package jwt
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+func newTestKeyManager(t *testing.T) *KeyManager {
+	t.Helper()
+	manager, err := NewKeyManagerWithConfig(KeyManagerConfig{
+		RotationInterval: time.Hour,
+		Algorithm:        AlgorithmES256,
+	})
+	if err != nil {
+		t.Fatalf("NewKeyManagerWithConfig: %v", err)
+	}
+	return manager
+}
+
+// keyInState returns the ID of the only key in the state, failing if there is not exactly one
+func keyInState(t *testing.T, manager *KeyManager, state KeyState) string {
+	t.Helper()
+	var keyIDs []string
+	for _, key := range manager.Keys() {
+		if key.State == state {
+			keyIDs = append(keyIDs, key.KeyID)
+		}
+	}
+	if len(keyIDs) != 1 {
+		t.Fatalf("%s keys = %v, want exactly one", state, keyIDs)
+	}
+	return keyIDs[0]
+}
+
+func keyState(t *testing.T, manager *KeyManager, keyID string) KeyState {
+	t.Helper()
+	for _, key := range manager.Keys() {
+		if key.KeyID == keyID {
+			return key.State
+		}
+	}
+	t.Fatalf("key %s not found", keyID)
+	return ""
+}
+
+func TestKeyManagerStartsWithActiveAndPendingKey(t *testing.T) {
+	manager := newTestKeyManager(t)
+
+	keyInState(t, manager, KeyStateActive)
+	keyInState(t, manager, KeyStatePending)
+	if manager.GetCurrentPrivateKey() == nil {
+		t.Fatal("no current private key")
+	}
+}
+
+func TestRotateNowActivatesThePendingKey(t *testing.T) {
+	manager := newTestKeyManager(t)
+	previous := keyInState(t, manager, KeyStateActive)
+	pending := keyInState(t, manager, KeyStatePending)
+
+	if err := manager.RotateNow(); err != nil {
+		t.Fatalf("RotateNow: %v", err)
+	}
+
+	if active := keyInState(t, manager, KeyStateActive); active != pending {
+		t.Errorf("active key = %s, want the pending key %s", active, pending)
+	}
+	if state := keyState(t, manager, previous); state != KeyStateRetired {
+		t.Errorf("previous key is %s, want retired", state)
+	}
+	if next := keyInState(t, manager, KeyStatePending); next == previous || next == pending {
+		t.Errorf("pending key = %s, want a new key", next)
+	}
+}
+
+func TestRevokeKeyReplacesTheActiveKey(t *testing.T) {
+	manager := newTestKeyManager(t)
+	revoked := keyInState(t, manager, KeyStateActive)
+	pending := keyInState(t, manager, KeyStatePending)
+
+	token, err := manager.SignTokenWithAlgorithm(jwt.MapClaims{"sub": "user"}, AlgorithmES256)
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+
+	if err := manager.RevokeKey(revoked); err != nil {
+		t.Fatalf("RevokeKey: %v", err)
+	}
+
+	if state := keyState(t, manager, revoked); state != KeyStateRevoked {
+		t.Errorf("revoked key is %s, want revoked", state)
+	}
+	active := keyInState(t, manager, KeyStateActive)
+	if active == revoked || active == pending {
+		t.Errorf("active key = %s, want a freshly generated key", active)
+	}
+	if next := keyInState(t, manager, KeyStatePending); next != pending {
+		t.Errorf("pending key = %s, want %s unchanged", next, pending)
+	}
+
+	if err := manager.VerifyToken(token, jwt.MapClaims{}); err == nil {
+		t.Error("token signed with the revoked key still verifies")
+	}
+	token, err = manager.SignTokenWithAlgorithm(jwt.MapClaims{"sub": "user"}, AlgorithmES256)
+	if err != nil {
+		t.Fatalf("sign after revocation: %v", err)
+	}
+	if err := manager.VerifyToken(token, jwt.MapClaims{}); err != nil {
+		t.Errorf("token signed after revocation does not verify: %v", err)
+	}
+}
+
+func TestRevokeKeyNeverReactivatesARetiredKey(t *testing.T) {
+	manager := newTestKeyManager(t)
+	retired := keyInState(t, manager, KeyStateActive)
+	if err := manager.RotateNow(); err != nil {
+		t.Fatalf("RotateNow: %v", err)
+	}
+	revoked := keyInState(t, manager, KeyStateActive)
+
+	if err := manager.RevokeKey(revoked); err != nil {
+		t.Fatalf("RevokeKey: %v", err)
+	}
+
+	if active := keyInState(t, manager, KeyStateActive); active == retired {
+		t.Errorf("retired key %s became active again", retired)
+	}
+	if state := keyState(t, manager, retired); state != KeyStateRetired {
+		t.Errorf("retired key is %s, want retired", state)
+	}
+}
+
+func TestRevokeKeyLeavesTheActiveKey(t *testing.T) {
+	tests := []struct {
+		name  string
+		state KeyState
+	}{
+		{name: "pending key", state: KeyStatePending},
+		{name: "retired key", state: KeyStateRetired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manager := newTestKeyManager(t)
+			if tt.state == KeyStateRetired {
+				if err := manager.RotateNow(); err != nil {
+					t.Fatalf("RotateNow: %v", err)
+				}
+			}
+			active := keyInState(t, manager, KeyStateActive)
+			target := keyInState(t, manager, tt.state)
+
+			if err := manager.RevokeKey(target); err != nil {
+				t.Fatalf("RevokeKey: %v", err)
+			}
+
+			if state := keyState(t, manager, target); state != KeyStateRevoked {
+				t.Errorf("key is %s, want revoked", state)
+			}
+			if current := keyInState(t, manager, KeyStateActive); current != active {
+				t.Errorf("active key = %s, want %s unchanged", current, active)
+			}
+			keyInState(t, manager, KeyStatePending)
+		})
+	}
+}
+
+func TestActiveKeyAt(t *testing.T) {
+	now := time.Now()
+	revokedAt := now.Add(-time.Minute)
+	key := func(id string, activatesAt time.Time, revoked bool) *KeyPair {
+		pair := &KeyPair{KeyID: id, Algorithm: AlgorithmES256, CreatedAt: activatesAt, ActivatesAt: activatesAt}
+		if revoked {
+			pair.RevokedAt = &revokedAt
+		}
+		return pair
+	}
+
+	tests := []struct {
+		name string
+		keys []*KeyPair
+		want string
+	}{
+		{
+			name: "latest activated key",
+			keys: []*KeyPair{key("old", now.Add(-2*time.Hour), false), key("current", now.Add(-time.Hour), false), key("next", now.Add(time.Hour), false)},
+			want: "current",
+		},
+		{
+			name: "latest activated key revoked",
+			keys: []*KeyPair{key("old", now.Add(-2*time.Hour), false), key("current", now.Add(-time.Hour), true), key("next", now.Add(time.Hour), false)},
+			want: "",
+		},
+		{
+			name: "retired key revoked",
+			keys: []*KeyPair{key("old", now.Add(-2*time.Hour), true), key("current", now.Add(-time.Hour), false)},
+			want: "current",
+		},
+		{
+			name: "only pending keys",
+			keys: []*KeyPair{key("next", now.Add(time.Hour), false)},
+			want: "",
+		},
+		{
+			name: "other algorithm",
+			keys: []*KeyPair{key("current", now.Add(-time.Hour), false), {KeyID: "rsa", Algorithm: AlgorithmRS256, ActivatesAt: now.Add(-time.Minute)}},
+			want: "current",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ""
+			if active := activeKeyAt(tt.keys, AlgorithmES256, now); active != nil {
+				got = active.KeyID
+			}
+			if got != tt.want {
+				t.Errorf("activeKeyAt = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,199 @@
+package jwt
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultSignerTimeout bounds each request to a remote signer
+const DefaultSignerTimeout = 5 * time.Second
+
+// RemoteSignerConfig configures a signer backed by an HTTP signing service
+type RemoteSignerConfig struct {
+	// URL is the base URL of the signing service
+	URL string
+	// Token is sent as a bearer token with every request when set
+	Token string
+	// Client sends the requests. Defaults to a client with DefaultSignerTimeout.
+	Client *http.Client
+}
+
+// remoteSigner keeps private keys in a KMS-like signing service. The service exposes:
+//
+//	GET  {url}/keys  -> {"keys": [{"kid", "alg", "publicKey" (PEM), "active"}]}
+//	POST {url}/sign  {"kid", "alg", "payload" (base64url)} -> {"signature" (base64url)}
+//
+// Signatures are in JWS form (RFC 7518 section 3), e.g. R || S for ES256. Every signature is
+// verified with the public key the service published for the key before it is used, so a
+// misbehaving service cannot hand out tokens that fail verification or verify under another key.
+type remoteSigner struct {
+	url    string
+	token  string
+	client *http.Client
+	// published holds the public keys last returned by the service, by key ID
+	published map[string]SignerKey
+	mu        sync.Mutex
+}
+
+type remoteSignerKey struct {
+	KeyID     string `json:"kid"`
+	Algorithm string `json:"alg"`
+	PublicKey string `json:"publicKey"`
+	Active    bool   `json:"active"`
+}
+
+type remoteSignRequest struct {
+	KeyID     string `json:"kid"`
+	Algorithm string `json:"alg"`
+	Payload   string `json:"payload"`
+}
+
+type remoteSignResponse struct {
+	Signature string `json:"signature"`
+}
+
+// NewRemoteSigner creates a signer that delegates to an HTTP signing service
+func NewRemoteSigner(cfg RemoteSignerConfig) Signer {
+	client := cfg.Client
+	if client == nil {
+		client = &http.Client{Timeout: DefaultSignerTimeout}
+	}
+	return &remoteSigner{
+		url:    strings.TrimSuffix(cfg.URL, "/"),
+		token:  cfg.Token,
+		client: client,
+	}
+}
+
+func (s *remoteSigner) Keys() ([]SignerKey, error) {
+	var body struct {
+		Keys []remoteSignerKey `json:"keys"`
+	}
+	if err := s.do(http.MethodGet, "/keys", nil, &body); err != nil {
+		return nil, err
+	}
+
+	keys := make([]SignerKey, 0, len(body.Keys))
+	for _, key := range body.Keys {
+		publicKey, err := ImportPublicKeyPEM([]byte(key.PublicKey))
+		if err != nil {
+			return nil, fmt.Errorf("invalid public key %s from signer: %v", key.KeyID, err)
+		}
+		if !algorithmMatchesKey(key.Algorithm, publicKey) {
+			return nil, fmt.Errorf("public key %s from signer cannot verify %s", key.KeyID, key.Algorithm)
+		}
+		keys = append(keys, SignerKey{
+			KeyID:     key.KeyID,
+			Algorithm: key.Algorithm,
+			PublicKey: publicKey,
+			Active:    key.Active,
+		})
+	}
+
+	published := make(map[string]SignerKey, len(keys))
+	for _, key := range keys {
+		published[key.KeyID] = key
+	}
+	s.mu.Lock()
+	s.published = published
+	s.mu.Unlock()
+
+	return keys, nil
+}
+
+func (s *remoteSigner) Sign(keyID, algorithm string, signingInput []byte) ([]byte, error) {
+	request := remoteSignRequest{
+		KeyID:     keyID,
+		Algorithm: algorithm,
+		Payload:   base64.RawURLEncoding.EncodeToString(signingInput),
+	}
+	var response remoteSignResponse
+	if err := s.do(http.MethodPost, "/sign", request, &response); err != nil {
+		return nil, err
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(response.Signature)
+	if err != nil || len(signature) == 0 {
+		return nil, fmt.Errorf("invalid signature from signer")
+	}
+	if err := s.verify(keyID, algorithm, signingInput, response.Signature); err != nil {
+		return nil, err
+	}
+	return signature, nil
+}
+
+// verify checks a signature from the service against the public key it published for the key,
+// fetching the published keys again if the key is new
+func (s *remoteSigner) verify(keyID, algorithm string, signingInput []byte, signature string) error {
+	s.mu.Lock()
+	key, ok := s.published[keyID]
+	s.mu.Unlock()
+	if !ok {
+		if _, err := s.Keys(); err != nil {
+			return err
+		}
+		s.mu.Lock()
+		key, ok = s.published[keyID]
+		s.mu.Unlock()
+	}
+	if !ok {
+		return fmt.Errorf("signer has not published key %s", keyID)
+	}
+	if key.Algorithm != algorithm {
+		return fmt.Errorf("signer published key %s for %s, not %s", keyID, key.Algorithm, algorithm)
+	}
+
+	method, err := signingMethod(algorithm)
+	if err != nil {
+		return err
+	}
+	if err := method.Verify(string(signingInput), signature, key.PublicKey); err != nil {
+		return fmt.Errorf("signature from signer does not verify with key %s: %v", keyID, err)
+	}
+	return nil
+}
+
+// do sends a JSON request to the signing service and decodes the JSON response
+func (s *remoteSigner) do(method, path string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		payload, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequest(method, s.url+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if s.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("signer request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("signer returned %s for %s %s", resp.Status, method, path)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("invalid signer response: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,362 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const standInToken = "signer-token"
+
+// standInKey is a key held by the stand-in signing service
+type standInKey struct {
+	keyID     string
+	algorithm string
+	private   crypto.Signer
+	// publicKey overrides the public key the service publishes
+	publicKey crypto.PublicKey
+	active    bool
+}
+
+// standInSigner is a local stand-in for a KMS-like signing service, speaking the protocol
+// remoteSigner expects
+type standInSigner struct {
+	t    *testing.T
+	keys []standInKey
+	// signature, when set, replaces the signatures the service returns
+	signature *string
+	signed    []remoteSignRequest
+}
+
+func (s *standInSigner) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer "+standInToken {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/keys":
+		keys := make([]remoteSignerKey, 0, len(s.keys))
+		for _, key := range s.keys {
+			publicKey := key.publicKey
+			if publicKey == nil {
+				publicKey = key.private.Public()
+			}
+			keys = append(keys, remoteSignerKey{
+				KeyID:     key.keyID,
+				Algorithm: key.algorithm,
+				PublicKey: encodePublicKey(s.t, publicKey),
+				Active:    key.active,
+			})
+		}
+		writeJSON(w, map[string]interface{}{"keys": keys})
+
+	case r.Method == http.MethodPost && r.URL.Path == "/sign":
+		var request remoteSignRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		s.signed = append(s.signed, request)
+		if s.signature != nil {
+			writeJSON(w, remoteSignResponse{Signature: *s.signature})
+			return
+		}
+
+		for _, key := range s.keys {
+			if key.keyID != request.KeyID || key.algorithm != request.Algorithm {
+				continue
+			}
+			payload, err := base64.RawURLEncoding.DecodeString(request.Payload)
+			if err != nil {
+				http.Error(w, "bad payload", http.StatusBadRequest)
+				return
+			}
+			method, _ := signingMethod(key.algorithm)
+			signature, err := method.Sign(string(payload), key.private)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			writeJSON(w, remoteSignResponse{Signature: signature})
+			return
+		}
+		http.Error(w, "unknown key", http.StatusNotFound)
+
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func encodePublicKey(t *testing.T, key crypto.PublicKey) string {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		t.Fatalf("marshal public key: %v", err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+}
+
+func newStandInKey(t *testing.T, keyID, algorithm string) standInKey {
+	t.Helper()
+	var private crypto.Signer
+	var err error
+	switch algorithm {
+	case AlgorithmRS256:
+		private, err = rsa.GenerateKey(rand.Reader, 2048)
+	case AlgorithmES256:
+		private, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case AlgorithmEdDSA:
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	}
+	if err != nil {
+		t.Fatalf("generate %s key: %v", algorithm, err)
+	}
+	return standInKey{keyID: keyID, algorithm: algorithm, private: private, active: true}
+}
+
+func startStandIn(t *testing.T, service *standInSigner) Signer {
+	t.Helper()
+	service.t = t
+	server := httptest.NewServer(service)
+	t.Cleanup(server.Close)
+	return NewRemoteSigner(RemoteSignerConfig{URL: server.URL + "/", Token: standInToken})
+}
+
+func TestRemoteSignerSignsTokensVerifiedWithItsKeys(t *testing.T) {
+	service := &standInSigner{keys: []standInKey{
+		newStandInKey(t, "es-1", AlgorithmES256),
+		newStandInKey(t, "rs-1", AlgorithmRS256),
+		newStandInKey(t, "ed-1", AlgorithmEdDSA),
+	}}
+	signer := startStandIn(t, service)
+
+	manager, err := NewKeyManagerWithConfig(KeyManagerConfig{
+		Algorithm:            AlgorithmES256,
+		AdditionalAlgorithms: []string{AlgorithmRS256, AlgorithmEdDSA},
+		Signer:               signer,
+	})
+	if err != nil {
+		t.Fatalf("NewKeyManagerWithConfig: %v", err)
+	}
+
+	for _, algorithm := range []string{AlgorithmES256, AlgorithmRS256, AlgorithmEdDSA} {
+		token, err := manager.SignTokenWithAlgorithm(jwt.MapClaims{"sub": "user"}, algorithm)
+		if err != nil {
+			t.Fatalf("sign %s: %v", algorithm, err)
+		}
+		claims := jwt.MapClaims{}
+		if err := manager.VerifyToken(token, claims); err != nil {
+			t.Fatalf("verify %s token: %v", algorithm, err)
+		}
+		if claims["sub"] != "user" {
+			t.Errorf("%s token sub = %v, want user", algorithm, claims["sub"])
+		}
+	}
+
+	if len(service.signed) != 3 {
+		t.Fatalf("signer received %d sign requests, want 3", len(service.signed))
+	}
+	if request := service.signed[0]; request.KeyID != "es-1" || request.Algorithm != AlgorithmES256 {
+		t.Errorf("first sign request used %s/%s, want es-1/ES256", request.KeyID, request.Algorithm)
+	}
+}
+
+func TestRemoteSignerKeys(t *testing.T) {
+	active := newStandInKey(t, "es-2", AlgorithmES256)
+	retired := newStandInKey(t, "es-1", AlgorithmES256)
+	retired.active = false
+	signer := startStandIn(t, &standInSigner{keys: []standInKey{retired, active}})
+
+	keys, err := signer.Keys()
+	if err != nil {
+		t.Fatalf("Keys: %v", err)
+	}
+	if len(keys) != 2 {
+		t.Fatalf("Keys returned %d keys, want 2", len(keys))
+	}
+	if keys[0].KeyID != "es-1" || keys[0].Active || keys[1].KeyID != "es-2" || !keys[1].Active {
+		t.Errorf("Keys = %+v, want es-1 inactive then es-2 active", keys)
+	}
+	if _, ok := keys[1].PublicKey.(*ecdsa.PublicKey); !ok {
+		t.Errorf("public key is %T, want *ecdsa.PublicKey", keys[1].PublicKey)
+	}
+}
+
+func TestRemoteSignerKeysErrors(t *testing.T) {
+	rsaKey := newStandInKey(t, "rs-1", AlgorithmRS256)
+	edKey := newStandInKey(t, "ed-1", AlgorithmEdDSA)
+	p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate P-384 key: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		handler http.Handler
+		want    string
+	}{
+		{
+			name: "error status",
+			handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "down", http.StatusServiceUnavailable)
+			}),
+			want: "503",
+		},
+		{
+			name: "malformed response",
+			handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("{not json"))
+			}),
+			want: "invalid signer response",
+		},
+		{
+			name: "invalid public key",
+			handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				writeJSON(w, map[string]interface{}{"keys": []remoteSignerKey{
+					{KeyID: "bad", Algorithm: AlgorithmRS256, PublicKey: "not a pem", Active: true},
+				}})
+			}),
+			want: "invalid public key bad",
+		},
+		{
+			name:    "RSA key published for ES256",
+			handler: &standInSigner{keys: []standInKey{{keyID: "rs-1", algorithm: AlgorithmES256, private: rsaKey.private, active: true}}},
+			want:    "cannot verify ES256",
+		},
+		{
+			name:    "Ed25519 key published for RS256",
+			handler: &standInSigner{keys: []standInKey{{keyID: "ed-1", algorithm: AlgorithmRS256, private: edKey.private, active: true}}},
+			want:    "cannot verify RS256",
+		},
+		{
+			name:    "P-384 key published for ES256",
+			handler: &standInSigner{keys: []standInKey{{keyID: "ec-384", algorithm: AlgorithmES256, private: p384, active: true}}},
+			want:    "cannot verify ES256",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if service, ok := tt.handler.(*standInSigner); ok {
+				service.t = t
+			}
+			server := httptest.NewServer(tt.handler)
+			defer server.Close()
+			signer := NewRemoteSigner(RemoteSignerConfig{URL: server.URL, Token: standInToken})
+
+			_, err := signer.Keys()
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Keys error = %v, want one containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestRemoteSignerSignErrors(t *testing.T) {
+	key := newStandInKey(t, "es-1", AlgorithmES256)
+	empty := ""
+	notBase64 := "***"
+
+	tests := []struct {
+		name      string
+		keyID     string
+		signature *string
+		want      string
+	}{
+		{name: "unknown key", keyID: "missing", want: "404"},
+		{name: "empty signature", keyID: "es-1", signature: &empty, want: "invalid signature"},
+		{name: "signature not base64url", keyID: "es-1", signature: &notBase64, want: "invalid signature"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signer := startStandIn(t, &standInSigner{keys: []standInKey{key}, signature: tt.signature})
+			_, err := signer.Sign(tt.keyID, AlgorithmES256, []byte("header.payload"))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Sign error = %v, want one containing %q", err, tt.want)
+			}
+		})
+	}
+
+	t.Run("wrong token", func(t *testing.T) {
+		service := &standInSigner{t: t, keys: []standInKey{key}}
+		server := httptest.NewServer(service)
+		defer server.Close()
+		signer := NewRemoteSigner(RemoteSignerConfig{URL: server.URL, Token: "wrong"})
+
+		if _, err := signer.Sign("es-1", AlgorithmES256, []byte("header.payload")); err == nil || !strings.Contains(err.Error(), "401") {
+			t.Fatalf("Sign error = %v, want a 401", err)
+		}
+	})
+
+	t.Run("unreachable signer", func(t *testing.T) {
+		server := httptest.NewServer(http.NotFoundHandler())
+		server.Close()
+		signer := NewRemoteSigner(RemoteSignerConfig{URL: server.URL, Token: standInToken})
+
+		if _, err := signer.Sign("es-1", AlgorithmES256, []byte("header.payload")); err == nil || !strings.Contains(err.Error(), "signer request failed") {
+			t.Fatalf("Sign error = %v, want a failed request", err)
+		}
+	})
+}
+
+func TestRemoteSignerRejectsSignaturesThatDoNotVerify(t *testing.T) {
+	key := newStandInKey(t, "es-1", AlgorithmES256)
+	other := newStandInKey(t, "es-2", AlgorithmES256)
+	method, _ := signingMethod(AlgorithmES256)
+	otherInput, err := method.Sign("other.payload", key.private)
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	mismatched := key
+	mismatched.publicKey = other.private.Public()
+
+	tests := []struct {
+		name      string
+		key       standInKey
+		signature *string
+	}{
+		{name: "signed with a key other than the published one", key: mismatched},
+		{name: "signature of another input", key: key, signature: &otherInput},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signer := startStandIn(t, &standInSigner{keys: []standInKey{tt.key}, signature: tt.signature})
+			_, err := signer.Sign("es-1", AlgorithmES256, []byte("header.payload"))
+			if err == nil || !strings.Contains(err.Error(), "does not verify") {
+				t.Fatalf("Sign error = %v, want a signature that does not verify", err)
+			}
+		})
+	}
+
+	t.Run("key manager", func(t *testing.T) {
+		signer := startStandIn(t, &standInSigner{keys: []standInKey{mismatched}})
+		manager, err := NewKeyManagerWithConfig(KeyManagerConfig{Algorithm: AlgorithmES256, Signer: signer})
+		if err != nil {
+			t.Fatalf("NewKeyManagerWithConfig: %v", err)
+		}
+		if token, err := manager.SignTokenWithAlgorithm(jwt.MapClaims{"sub": "user"}, AlgorithmES256); err == nil {
+			t.Fatalf("signed token %s with a signature that does not verify", token)
+		}
+	})
+}
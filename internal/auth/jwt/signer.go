@@ -0,0 +1,73 @@
+package jwt
+
+import (
+	"crypto"
+	"errors"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+var ErrExternalSigner = errors.New("signing keys are managed by the external signer")
+
+// SignerKey is a public key held by a Signer
+type SignerKey struct {
+	KeyID     string
+	Algorithm string
+	PublicKey crypto.PublicKey
+	// Active keys sign new tokens; the others only verify tokens signed earlier
+	Active bool
+}
+
+// Signer signs tokens with private keys that may live outside this process
+type Signer interface {
+	// Keys returns the keys tokens may be verified with. Each algorithm has one active key.
+	Keys() ([]SignerKey, error)
+	// Sign returns the JWS signature of the signing input, made with the given key
+	Sign(keyID, algorithm string, signingInput []byte) ([]byte, error)
+}
+
+// localSigner signs with the KeyManager's own keys, which are held in process memory
+type localSigner struct {
+	manager *KeyManager
+}
+
+func (s *localSigner) Keys() ([]SignerKey, error) {
+	s.manager.mu.RLock()
+	defer s.manager.mu.RUnlock()
+
+	now := time.Now()
+	keys := make([]SignerKey, 0, len(s.manager.keys))
+	for _, key := range s.manager.keys {
+		if key.RevokedAt != nil {
+			continue
+		}
+		keys = append(keys, SignerKey{
+			KeyID:     key.KeyID,
+			Algorithm: key.Algorithm,
+			PublicKey: key.PublicKey,
+			Active:    key == activeKeyAt(s.manager.keys, key.Algorithm, now),
+		})
+	}
+	return keys, nil
+}
+
+func (s *localSigner) Sign(keyID, algorithm string, signingInput []byte) ([]byte, error) {
+	key := s.manager.GetKeyPairByID(keyID)
+	if key == nil || key.PrivateKey == nil {
+		return nil, ErrKeyNotFound
+	}
+	if key.Algorithm != algorithm {
+		return nil, ErrInvalidKey
+	}
+
+	method, err := signingMethod(algorithm)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := method.Sign(string(signingInput), key.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	return jwt.DecodeSegment(signature)
+}
@@ -0,0 +1,102 @@
+package jwt
+
+import (
+	"sync"
+	"time"
+)
+
+// StoredKey is a signing key as persisted by a KeyStore. The private key is encrypted.
+type StoredKey struct {
+	KeyID               string     `json:"kid"`
+	Algorithm           string     `json:"alg"`
+	EncryptedPrivateKey []byte     `json:"encryptedPrivateKey"`
+	CreatedAt           time.Time  `json:"createdAt"`
+	ActivatesAt         time.Time  `json:"activatesAt"`
+	ExpiresAt           time.Time  `json:"expiresAt"`
+	RevokedAt           *time.Time `json:"revokedAt,omitempty"`
+}
+
+// KeyStore persists signing keys so they survive restarts and are shared between replicas
+type KeyStore interface {
+	// LoadKeys returns every stored key that has not expired, including revoked keys
+	LoadKeys() ([]*StoredKey, error)
+	// SaveKey stores a new key
+	SaveKey(key *StoredKey) error
+	// UpdateKey updates the activation, expiry and revocation times of a stored key
+	UpdateKey(key *StoredKey) error
+	// DeleteExpiredKeys removes keys that expired before the given time
+	DeleteExpiredKeys(before time.Time) error
+	// WithRotationLock runs fn while holding a lock shared by all replicas. It reports false
+	// without running fn when another replica holds the lock.
+	WithRotationLock(fn func() error) (bool, error)
+}
+
+// memoryKeyStore keeps keys in process memory; keys are lost on restart
+type memoryKeyStore struct {
+	keys     map[string]*StoredKey
+	mu       sync.Mutex
+	rotation sync.Mutex
+}
+
+// NewMemoryKeyStore creates a key store that is local to the process
+func NewMemoryKeyStore() KeyStore {
+	return &memoryKeyStore{
+		keys: make(map[string]*StoredKey),
+	}
+}
+
+func (s *memoryKeyStore) LoadKeys() ([]*StoredKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	keys := make([]*StoredKey, 0, len(s.keys))
+	for _, key := range s.keys {
+		if key.ExpiresAt.After(now) {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+func (s *memoryKeyStore) SaveKey(key *StoredKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys[key.KeyID] = key
+	return nil
+}
+
+func (s *memoryKeyStore) UpdateKey(key *StoredKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.keys[key.KeyID]
+	if !ok {
+		return ErrKeyNotFound
+	}
+	updated := *stored
+	updated.ActivatesAt = key.ActivatesAt
+	updated.ExpiresAt = key.ExpiresAt
+	updated.RevokedAt = key.RevokedAt
+	s.keys[key.KeyID] = &updated
+	return nil
+}
+
+func (s *memoryKeyStore) DeleteExpiredKeys(before time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for keyID, key := range s.keys {
+		if key.ExpiresAt.Before(before) {
+			delete(s.keys, keyID)
+		}
+	}
+	return nil
+}
+
+func (s *memoryKeyStore) WithRotationLock(fn func() error) (bool, error) {
+	if !s.rotation.TryLock() {
+		return false, nil
+	}
+	defer s.rotation.Unlock()
+	return true, fn()
+}
@@ -0,0 +1,281 @@
+package auth
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"identity-service/internal/models"
+	"net"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/google/uuid"
+)
+
+// ldapEncryptionKeyLength is the AES-256 key length for bind passwords
+const ldapEncryptionKeyLength = 32
+
+var (
+	ErrLDAPUserNotFound       = errors.New("LDAP user not found")
+	ErrLDAPInvalidCredentials = errors.New("invalid LDAP credentials")
+	ErrInvalidLDAPRootCA      = errors.New("invalid LDAP root CA certificate")
+	ErrLDAPSecretDecryption   = errors.New("failed to decrypt LDAP bind password")
+)
+
+// LDAPConn is the part of an LDAP connection the connector uses. *ldap.Conn implements it.
+type LDAPConn interface {
+	Bind(username, password string) error
+	Search(request *ldap.SearchRequest) (*ldap.SearchResult, error)
+	Close() error
+}
+
+// LDAPDialer opens a connection to a tenant's LDAP server
+type LDAPDialer func(connection *models.LDAPConnection, timeout time.Duration) (LDAPConn, error)
+
+// LDAPEntry is a user found in the directory
+type LDAPEntry struct {
+	DN     string
+	Email  string
+	Name   string
+	Groups []string
+}
+
+// DialLDAP connects to the server of an LDAP connection, upgrading plain connections with
+// StartTLS if the connection asks for it
+func DialLDAP(connection *models.LDAPConnection, timeout time.Duration) (LDAPConn, error) {
+	tlsConfig, err := ldapTLSConfig(connection)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := ldap.DialURL(connection.URL,
+		ldap.DialWithDialer(&net.Dialer{Timeout: timeout}),
+		ldap.DialWithTLSConfig(tlsConfig),
+	)
+	if err != nil {
+		return nil, err
+	}
+	conn.SetTimeout(timeout)
+
+	if connection.StartTLS {
+		if err := conn.StartTLS(tlsConfig); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+func ldapTLSConfig(connection *models.LDAPConnection) (*tls.Config, error) {
+	serverURL, err := url.Parse(connection.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfig := &tls.Config{
+		ServerName:         serverURL.Hostname(),
+		InsecureSkipVerify: connection.InsecureSkipVerify,
+		MinVersion:         tls.VersionTLS12,
+	}
+	if connection.RootCA != "" {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM([]byte(connection.RootCA)) {
+			return nil, ErrInvalidLDAPRootCA
+		}
+		tlsConfig.RootCAs = pool
+	}
+	return tlsConfig, nil
+}
+
+// TestLDAPConnection checks the service account can bind and read the user search base
+func TestLDAPConnection(conn LDAPConn, connection *models.LDAPConnection, bindPassword string) error {
+	if err := conn.Bind(connection.BindDN, bindPassword); err != nil {
+		return fmt.Errorf("service account bind failed: %w", err)
+	}
+
+	_, err := conn.Search(ldap.NewSearchRequest(
+		connection.UserSearchBase, ldap.ScopeBaseObject, ldap.NeverDerefAliases,
+		1, 0, false, "(objectClass=*)", []string{"dn"}, nil,
+	))
+	if err != nil {
+		return fmt.Errorf("user search base is not readable: %w", err)
+	}
+	return nil
+}
+
+// AuthenticateLDAP finds the user with the service account and verifies the password by
+// binding as the user
+func AuthenticateLDAP(conn LDAPConn, connection *models.LDAPConnection, bindPassword, email, password string) (*LDAPEntry, error) {
+	// An empty password makes an unauthenticated bind, which servers accept for any DN
+	if password == "" {
+		return nil, ErrLDAPInvalidCredentials
+	}
+
+	if err := conn.Bind(connection.BindDN, bindPassword); err != nil {
+		return nil, fmt.Errorf("service account bind failed: %w", err)
+	}
+
+	username, _, _ := strings.Cut(email, "@")
+	filter := expandLDAPFilter(connection.UserFilter, map[string]string{
+		"email":    email,
+		"username": username,
+	})
+	attributes := []string{connection.EmailAttribute, connection.NameAttribute, "memberOf"}
+	result, err := conn.Search(ldap.NewSearchRequest(
+		connection.UserSearchBase, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases,
+		2, 0, false, filter, attributes, nil,
+	))
+	if err != nil && !ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded) {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultNoSuchObject) {
+			return nil, ErrLDAPUserNotFound
+		}
+		return nil, err
+	}
+	// An ambiguous filter must not let one user sign in as another
+	if result == nil || len(result.Entries) != 1 {
+		return nil, ErrLDAPUserNotFound
+	}
+	entry := result.Entries[0]
+
+	if err := conn.Bind(entry.DN, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return nil, ErrLDAPInvalidCredentials
+		}
+		return nil, err
+	}
+
+	user := &LDAPEntry{
+		DN:     entry.DN,
+		Email:  entry.GetAttributeValue(connection.EmailAttribute),
+		Name:   entry.GetAttributeValue(connection.NameAttribute),
+		Groups: entry.GetAttributeValues("memberOf"),
+	}
+
+	if connection.GroupSearchBase != "" {
+		groups, err := searchLDAPGroups(conn, connection, bindPassword, entry.DN, username)
+		if err != nil {
+			return nil, err
+		}
+		user.Groups = groups
+	}
+	return user, nil
+}
+
+// searchLDAPGroups returns the DNs of the groups the user is a member of. The user may not
+// be allowed to search groups, so the service account is bound again first.
+func searchLDAPGroups(conn LDAPConn, connection *models.LDAPConnection, bindPassword, dn, username string) ([]string, error) {
+	if err := conn.Bind(connection.BindDN, bindPassword); err != nil {
+		return nil, fmt.Errorf("service account bind failed: %w", err)
+	}
+
+	filter := expandLDAPFilter(connection.GroupFilter, map[string]string{
+		"dn":       dn,
+		"username": username,
+	})
+	result, err := conn.Search(ldap.NewSearchRequest(
+		connection.GroupSearchBase, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases,
+		0, 0, false, filter, []string{"dn"}, nil,
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	groups := make([]string, 0, len(result.Entries))
+	for _, entry := range result.Entries {
+		groups = append(groups, entry.DN)
+	}
+	return groups, nil
+}
+
+// expandLDAPFilter replaces {name} placeholders with escaped values
+func expandLDAPFilter(filter string, values map[string]string) string {
+	for name, value := range values {
+		filter = strings.ReplaceAll(filter, "{"+name+"}", ldap.EscapeFilter(value))
+	}
+	return filter
+}
+
+// LDAPRoles returns the tenant roles granted by the user's groups. Groups are matched by DN
+// or by CN, case-insensitively.
+func LDAPRoles(groupRoles models.LDAPGroupRoles, groups []string) []string {
+	var roles []string
+	for _, group := range groups {
+		names := []string{group}
+		if dn, err := ldap.ParseDN(group); err == nil && len(dn.RDNs) > 0 {
+			for _, attribute := range dn.RDNs[0].Attributes {
+				if strings.EqualFold(attribute.Type, "cn") {
+					names = append(names, attribute.Value)
+				}
+			}
+		}
+
+		for key, mapped := range groupRoles {
+			for _, name := range names {
+				if !strings.EqualFold(key, name) {
+					continue
+				}
+				for _, role := range mapped {
+					if !slices.Contains(roles, role) {
+						roles = append(roles, role)
+					}
+				}
+			}
+		}
+	}
+	slices.Sort(roles)
+	return roles
+}
+
+// SealLDAPSecret encrypts a bind password with AES-256-GCM. The tenant ID is authenticated
+// with it, so an encrypted password cannot be moved onto another tenant's connection.
+func SealLDAPSecret(key []byte, tenantID uuid.UUID, secret string) ([]byte, error) {
+	aead, err := newLDAPCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, []byte(secret), tenantID[:]), nil
+}
+
+// OpenLDAPSecret decrypts a bind password sealed by SealLDAPSecret
+func OpenLDAPSecret(key []byte, tenantID uuid.UUID, sealed []byte) (string, error) {
+	aead, err := newLDAPCipher(key)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return "", ErrLDAPSecretDecryption
+	}
+
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	secret, err := aead.Open(nil, nonce, ciphertext, tenantID[:])
+	if err != nil {
+		return "", ErrLDAPSecretDecryption
+	}
+	return string(secret), nil
+}
+
+func newLDAPCipher(key []byte) (cipher.AEAD, error) {
+	if len(key) != ldapEncryptionKeyLength {
+		return nil, errors.New("LDAP encryption key must be 32 bytes")
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Ensure *ldap.Conn implements LDAPConn
+var _ LDAPConn = (*ldap.Conn)(nil)
@@ -0,0 +1,79 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+)
+
+// PKCE code challenge methods (RFC 7636)
+const (
+	CodeChallengeMethodS256  = "S256"
+	CodeChallengeMethodPlain = "plain"
+)
+
+var (
+	ErrInvalidCodeChallenge       = errors.New("invalid code_challenge")
+	ErrUnsupportedChallengeMethod = errors.New("unsupported code_challenge_method")
+	ErrInvalidCodeVerifier        = errors.New("invalid code_verifier")
+)
+
+// NormalizeCodeChallengeMethod applies the RFC 7636 default of "plain" and rejects unknown methods
+func NormalizeCodeChallengeMethod(method string) (string, error) {
+	switch method {
+	case "":
+		return CodeChallengeMethodPlain, nil
+	case CodeChallengeMethodS256, CodeChallengeMethodPlain:
+		return method, nil
+	default:
+		return "", ErrUnsupportedChallengeMethod
+	}
+}
+
+// ValidateCodeChallenge checks the challenge sent when the authorization request starts
+func ValidateCodeChallenge(challenge string) error {
+	if !isPKCEString(challenge) {
+		return ErrInvalidCodeChallenge
+	}
+	return nil
+}
+
+// VerifyCodeVerifier checks a code_verifier against the stored challenge
+func VerifyCodeVerifier(verifier, challenge, method string) error {
+	if !isPKCEString(verifier) {
+		return ErrInvalidCodeVerifier
+	}
+
+	var computed string
+	switch method {
+	case CodeChallengeMethodS256:
+		sum := sha256.Sum256([]byte(verifier))
+		computed = base64.RawURLEncoding.EncodeToString(sum[:])
+	case CodeChallengeMethodPlain:
+		computed = verifier
+	default:
+		return ErrUnsupportedChallengeMethod
+	}
+
+	if subtle.ConstantTimeCompare([]byte(computed), []byte(challenge)) != 1 {
+		return ErrInvalidCodeVerifier
+	}
+	return nil
+}
+
+// isPKCEString reports whether s is 43-128 characters from the unreserved set
+func isPKCEString(s string) bool {
+	if len(s) < 43 || len(s) > 128 {
+		return false
+	}
+	for _, r := range s {
+		switch {
+		case r >= 'A' && r <= 'Z', r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+		case r == '-', r == '.', r == '_', r == '~':
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,93 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"time"
+)
+
+// samlCertificateLifetime is how long a generated SP certificate is valid
+const samlCertificateLifetime = 10 * 365 * 24 * time.Hour
+
+var ErrInvalidSAMLKey = errors.New("SAML service provider key must be a PEM encoded RSA private key")
+
+// LoadSAMLKeyPair reads the service provider's signing key and certificate from PEM files
+func LoadSAMLKeyPair(keyPath, certPath string) (*rsa.PrivateKey, *x509.Certificate, error) {
+	keyPEM, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	certPEM, err := os.ReadFile(certPath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	keyBlock, _ := pem.Decode(keyPEM)
+	if keyBlock == nil {
+		return nil, nil, ErrInvalidSAMLKey
+	}
+	var key *rsa.PrivateKey
+	if parsed, err := x509.ParsePKCS8PrivateKey(keyBlock.Bytes); err == nil {
+		rsaKey, ok := parsed.(*rsa.PrivateKey)
+		if !ok {
+			return nil, nil, ErrInvalidSAMLKey
+		}
+		key = rsaKey
+	} else if key, err = x509.ParsePKCS1PrivateKey(keyBlock.Bytes); err != nil {
+		return nil, nil, ErrInvalidSAMLKey
+	}
+
+	certBlock, _ := pem.Decode(certPEM)
+	if certBlock == nil {
+		return nil, nil, fmt.Errorf("SAML service provider certificate must be PEM encoded")
+	}
+	cert, err := x509.ParseCertificate(certBlock.Bytes)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !key.PublicKey.Equal(cert.PublicKey) {
+		return nil, nil, fmt.Errorf("SAML service provider certificate does not match the key")
+	}
+
+	return key, cert, nil
+}
+
+// GenerateSAMLKeyPair creates a signing key with a self-signed certificate. Identity
+// providers pin the certificate from our metadata, so a generated pair must be replaced
+// with a persistent one before tenants configure their IdP.
+func GenerateSAMLKeyPair(commonName string, keySize int) (*rsa.PrivateKey, *x509.Certificate, error) {
+	key, err := rsa.GenerateKey(rand.Reader, keySize)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, err
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(samlCertificateLifetime),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return key, cert, nil
+}
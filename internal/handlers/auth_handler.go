@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"identity-service/internal/models"
 	"identity-service/internal/services"
 	"net/http"
@@ -11,16 +12,30 @@ import (
 
 // AuthHandler handles all authentication-related HTTP requests
 type AuthHandler struct {
-	authService services.AuthService
+	authService      services.AuthService
+	discoveryService services.DiscoveryService
 }
 
 // NewAuthHandler creates a new auth handler instance
-func NewAuthHandler(authService services.AuthService) *AuthHandler {
+func NewAuthHandler(authService services.AuthService, discoveryService services.DiscoveryService) *AuthHandler {
 	return &AuthHandler{
-		authService: authService,
+		authService:      authService,
+		discoveryService: discoveryService,
 	}
 }
 
+// Discover returns the login methods for an email, so the login page can send users of
+// organizations with single sign-on to their identity provider
+func (h *AuthHandler) Discover(c *gin.Context) {
+	var request models.DiscoveryRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, h.discoveryService.Discover(request.Email))
+}
+
 // Login handles user login
 func (h *AuthHandler) Login(c *gin.Context) {
 	var credentials models.LoginCredentials
@@ -30,6 +45,14 @@ func (h *AuthHandler) Login(c *gin.Context) {
 	}
 
 	session, err := h.authService.Login(c, &credentials)
+	if errors.Is(err, services.ErrLDAPUnavailable) {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, services.ErrSSORequired) {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 		return
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"identity-service/internal/models"
+	"identity-service/internal/services"
+	"net/http"
+	"net/url"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// AuthzHandler answers authorization checks from other services
+type AuthzHandler struct {
+	authzService  services.AuthzService
+	clientService services.OAuthClientService
+}
+
+// NewAuthzHandler creates a new authorization check handler instance
+func NewAuthzHandler(authzService services.AuthzService, clientService services.OAuthClientService) *AuthzHandler {
+	return &AuthzHandler{
+		authzService:  authzService,
+		clientService: clientService,
+	}
+}
+
+// RequireClient authenticates a confidential OAuth client with HTTP Basic authentication.
+// Checks are scoped to the client's tenant.
+func (h *AuthzHandler) RequireClient() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		clientID, clientSecret, ok := c.Request.BasicAuth()
+		if ok {
+			// Credentials are form-urlencoded before being placed in the header (RFC 6749 section 2.3.1)
+			clientID, _ = url.QueryUnescape(clientID)
+			clientSecret, _ = url.QueryUnescape(clientSecret)
+		}
+
+		client, err := h.clientService.AuthenticateClient(clientID, clientSecret)
+		if !ok || err != nil || client.Public {
+			c.Header("WWW-Authenticate", `Basic realm="authz"`)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Confidential client authentication required"})
+			c.Abort()
+			return
+		}
+
+		c.Set("authzTenantID", client.TenantID)
+		c.Set("authzClientID", client.ClientID)
+		c.Set("authzClientScopes", []string(client.AllowedScopes))
+		c.Next()
+	}
+}
+
+// RequireClientScope requires the client authenticated by RequireClient to be allowed the scope
+func (h *AuthzHandler) RequireClientScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		scopes, _ := c.MustGet("authzClientScopes").([]string)
+		if !slices.Contains(scopes, scope) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Client is not allowed the scope: " + scope})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// Check decides whether a user may perform an action
+func (h *AuthzHandler) Check(c *gin.Context) {
+	var check models.AuthzCheck
+	if err := c.ShouldBindJSON(&check); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	decision, err := h.authzService.Check(authzTenantID(c), &check)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, decision)
+}
+
+// CheckBatch decides several checks at once
+func (h *AuthzHandler) CheckBatch(c *gin.Context) {
+	var batch models.AuthzBatchCheck
+	if err := c.ShouldBindJSON(&batch); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	decisions, err := h.authzService.CheckBatch(authzTenantID(c), batch.Checks)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"decisions": decisions})
+}
+
+// authzTenantID returns the tenant of the client authenticated by RequireClient
+func authzTenantID(c *gin.Context) uuid.UUID {
+	tenantID, _ := c.MustGet("authzTenantID").(uuid.UUID)
+	return tenantID
+}
+
+// authzClientID returns the client ID of the client authenticated by RequireClient
+func authzClientID(c *gin.Context) string {
+	return c.GetString("authzClientID")
+}
@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"errors"
+	"identity-service/internal/models"
+	"identity-service/internal/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// AutoJoinHandler manages how users in a tenant's verified domain join the tenant
+type AutoJoinHandler struct {
+	autoJoinService services.AutoJoinService
+	roleService     services.RoleService
+}
+
+// NewAutoJoinHandler creates a new auto-join handler instance
+func NewAutoJoinHandler(autoJoinService services.AutoJoinService, roleService services.RoleService) *AutoJoinHandler {
+	return &AutoJoinHandler{
+		autoJoinService: autoJoinService,
+		roleService:     roleService,
+	}
+}
+
+// GetPolicy returns the tenant's auto-join policy
+func (h *AutoJoinHandler) GetPolicy(c *gin.Context) {
+	tenantID, ok := h.tenantID(c)
+	if !ok {
+		return
+	}
+
+	settings, err := h.autoJoinService.GetPolicy(tenantID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tenant not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, settings)
+}
+
+// UpdatePolicy sets the tenant's auto-join policy
+func (h *AutoJoinHandler) UpdatePolicy(c *gin.Context) {
+	tenantID, ok := h.tenantID(c)
+	if !ok {
+		return
+	}
+
+	var settings models.AutoJoinSettings
+	if err := c.ShouldBindJSON(&settings); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// Members who join get the default role, so it may only grant what the caller holds
+	if settings.DefaultRole != "" && !checkGrantable(c, h.roleService, tenantID, []string{settings.DefaultRole}) {
+		return
+	}
+
+	updated, err := h.autoJoinService.UpdatePolicy(tenantID, &settings, currentUser(c).ID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tenant not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, updated)
+}
+
+// ListJoinRequests returns the tenant's join requests, optionally filtered by status
+func (h *AutoJoinHandler) ListJoinRequests(c *gin.Context) {
+	tenantID, ok := h.tenantID(c)
+	if !ok {
+		return
+	}
+
+	requests, err := h.autoJoinService.ListJoinRequests(tenantID, c.Query("status"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"joinRequests": requests})
+}
+
+// ApproveJoinRequest adds the requesting user to the tenant
+func (h *AutoJoinHandler) ApproveJoinRequest(c *gin.Context) {
+	h.reviewJoinRequest(c, true)
+}
+
+// DenyJoinRequest turns down a join request
+func (h *AutoJoinHandler) DenyJoinRequest(c *gin.Context) {
+	h.reviewJoinRequest(c, false)
+}
+
+func (h *AutoJoinHandler) reviewJoinRequest(c *gin.Context, approve bool) {
+	tenantID, ok := h.tenantID(c)
+	if !ok {
+		return
+	}
+	requestID, err := uuid.Parse(c.Param("requestId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid join request ID"})
+		return
+	}
+
+	request, err := h.autoJoinService.ReviewJoinRequest(tenantID, requestID, currentUser(c).ID, approve)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Join request not found"})
+		return
+	case errors.Is(err, services.ErrJoinRequestReviewed), errors.Is(err, services.ErrTenantFull):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, request)
+}
+
+// tenantID parses the tenant from the path and checks the caller belongs to it
+func (h *AutoJoinHandler) tenantID(c *gin.Context) (uuid.UUID, bool) {
+	tenantID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tenant ID"})
+		return uuid.Nil, false
+	}
+	if !hasTenantAccess(c, tenantID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied to tenant"})
+		return uuid.Nil, false
+	}
+	return tenantID, true
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"identity-service/internal/models"
+	"identity-service/internal/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// currentUser returns the user set by the JWT auth middleware
+func currentUser(c *gin.Context) *models.User {
+	if value, exists := c.Get("user"); exists {
+		if user, ok := value.(*models.User); ok {
+			return user
+		}
+	}
+	return nil
+}
+
+// hasTenantAccess reports whether the authenticated user is a member of the tenant
+func hasTenantAccess(c *gin.Context, tenantID uuid.UUID) bool {
+	value, exists := c.Get("tenantAccess")
+	if !exists {
+		return false
+	}
+	accessList, ok := value.([]models.UserTenantAccess)
+	if !ok {
+		return false
+	}
+	for _, access := range accessList {
+		if access.TenantID == tenantID {
+			return true
+		}
+	}
+	return false
+}
+
+// currentTenant returns the tenant of the token, set by the JWT auth middleware
+func currentTenant(c *gin.Context) *models.Tenant {
+	if value, exists := c.Get("currentTenant"); exists {
+		if tenant, ok := value.(*models.Tenant); ok {
+			return tenant
+		}
+	}
+	return nil
+}
+
+// hasPermission reports whether the authenticated user holds the permission in the tenant.
+// Platform administrators hold every permission.
+func hasPermission(c *gin.Context, tenantID uuid.UUID, permission string) bool {
+	if user := currentUser(c); user != nil && user.Role == models.RoleAdmin {
+		return true
+	}
+	value, exists := c.Get("permissions")
+	if !exists {
+		return false
+	}
+	permissions, ok := value.(map[uuid.UUID][]string)
+	if !ok {
+		return false
+	}
+	return models.HasPermission(permissions[tenantID], permission)
+}
+
+// ungrantable returns the first of permissions the authenticated user does not hold in the
+// tenant, or "" if they hold them all. Users may only grant permissions they hold themselves.
+func ungrantable(c *gin.Context, tenantID uuid.UUID, permissions []string) string {
+	for _, permission := range permissions {
+		if !hasPermission(c, tenantID, permission) {
+			return permission
+		}
+	}
+	return ""
+}
+
+// checkGrantable makes sure the roles exist in the tenant and grant nothing the caller does not
+// hold, writing the error response if not. Every path that gives members roles goes through it.
+func checkGrantable(c *gin.Context, roleService services.RoleService, tenantID uuid.UUID, roles []string) bool {
+	if len(roles) == 0 {
+		return true
+	}
+	permissions, err := roleService.RolePermissions(tenantID, roles)
+	if err != nil {
+		writeRoleError(c, err)
+		return false
+	}
+	if permission := ungrantable(c, tenantID, permissions); permission != "" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Cannot grant a permission you do not hold: " + permission})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"errors"
+	"identity-service/internal/models"
+	"identity-service/internal/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// DomainHandler lets tenants prove they own email domains
+type DomainHandler struct {
+	domainService services.DomainService
+}
+
+// NewDomainHandler creates a new domain handler instance
+func NewDomainHandler(domainService services.DomainService) *DomainHandler {
+	return &DomainHandler{
+		domainService: domainService,
+	}
+}
+
+// ListDomains returns the tenant's domains and their verification status
+func (h *DomainHandler) ListDomains(c *gin.Context) {
+	tenantID, ok := h.tenantID(c)
+	if !ok {
+		return
+	}
+
+	verifications, err := h.domainService.ListVerifications(tenantID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"domains": verifications})
+}
+
+// AddDomain starts verifying a domain and returns the token to publish on it
+func (h *DomainHandler) AddDomain(c *gin.Context) {
+	tenantID, ok := h.tenantID(c)
+	if !ok {
+		return
+	}
+
+	var request models.DomainVerificationRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	verification, err := h.domainService.InitiateDomainVerification(tenantID, request.Domain, request.Method)
+	if err != nil {
+		writeDomainError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, verification)
+}
+
+// GetDomain returns the verification status of one of the tenant's domains
+func (h *DomainHandler) GetDomain(c *gin.Context) {
+	tenantID, ok := h.tenantID(c)
+	if !ok {
+		return
+	}
+
+	verification, err := h.domainService.GetVerification(tenantID, c.Param("domain"))
+	if err != nil {
+		writeDomainError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, verification)
+}
+
+// VerifyDomain checks the domain for the token now
+func (h *DomainHandler) VerifyDomain(c *gin.Context) {
+	tenantID, ok := h.tenantID(c)
+	if !ok {
+		return
+	}
+
+	verification, err := h.domainService.VerifyDomain(tenantID, c.Param("domain"))
+	if err != nil {
+		writeDomainError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, verification)
+}
+
+// RemoveDomain removes a domain from the tenant
+func (h *DomainHandler) RemoveDomain(c *gin.Context) {
+	tenantID, ok := h.tenantID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.domainService.RemoveDomain(tenantID, c.Param("domain")); err != nil {
+		writeDomainError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Domain removed successfully"})
+}
+
+// tenantID parses the tenant from the path and checks the caller belongs to it
+func (h *DomainHandler) tenantID(c *gin.Context) (uuid.UUID, bool) {
+	tenantID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tenant ID"})
+		return uuid.Nil, false
+	}
+	if !hasTenantAccess(c, tenantID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied to tenant"})
+		return uuid.Nil, false
+	}
+	return tenantID, true
+}
+
+// writeDomainError maps domain verification failures to responses
+func writeDomainError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Domain not found"})
+	case errors.Is(err, services.ErrInvalidDomain), errors.Is(err, services.ErrInvalidVerificationMethod):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrDomainTaken), errors.Is(err, services.ErrTenantHasDomain):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrVerificationExpired):
+		c.JSON(http.StatusGone, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrVerificationFailed):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,262 @@
+package handlers
+
+import (
+	"errors"
+	"identity-service/internal/models"
+	"identity-service/internal/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// GroupHandler manages the groups of a tenant's members
+type GroupHandler struct {
+	groupService services.GroupService
+	roleService  services.RoleService
+}
+
+// NewGroupHandler creates a new group handler instance
+func NewGroupHandler(groupService services.GroupService, roleService services.RoleService) *GroupHandler {
+	return &GroupHandler{
+		groupService: groupService,
+		roleService:  roleService,
+	}
+}
+
+// ListGroups returns the tenant's groups
+func (h *GroupHandler) ListGroups(c *gin.Context) {
+	tenantID, ok := h.tenantID(c)
+	if !ok {
+		return
+	}
+
+	groups, err := h.groupService.ListGroups(tenantID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"groups": groups})
+}
+
+// CreateGroup creates a group, optionally giving its members roles
+func (h *GroupHandler) CreateGroup(c *gin.Context) {
+	tenantID, ok := h.tenantID(c)
+	if !ok {
+		return
+	}
+
+	var request models.TenantGroupRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !checkGrantable(c, h.roleService, tenantID, request.Roles) {
+		return
+	}
+
+	group, err := h.groupService.CreateGroup(tenantID, &request, currentUser(c).ID)
+	if err != nil {
+		writeGroupError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, group)
+}
+
+// GetGroup returns a group with the users and groups directly in it
+func (h *GroupHandler) GetGroup(c *gin.Context) {
+	tenantID, groupID, ok := h.groupID(c)
+	if !ok {
+		return
+	}
+
+	group, err := h.groupService.GetGroup(tenantID, groupID)
+	if err != nil {
+		writeGroupError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, group)
+}
+
+// UpdateGroup changes the description or roles of a group
+func (h *GroupHandler) UpdateGroup(c *gin.Context) {
+	tenantID, groupID, ok := h.groupID(c)
+	if !ok {
+		return
+	}
+
+	var update models.TenantGroupUpdate
+	if err := c.ShouldBindJSON(&update); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !checkGrantable(c, h.roleService, tenantID, update.Roles) {
+		return
+	}
+
+	group, err := h.groupService.UpdateGroup(tenantID, groupID, &update, currentUser(c).ID)
+	if err != nil {
+		writeGroupError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, group)
+}
+
+// DeleteGroup deletes a group, its members losing the roles it gave them
+func (h *GroupHandler) DeleteGroup(c *gin.Context) {
+	tenantID, groupID, ok := h.groupID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.groupService.DeleteGroup(tenantID, groupID, currentUser(c).ID); err != nil {
+		writeGroupError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Group deleted successfully"})
+}
+
+// AddUser puts a member of the tenant in a group
+func (h *GroupHandler) AddUser(c *gin.Context) {
+	tenantID, groupID, ok := h.groupID(c)
+	if !ok {
+		return
+	}
+	userID, err := uuid.Parse(c.Param("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	if !h.checkGroupRoles(c, tenantID, groupID) {
+		return
+	}
+
+	if err := h.groupService.AddUser(tenantID, groupID, userID, currentUser(c).ID); err != nil {
+		writeGroupError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User added to group successfully"})
+}
+
+// RemoveUser takes a user out of a group
+func (h *GroupHandler) RemoveUser(c *gin.Context) {
+	tenantID, groupID, ok := h.groupID(c)
+	if !ok {
+		return
+	}
+	userID, err := uuid.Parse(c.Param("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	if err := h.groupService.RemoveUser(tenantID, groupID, userID, currentUser(c).ID); err != nil {
+		writeGroupError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User removed from group successfully"})
+}
+
+// AddGroup nests another group in a group, giving its members the group's roles
+func (h *GroupHandler) AddGroup(c *gin.Context) {
+	tenantID, groupID, ok := h.groupID(c)
+	if !ok {
+		return
+	}
+	memberGroupID, err := uuid.Parse(c.Param("memberGroupId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid member group ID"})
+		return
+	}
+	if !h.checkGroupRoles(c, tenantID, groupID) {
+		return
+	}
+
+	if err := h.groupService.AddGroup(tenantID, groupID, memberGroupID, currentUser(c).ID); err != nil {
+		writeGroupError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Group nested successfully"})
+}
+
+// RemoveGroup takes a nested group out of a group
+func (h *GroupHandler) RemoveGroup(c *gin.Context) {
+	tenantID, groupID, ok := h.groupID(c)
+	if !ok {
+		return
+	}
+	memberGroupID, err := uuid.Parse(c.Param("memberGroupId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid member group ID"})
+		return
+	}
+
+	if err := h.groupService.RemoveGroup(tenantID, groupID, memberGroupID, currentUser(c).ID); err != nil {
+		writeGroupError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Group removed successfully"})
+}
+
+// tenantID parses the tenant from the path and checks the caller belongs to it
+func (h *GroupHandler) tenantID(c *gin.Context) (uuid.UUID, bool) {
+	tenantID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tenant ID"})
+		return uuid.Nil, false
+	}
+	if !hasTenantAccess(c, tenantID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied to tenant"})
+		return uuid.Nil, false
+	}
+	return tenantID, true
+}
+
+// groupID parses the tenant and group from the path
+func (h *GroupHandler) groupID(c *gin.Context) (uuid.UUID, uuid.UUID, bool) {
+	tenantID, ok := h.tenantID(c)
+	if !ok {
+		return uuid.Nil, uuid.Nil, false
+	}
+	groupID, err := uuid.Parse(c.Param("groupId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid group ID"})
+		return uuid.Nil, uuid.Nil, false
+	}
+	return tenantID, groupID, true
+}
+
+// checkGroupRoles checks the caller could grant the roles of the group and of every group it is
+// nested in, which new members of the group receive
+func (h *GroupHandler) checkGroupRoles(c *gin.Context, tenantID, groupID uuid.UUID) bool {
+	roles, err := h.groupService.GroupRoles(tenantID, groupID)
+	if err != nil {
+		writeGroupError(c, err)
+		return false
+	}
+	return checkGrantable(c, h.roleService, tenantID, roles)
+}
+
+// writeGroupError maps group failures to responses
+func writeGroupError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Group not found"})
+	case errors.Is(err, services.ErrInvalidGroupName), errors.Is(err, services.ErrNotTenantMember):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrGroupExists), errors.Is(err, services.ErrGroupCycle):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
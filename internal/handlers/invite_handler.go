@@ -0,0 +1,332 @@
+package handlers
+
+import (
+	"errors"
+	"identity-service/internal/models"
+	"identity-service/internal/services"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// InviteHandler handles tenant invitations, both for the tenant sending them and for the
+// people receiving them
+type InviteHandler struct {
+	inviteService     services.InviteService
+	bulkInviteService services.BulkInviteService
+	roleService       services.RoleService
+	groupService      services.GroupService
+}
+
+// maxBulkInviteUploadSize bounds the size of CSV uploads
+const maxBulkInviteUploadSize = 1 << 20
+
+// NewInviteHandler creates a new invite handler instance
+func NewInviteHandler(inviteService services.InviteService, bulkInviteService services.BulkInviteService, roleService services.RoleService, groupService services.GroupService) *InviteHandler {
+	return &InviteHandler{
+		inviteService:     inviteService,
+		bulkInviteService: bulkInviteService,
+		roleService:       roleService,
+		groupService:      groupService,
+	}
+}
+
+// ListInvites returns the tenant's invitations, optionally filtered by status
+func (h *InviteHandler) ListInvites(c *gin.Context) {
+	tenantID, ok := h.tenantID(c)
+	if !ok {
+		return
+	}
+
+	invites, err := h.inviteService.ListInvites(tenantID, c.Query("status"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"invites": invites})
+}
+
+// CreateInvite invites an email address to the tenant
+func (h *InviteHandler) CreateInvite(c *gin.Context) {
+	tenantID, ok := h.tenantID(c)
+	if !ok {
+		return
+	}
+
+	var request models.TenantInviteRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	role := request.Role
+	if role == "" {
+		role = models.TenantRoleMember
+	}
+	if !checkGrantable(c, h.roleService, tenantID, []string{role}) {
+		return
+	}
+	// Joining the group grants its roles and those of the groups it is nested in
+	if request.Group != "" {
+		roles, err := h.groupService.GroupRolesByName(tenantID, request.Group)
+		if err != nil {
+			writeInviteError(c, err)
+			return
+		}
+		if !checkGrantable(c, h.roleService, tenantID, roles) {
+			return
+		}
+	}
+
+	invite, err := h.inviteService.CreateInvite(tenantID, &request, currentUser(c).ID)
+	if err != nil {
+		writeInviteError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, invite)
+}
+
+// ResendInvite emails a pending invitation again with a new token
+func (h *InviteHandler) ResendInvite(c *gin.Context) {
+	tenantID, inviteID, ok := h.inviteID(c)
+	if !ok {
+		return
+	}
+
+	invite, err := h.inviteService.ResendInvite(tenantID, inviteID)
+	if err != nil {
+		writeInviteError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, invite)
+}
+
+// DeleteInvite revokes an invitation
+func (h *InviteHandler) DeleteInvite(c *gin.Context) {
+	tenantID, inviteID, ok := h.inviteID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.inviteService.RevokeInvite(tenantID, inviteID); err != nil {
+		writeInviteError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Invite deleted successfully"})
+}
+
+// BulkInvite validates a CSV upload and starts inviting its rows. The CSV is sent as the file
+// field of a multipart form or as a text/csv body.
+func (h *InviteHandler) BulkInvite(c *gin.Context) {
+	tenantID, ok := h.tenantID(c)
+	if !ok {
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBulkInviteUploadSize)
+	upload := io.Reader(c.Request.Body)
+	if c.ContentType() == "multipart/form-data" {
+		file, err := c.FormFile("file")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "A CSV file is required"})
+			return
+		}
+		opened, err := file.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		defer opened.Close()
+		upload = opened
+	}
+
+	job, err := h.bulkInviteService.StartJob(tenantID, upload, currentUser(c).ID, func(permissions []string) string {
+		return ungrantable(c, tenantID, permissions)
+	})
+	var validationErr *services.BulkInviteValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error(), "rows": validationErr.Rows})
+		return
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tenant not found"})
+		return
+	case errors.Is(err, services.ErrBulkInviteNotEnabled):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, services.ErrBulkInviteSeats):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, job)
+}
+
+// ListBulkInviteJobs returns the tenant's CSV uploads and their progress
+func (h *InviteHandler) ListBulkInviteJobs(c *gin.Context) {
+	tenantID, ok := h.tenantID(c)
+	if !ok {
+		return
+	}
+
+	jobs, err := h.bulkInviteService.ListJobs(tenantID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"jobs": jobs})
+}
+
+// GetBulkInviteJob returns the progress of a CSV upload and the outcome of each processed row
+func (h *InviteHandler) GetBulkInviteJob(c *gin.Context) {
+	tenantID, ok := h.tenantID(c)
+	if !ok {
+		return
+	}
+	jobID, err := uuid.Parse(c.Param("jobId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid job ID"})
+		return
+	}
+
+	job, err := h.bulkInviteService.GetJob(tenantID, jobID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
+
+// LookupInvitation describes the invitation of a token to its recipient
+func (h *InviteHandler) LookupInvitation(c *gin.Context) {
+	var request models.InviteToken
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	details, err := h.inviteService.GetInvitation(request.Token)
+	if err != nil {
+		writeInviteError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, details)
+}
+
+// AcceptInvite adds the signed in user to the invitation's tenant
+func (h *InviteHandler) AcceptInvite(c *gin.Context) {
+	var request models.InviteToken
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	invite, err := h.inviteService.AcceptInvite(request.Token, currentUser(c))
+	if err != nil {
+		writeInviteError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, invite)
+}
+
+// SignUp creates an account for the invited email and accepts the invitation
+func (h *InviteHandler) SignUp(c *gin.Context) {
+	var signup models.InviteSignup
+	if err := c.ShouldBindJSON(&signup); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := h.inviteService.SignUp(&signup)
+	if err != nil {
+		writeInviteError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, user)
+}
+
+// DeclineInvite turns down an invitation
+func (h *InviteHandler) DeclineInvite(c *gin.Context) {
+	var request models.InviteToken
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.inviteService.DeclineInvite(request.Token); err != nil {
+		writeInviteError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Invite declined"})
+}
+
+// tenantID parses the tenant from the path and checks the caller belongs to it
+func (h *InviteHandler) tenantID(c *gin.Context) (uuid.UUID, bool) {
+	tenantID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tenant ID"})
+		return uuid.Nil, false
+	}
+	if !hasTenantAccess(c, tenantID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied to tenant"})
+		return uuid.Nil, false
+	}
+	return tenantID, true
+}
+
+// inviteID parses the tenant and invite from the path
+func (h *InviteHandler) inviteID(c *gin.Context) (uuid.UUID, uuid.UUID, bool) {
+	tenantID, ok := h.tenantID(c)
+	if !ok {
+		return uuid.Nil, uuid.Nil, false
+	}
+	inviteID, err := uuid.Parse(c.Param("inviteId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid invite ID"})
+		return uuid.Nil, uuid.Nil, false
+	}
+	return tenantID, inviteID, true
+}
+
+// writeInviteError maps invitation failures to responses
+func writeInviteError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invite not found"})
+	case errors.Is(err, services.ErrInvalidInviteToken):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInviteExpired):
+		c.JSON(http.StatusGone, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInviteEmailMismatch):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInviteNotPending), errors.Is(err, services.ErrInviteExists),
+		errors.Is(err, services.ErrAlreadyMember), errors.Is(err, services.ErrAccountExists),
+		errors.Is(err, services.ErrTenantFull):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrUnknownRole), errors.Is(err, services.ErrUnknownGroup):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInviteNotSent):
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"identity-service/internal/auth/jwt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// KeyHandler publishes the public keys tokens are signed with
+type KeyHandler struct {
+	keyManager *jwt.KeyManager
+}
+
+// NewKeyHandler creates a new key handler instance
+func NewKeyHandler(keyManager *jwt.KeyManager) *KeyHandler {
+	return &KeyHandler{
+		keyManager: keyManager,
+	}
+}
+
+// JWKS returns the JSON Web Key Set. The document only changes on key rotation, so caches
+// may keep it until then; the next key is already included ahead of its use.
+func (h *KeyHandler) JWKS(c *gin.Context) {
+	body, etag, nextRotation := h.keyManager.JWKS()
+
+	maxAge := int(time.Until(nextRotation).Seconds())
+	if maxAge < 0 {
+		maxAge = 0
+	}
+	c.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", maxAge))
+	c.Header("ETag", etag)
+
+	if c.GetHeader("If-None-Match") == etag {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	c.Data(http.StatusOK, "application/json", body)
+}
+
+// ListKeys returns every unexpired signing key and its lifecycle state
+func (h *KeyHandler) ListKeys(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"keys": h.keyManager.Keys()})
+}
+
+// RotateKeys activates the pending key immediately
+func (h *KeyHandler) RotateKeys(c *gin.Context) {
+	if err := h.keyManager.RotateNow(); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, jwt.ErrRotationInProgress) || errors.Is(err, jwt.ErrExternalSigner) {
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"keys": h.keyManager.Keys()})
+}
+
+// RevokeKey revokes a compromised key, invalidating every token it signed
+func (h *KeyHandler) RevokeKey(c *gin.Context) {
+	if err := h.keyManager.RevokeKey(c.Param("kid")); err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, jwt.ErrKeyNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, jwt.ErrRotationInProgress), errors.Is(err, jwt.ErrExternalSigner):
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"keys": h.keyManager.Keys()})
+}
@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"errors"
+	"identity-service/internal/auth"
+	"identity-service/internal/models"
+	"identity-service/internal/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// LDAPHandler manages tenants' LDAP and Active Directory connections
+type LDAPHandler struct {
+	ldapService services.LDAPService
+	roleService services.RoleService
+}
+
+// NewLDAPHandler creates a new LDAP handler instance
+func NewLDAPHandler(ldapService services.LDAPService, roleService services.RoleService) *LDAPHandler {
+	return &LDAPHandler{
+		ldapService: ldapService,
+		roleService: roleService,
+	}
+}
+
+// GetConnection returns the tenant's LDAP connection. The bind password is never returned.
+func (h *LDAPHandler) GetConnection(c *gin.Context) {
+	tenantID, ok := h.tenantID(c)
+	if !ok {
+		return
+	}
+
+	connection, err := h.ldapService.GetConnection(tenantID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "LDAP connection not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, connection)
+}
+
+// SaveConnection creates or updates the tenant's LDAP connection
+func (h *LDAPHandler) SaveConnection(c *gin.Context) {
+	tenantID, ok := h.tenantID(c)
+	if !ok {
+		return
+	}
+
+	var update models.LDAPConnectionUpdate
+	if err := c.ShouldBindJSON(&update); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// Directory users get the mapped roles on every login, so they may only grant what the
+	// caller holds
+	if update.GroupRoles != nil {
+		var roles []string
+		for _, groupRoles := range *update.GroupRoles {
+			roles = append(roles, groupRoles...)
+		}
+		if !checkGrantable(c, h.roleService, tenantID, roles) {
+			return
+		}
+	}
+
+	connection, err := h.ldapService.SaveConnection(tenantID, &update)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tenant not found"})
+		return
+	case errors.Is(err, services.ErrSSONotEnabled):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, services.ErrInvalidLDAPConnection):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save LDAP connection"})
+		return
+	}
+
+	c.JSON(http.StatusOK, connection)
+}
+
+// DeleteConnection removes the tenant's LDAP connection
+func (h *LDAPHandler) DeleteConnection(c *gin.Context) {
+	tenantID, ok := h.tenantID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.ldapService.DeleteConnection(tenantID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "LDAP connection deleted successfully"})
+}
+
+// TestConnection checks the server is reachable and the service account can bind
+func (h *LDAPHandler) TestConnection(c *gin.Context) {
+	tenantID, ok := h.tenantID(c)
+	if !ok {
+		return
+	}
+
+	err := h.ldapService.TestConnection(tenantID)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "LDAP connection not found"})
+		return
+	case errors.Is(err, auth.ErrLDAPSecretDecryption):
+		c.JSON(http.StatusConflict, gin.H{"error": "Bind password cannot be decrypted; set it again"})
+		return
+	case err != nil:
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "LDAP connection succeeded"})
+}
+
+// tenantID parses the tenant from the path and checks the caller belongs to it
+func (h *LDAPHandler) tenantID(c *gin.Context) (uuid.UUID, bool) {
+	tenantID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tenant ID"})
+		return uuid.Nil, false
+	}
+	if !hasTenantAccess(c, tenantID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied to tenant"})
+		return uuid.Nil, false
+	}
+	return tenantID, true
+}
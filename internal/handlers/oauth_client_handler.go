@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"errors"
+	"identity-service/internal/models"
+	"identity-service/internal/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// OAuthClientHandler handles registration of a tenant's OAuth clients
+type OAuthClientHandler struct {
+	clientService services.OAuthClientService
+}
+
+// NewOAuthClientHandler creates a new OAuth client handler instance
+func NewOAuthClientHandler(clientService services.OAuthClientService) *OAuthClientHandler {
+	return &OAuthClientHandler{
+		clientService: clientService,
+	}
+}
+
+// ListClients returns the OAuth clients registered by a tenant
+func (h *OAuthClientHandler) ListClients(c *gin.Context) {
+	tenantID, ok := h.tenantID(c)
+	if !ok {
+		return
+	}
+
+	clients, err := h.clientService.ListClients(tenantID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"clients": clients})
+}
+
+// CreateClient registers a new OAuth client. The client secret is only returned in this response.
+func (h *OAuthClientHandler) CreateClient(c *gin.Context) {
+	tenantID, ok := h.tenantID(c)
+	if !ok {
+		return
+	}
+
+	var req models.OAuthClientCreate
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	client, secret, err := h.clientService.CreateClient(tenantID, &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	response := gin.H{"client": client}
+	if secret != "" {
+		response["clientSecret"] = secret
+	}
+	c.JSON(http.StatusCreated, response)
+}
+
+// GetClient returns a single OAuth client
+func (h *OAuthClientHandler) GetClient(c *gin.Context) {
+	tenantID, ok := h.tenantID(c)
+	if !ok {
+		return
+	}
+	clientID, err := uuid.Parse(c.Param("clientId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid client ID"})
+		return
+	}
+
+	client, err := h.clientService.GetClient(tenantID, clientID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Client not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, client)
+}
+
+// UpdateClient updates an OAuth client's name, redirect URIs, scopes or grant types
+func (h *OAuthClientHandler) UpdateClient(c *gin.Context) {
+	tenantID, ok := h.tenantID(c)
+	if !ok {
+		return
+	}
+	clientID, err := uuid.Parse(c.Param("clientId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid client ID"})
+		return
+	}
+
+	var update models.OAuthClientUpdate
+	if err := c.ShouldBindJSON(&update); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	client, err := h.clientService.UpdateClient(tenantID, clientID, &update)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Client not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, client)
+}
+
+// DeleteClient removes an OAuth client
+func (h *OAuthClientHandler) DeleteClient(c *gin.Context) {
+	tenantID, ok := h.tenantID(c)
+	if !ok {
+		return
+	}
+	clientID, err := uuid.Parse(c.Param("clientId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid client ID"})
+		return
+	}
+
+	if err := h.clientService.DeleteClient(tenantID, clientID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Client deleted successfully"})
+}
+
+// tenantID parses the tenant from the path and checks the caller belongs to it
+func (h *OAuthClientHandler) tenantID(c *gin.Context) (uuid.UUID, bool) {
+	tenantID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tenant ID"})
+		return uuid.Nil, false
+	}
+	if !hasTenantAccess(c, tenantID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied to tenant"})
+		return uuid.Nil, false
+	}
+	return tenantID, true
+}
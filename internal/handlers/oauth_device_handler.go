@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"errors"
+	"identity-service/internal/models"
+	"identity-service/internal/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DeviceAuthorization issues device and user codes to a client that cannot open a browser (RFC 8628)
+func (h *OAuthServerHandler) DeviceAuthorization(c *gin.Context) {
+	var req models.DeviceAuthorizationRequest
+	if err := c.ShouldBind(&req); err != nil {
+		writeOAuthError(c, services.NewOAuthError(services.OAuthErrInvalidRequest, err.Error()))
+		return
+	}
+
+	if !basicClientAuth(c, &req.ClientID, &req.ClientSecret) {
+		return
+	}
+
+	resp, err := h.serverService.AuthorizeDevice(&req)
+	if err != nil {
+		writeClientError(c, err)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+	c.JSON(http.StatusOK, resp)
+}
+
+// GetDeviceRequest shows the signed-in user which application a user code belongs to
+func (h *OAuthServerHandler) GetDeviceRequest(c *gin.Context) {
+	verification, err := h.serverService.LookupDeviceCode(c.Query("user_code"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, verification)
+}
+
+// DecideDevice approves or denies a device for the signed-in user
+func (h *OAuthServerHandler) DecideDevice(c *gin.Context) {
+	var decision models.DeviceDecision
+	if err := c.ShouldBindJSON(&decision); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user := currentUser(c)
+	if user == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	// The user authenticated when their current session was issued
+	session, err := h.authService.GetSession(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	if err := h.serverService.DecideDevice(user, &decision, session.CreatedAt); err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, services.ErrInvalidUserCode):
+			status = http.StatusNotFound
+		case errors.Is(err, services.ErrDeviceTenantDenied), errors.Is(err, services.ErrDeviceTenantClient):
+			status = http.StatusForbidden
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	message := "Device denied"
+	if decision.Approve {
+		message = "Device approved"
+	}
+	c.JSON(http.StatusOK, gin.H{"message": message})
+}
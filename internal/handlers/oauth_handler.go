@@ -2,11 +2,11 @@ package handlers
 
 import (
 	"context"
-	"fmt"
+	"identity-service/internal/auth"
 	"identity-service/internal/models"
 	"identity-service/internal/services"
-	"identity-service/pkg/utils"
 	"net/http"
+	"net/url"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -15,19 +15,33 @@ import (
 
 // OAuthHandler handles OAuth-related HTTP requests
 type OAuthHandler struct {
-	providers   map[string]services.OAuthProvider
-	userService services.UserService
-	authService services.AuthService
-	pkceService services.PKCEService
+	providers       map[string]services.OAuthProvider
+	userService     services.UserService
+	authService     services.AuthService
+	pkceService     services.PKCEService
+	tenantService   services.TenantService
+	stateService    services.OAuthStateService
+	redirectService services.RedirectService
 }
 
 // NewOAuthHandler creates a new OAuth handler instance
-func NewOAuthHandler(providers map[string]services.OAuthProvider, userService services.UserService, authService services.AuthService, pkceService services.PKCEService) *OAuthHandler {
+func NewOAuthHandler(
+	providers map[string]services.OAuthProvider,
+	userService services.UserService,
+	authService services.AuthService,
+	pkceService services.PKCEService,
+	tenantService services.TenantService,
+	stateService services.OAuthStateService,
+	redirectService services.RedirectService,
+) *OAuthHandler {
 	return &OAuthHandler{
-		providers:   providers,
-		userService: userService,
-		authService: authService,
-		pkceService: pkceService,
+		providers:       providers,
+		userService:     userService,
+		authService:     authService,
+		pkceService:     pkceService,
+		tenantService:   tenantService,
+		stateService:    stateService,
+		redirectService: redirectService,
 	}
 }
 
@@ -40,9 +54,53 @@ func (h *OAuthHandler) HandleLogin(c *gin.Context) {
 		return
 	}
 
-	state := utils.GenerateRandomState()
-	url := provider.GetAuthURL(state)
-	c.JSON(http.StatusOK, gin.H{"url": url})
+	// Optional tenant hint, used to extend the redirect allowlist and pick the session tenant
+	var tenant *models.Tenant
+	if slug := c.Query("tenant"); slug != "" {
+		var err error
+		tenant, err = h.tenantService.GetTenantBySlug(slug)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown tenant"})
+			return
+		}
+	}
+
+	redirectURI := c.Query("redirect_uri")
+	if redirectURI != "" {
+		if err := h.redirectService.ValidateRedirectURI(redirectURI, tenant); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	// The client generates the code_verifier and only sends us its challenge
+	codeChallenge := c.Query("code_challenge")
+	if err := auth.ValidateCodeChallenge(codeChallenge); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	codeChallengeMethod, err := auth.NormalizeCodeChallengeMethod(c.Query("code_challenge_method"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	state := &models.OAuthState{
+		Provider:            providerName,
+		RedirectURI:         redirectURI,
+		CodeChallenge:       codeChallenge,
+		CodeChallengeMethod: codeChallengeMethod,
+	}
+	if tenant != nil {
+		state.TenantID = &tenant.ID
+	}
+	if err := h.stateService.CreateState(state); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create login state"})
+		return
+	}
+
+	authURL := provider.GetAuthURL(state.State)
+	c.JSON(http.StatusOK, gin.H{"url": authURL})
 }
 
 // HandleCallback handles OAuth callback
@@ -54,6 +112,12 @@ func (h *OAuthHandler) HandleCallback(c *gin.Context) {
 		return
 	}
 
+	loginState, err := h.stateService.ConsumeState(providerName, c.Query("state"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	code := c.Query("code")
 	if code == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Authorization code not provided"})
@@ -85,50 +149,30 @@ func (h *OAuthHandler) HandleCallback(c *gin.Context) {
 		return
 	}
 
-	// Find personal tenant
-	var personalTenant *models.Tenant
+	// Use the requested tenant if the user belongs to it, otherwise the personal tenant
+	var sessionTenant *models.Tenant
 	for _, tenant := range tenants {
-		if tenant.Type == models.PersonalTenant {
-			personalTenant = tenant
+		if loginState.TenantID != nil && tenant.ID == *loginState.TenantID {
+			sessionTenant = tenant
 			break
 		}
+		if tenant.Type == models.PersonalTenant && sessionTenant == nil {
+			sessionTenant = tenant
+		}
 	}
 
-	if personalTenant == nil {
+	if sessionTenant == nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Personal tenant not found"})
 		return
 	}
 
-	// Create a PKCE challenge for secure token exchange
-	challengeID := uuid.New()
-	challenge := &models.PKCEChallenge{
-		ID:            challengeID,
-		CodeChallenge: utils.GenerateRandomString(32), // This will be verified against the code_verifier from frontend
-		CodeVerifier:  utils.GenerateRandomString(32), // This will be sent to frontend
-		UserID:        user.ID,
-		TenantID:      personalTenant.ID,
-		ExpiresAt:     time.Now().Add(5 * time.Minute),
-		CreatedAt:     time.Now(),
-	}
-
-	if err := h.pkceService.CreateChallenge(challenge); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create challenge"})
-		return
-	}
-
-	// Redirect to frontend with the code and code_verifier
-	frontendURL := fmt.Sprintf("http://localhost:3000/%s/callback?code=%s&codeVerifier=%s",
-		personalTenant.Slug,
-		challengeID.String(),
-		challenge.CodeVerifier,
-	)
-	c.Redirect(http.StatusTemporaryRedirect, frontendURL)
+	redirectWithLoginCode(c, h.pkceService, h.redirectService, loginState, user, sessionTenant)
 }
 
 // HandleTokenExchange handles the exchange of PKCE code for tokens
 func (h *OAuthHandler) HandleTokenExchange(c *gin.Context) {
 	var req models.TokenExchangeRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+	if err := c.ShouldBind(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
 		return
 	}
@@ -140,16 +184,10 @@ func (h *OAuthHandler) HandleTokenExchange(c *gin.Context) {
 		return
 	}
 
-	// Get and verify the challenge
-	challenge, err := h.pkceService.GetChallenge(challengeID)
+	// Redeem the code and verify the code verifier against the stored challenge
+	challenge, err := h.pkceService.ConsumeChallenge(challengeID, req.CodeVerifier)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired code"})
-		return
-	}
-
-	// Verify the code verifier
-	if challenge.CodeVerifier != req.CodeVerifier {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid code verifier"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
@@ -167,15 +205,59 @@ func (h *OAuthHandler) HandleTokenExchange(c *gin.Context) {
 		return
 	}
 
-	// Mark challenge as used
-	if err := h.pkceService.MarkChallengeAsUsed(challengeID); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to mark challenge as used"})
-		return
-	}
-
 	// Return tokens
 	c.JSON(http.StatusOK, models.TokenResponse{
 		AccessToken:  session.AccessToken,
 		RefreshToken: session.RefreshToken,
 	})
 }
+
+// redirectWithLoginCode sends the browser back to the frontend with a one-time code, bound to
+// the challenge the client sent when login started
+func redirectWithLoginCode(
+	c *gin.Context,
+	pkceService services.PKCEService,
+	redirectService services.RedirectService,
+	loginState *models.OAuthState,
+	user *models.User,
+	tenant *models.Tenant,
+) {
+	// The redirect URI was allowed by the requested tenant's allowlist. A user who is not a
+	// member of that tenant logs in to another one, whose admins did not vouch for the URI, so it
+	// must then be on the global allowlist.
+	if loginState.RedirectURI != "" && loginState.TenantID != nil && *loginState.TenantID != tenant.ID {
+		if err := redirectService.ValidateRedirectURI(loginState.RedirectURI, nil); err != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "redirect_uri is not allowed for the tenant you belong to"})
+			return
+		}
+	}
+
+	challengeID := uuid.New()
+	challenge := &models.PKCEChallenge{
+		ID:                  challengeID,
+		CodeChallenge:       loginState.CodeChallenge,
+		CodeChallengeMethod: loginState.CodeChallengeMethod,
+		UserID:              user.ID,
+		TenantID:            tenant.ID,
+		ExpiresAt:           time.Now().Add(5 * time.Minute),
+		CreatedAt:           time.Now(),
+	}
+
+	if err := pkceService.CreateChallenge(challenge); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create challenge"})
+		return
+	}
+
+	// Redirect to the frontend with the one-time code
+	frontendURL, err := url.Parse(redirectService.ResolveRedirectURI(loginState.RedirectURI, tenant))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid redirect URI"})
+		return
+	}
+	query := frontendURL.Query()
+	query.Set("code", challengeID.String())
+	frontendURL.RawQuery = query.Encode()
+
+	// See Other makes the browser follow with a GET, so a posted SAML response is not re-sent
+	c.Redirect(http.StatusSeeOther, frontendURL.String())
+}
@@ -0,0 +1,234 @@
+package handlers
+
+import (
+	"identity-service/config"
+	"identity-service/internal/models"
+	"identity-service/internal/services"
+	"net/http"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OAuthServerHandler exposes the OAuth 2.0 authorization server endpoints used by registered clients
+type OAuthServerHandler struct {
+	serverService services.OAuthServerService
+	authService   services.AuthService
+}
+
+// NewOAuthServerHandler creates a new authorization server handler instance
+func NewOAuthServerHandler(serverService services.OAuthServerService, authService services.AuthService) *OAuthServerHandler {
+	return &OAuthServerHandler{
+		serverService: serverService,
+		authService:   authService,
+	}
+}
+
+// Authorize validates an authorization request and sends the browser to the login page,
+// which completes it through Approve once the user has signed in
+func (h *OAuthServerHandler) Authorize(c *gin.Context) {
+	var req models.AuthorizeRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		writeOAuthError(c, services.NewOAuthError(services.OAuthErrInvalidRequest, err.Error()))
+		return
+	}
+
+	client, redirectURI, err := h.serverService.LookupClient(req.ClientID, req.RedirectURI)
+	if err != nil {
+		writeOAuthError(c, services.AsOAuthError(err))
+		return
+	}
+
+	if err := h.serverService.ValidateAuthorizeRequest(client, &req); err != nil {
+		c.Redirect(http.StatusFound, authorizeErrorRedirect(redirectURI, req.State, services.AsOAuthError(err)))
+		return
+	}
+
+	loginURL, err := url.Parse(config.AuthServer.LoginURL)
+	if err != nil {
+		writeOAuthError(c, services.NewOAuthError(services.OAuthErrServerError, ""))
+		return
+	}
+	loginURL.RawQuery = c.Request.URL.RawQuery
+
+	c.Redirect(http.StatusFound, loginURL.String())
+}
+
+// Approve issues an authorization code for the signed-in user and returns the URI the
+// login page should redirect the browser to
+func (h *OAuthServerHandler) Approve(c *gin.Context) {
+	var req models.AuthorizeRequest
+	if err := c.ShouldBind(&req); err != nil {
+		writeOAuthError(c, services.NewOAuthError(services.OAuthErrInvalidRequest, err.Error()))
+		return
+	}
+
+	client, redirectURI, err := h.serverService.LookupClient(req.ClientID, req.RedirectURI)
+	if err != nil {
+		writeOAuthError(c, services.AsOAuthError(err))
+		return
+	}
+
+	user := currentUser(c)
+	if user == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	// The user authenticated when their current session was issued
+	session, err := h.authService.GetSession(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	code, err := h.serverService.Authorize(user, client, &req, session.CreatedAt)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"redirect_uri": authorizeErrorRedirect(redirectURI, req.State, services.AsOAuthError(err))})
+		return
+	}
+
+	target, err := url.Parse(redirectURI)
+	if err != nil {
+		writeOAuthError(c, services.NewOAuthError(services.OAuthErrServerError, ""))
+		return
+	}
+	query := target.Query()
+	query.Set("code", code)
+	if req.State != "" {
+		query.Set("state", req.State)
+	}
+	target.RawQuery = query.Encode()
+
+	c.JSON(http.StatusOK, gin.H{"redirect_uri": target.String()})
+}
+
+// Token issues tokens for the authorization_code, refresh_token and client_credentials grants
+func (h *OAuthServerHandler) Token(c *gin.Context) {
+	var req models.OAuthTokenRequest
+	if err := c.ShouldBind(&req); err != nil {
+		writeOAuthError(c, services.NewOAuthError(services.OAuthErrInvalidRequest, err.Error()))
+		return
+	}
+
+	if !basicClientAuth(c, &req.ClientID, &req.ClientSecret) {
+		return
+	}
+
+	resp, err := h.serverService.Exchange(c, &req)
+	if err != nil {
+		writeClientError(c, err)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+	c.JSON(http.StatusOK, resp)
+}
+
+// Introspect tells a resource server whether a token is active (RFC 7662)
+func (h *OAuthServerHandler) Introspect(c *gin.Context) {
+	var req models.IntrospectionRequest
+	if err := c.ShouldBind(&req); err != nil {
+		writeOAuthError(c, services.NewOAuthError(services.OAuthErrInvalidRequest, err.Error()))
+		return
+	}
+
+	if !basicClientAuth(c, &req.ClientID, &req.ClientSecret) {
+		return
+	}
+
+	resp, err := h.serverService.Introspect(&req)
+	if err != nil {
+		writeClientError(c, err)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+	c.JSON(http.StatusOK, resp)
+}
+
+// Revoke invalidates a token and the session it belongs to (RFC 7009)
+func (h *OAuthServerHandler) Revoke(c *gin.Context) {
+	var req models.RevocationRequest
+	if err := c.ShouldBind(&req); err != nil {
+		writeOAuthError(c, services.NewOAuthError(services.OAuthErrInvalidRequest, err.Error()))
+		return
+	}
+
+	if !basicClientAuth(c, &req.ClientID, &req.ClientSecret) {
+		return
+	}
+
+	if err := h.serverService.Revoke(&req); err != nil {
+		writeClientError(c, err)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+// basicClientAuth reads client credentials sent with HTTP Basic. Clients may authenticate with
+// HTTP Basic instead of form parameters, but not both.
+func basicClientAuth(c *gin.Context, clientID, clientSecret *string) bool {
+	basicID, basicSecret, ok := c.Request.BasicAuth()
+	if !ok {
+		return true
+	}
+	if *clientSecret != "" {
+		writeOAuthError(c, services.NewOAuthError(services.OAuthErrInvalidRequest, "multiple client authentication methods used"))
+		return false
+	}
+
+	// Credentials are form-urlencoded before being placed in the header (RFC 6749 section 2.3.1)
+	var err error
+	if *clientID, err = url.QueryUnescape(basicID); err != nil {
+		writeOAuthError(c, services.NewOAuthError(services.OAuthErrInvalidClient, ""))
+		return false
+	}
+	if *clientSecret, err = url.QueryUnescape(basicSecret); err != nil {
+		writeOAuthError(c, services.NewOAuthError(services.OAuthErrInvalidClient, ""))
+		return false
+	}
+	return true
+}
+
+// writeClientError writes an error for a client-authenticated endpoint, challenging clients
+// that failed HTTP Basic authentication
+func writeClientError(c *gin.Context, err error) {
+	oauthErr := services.AsOAuthError(err)
+	if oauthErr.Code == services.OAuthErrInvalidClient && c.GetHeader("Authorization") != "" {
+		c.Header("WWW-Authenticate", `Basic realm="oauth"`)
+	}
+	writeOAuthError(c, oauthErr)
+}
+
+// writeOAuthError writes an error response in the RFC 6749 section 5.2 format
+func writeOAuthError(c *gin.Context, err *services.OAuthError) {
+	body := gin.H{"error": err.Code}
+	if err.Description != "" {
+		body["error_description"] = err.Description
+	}
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+	c.JSON(err.StatusCode, body)
+}
+
+// authorizeErrorRedirect builds the redirect carrying an authorization error (RFC 6749 section 4.1.2.1)
+func authorizeErrorRedirect(redirectURI, state string, err *services.OAuthError) string {
+	target, parseErr := url.Parse(redirectURI)
+	if parseErr != nil {
+		return redirectURI
+	}
+	query := target.Query()
+	query.Set("error", err.Code)
+	if err.Description != "" {
+		query.Set("error_description", err.Description)
+	}
+	if state != "" {
+		query.Set("state", state)
+	}
+	target.RawQuery = query.Encode()
+	return target.String()
+}
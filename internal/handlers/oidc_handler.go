@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"identity-service/config"
+	"identity-service/internal/auth"
+	jwtmanager "identity-service/internal/auth/jwt"
+	"identity-service/internal/models"
+	"identity-service/internal/services"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OIDCHandler serves the OpenID Connect discovery document and userinfo endpoint
+type OIDCHandler struct {
+	oidcService services.OIDCService
+	keyManager  *jwtmanager.KeyManager
+}
+
+// NewOIDCHandler creates a new OpenID Connect handler instance
+func NewOIDCHandler(oidcService services.OIDCService, keyManager *jwtmanager.KeyManager) *OIDCHandler {
+	return &OIDCHandler{
+		oidcService: oidcService,
+		keyManager:  keyManager,
+	}
+}
+
+// Discovery returns the OpenID Provider metadata (OpenID Connect Discovery 1.0 section 3)
+func (h *OIDCHandler) Discovery(c *gin.Context) {
+	issuer := config.AuthServer.Issuer
+	c.JSON(http.StatusOK, gin.H{
+		"issuer":                                issuer,
+		"authorization_endpoint":                issuer + "/oauth/authorize",
+		"token_endpoint":                        issuer + "/oauth/token",
+		"introspection_endpoint":                issuer + "/oauth/introspect",
+		"revocation_endpoint":                   issuer + "/oauth/revoke",
+		"device_authorization_endpoint":         issuer + "/oauth/device_authorization",
+		"userinfo_endpoint":                     issuer + "/userinfo",
+		"jwks_uri":                              issuer + "/.well-known/jwks.json",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": h.keyManager.Algorithms(),
+		"scopes_supported":                      []string{models.ScopeOpenID, models.ScopeProfile, models.ScopeEmail, models.ScopeGroups},
+		"grant_types_supported":                 []string{models.GrantTypeAuthorizationCode, models.GrantTypeRefreshToken, models.GrantTypeClientCredentials, models.GrantTypeDeviceCode, models.GrantTypeTokenExchange},
+		"token_endpoint_auth_methods_supported": []string{"client_secret_basic", "client_secret_post", "none"},
+		"code_challenge_methods_supported":      []string{auth.CodeChallengeMethodS256, auth.CodeChallengeMethodPlain},
+		"claims_supported":                      []string{"sub", "iss", "aud", "exp", "iat", "auth_time", "nonce", "email", "email_verified", "name", "tenant", "groups"},
+	})
+}
+
+// UserInfo returns the claims about the user the bearer access token was issued for
+func (h *OIDCHandler) UserInfo(c *gin.Context) {
+	token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if token == "" || token == c.GetHeader("Authorization") {
+		c.Header("WWW-Authenticate", `Bearer`)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid_request"})
+		return
+	}
+
+	info, err := h.oidcService.UserInfo(token)
+	if err != nil {
+		c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid_token", "error_description": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, info)
+}
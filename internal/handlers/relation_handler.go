@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"errors"
+	"identity-service/internal/models"
+	"identity-service/internal/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// RelationHandler serves relationship-based authorization to other services, authenticated as
+// OAuth clients of a tenant by AuthzHandler.RequireClient
+type RelationHandler struct {
+	relationService services.RelationService
+}
+
+// NewRelationHandler creates a new relationship-based authorization handler instance
+func NewRelationHandler(relationService services.RelationService) *RelationHandler {
+	return &RelationHandler{
+		relationService: relationService,
+	}
+}
+
+// ListNamespaces lists the tenant's namespace configurations
+func (h *RelationHandler) ListNamespaces(c *gin.Context) {
+	namespaces, err := h.relationService.ListNamespaces(authzTenantID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"namespaces": namespaces})
+}
+
+// GetNamespace returns a namespace configuration
+func (h *RelationHandler) GetNamespace(c *gin.Context) {
+	namespace, err := h.relationService.GetNamespace(authzTenantID(c), c.Param("name"))
+	if err != nil {
+		writeRelationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, namespace)
+}
+
+// SaveNamespace creates or replaces a namespace configuration
+func (h *RelationHandler) SaveNamespace(c *gin.Context) {
+	var request models.RelationNamespaceRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	namespace, err := h.relationService.SaveNamespace(authzTenantID(c), c.Param("name"), &request, authzClientID(c))
+	if err != nil {
+		writeRelationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, namespace)
+}
+
+// DeleteNamespace deletes a namespace no tuples use
+func (h *RelationHandler) DeleteNamespace(c *gin.Context) {
+	if err := h.relationService.DeleteNamespace(authzTenantID(c), c.Param("name"), authzClientID(c)); err != nil {
+		writeRelationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Namespace deleted successfully"})
+}
+
+// WriteTuples adds and deletes tuples
+func (h *RelationHandler) WriteTuples(c *gin.Context) {
+	var write models.RelationWrite
+	if err := c.ShouldBindJSON(&write); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	token, err := h.relationService.Write(authzTenantID(c), &write)
+	if err != nil {
+		writeRelationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"token": token})
+}
+
+// ReadTuples returns a page of the tuples matching the query's filter
+func (h *RelationHandler) ReadTuples(c *gin.Context) {
+	var filter models.RelationFilter
+	var consistency models.RelationConsistency
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := c.ShouldBindQuery(&consistency); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid page"})
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+		return
+	}
+
+	tuples, token, err := h.relationService.Read(authzTenantID(c), &filter, &consistency, page, limit)
+	if err != nil {
+		writeRelationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"tuples": tuples,
+		"page":   page,
+		"token":  token,
+	})
+}
+
+// Check decides whether a subject has a relation to an object
+func (h *RelationHandler) Check(c *gin.Context) {
+	var check models.RelationCheck
+	if err := c.ShouldBindJSON(&check); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	allowed, token, err := h.relationService.Check(authzTenantID(c), &check)
+	if err != nil {
+		writeRelationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"allowed": allowed,
+		"token":   token,
+	})
+}
+
+// Expand returns the subjects with a relation to an object
+func (h *RelationHandler) Expand(c *gin.Context) {
+	var expand models.RelationExpand
+	if err := c.ShouldBindJSON(&expand); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tree, token, err := h.relationService.Expand(authzTenantID(c), &expand)
+	if err != nil {
+		writeRelationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"tree":  tree,
+		"token": token,
+	})
+}
+
+// ListObjects returns the objects of a namespace a subject has a relation to
+func (h *RelationHandler) ListObjects(c *gin.Context) {
+	var list models.RelationListObjects
+	if err := c.ShouldBindJSON(&list); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	objects, token, err := h.relationService.ListObjects(authzTenantID(c), &list)
+	if err != nil {
+		writeRelationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"objects": objects,
+		"token":   token,
+	})
+}
+
+func writeRelationError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Namespace not found"})
+	case errors.Is(err, services.ErrInvalidRelationship), errors.Is(err, services.ErrInvalidNamespace),
+		errors.Is(err, services.ErrUnknownNamespace), errors.Is(err, services.ErrUnknownRelation),
+		errors.Is(err, services.ErrInvalidConsistencyToken):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrNamespaceInUse):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrSnapshotExpired):
+		c.JSON(http.StatusGone, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrRelationLimit):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"errors"
+	"identity-service/internal/models"
+	"identity-service/internal/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// RoleHandler manages a tenant's custom roles
+type RoleHandler struct {
+	roleService services.RoleService
+}
+
+// NewRoleHandler creates a new role handler instance
+func NewRoleHandler(roleService services.RoleService) *RoleHandler {
+	return &RoleHandler{
+		roleService: roleService,
+	}
+}
+
+// ListRoles returns the built-in roles and the tenant's custom roles
+func (h *RoleHandler) ListRoles(c *gin.Context) {
+	tenantID, ok := h.tenantID(c)
+	if !ok {
+		return
+	}
+
+	roles, err := h.roleService.ListRoles(tenantID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"roles": roles})
+}
+
+// CreateRole defines a custom role
+func (h *RoleHandler) CreateRole(c *gin.Context) {
+	tenantID, ok := h.tenantID(c)
+	if !ok {
+		return
+	}
+
+	var request models.TenantRoleRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if permission := ungrantable(c, tenantID, request.Permissions); permission != "" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Cannot grant a permission you do not hold: " + permission})
+		return
+	}
+
+	role, err := h.roleService.CreateRole(tenantID, &request, currentUser(c).ID)
+	if err != nil {
+		writeRoleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, role)
+}
+
+// GetRole returns one of the tenant's custom roles
+func (h *RoleHandler) GetRole(c *gin.Context) {
+	tenantID, roleID, ok := h.roleID(c)
+	if !ok {
+		return
+	}
+
+	role, err := h.roleService.GetRole(tenantID, roleID)
+	if err != nil {
+		writeRoleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, role)
+}
+
+// UpdateRole changes the description or permissions of a custom role
+func (h *RoleHandler) UpdateRole(c *gin.Context) {
+	tenantID, roleID, ok := h.roleID(c)
+	if !ok {
+		return
+	}
+
+	var update models.TenantRoleUpdate
+	if err := c.ShouldBindJSON(&update); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if permission := ungrantable(c, tenantID, update.Permissions); permission != "" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Cannot grant a permission you do not hold: " + permission})
+		return
+	}
+
+	role, err := h.roleService.UpdateRole(tenantID, roleID, &update, currentUser(c).ID)
+	if err != nil {
+		writeRoleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, role)
+}
+
+// DeleteRole deletes a custom role no member holds
+func (h *RoleHandler) DeleteRole(c *gin.Context) {
+	tenantID, roleID, ok := h.roleID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.roleService.DeleteRole(tenantID, roleID, currentUser(c).ID); err != nil {
+		writeRoleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Role deleted successfully"})
+}
+
+// tenantID parses the tenant from the path and checks the caller belongs to it
+func (h *RoleHandler) tenantID(c *gin.Context) (uuid.UUID, bool) {
+	tenantID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tenant ID"})
+		return uuid.Nil, false
+	}
+	if !hasTenantAccess(c, tenantID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied to tenant"})
+		return uuid.Nil, false
+	}
+	return tenantID, true
+}
+
+// roleID parses the tenant and role from the path
+func (h *RoleHandler) roleID(c *gin.Context) (uuid.UUID, uuid.UUID, bool) {
+	tenantID, ok := h.tenantID(c)
+	if !ok {
+		return uuid.Nil, uuid.Nil, false
+	}
+	roleID, err := uuid.Parse(c.Param("roleId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role ID"})
+		return uuid.Nil, uuid.Nil, false
+	}
+	return tenantID, roleID, true
+}
+
+// writeRoleError maps role failures to responses
+func writeRoleError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Role not found"})
+	case errors.Is(err, services.ErrInvalidRoleName), errors.Is(err, services.ErrInvalidPermission),
+		errors.Is(err, services.ErrInvalidConditions), errors.Is(err, services.ErrUnknownRole):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrBuiltinRoleName), errors.Is(err, services.ErrRoleExists),
+		errors.Is(err, services.ErrRoleInUse):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,250 @@
+package handlers
+
+import (
+	"errors"
+	"identity-service/internal/auth"
+	"identity-service/internal/models"
+	"identity-service/internal/services"
+	"net/http"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// SAMLHandler handles SAML 2.0 single sign-on for enterprise tenants
+type SAMLHandler struct {
+	samlService     services.SAMLService
+	tenantService   services.TenantService
+	pkceService     services.PKCEService
+	stateService    services.OAuthStateService
+	redirectService services.RedirectService
+}
+
+// NewSAMLHandler creates a new SAML handler instance
+func NewSAMLHandler(
+	samlService services.SAMLService,
+	tenantService services.TenantService,
+	pkceService services.PKCEService,
+	stateService services.OAuthStateService,
+	redirectService services.RedirectService,
+) *SAMLHandler {
+	return &SAMLHandler{
+		samlService:     samlService,
+		tenantService:   tenantService,
+		pkceService:     pkceService,
+		stateService:    stateService,
+		redirectService: redirectService,
+	}
+}
+
+// Metadata returns the tenant's service provider metadata for its IdP administrator
+func (h *SAMLHandler) Metadata(c *gin.Context) {
+	tenantID, err := uuid.Parse(c.Param("tenantId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tenant ID"})
+		return
+	}
+
+	metadata, err := h.samlService.Metadata(tenantID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tenant not found"})
+		return
+	}
+
+	c.Data(http.StatusOK, "application/samlmetadata+xml", metadata)
+}
+
+// Login starts an SP-initiated login and returns the IdP URL carrying the signed AuthnRequest
+func (h *SAMLHandler) Login(c *gin.Context) {
+	tenantID, err := uuid.Parse(c.Param("tenantId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tenant ID"})
+		return
+	}
+	tenant, err := h.tenantService.GetTenantByID(tenantID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tenant not found"})
+		return
+	}
+
+	redirectURI := c.Query("redirect_uri")
+	if redirectURI != "" {
+		if err := h.redirectService.ValidateRedirectURI(redirectURI, tenant); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	// The client generates the code_verifier and only sends us its challenge
+	codeChallenge := c.Query("code_challenge")
+	if err := auth.ValidateCodeChallenge(codeChallenge); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	codeChallengeMethod, err := auth.NormalizeCodeChallengeMethod(c.Query("code_challenge_method"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	state := &models.OAuthState{
+		Provider:            models.SAMLProvider,
+		RedirectURI:         redirectURI,
+		TenantID:            &tenant.ID,
+		CodeChallenge:       codeChallenge,
+		CodeChallengeMethod: codeChallengeMethod,
+	}
+	if err := h.stateService.CreateState(state); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create login state"})
+		return
+	}
+
+	authURL, err := h.samlService.AuthnRequestURL(tenant.ID, state.State)
+	if err != nil {
+		writeSAMLError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"url": authURL})
+}
+
+// ACS is the assertion consumer service the IdP posts its SAML response to
+func (h *SAMLHandler) ACS(c *gin.Context) {
+	tenantID, err := uuid.Parse(c.Param("tenantId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tenant ID"})
+		return
+	}
+	tenant, err := h.tenantService.GetTenantByID(tenantID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tenant not found"})
+		return
+	}
+
+	// A relay state we issued marks an SP-initiated login. Anything else, including a relay
+	// state chosen by the IdP, is an unsolicited response.
+	loginState, err := h.stateService.ConsumeState(models.SAMLProvider, c.PostForm("RelayState"))
+	if err != nil || loginState.TenantID == nil || *loginState.TenantID != tenant.ID {
+		loginState = nil
+	}
+
+	relayState := ""
+	if loginState != nil {
+		relayState = loginState.State
+	}
+	user, err := h.samlService.Authenticate(tenant.ID, c.Request, relayState)
+	if err != nil {
+		writeSAMLError(c, err)
+		return
+	}
+
+	if loginState == nil {
+		// An IdP-initiated response has no PKCE challenge to bind a login code to. The
+		// frontend starts an SP-initiated login instead, which the IdP session completes.
+		frontendURL, err := url.Parse(h.redirectService.ResolveRedirectURI("", tenant))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid redirect URI"})
+			return
+		}
+		query := frontendURL.Query()
+		query.Set("sso_tenant", tenant.ID.String())
+		frontendURL.RawQuery = query.Encode()
+
+		c.Redirect(http.StatusSeeOther, frontendURL.String())
+		return
+	}
+
+	redirectWithLoginCode(c, h.pkceService, h.redirectService, loginState, user, tenant)
+}
+
+// GetConnection returns the tenant's SAML identity provider configuration
+func (h *SAMLHandler) GetConnection(c *gin.Context) {
+	tenantID, ok := h.tenantID(c)
+	if !ok {
+		return
+	}
+
+	connection, err := h.samlService.GetConnection(tenantID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "SAML connection not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, connection)
+}
+
+// SaveConnection creates or updates the tenant's SAML identity provider
+func (h *SAMLHandler) SaveConnection(c *gin.Context) {
+	tenantID, ok := h.tenantID(c)
+	if !ok {
+		return
+	}
+
+	var update models.SAMLConnectionUpdate
+	if err := c.ShouldBindJSON(&update); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	connection, err := h.samlService.SaveConnection(tenantID, &update)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tenant not found"})
+		return
+	case errors.Is(err, services.ErrSSONotEnabled):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, connection)
+}
+
+// DeleteConnection removes the tenant's SAML identity provider
+func (h *SAMLHandler) DeleteConnection(c *gin.Context) {
+	tenantID, ok := h.tenantID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.samlService.DeleteConnection(tenantID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "SAML connection deleted successfully"})
+}
+
+// tenantID parses the tenant from the path and checks the caller belongs to it
+func (h *SAMLHandler) tenantID(c *gin.Context) (uuid.UUID, bool) {
+	tenantID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tenant ID"})
+		return uuid.Nil, false
+	}
+	if !hasTenantAccess(c, tenantID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied to tenant"})
+		return uuid.Nil, false
+	}
+	return tenantID, true
+}
+
+// writeSAMLError maps SAML login failures to responses
+func writeSAMLError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrSSONotEnabled), errors.Is(err, services.ErrIdPInitiatedDisabled),
+		errors.Is(err, services.ErrSAMLEmailNotInDomain):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrSAMLNotConfigured):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidSAMLResponse), errors.Is(err, services.ErrSAMLMissingAttributes),
+		errors.Is(err, services.ErrInvalidIdPMetadata):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "SAML login failed"})
+	}
+}
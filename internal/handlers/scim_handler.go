@@ -0,0 +1,503 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"identity-service/config"
+	"identity-service/internal/models"
+	"identity-service/internal/scim"
+	"identity-service/internal/services"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// scimContentType is the media type of SCIM requests and responses (RFC 7644 section 3.1)
+const scimContentType = "application/scim+json"
+
+// SCIMHandler serves the SCIM 2.0 API tenants' identity providers provision users through
+type SCIMHandler struct {
+	scimService services.SCIMService
+	roleService services.RoleService
+}
+
+// NewSCIMHandler creates a new SCIM handler instance
+func NewSCIMHandler(scimService services.SCIMService, roleService services.RoleService) *SCIMHandler {
+	return &SCIMHandler{
+		scimService: scimService,
+		roleService: roleService,
+	}
+}
+
+// RequireToken authenticates the identity provider by its SCIM bearer token and scopes the
+// request to the token's tenant
+func (h *SCIMHandler) RequireToken() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		secret, found := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !found {
+			c.Header("WWW-Authenticate", `Bearer`)
+			writeSCIMError(c, &services.SCIMError{Status: http.StatusUnauthorized, Detail: "missing bearer token"})
+			c.Abort()
+			return
+		}
+
+		token, err := h.scimService.AuthenticateToken(secret)
+		if errors.Is(err, services.ErrSSONotEnabled) {
+			writeSCIMError(c, &services.SCIMError{Status: http.StatusForbidden, Detail: err.Error()})
+			c.Abort()
+			return
+		}
+		if err != nil {
+			c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+			writeSCIMError(c, &services.SCIMError{Status: http.StatusUnauthorized, Detail: err.Error()})
+			c.Abort()
+			return
+		}
+
+		c.Set("scimTenantID", token.TenantID)
+		c.Set("scimRoles", []string(token.Roles))
+		c.Next()
+	}
+}
+
+// ServiceProviderConfig describes the SCIM features this service supports
+func (h *SCIMHandler) ServiceProviderConfig(c *gin.Context) {
+	writeSCIM(c, http.StatusOK, gin.H{
+		"schemas":        []string{scim.SchemaServiceProviderConfig},
+		"patch":          gin.H{"supported": true},
+		"bulk":           gin.H{"supported": false, "maxOperations": 0, "maxPayloadSize": 0},
+		"filter":         gin.H{"supported": true, "maxResults": scim.MaxCount},
+		"changePassword": gin.H{"supported": false},
+		"sort":           gin.H{"supported": false},
+		"etag":           gin.H{"supported": false},
+		"authenticationSchemes": []gin.H{{
+			"type":        "oauthbearertoken",
+			"name":        "Bearer Token",
+			"description": "A SCIM token issued to the tenant",
+			"primary":     true,
+		}},
+	})
+}
+
+// ResourceTypes lists the resources that can be provisioned
+func (h *SCIMHandler) ResourceTypes(c *gin.Context) {
+	resources := []interface{}{
+		gin.H{
+			"schemas":  []string{scim.SchemaResourceType},
+			"id":       "User",
+			"name":     "User",
+			"endpoint": "/Users",
+			"schema":   scim.SchemaUser,
+		},
+		gin.H{
+			"schemas":  []string{scim.SchemaResourceType},
+			"id":       "Group",
+			"name":     "Group",
+			"endpoint": "/Groups",
+			"schema":   scim.SchemaGroup,
+		},
+	}
+	writeSCIM(c, http.StatusOK, scim.NewListResponse(resources, int64(len(resources)), 1))
+}
+
+// ListUsers returns the tenant's users matching the filter, one page at a time
+func (h *SCIMHandler) ListUsers(c *gin.Context) {
+	startIndex, count, ok := scimPagination(c)
+	if !ok {
+		return
+	}
+
+	list, err := h.scimService.ListUsers(scimTenantID(c), c.Query("filter"), startIndex, count)
+	if err != nil {
+		writeSCIMError(c, err)
+		return
+	}
+
+	writeSCIM(c, http.StatusOK, list)
+}
+
+// GetUser returns a user of the tenant
+func (h *SCIMHandler) GetUser(c *gin.Context) {
+	id, ok := scimResourceID(c, "User")
+	if !ok {
+		return
+	}
+
+	user, err := h.scimService.GetUser(scimTenantID(c), id)
+	if err != nil {
+		writeSCIMError(c, err)
+		return
+	}
+
+	writeSCIM(c, http.StatusOK, user)
+}
+
+// CreateUser provisions a user into the tenant
+func (h *SCIMHandler) CreateUser(c *gin.Context) {
+	var resource scim.User
+	if !bindSCIM(c, &resource) {
+		return
+	}
+
+	user, err := h.scimService.CreateUser(scimTenantID(c), &resource, scimRoles(c))
+	if err != nil {
+		writeSCIMError(c, err)
+		return
+	}
+
+	c.Header("Location", user.Meta.Location)
+	writeSCIM(c, http.StatusCreated, user)
+}
+
+// ReplaceUser replaces a user's attributes
+func (h *SCIMHandler) ReplaceUser(c *gin.Context) {
+	id, ok := scimResourceID(c, "User")
+	if !ok {
+		return
+	}
+	var resource scim.User
+	if !bindSCIM(c, &resource) {
+		return
+	}
+
+	user, err := h.scimService.ReplaceUser(scimTenantID(c), id, &resource, scimRoles(c))
+	if err != nil {
+		writeSCIMError(c, err)
+		return
+	}
+
+	writeSCIM(c, http.StatusOK, user)
+}
+
+// PatchUser changes some of a user's attributes, e.g. deactivates the user
+func (h *SCIMHandler) PatchUser(c *gin.Context) {
+	id, ok := scimResourceID(c, "User")
+	if !ok {
+		return
+	}
+	var patch scim.PatchRequest
+	if !bindSCIM(c, &patch) {
+		return
+	}
+
+	user, err := h.scimService.PatchUser(scimTenantID(c), id, &patch, scimRoles(c))
+	if err != nil {
+		writeSCIMError(c, err)
+		return
+	}
+
+	writeSCIM(c, http.StatusOK, user)
+}
+
+// DeleteUser removes a user from the tenant
+func (h *SCIMHandler) DeleteUser(c *gin.Context) {
+	id, ok := scimResourceID(c, "User")
+	if !ok {
+		return
+	}
+
+	if err := h.scimService.DeleteUser(scimTenantID(c), id); err != nil {
+		writeSCIMError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ListGroups returns the tenant's groups matching the filter, one page at a time
+func (h *SCIMHandler) ListGroups(c *gin.Context) {
+	startIndex, count, ok := scimPagination(c)
+	if !ok {
+		return
+	}
+
+	list, err := h.scimService.ListGroups(scimTenantID(c), c.Query("filter"), startIndex, count)
+	if err != nil {
+		writeSCIMError(c, err)
+		return
+	}
+
+	writeSCIM(c, http.StatusOK, list)
+}
+
+// GetGroup returns a group of the tenant
+func (h *SCIMHandler) GetGroup(c *gin.Context) {
+	id, ok := scimResourceID(c, "Group")
+	if !ok {
+		return
+	}
+
+	group, err := h.scimService.GetGroup(scimTenantID(c), id)
+	if err != nil {
+		writeSCIMError(c, err)
+		return
+	}
+
+	writeSCIM(c, http.StatusOK, group)
+}
+
+// CreateGroup provisions a group into the tenant
+func (h *SCIMHandler) CreateGroup(c *gin.Context) {
+	var resource scim.Group
+	if !bindSCIM(c, &resource) {
+		return
+	}
+
+	group, err := h.scimService.CreateGroup(scimTenantID(c), &resource, scimRoles(c))
+	if err != nil {
+		writeSCIMError(c, err)
+		return
+	}
+
+	c.Header("Location", group.Meta.Location)
+	writeSCIM(c, http.StatusCreated, group)
+}
+
+// ReplaceGroup replaces a group's name and members
+func (h *SCIMHandler) ReplaceGroup(c *gin.Context) {
+	id, ok := scimResourceID(c, "Group")
+	if !ok {
+		return
+	}
+	var resource scim.Group
+	if !bindSCIM(c, &resource) {
+		return
+	}
+
+	group, err := h.scimService.ReplaceGroup(scimTenantID(c), id, &resource, scimRoles(c))
+	if err != nil {
+		writeSCIMError(c, err)
+		return
+	}
+
+	writeSCIM(c, http.StatusOK, group)
+}
+
+// PatchGroup changes a group, e.g. adds or removes members
+func (h *SCIMHandler) PatchGroup(c *gin.Context) {
+	id, ok := scimResourceID(c, "Group")
+	if !ok {
+		return
+	}
+	var patch scim.PatchRequest
+	if !bindSCIM(c, &patch) {
+		return
+	}
+
+	group, err := h.scimService.PatchGroup(scimTenantID(c), id, &patch, scimRoles(c))
+	if err != nil {
+		writeSCIMError(c, err)
+		return
+	}
+
+	writeSCIM(c, http.StatusOK, group)
+}
+
+// DeleteGroup deletes a group, taking its role away from the members
+func (h *SCIMHandler) DeleteGroup(c *gin.Context) {
+	id, ok := scimResourceID(c, "Group")
+	if !ok {
+		return
+	}
+
+	if err := h.scimService.DeleteGroup(scimTenantID(c), id); err != nil {
+		writeSCIMError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ListTokens returns the tenant's SCIM tokens
+func (h *SCIMHandler) ListTokens(c *gin.Context) {
+	tenantID, ok := h.tenantID(c)
+	if !ok {
+		return
+	}
+
+	tokens, err := h.scimService.ListTokens(tenantID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+// CreateToken issues a SCIM token for the tenant's identity provider. The token is only
+// shown in this response.
+func (h *SCIMHandler) CreateToken(c *gin.Context) {
+	tenantID, ok := h.tenantID(c)
+	if !ok {
+		return
+	}
+
+	var req models.SCIMTokenCreate
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Roles == nil {
+		roles, err := h.grantableRoles(c, tenantID)
+		if err != nil {
+			writeRoleError(c, err)
+			return
+		}
+		req.Roles = roles
+	} else if !checkGrantable(c, h.roleService, tenantID, req.Roles) {
+		return
+	}
+
+	token, secret, err := h.scimService.CreateToken(tenantID, &req)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tenant not found"})
+		return
+	case errors.Is(err, services.ErrSSONotEnabled):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"token":       token,
+		"secret":      secret,
+		"scimBaseUrl": config.AuthServer.Issuer + "/scim/v2",
+	})
+}
+
+// RevokeToken revokes a SCIM token
+func (h *SCIMHandler) RevokeToken(c *gin.Context) {
+	tenantID, ok := h.tenantID(c)
+	if !ok {
+		return
+	}
+	tokenID, err := uuid.Parse(c.Param("tokenId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid token ID"})
+		return
+	}
+
+	if err := h.scimService.RevokeToken(tenantID, tokenID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "SCIM token not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "SCIM token revoked successfully"})
+}
+
+// grantableRoles returns the tenant's roles the caller could grant, except owner, which
+// identity providers are only given when asked for explicitly
+func (h *SCIMHandler) grantableRoles(c *gin.Context, tenantID uuid.UUID) ([]string, error) {
+	roles, err := h.roleService.ListRoles(tenantID)
+	if err != nil {
+		return nil, err
+	}
+	grantable := []string{}
+	for _, role := range roles {
+		if role.Name == models.TenantRoleOwner {
+			continue
+		}
+		permissions, err := h.roleService.RolePermissions(tenantID, []string{role.Name})
+		if err != nil {
+			return nil, err
+		}
+		if ungrantable(c, tenantID, permissions) == "" {
+			grantable = append(grantable, role.Name)
+		}
+	}
+	return grantable, nil
+}
+
+// tenantID parses the tenant from the path and checks the caller belongs to it
+func (h *SCIMHandler) tenantID(c *gin.Context) (uuid.UUID, bool) {
+	tenantID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tenant ID"})
+		return uuid.Nil, false
+	}
+	if !hasTenantAccess(c, tenantID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied to tenant"})
+		return uuid.Nil, false
+	}
+	return tenantID, true
+}
+
+// scimTenantID returns the tenant set by RequireToken
+func scimTenantID(c *gin.Context) uuid.UUID {
+	tenantID, _ := c.MustGet("scimTenantID").(uuid.UUID)
+	return tenantID
+}
+
+// scimRoles returns the roles the token authenticated by RequireToken may grant
+func scimRoles(c *gin.Context) []string {
+	roles, _ := c.MustGet("scimRoles").([]string)
+	return roles
+}
+
+// scimResourceID parses the resource ID from the path. Unknown IDs are reported as not found.
+func scimResourceID(c *gin.Context, resourceType string) (uuid.UUID, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		writeSCIMError(c, &services.SCIMError{Status: http.StatusNotFound, Detail: resourceType + " not found"})
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+func scimPagination(c *gin.Context) (int, int, bool) {
+	startIndex, count, err := scim.ParsePagination(c.Query("startIndex"), c.Query("count"))
+	if err != nil {
+		writeSCIMError(c, services.NewSCIMError(services.SCIMErrInvalidValue, err.Error()))
+		return 0, 0, false
+	}
+	return startIndex, count, true
+}
+
+// bindSCIM decodes a SCIM request body, which is JSON whether sent as application/json or
+// application/scim+json
+func bindSCIM(c *gin.Context, v interface{}) bool {
+	if err := json.NewDecoder(c.Request.Body).Decode(v); err != nil {
+		writeSCIMError(c, services.NewSCIMError(services.SCIMErrInvalidSyntax, err.Error()))
+		return false
+	}
+	return true
+}
+
+func writeSCIM(c *gin.Context, status int, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to encode response"})
+		return
+	}
+	c.Data(status, scimContentType, data)
+}
+
+// writeSCIMError writes an error in the RFC 7644 section 3.12 format
+func writeSCIMError(c *gin.Context, err error) {
+	var scimErr *services.SCIMError
+	if !errors.As(err, &scimErr) {
+		log.Printf("SCIM request failed: %v", err)
+		scimErr = services.AsSCIMError(err)
+	}
+	body := gin.H{
+		"schemas": []string{scim.SchemaError},
+		"status":  strconv.Itoa(scimErr.Status),
+		"detail":  scimErr.Detail,
+	}
+	if scimErr.ScimType != "" {
+		body["scimType"] = scimErr.ScimType
+	}
+	writeSCIM(c, scimErr.Status, body)
+}
@@ -233,7 +233,7 @@ func (h *SecurityHandler) UpdateSecurityAlert(c *gin.Context) {
 }
 
 func (h *SecurityHandler) getTenantID(c *gin.Context) uuid.UUID {
-	return uuid.MustParse(c.GetString("tenantID"))
+	return currentTenant(c).ID
 }
 
 // GetAPIKey returns an API key
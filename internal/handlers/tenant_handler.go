@@ -51,7 +51,7 @@ func (h *TenantHandler) ListTenants(c *gin.Context) {
 	})
 }
 
-// CreateTenant creates a new tenant
+// CreateTenant creates a new tenant owned by the current user
 func (h *TenantHandler) CreateTenant(c *gin.Context) {
 	var tenant models.Tenant
 	if err := c.ShouldBindJSON(&tenant); err != nil {
@@ -59,7 +59,7 @@ func (h *TenantHandler) CreateTenant(c *gin.Context) {
 		return
 	}
 
-	createdTenant, err := h.tenantService.CreateTenant(&tenant)
+	createdTenant, err := h.tenantService.CreateTenant(&tenant, currentUser(c).ID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -98,6 +98,9 @@ func (h *TenantHandler) UpdateTenant(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	// Domains are only set by proving ownership through /api/tenants/:id/domains
+	updates.Domain = nil
+	updates.DomainVerified = nil
 
 	updatedTenant, err := h.tenantService.UpdateTenant(tenantID, &updates)
 	if err != nil {
@@ -246,7 +249,7 @@ func (h *TenantHandler) UpdateTenantFeatures(c *gin.Context) {
 
 // SwitchTenant switches the active tenant for the current user
 func (h *TenantHandler) SwitchTenant(c *gin.Context) {
-	userID := h.getUserID(c)
+	userID := currentUser(c).ID
 	tenantID, err := uuid.Parse(c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tenant ID"})
@@ -283,56 +286,7 @@ func (h *TenantHandler) UpgradeTenant(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Tenant upgraded successfully"})
 }
 
-// CreateTenantInvite creates a new invite for a tenant
-func (h *TenantHandler) CreateTenantInvite(c *gin.Context) {
-	tenantID, err := uuid.Parse(c.Param("id"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tenant ID"})
-		return
-	}
-
-	var invite models.TenantInvite
-	if err := c.ShouldBindJSON(&invite); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
-
-	createdInvite, err := h.tenantService.CreateTenantInvite(tenantID, &invite)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-
-	c.JSON(http.StatusCreated, createdInvite)
-}
-
-// DeleteTenantInvite deletes an invite from a tenant
-func (h *TenantHandler) DeleteTenantInvite(c *gin.Context) {
-	tenantID, err := uuid.Parse(c.Param("id"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tenant ID"})
-		return
-	}
-
-	inviteID, err := uuid.Parse(c.Param("inviteId"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid invite ID"})
-		return
-	}
-
-	if err := h.tenantService.DeleteTenantInvite(tenantID, inviteID); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-
-	c.JSON(http.StatusOK, gin.H{"message": "Invite deleted successfully"})
-}
-
 // ListTenantFeatures returns all features for a specific tenant
 func (h *TenantHandler) ListTenantFeatures(c *gin.Context) {
 	h.GetTenantFeatures(c)
 }
-
-func (h *TenantHandler) getUserID(c *gin.Context) uuid.UUID {
-	return uuid.MustParse(c.GetString("userID"))
-}
@@ -1,23 +1,27 @@
 package handlers
 
 import (
+	"errors"
 	"identity-service/internal/models"
 	"identity-service/internal/services"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 // UserHandler handles all user-related HTTP requests
 type UserHandler struct {
 	userService services.UserService
+	roleService services.RoleService
 }
 
 // NewUserHandler creates a new user handler instance
-func NewUserHandler(userService services.UserService) *UserHandler {
+func NewUserHandler(userService services.UserService, roleService services.RoleService) *UserHandler {
 	return &UserHandler{
 		userService: userService,
+		roleService: roleService,
 	}
 }
 
@@ -159,7 +163,7 @@ func (h *UserHandler) GetUserTenants(c *gin.Context) {
 	c.JSON(http.StatusOK, tenants)
 }
 
-// AddUserToTenant adds a user to a tenant
+// AddUserToTenant adds a user to a tenant with built-in or custom roles
 func (h *UserHandler) AddUserToTenant(c *gin.Context) {
 	userID, err := uuid.Parse(c.Param("id"))
 	if err != nil {
@@ -175,11 +179,19 @@ func (h *UserHandler) AddUserToTenant(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if !hasPermission(c, req.TenantID, models.PermMembersManage) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Permission denied: " + models.PermMembersManage})
+		return
+	}
+	if !checkGrantable(c, h.roleService, req.TenantID, req.Roles) {
+		return
+	}
 
 	if err := h.userService.AddUserToTenant(userID, req.TenantID, req.Roles); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	h.roleService.RecordAssignment(req.TenantID, userID, req.Roles, currentUser(c).ID)
 
 	c.JSON(http.StatusOK, gin.H{"message": "User added to tenant successfully"})
 }
@@ -192,11 +204,14 @@ func (h *UserHandler) RemoveUserFromTenant(c *gin.Context) {
 		return
 	}
 
-	tenantID, err := uuid.Parse(c.Param("tenant_id"))
+	tenantID, err := uuid.Parse(c.Param("tenantId"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tenant ID"})
 		return
 	}
+	if !h.checkMemberRoles(c, userID, tenantID) {
+		return
+	}
 
 	if err := h.userService.RemoveUserFromTenant(userID, tenantID); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -206,7 +221,7 @@ func (h *UserHandler) RemoveUserFromTenant(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "User removed from tenant successfully"})
 }
 
-// UpdateUserRole updates a user's role in a tenant
+// UpdateUserRole replaces a user's roles in a tenant with a built-in or custom role
 func (h *UserHandler) UpdateUserRole(c *gin.Context) {
 	userID, err := uuid.Parse(c.Param("id"))
 	if err != nil {
@@ -214,7 +229,7 @@ func (h *UserHandler) UpdateUserRole(c *gin.Context) {
 		return
 	}
 
-	tenantID, err := uuid.Parse(c.Param("tenant_id"))
+	tenantID, err := uuid.Parse(c.Param("tenantId"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tenant ID"})
 		return
@@ -227,22 +242,31 @@ func (h *UserHandler) UpdateUserRole(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if !h.checkMemberRoles(c, userID, tenantID) || !checkGrantable(c, h.roleService, tenantID, []string{req.Role}) {
+		return
+	}
 
 	if err := h.userService.UpdateUserRole(userID, tenantID, req.Role); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	h.roleService.RecordAssignment(tenantID, userID, []string{req.Role}, currentUser(c).ID)
 
 	c.JSON(http.StatusOK, gin.H{"message": "User role updated successfully"})
 }
 
-// ListUserTenants returns all tenants for a specific user
+// ListUserTenants returns all tenants for a specific user. Users may list their own tenants;
+// anyone else's need a platform administrator.
 func (h *UserHandler) ListUserTenants(c *gin.Context) {
 	userID, err := uuid.Parse(c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
 		return
 	}
+	if user := currentUser(c); user.ID != userID && user.Role != models.RoleAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Administrator access required"})
+		return
+	}
 
 	tenants, err := h.userService.GetUserTenants(userID)
 	if err != nil {
@@ -255,7 +279,7 @@ func (h *UserHandler) ListUserTenants(c *gin.Context) {
 
 // GetProfile returns the current user's profile
 func (h *UserHandler) GetProfile(c *gin.Context) {
-	userID := uuid.MustParse(c.GetString("userID"))
+	userID := currentUser(c).ID
 	profile, err := h.userService.GetUserProfile(userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -266,7 +290,7 @@ func (h *UserHandler) GetProfile(c *gin.Context) {
 
 // UpdateProfile updates the current user's profile
 func (h *UserHandler) UpdateProfile(c *gin.Context) {
-	userID := uuid.MustParse(c.GetString("userID"))
+	userID := currentUser(c).ID
 	var profile models.UserProfile
 	if err := c.ShouldBindJSON(&profile); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -282,7 +306,7 @@ func (h *UserHandler) UpdateProfile(c *gin.Context) {
 
 // UpdatePassword updates the current user's password
 func (h *UserHandler) UpdatePassword(c *gin.Context) {
-	userID := uuid.MustParse(c.GetString("userID"))
+	userID := currentUser(c).ID
 	var req struct {
 		CurrentPassword string `json:"currentPassword" binding:"required"`
 		NewPassword     string `json:"newPassword" binding:"required"`
@@ -297,3 +321,27 @@ func (h *UserHandler) UpdatePassword(c *gin.Context) {
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "Password updated successfully"})
 }
+
+// checkMemberRoles checks the caller could grant the roles the member holds, so nobody can
+// demote or remove a member with more permissions than they have, such as an owner
+func (h *UserHandler) checkMemberRoles(c *gin.Context, userID, tenantID uuid.UUID) bool {
+	roles, err := h.userService.GetUserTenantRoles(userID, tenantID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User is not a member of the tenant"})
+		return false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return false
+	}
+	permissions, err := h.roleService.RolePermissions(tenantID, roles)
+	if err != nil {
+		writeRoleError(c, err)
+		return false
+	}
+	if permission := ungrantable(c, tenantID, permissions); permission != "" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Cannot change a member who holds a permission you do not hold: " + permission})
+		return false
+	}
+	return true
+}
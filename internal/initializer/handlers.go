@@ -9,11 +9,25 @@ import (
 
 // Handlers contains all HTTP handlers
 type Handlers struct {
-	AuthHandler     *handlers.AuthHandler
-	OAuthHandler    *handlers.OAuthHandler
-	UserHandler     *handlers.UserHandler
-	TenantHandler   *handlers.TenantHandler
-	SecurityHandler *handlers.SecurityHandler
+	AuthHandler        *handlers.AuthHandler
+	OAuthHandler       *handlers.OAuthHandler
+	UserHandler        *handlers.UserHandler
+	TenantHandler      *handlers.TenantHandler
+	SecurityHandler    *handlers.SecurityHandler
+	OAuthServerHandler *handlers.OAuthServerHandler
+	OAuthClientHandler *handlers.OAuthClientHandler
+	OIDCHandler        *handlers.OIDCHandler
+	KeyHandler         *handlers.KeyHandler
+	SAMLHandler        *handlers.SAMLHandler
+	SCIMHandler        *handlers.SCIMHandler
+	LDAPHandler        *handlers.LDAPHandler
+	DomainHandler      *handlers.DomainHandler
+	AutoJoinHandler    *handlers.AutoJoinHandler
+	InviteHandler      *handlers.InviteHandler
+	RoleHandler        *handlers.RoleHandler
+	AuthzHandler       *handlers.AuthzHandler
+	RelationHandler    *handlers.RelationHandler
+	GroupHandler       *handlers.GroupHandler
 }
 
 // InitHandlers initializes all handlers with their required services
@@ -27,10 +41,24 @@ func InitHandlers(s *Services) *Handlers {
 	log.Printf("Initialized Google OAuth provider")
 
 	return &Handlers{
-		AuthHandler:     handlers.NewAuthHandler(s.AuthService),
-		OAuthHandler:    handlers.NewOAuthHandler(providers, s.UserService, s.AuthService, s.PKCEService),
-		UserHandler:     handlers.NewUserHandler(s.UserService),
-		TenantHandler:   handlers.NewTenantHandler(s.TenantService),
-		SecurityHandler: handlers.NewSecurityHandler(s.SecurityService),
+		AuthHandler:        handlers.NewAuthHandler(s.AuthService, s.DiscoveryService),
+		OAuthHandler:       handlers.NewOAuthHandler(providers, s.UserService, s.AuthService, s.PKCEService, s.TenantService, s.OAuthStateService, s.RedirectService),
+		UserHandler:        handlers.NewUserHandler(s.UserService, s.RoleService),
+		TenantHandler:      handlers.NewTenantHandler(s.TenantService),
+		SecurityHandler:    handlers.NewSecurityHandler(s.SecurityService),
+		OAuthServerHandler: handlers.NewOAuthServerHandler(s.OAuthServerService, s.AuthService),
+		OAuthClientHandler: handlers.NewOAuthClientHandler(s.OAuthClientService),
+		OIDCHandler:        handlers.NewOIDCHandler(s.OIDCService, s.keyManager),
+		KeyHandler:         handlers.NewKeyHandler(s.GetKeyManager()),
+		SAMLHandler:        handlers.NewSAMLHandler(s.SAMLService, s.TenantService, s.PKCEService, s.OAuthStateService, s.RedirectService),
+		SCIMHandler:        handlers.NewSCIMHandler(s.SCIMService, s.RoleService),
+		LDAPHandler:        handlers.NewLDAPHandler(s.LDAPService, s.RoleService),
+		DomainHandler:      handlers.NewDomainHandler(s.DomainService),
+		AutoJoinHandler:    handlers.NewAutoJoinHandler(s.AutoJoinService, s.RoleService),
+		InviteHandler:      handlers.NewInviteHandler(s.InviteService, s.BulkInviteService, s.RoleService, s.GroupService),
+		RoleHandler:        handlers.NewRoleHandler(s.RoleService),
+		AuthzHandler:       handlers.NewAuthzHandler(s.AuthzService, s.OAuthClientService),
+		RelationHandler:    handlers.NewRelationHandler(s.RelationService),
+		GroupHandler:       handlers.NewGroupHandler(s.GroupService, s.RoleService),
 	}
 }
@@ -7,21 +7,51 @@ import (
 
 // Repositories contains all data access repositories
 type Repositories struct {
-	UserRepo       repositories.UserRepository
-	TenantRepo     repositories.TenantRepository
-	SecurityRepo   repositories.SecurityRepository
-	SessionRepo    repositories.SessionRepository
-	PKCERepository repositories.PKCERepository
+	UserRepo        repositories.UserRepository
+	TenantRepo      repositories.TenantRepository
+	SecurityRepo    repositories.SecurityRepository
+	SessionRepo     repositories.SessionRepository
+	PKCERepository  repositories.PKCERepository
+	OAuthStateRepo  repositories.OAuthStateRepository
+	OAuthClientRepo repositories.OAuthClientRepository
+	AuthCodeRepo    repositories.AuthorizationCodeRepository
+	DeviceCodeRepo  repositories.DeviceCodeRepository
+	SigningKeyRepo  repositories.SigningKeyRepository
+	SAMLRepo        repositories.SAMLConnectionRepository
+	SCIMRepo        repositories.SCIMRepository
+	LDAPRepo        repositories.LDAPConnectionRepository
+	DomainRepo      repositories.DomainVerificationRepository
+	JoinRequestRepo repositories.JoinRequestRepository
+	InviteRepo      repositories.InviteRepository
+	BulkInviteRepo  repositories.BulkInviteRepository
+	RoleRepo        repositories.RoleRepository
+	RelationRepo    repositories.RelationRepository
+	GroupRepo       repositories.GroupRepository
 }
 
 // InitRepositories initializes all repositories with database connections
 func InitRepositories() *Repositories {
 	database := repositories.WrapDB(db.GetDB())
 	return &Repositories{
-		UserRepo:       repositories.NewUserRepository(database),
-		TenantRepo:     repositories.NewTenantRepository(database),
-		SecurityRepo:   repositories.NewSecurityRepository(database),
-		SessionRepo:    repositories.NewSessionRepository(database),
-		PKCERepository: repositories.NewPKCERepository(database),
+		UserRepo:        repositories.NewUserRepository(database),
+		TenantRepo:      repositories.NewTenantRepository(database),
+		SecurityRepo:    repositories.NewSecurityRepository(database),
+		SessionRepo:     repositories.NewSessionRepository(database),
+		PKCERepository:  repositories.NewPKCERepository(database),
+		OAuthStateRepo:  repositories.NewOAuthStateRepository(database),
+		OAuthClientRepo: repositories.NewOAuthClientRepository(database),
+		AuthCodeRepo:    repositories.NewAuthorizationCodeRepository(database),
+		DeviceCodeRepo:  repositories.NewDeviceCodeRepository(database),
+		SigningKeyRepo:  repositories.NewSigningKeyRepository(database),
+		SAMLRepo:        repositories.NewSAMLConnectionRepository(database),
+		SCIMRepo:        repositories.NewSCIMRepository(database),
+		LDAPRepo:        repositories.NewLDAPConnectionRepository(database),
+		DomainRepo:      repositories.NewDomainVerificationRepository(database),
+		JoinRequestRepo: repositories.NewJoinRequestRepository(database),
+		InviteRepo:      repositories.NewInviteRepository(database),
+		BulkInviteRepo:  repositories.NewBulkInviteRepository(database),
+		RoleRepo:        repositories.NewRoleRepository(database),
+		RelationRepo:    repositories.NewRelationRepository(database),
+		GroupRepo:       repositories.NewGroupRepository(database),
 	}
 }
@@ -1,7 +1,13 @@
 package initializer
 
 import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"identity-service/config"
+	"identity-service/internal/auth"
 	"identity-service/internal/auth/jwt"
+	"identity-service/internal/mail"
 	"identity-service/internal/services"
 	"log"
 	"time"
@@ -15,31 +21,76 @@ const (
 
 // Services holds all service instances
 type Services struct {
-	AuthService     services.AuthService
-	UserService     services.UserService
-	TenantService   services.TenantService
-	SecurityService services.SecurityService
-	PKCEService     services.PKCEService
-	keyManager      *jwt.KeyManager
+	AuthService        services.AuthService
+	UserService        services.UserService
+	TenantService      services.TenantService
+	SecurityService    services.SecurityService
+	PKCEService        services.PKCEService
+	OAuthStateService  services.OAuthStateService
+	RedirectService    services.RedirectService
+	OAuthClientService services.OAuthClientService
+	OAuthServerService services.OAuthServerService
+	OIDCService        services.OIDCService
+	SAMLService        services.SAMLService
+	SCIMService        services.SCIMService
+	LDAPService        services.LDAPService
+	DiscoveryService   services.DiscoveryService
+	DomainService      services.DomainService
+	AutoJoinService    services.AutoJoinService
+	InviteService      services.InviteService
+	BulkInviteService  services.BulkInviteService
+	RoleService        services.RoleService
+	AuthzService       services.AuthzService
+	RelationService    services.RelationService
+	GroupService       services.GroupService
+	keyManager         *jwt.KeyManager
 }
 
 // InitServices initializes all services with their required repositories
 func InitServices(repos *Repositories) *Services {
-	userService := services.NewUserService(repos.UserRepo, repos.TenantRepo)
+	autoJoinService := services.NewAutoJoinService(repos.JoinRequestRepo, repos.TenantRepo, repos.UserRepo, repos.SecurityRepo)
+	userService := services.NewUserService(repos.UserRepo, repos.TenantRepo, autoJoinService)
 
-	// Initialize key manager with default settings
-	keyManager, err := jwt.NewKeyManager(defaultKeyRotationPeriod, defaultKeySize)
+	// Initialize key manager with keys from the configured store
+	keyManager, err := jwt.NewKeyManagerWithConfig(keyManagerConfig(repos))
 	if err != nil {
 		log.Fatalf("Failed to initialize key manager: %v", err)
 	}
 
+	ldapService := services.NewLDAPService(repos.LDAPRepo, repos.TenantRepo, userService, ldapEncryptionKey(), auth.DialLDAP)
+	discoveryService := services.NewDiscoveryService(repos.TenantRepo, repos.SAMLRepo, repos.LDAPRepo)
+	authService := services.NewAuthService(userService, repos.SessionRepo, keyManager, ldapService, discoveryService, repos.GroupRepo)
+	oauthClientService := services.NewOAuthClientService(repos.OAuthClientRepo, keyManager)
+	oidcService := services.NewOIDCService(userService, repos.SessionRepo, repos.GroupRepo, keyManager)
+	samlKey, samlCert := samlKeyPair()
+	roleService := services.NewRoleService(repos.RoleRepo, repos.SecurityRepo)
+	groupService := services.NewGroupService(repos.GroupRepo, repos.TenantRepo, repos.SecurityRepo)
+	inviteService := services.NewInviteService(repos.InviteRepo, repos.TenantRepo, repos.UserRepo, autoJoinService, roleService, groupService, mailer(), inviteSigningKey())
+
 	return &Services{
-		AuthService:     services.NewAuthService(userService, repos.SessionRepo, keyManager),
-		UserService:     userService,
-		TenantService:   services.NewTenantService(repos.TenantRepo),
-		SecurityService: services.NewSecurityService(repos.SecurityRepo),
-		PKCEService:     services.NewPKCEService(repos.PKCERepository),
-		keyManager:      keyManager,
+		AuthService:        authService,
+		UserService:        userService,
+		TenantService:      services.NewTenantService(repos.TenantRepo, repos.GroupRepo),
+		SecurityService:    services.NewSecurityService(repos.SecurityRepo),
+		PKCEService:        services.NewPKCEService(repos.PKCERepository),
+		OAuthStateService:  services.NewOAuthStateService(repos.OAuthStateRepo),
+		RedirectService:    services.NewRedirectService(config.Redirect.AllowedURIs, config.Redirect.DefaultURI),
+		OAuthClientService: oauthClientService,
+		OAuthServerService: services.NewOAuthServerService(oauthClientService, authService, oidcService, userService, repos.AuthCodeRepo, repos.DeviceCodeRepo, repos.TenantRepo),
+		OIDCService:        oidcService,
+		SAMLService:        services.NewSAMLService(repos.SAMLRepo, repos.TenantRepo, userService, samlKey, samlCert),
+		SCIMService:        services.NewSCIMService(repos.SCIMRepo, repos.TenantRepo, repos.UserRepo, roleService),
+		LDAPService:        ldapService,
+		DiscoveryService:   discoveryService,
+		AutoJoinService:    autoJoinService,
+		InviteService:      inviteService,
+		BulkInviteService:  services.NewBulkInviteService(repos.BulkInviteRepo, repos.InviteRepo, repos.TenantRepo, inviteService, roleService, groupService),
+		RoleService:        roleService,
+		AuthzService:       services.NewAuthzService(repos.UserRepo, repos.TenantRepo, repos.RoleRepo),
+		RelationService:    services.NewRelationService(repos.RelationRepo, repos.SecurityRepo),
+		GroupService:       groupService,
+		DomainService:      services.NewDomainService(repos.DomainRepo, repos.TenantRepo, services.NewDomainResolver(config.Domain.DNSResolver), services.NewDomainHTTPClient()),
+		keyManager:         keyManager,
 	}
 }
 
@@ -47,3 +98,122 @@ func InitServices(repos *Repositories) *Services {
 func (s *Services) GetKeyManager() *jwt.KeyManager {
 	return s.keyManager
 }
+
+// keyManagerConfig selects the signer configured by JWT_SIGNER and, for local signing, the
+// key store configured by JWT_KEYSTORE
+func keyManagerConfig(repos *Repositories) jwt.KeyManagerConfig {
+	cfg := jwt.KeyManagerConfig{
+		RotationInterval: defaultKeyRotationPeriod,
+		KeySize:          defaultKeySize,
+		Algorithm:        config.Keys.Algorithm,
+		// Clients may request any additional algorithm for their id_tokens
+		AdditionalAlgorithms: config.Keys.AdditionalAlgorithms,
+		// Refresh tokens are the longest-lived tokens we sign
+		MaxTokenLifetime: services.RefreshTokenTTL,
+	}
+
+	switch config.Keys.Signer {
+	case config.SignerLocal:
+	case config.SignerRemote:
+		if config.Keys.SignerURL == "" {
+			log.Fatalf("JWT_SIGNER_URL is required for the remote signer")
+		}
+		cfg.Signer = jwt.NewRemoteSigner(jwt.RemoteSignerConfig{
+			URL:   config.Keys.SignerURL,
+			Token: config.Keys.SignerToken,
+		})
+		return cfg
+	default:
+		log.Fatalf("Unknown JWT_SIGNER %q", config.Keys.Signer)
+	}
+
+	if config.Keys.Store == config.KeyStoreMemory {
+		log.Printf("Signing keys are kept in memory; tokens will not survive a restart")
+		return cfg
+	}
+
+	encryptionKey, err := jwt.DecodeKey(config.Keys.EncryptionKey)
+	if err != nil {
+		log.Fatalf("JWT_KEY_ENCRYPTION_KEY must be a base64url encoded 32 byte key: %v", err)
+	}
+	cfg.EncryptionKey = encryptionKey
+
+	switch config.Keys.Store {
+	case config.KeyStorePostgres:
+		cfg.Store = repos.SigningKeyRepo
+	case config.KeyStoreFile:
+		store, err := jwt.NewFileKeyStore(config.Keys.Path)
+		if err != nil {
+			log.Fatalf("Failed to open key store %s: %v", config.Keys.Path, err)
+		}
+		cfg.Store = store
+	default:
+		log.Fatalf("Unknown JWT_KEYSTORE %q", config.Keys.Store)
+	}
+
+	return cfg
+}
+
+// samlKeyPair loads the SAML service provider key pair, or generates one for development
+func samlKeyPair() (*rsa.PrivateKey, *x509.Certificate) {
+	if config.SAML.SPKeyPath == "" || config.SAML.SPCertPath == "" {
+		log.Printf("SAML_SP_KEY_PATH and SAML_SP_CERT_PATH are not set; using a generated SAML key that changes on restart")
+		key, cert, err := auth.GenerateSAMLKeyPair(config.AuthServer.Issuer, defaultKeySize)
+		if err != nil {
+			log.Fatalf("Failed to generate SAML key pair: %v", err)
+		}
+		return key, cert
+	}
+
+	key, cert, err := auth.LoadSAMLKeyPair(config.SAML.SPKeyPath, config.SAML.SPCertPath)
+	if err != nil {
+		log.Fatalf("Failed to load SAML key pair: %v", err)
+	}
+	return key, cert
+}
+
+// ldapEncryptionKey loads the key that encrypts LDAP bind passwords, or generates one for
+// development
+func ldapEncryptionKey() []byte {
+	if config.LDAP.EncryptionKey == "" {
+		log.Printf("LDAP_ENCRYPTION_KEY is not set; using a generated key, so saved LDAP bind passwords will not survive a restart")
+		key := make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			log.Fatalf("Failed to generate LDAP encryption key: %v", err)
+		}
+		return key
+	}
+
+	key, err := jwt.DecodeKey(config.LDAP.EncryptionKey)
+	if err != nil || len(key) != 32 {
+		log.Fatalf("LDAP_ENCRYPTION_KEY must be a base64url encoded 32 byte key")
+	}
+	return key
+}
+
+// inviteSigningKey loads the key that signs invite tokens, or generates one for development
+func inviteSigningKey() []byte {
+	if config.Invite.SigningKey == "" {
+		log.Printf("INVITE_SIGNING_KEY is not set; using a generated key, so sent invitations will not survive a restart")
+		key := make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			log.Fatalf("Failed to generate invite signing key: %v", err)
+		}
+		return key
+	}
+
+	key, err := jwt.DecodeKey(config.Invite.SigningKey)
+	if err != nil || len(key) != 32 {
+		log.Fatalf("INVITE_SIGNING_KEY must be a base64url encoded 32 byte key")
+	}
+	return key
+}
+
+// mailer returns the SMTP mailer, or logs emails when no SMTP server is configured
+func mailer() mail.Mailer {
+	if config.Email.SMTPHost == "" {
+		log.Printf("SMTP_HOST is not set; emails are written to the log instead of being sent")
+		return mail.NewLogMailer()
+	}
+	return mail.NewSMTPMailer(config.Email.SMTPHost, config.Email.SMTPPort, config.Email.SMTPUsername, config.Email.SMTPPassword, config.Email.From)
+}
@@ -0,0 +1,79 @@
+package mail
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// Message is a plain text email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer sends transactional email
+type Mailer interface {
+	Send(message *Message) error
+}
+
+type smtpMailer struct {
+	addr string
+	auth smtp.Auth
+	from string
+}
+
+// NewSMTPMailer sends email through an SMTP server, using STARTTLS when the server offers it.
+// Credentials are only sent when a username is set.
+func NewSMTPMailer(host, port, username, password, from string) Mailer {
+	var auth smtp.Auth
+	if username != "" {
+		auth = smtp.PlainAuth("", username, password, host)
+	}
+	return &smtpMailer{
+		addr: net.JoinHostPort(host, port),
+		auth: auth,
+		from: from,
+	}
+}
+
+func (m *smtpMailer) Send(message *Message) error {
+	if err := smtp.SendMail(m.addr, m.auth, m.from, []string{message.To}, m.format(message)); err != nil {
+		return fmt.Errorf("failed to send email to %s: %w", message.To, err)
+	}
+	return nil
+}
+
+func (m *smtpMailer) format(message *Message) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", headerValue(m.from))
+	fmt.Fprintf(&b, "To: %s\r\n", headerValue(message.To))
+	fmt.Fprintf(&b, "Subject: %s\r\n", headerValue(message.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(message.Body, "\n", "\r\n"))
+	return []byte(b.String())
+}
+
+// headerValue keeps a value on its header line
+func headerValue(value string) string {
+	return strings.NewReplacer("\r", " ", "\n", " ").Replace(value)
+}
+
+type logMailer struct{}
+
+// NewLogMailer writes emails to the log instead of sending them, for development
+func NewLogMailer() Mailer {
+	return logMailer{}
+}
+
+func (logMailer) Send(message *Message) error {
+	log.Printf("Email to %s: %s\n%s", message.To, message.Subject, message.Body)
+	return nil
+}
@@ -8,27 +8,37 @@ import (
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/google/uuid"
 
-	"identity-service/internal/auth"
+	"identity-service/config"
 	jwtmanager "identity-service/internal/auth/jwt"
 	"identity-service/internal/models"
 	"identity-service/internal/response"
 )
 
 type JWTAuthMiddleware struct {
-	keyManager *jwtmanager.KeyManager
-	userRepo   UserRepository
+	keyManager  *jwtmanager.KeyManager
+	userRepo    UserRepository
+	sessionRepo SessionRepository
 }
 
 type UserRepository interface {
 	GetUserByID(id uuid.UUID) (*models.User, error)
 	GetUserTenantAccess(userID uuid.UUID) ([]models.UserTenantAccess, error)
+	GetTenantRoles(tenantIDs []uuid.UUID) ([]models.TenantRole, error)
+	GetMemberGroups(userIDs []uuid.UUID) ([]models.MemberGroup, error)
 	GetTenantByID(id uuid.UUID) (*models.Tenant, error)
 }
 
-func NewJWTAuthMiddleware(keyManager *jwtmanager.KeyManager, userRepo UserRepository) *JWTAuthMiddleware {
+// SessionRepository finds the session a token was issued with, which is deleted on logout and
+// revocation
+type SessionRepository interface {
+	GetSession(id uuid.UUID) (*models.Session, error)
+}
+
+func NewJWTAuthMiddleware(keyManager *jwtmanager.KeyManager, userRepo UserRepository, sessionRepo SessionRepository) *JWTAuthMiddleware {
 	return &JWTAuthMiddleware{
-		keyManager: keyManager,
-		userRepo:   userRepo,
+		keyManager:  keyManager,
+		userRepo:    userRepo,
+		sessionRepo: sessionRepo,
 	}
 }
 
@@ -49,51 +59,45 @@ func (m *JWTAuthMiddleware) RequireAuth() gin.HandlerFunc {
 			return
 		}
 
-		// Parse token without validation to get the key ID
-		parser := jwt.Parser{
-			ValidMethods: []string{jwt.SigningMethodRS256.Name},
-		}
-		token, _ := parser.Parse(tokenString, nil)
-		if token == nil {
-			response.Error(c, http.StatusUnauthorized, "Invalid token format", nil)
+		// Verify the signature with the key and algorithm the token's key ID is bound to
+		claims := jwt.MapClaims{}
+		if err := m.keyManager.VerifyToken(tokenString, claims); err != nil {
+			response.Error(c, http.StatusUnauthorized, "Invalid token", err)
 			c.Abort()
 			return
 		}
 
-		// Get key ID from token header
-		keyID, ok := token.Header["kid"].(string)
-		if !ok {
-			response.Error(c, http.StatusUnauthorized, "Missing key ID in token", nil)
+		// Only first-party access tokens are user credentials for this API. Refresh tokens,
+		// tokens issued to OAuth clients and delegated tokens, which carry a narrower scope or
+		// another audience, are not.
+		if tokenType, _ := claims["tokenType"].(string); tokenType != "access" {
+			response.Error(c, http.StatusUnauthorized, "Not an access token", nil)
 			c.Abort()
 			return
 		}
-
-		// Get the key pair for this key ID
-		keyPair := m.keyManager.GetKeyPairByID(keyID)
-		if keyPair == nil {
-			response.Error(c, http.StatusUnauthorized, "Invalid key ID", nil)
+		if clientID, _ := claims["clientId"].(string); clientID != "" || claims["act"] != nil {
+			response.Error(c, http.StatusUnauthorized, "Token was issued to an OAuth client", nil)
 			c.Abort()
 			return
 		}
-
-		// Parse and validate token with the correct public key
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
-				return nil, auth.ErrInvalidToken
-			}
-			return keyPair.PublicKey, nil
-		})
-
-		if err != nil || !token.Valid {
-			response.Error(c, http.StatusUnauthorized, "Invalid token", err)
+		if !claims.VerifyAudience(config.AuthServer.Issuer, false) {
+			response.Error(c, http.StatusUnauthorized, "Token is not intended for this API", nil)
 			c.Abort()
 			return
 		}
 
-		// Extract claims
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok {
-			response.Error(c, http.StatusUnauthorized, "Invalid token claims", nil)
+		// The session must still exist and hold this token, so logged out, revoked and
+		// refreshed tokens stop working immediately
+		sessionIDClaim, _ := claims["sessionId"].(string)
+		sessionID, err := uuid.Parse(sessionIDClaim)
+		if err != nil {
+			response.Error(c, http.StatusUnauthorized, "Invalid session ID in token", err)
+			c.Abort()
+			return
+		}
+		session, err := m.sessionRepo.GetSession(sessionID)
+		if err != nil || session.AccessToken != tokenString {
+			response.Error(c, http.StatusUnauthorized, "Session has ended", err)
 			c.Abort()
 			return
 		}
@@ -160,11 +164,112 @@ func (m *JWTAuthMiddleware) RequireAuth() gin.HandlerFunc {
 			return
 		}
 
+		// Resolve what each of the user's memberships allows, including through the
+		// tenants' custom roles and the roles of the user's groups
+		tenantIDs := make([]uuid.UUID, 0, len(tenantAccess))
+		for _, access := range tenantAccess {
+			tenantIDs = append(tenantIDs, access.TenantID)
+		}
+		customRoles, err := m.userRepo.GetTenantRoles(tenantIDs)
+		if err != nil {
+			response.Error(c, http.StatusInternalServerError, "Failed to get tenant roles", err)
+			c.Abort()
+			return
+		}
+		groups, err := m.userRepo.GetMemberGroups([]uuid.UUID{userID})
+		if err != nil {
+			response.Error(c, http.StatusInternalServerError, "Failed to get user groups", err)
+			c.Abort()
+			return
+		}
+		// Roles with conditions need the resource and request they apply to, so they only grant
+		// through authorization checks
+		rolesByTenant := make(map[uuid.UUID][]models.TenantRole)
+		for _, role := range customRoles {
+			if role.Conditions.Empty() {
+				rolesByTenant[role.TenantID] = append(rolesByTenant[role.TenantID], role)
+			}
+		}
+		permissions := make(map[uuid.UUID][]string, len(tenantAccess))
+		for _, access := range tenantAccess {
+			roles := append(models.GroupRoles(groups, access.TenantID), access.Roles...)
+			permissions[access.TenantID] = models.EffectivePermissions(roles, access.Permissions, rolesByTenant[access.TenantID])
+		}
+
 		// Set context values
 		c.Set("user", user)
 		c.Set("currentTenant", currentTenant)
 		c.Set("tenantAccess", tenantAccess)
+		c.Set("permissions", permissions)
 
 		c.Next()
 	}
 }
+
+// RequireAdmin only lets platform administrators through. It must run after RequireAuth.
+func RequireAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		value, exists := c.Get("user")
+		user, ok := value.(*models.User)
+		if !exists || !ok || user.Role != models.RoleAdmin {
+			response.Error(c, http.StatusForbidden, "Administrator access required", nil)
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequirePermission only lets users holding the permission in the tenant of their token
+// through. Platform administrators hold every permission. It must run after RequireAuth.
+func RequirePermission(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		value, _ := c.Get("currentTenant")
+		tenant, ok := value.(*models.Tenant)
+		if !ok {
+			response.Error(c, http.StatusForbidden, "Permission denied", nil)
+			c.Abort()
+			return
+		}
+
+		authorize(c, tenant.ID, permission)
+	}
+}
+
+// RequireTenantPermission is RequirePermission for the tenant named by a path parameter
+// rather than the tenant of the token
+func RequireTenantPermission(param, permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tenantID, err := uuid.Parse(c.Param(param))
+		if err != nil {
+			response.Error(c, http.StatusBadRequest, "Invalid tenant ID", err)
+			c.Abort()
+			return
+		}
+
+		authorize(c, tenantID, permission)
+	}
+}
+
+// hasPermission reports whether the authenticated user holds the permission in the tenant
+func hasPermission(c *gin.Context, tenantID uuid.UUID, permission string) bool {
+	if value, exists := c.Get("user"); exists {
+		if user, ok := value.(*models.User); ok && user.Role == models.RoleAdmin {
+			return true
+		}
+	}
+	value, _ := c.Get("permissions")
+	permissions, _ := value.(map[uuid.UUID][]string)
+	return models.HasPermission(permissions[tenantID], permission)
+}
+
+func authorize(c *gin.Context, tenantID uuid.UUID, permission string) {
+	if !hasPermission(c, tenantID, permission) {
+		response.Error(c, http.StatusForbidden, "Permission denied: "+permission, nil)
+		c.Abort()
+		return
+	}
+
+	c.Next()
+}
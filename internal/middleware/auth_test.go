@@ -0,0 +1,208 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"identity-service/config"
+	jwtmanager "identity-service/internal/auth/jwt"
+	"identity-service/internal/models"
+)
+
+type fakeUserRepository struct {
+	user   *models.User
+	tenant *models.Tenant
+}
+
+func (r *fakeUserRepository) GetUserByID(id uuid.UUID) (*models.User, error) {
+	if id != r.user.ID {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return r.user, nil
+}
+
+func (r *fakeUserRepository) GetUserTenantAccess(userID uuid.UUID) ([]models.UserTenantAccess, error) {
+	return []models.UserTenantAccess{{TenantID: r.tenant.ID, Roles: []string{models.TenantRoleOwner}}}, nil
+}
+
+func (r *fakeUserRepository) GetTenantRoles(tenantIDs []uuid.UUID) ([]models.TenantRole, error) {
+	return nil, nil
+}
+
+func (r *fakeUserRepository) GetMemberGroups(userIDs []uuid.UUID) ([]models.MemberGroup, error) {
+	return nil, nil
+}
+
+func (r *fakeUserRepository) GetTenantByID(id uuid.UUID) (*models.Tenant, error) {
+	if id != r.tenant.ID {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return r.tenant, nil
+}
+
+type fakeSessionRepository struct {
+	sessions map[uuid.UUID]*models.Session
+}
+
+func (r *fakeSessionRepository) GetSession(id uuid.UUID) (*models.Session, error) {
+	session, ok := r.sessions[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return session, nil
+}
+
+// authFixture is a user with a live first-party session in their tenant
+type authFixture struct {
+	keyManager *jwtmanager.KeyManager
+	users      *fakeUserRepository
+	sessions   *fakeSessionRepository
+	session    *models.Session
+	router     *gin.Engine
+}
+
+func newAuthFixture(t *testing.T) *authFixture {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	config.LoadAuthServerConfig()
+
+	keyManager, err := jwtmanager.NewKeyManager(time.Hour, 2048)
+	if err != nil {
+		t.Fatalf("NewKeyManager: %v", err)
+	}
+	f := &authFixture{
+		keyManager: keyManager,
+		users: &fakeUserRepository{
+			user:   &models.User{ID: uuid.New(), Email: "user@example.com"},
+			tenant: &models.Tenant{ID: uuid.New(), Name: "Example"},
+		},
+		sessions: &fakeSessionRepository{sessions: map[uuid.UUID]*models.Session{}},
+	}
+	f.session = &models.Session{ID: uuid.New(), UserID: f.users.user.ID, TenantID: f.users.tenant.ID}
+	f.session.AccessToken = f.sign(t, f.claims("access"))
+	f.sessions.sessions[f.session.ID] = f.session
+
+	auth := NewJWTAuthMiddleware(keyManager, f.users, f.sessions)
+	f.router = gin.New()
+	f.router.GET("/api/users/me", auth.RequireAuth(), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	return f
+}
+
+// claims are those the auth service puts in the session's tokens
+func (f *authFixture) claims(tokenType string) jwt.MapClaims {
+	return jwt.MapClaims{
+		"userId":    f.users.user.ID.String(),
+		"sessionId": f.session.ID.String(),
+		"tokenType": tokenType,
+		"tenantId":  f.users.tenant.ID.String(),
+		"iat":       time.Now().Unix(),
+		"exp":       time.Now().Add(time.Hour).Unix(),
+	}
+}
+
+func (f *authFixture) sign(t *testing.T, claims jwt.MapClaims) string {
+	t.Helper()
+	token, err := f.keyManager.SignToken(claims)
+	if err != nil {
+		t.Fatalf("SignToken: %v", err)
+	}
+	return token
+}
+
+func (f *authFixture) request(token string) int {
+	req := httptest.NewRequest(http.MethodGet, "/api/users/me", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	f.router.ServeHTTP(rec, req)
+	return rec.Code
+}
+
+func TestRequireAuthAcceptsLiveSessionToken(t *testing.T) {
+	f := newAuthFixture(t)
+
+	if code := f.request(f.session.AccessToken); code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", code, http.StatusOK)
+	}
+}
+
+func TestRequireAuthRejectsRevokedToken(t *testing.T) {
+	f := newAuthFixture(t)
+	if code := f.request(f.session.AccessToken); code != http.StatusOK {
+		t.Fatalf("status before revocation = %d, want %d", code, http.StatusOK)
+	}
+
+	// Revocation and logout end the session by deleting it
+	delete(f.sessions.sessions, f.session.ID)
+
+	if code := f.request(f.session.AccessToken); code != http.StatusUnauthorized {
+		t.Fatalf("status after revocation = %d, want %d", code, http.StatusUnauthorized)
+	}
+}
+
+func TestRequireAuthRejectsReplacedToken(t *testing.T) {
+	f := newAuthFixture(t)
+	previous := f.session.AccessToken
+
+	// Refreshing the session replaces its access token
+	claims := f.claims("access")
+	claims["exp"] = time.Now().Add(2 * time.Hour).Unix()
+	f.session.AccessToken = f.sign(t, claims)
+
+	if code := f.request(previous); code != http.StatusUnauthorized {
+		t.Fatalf("status with replaced token = %d, want %d", code, http.StatusUnauthorized)
+	}
+	if code := f.request(f.session.AccessToken); code != http.StatusOK {
+		t.Fatalf("status with current token = %d, want %d", code, http.StatusOK)
+	}
+}
+
+func TestRequireAuthRejectsOtherTokens(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(claims jwt.MapClaims)
+	}{
+		{name: "refresh token", modify: func(claims jwt.MapClaims) { claims["tokenType"] = "refresh" }},
+		{name: "OAuth client token", modify: func(claims jwt.MapClaims) {
+			claims["clientId"] = "third-party"
+			claims["scope"] = "openid"
+		}},
+		{name: "delegated token", modify: func(claims jwt.MapClaims) {
+			claims["act"] = map[string]interface{}{"sub": "service"}
+		}},
+		{name: "other audience", modify: func(claims jwt.MapClaims) { claims["aud"] = "https://api.example.com" }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newAuthFixture(t)
+			claims := f.claims("access")
+			tt.modify(claims)
+			// The session holds the token, so only its claims can get it rejected
+			f.session.AccessToken = f.sign(t, claims)
+
+			if code := f.request(f.session.AccessToken); code != http.StatusUnauthorized {
+				t.Fatalf("status = %d, want %d", code, http.StatusUnauthorized)
+			}
+		})
+	}
+}
+
+func TestRequireAuthAcceptsOwnAudience(t *testing.T) {
+	f := newAuthFixture(t)
+	claims := f.claims("access")
+	claims["aud"] = config.AuthServer.Issuer
+	f.session.AccessToken = f.sign(t, claims)
+
+	if code := f.request(f.session.AccessToken); code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", code, http.StatusOK)
+	}
+}
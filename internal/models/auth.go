@@ -18,15 +18,28 @@ type Session struct {
 	LastUsedAt   time.Time `json:"lastUsedAt"`
 	IPAddress    string    `json:"ipAddress"`
 	UserAgent    string    `json:"userAgent"`
+	ClientID     string    `json:"clientId,omitempty"`
+	Scope        string    `json:"scope,omitempty"`
+	// AccessScope is the scope of the access token issued with the session, which a refresh
+	// may narrow below the session's scope. It is only set on newly issued sessions.
+	AccessScope string `gorm:"-" json:"-"`
 }
 
 // OAuthState represents the state of an OAuth flow
 type OAuthState struct {
-	ID        uuid.UUID `json:"id"`
-	State     string    `json:"state"`
-	Provider  string    `json:"provider"`
-	CreatedAt time.Time `json:"createdAt"`
-	ExpiresAt time.Time `json:"expiresAt"`
+	ID                  uuid.UUID  `json:"id"`
+	State               string     `json:"state"`
+	Provider            string     `json:"provider"`
+	RedirectURI         string     `json:"redirectUri"`
+	TenantID            *uuid.UUID `json:"tenantId,omitempty"`
+	CodeChallenge       string     `json:"codeChallenge"`
+	CodeChallengeMethod string     `json:"codeChallengeMethod"`
+	CreatedAt           time.Time  `json:"createdAt"`
+	ExpiresAt           time.Time  `json:"expiresAt"`
+}
+
+func (OAuthState) TableName() string {
+	return "oauth_states"
 }
 
 // OAuthToken represents an OAuth token
@@ -95,20 +108,48 @@ type LoginCredentials struct {
 	Password string `json:"password" binding:"required,min=8"`
 }
 
+// Login methods returned by home realm discovery
+const (
+	PasswordLoginMethod = "password"
+	LDAPLoginMethod     = LDAPProvider
+	SAMLLoginMethod     = SAMLProvider
+)
+
+// DiscoveryRequest is the identifier a user enters before choosing how to sign in
+type DiscoveryRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// LoginMethod is a way to sign in. LDAP and password logins post the password to
+// /api/auth/login; SAML logins start at LoginURL.
+type LoginMethod struct {
+	Type     string `json:"type"`
+	LoginURL string `json:"loginUrl,omitempty"`
+}
+
+// LoginDiscovery lists the login methods for an email. Tenant is set when the email's domain
+// is verified by a tenant.
+type LoginDiscovery struct {
+	TenantID    *uuid.UUID    `json:"tenantId,omitempty"`
+	TenantName  string        `json:"tenantName,omitempty"`
+	SSORequired bool          `json:"ssoRequired"`
+	Methods     []LoginMethod `json:"methods"`
+}
+
 type PKCEChallenge struct {
-	ID            uuid.UUID `json:"id" gorm:"type:uuid;primary_key;"`
-	CodeChallenge string    `json:"codeChallenge" gorm:"not null"`
-	CodeVerifier  string    `json:"codeVerifier" gorm:"not null"`
-	UserID        uuid.UUID `json:"userId" gorm:"type:uuid;not null"`
-	TenantID      uuid.UUID `json:"tenantId" gorm:"type:uuid;not null"`
-	ExpiresAt     time.Time `json:"expiresAt" gorm:"not null"`
-	CreatedAt     time.Time `json:"createdAt" gorm:"not null"`
-	Used          bool      `json:"used" gorm:"not null;default:false"`
+	ID                  uuid.UUID `json:"id" gorm:"type:uuid;primary_key;"`
+	CodeChallenge       string    `json:"codeChallenge" gorm:"not null"`
+	CodeChallengeMethod string    `json:"codeChallengeMethod" gorm:"not null"`
+	UserID              uuid.UUID `json:"userId" gorm:"type:uuid;not null"`
+	TenantID            uuid.UUID `json:"tenantId" gorm:"type:uuid;not null"`
+	ExpiresAt           time.Time `json:"expiresAt" gorm:"not null"`
+	CreatedAt           time.Time `json:"createdAt" gorm:"not null"`
+	Used                bool      `json:"used" gorm:"not null;default:false"`
 }
 
 type TokenExchangeRequest struct {
-	Code         string `json:"code" binding:"required"`
-	CodeVerifier string `json:"codeVerifier" binding:"required"`
+	Code         string `json:"code" form:"code" binding:"required"`
+	CodeVerifier string `json:"code_verifier" form:"code_verifier" binding:"required"`
 }
 
 type TokenResponse struct {
@@ -0,0 +1,42 @@
+package models
+
+import "github.com/google/uuid"
+
+// AuthzCheck asks whether a user may perform an action, named like a permission, on a resource
+// in a tenant
+type AuthzCheck struct {
+	UserID uuid.UUID `json:"userId" binding:"required"`
+	// TenantID defaults to the tenant of the calling client, the only tenant it may check
+	TenantID uuid.UUID      `json:"tenantId,omitempty"`
+	Action   string         `json:"action" binding:"required"`
+	Resource *AuthzResource `json:"resource,omitempty"`
+	Context  AuthzContext   `json:"context"`
+}
+
+// AuthzResource describes the resource an action is performed on
+type AuthzResource struct {
+	Type    string     `json:"type,omitempty"`
+	ID      string     `json:"id,omitempty"`
+	OwnerID *uuid.UUID `json:"ownerId,omitempty"`
+}
+
+// AuthzContext describes the request an action is performed in
+type AuthzContext struct {
+	IP string `json:"ip,omitempty"`
+}
+
+// AuthzBatchCheck asks several questions at once
+type AuthzBatchCheck struct {
+	Checks []AuthzCheck `json:"checks" binding:"required,min=1,max=100,dive"`
+}
+
+// AuthzDecision answers an authorization check. Reason explains the decision for debugging and
+// is not meant to be shown to end users.
+type AuthzDecision struct {
+	Allowed  bool           `json:"allowed"`
+	UserID   uuid.UUID      `json:"userId"`
+	TenantID uuid.UUID      `json:"tenantId"`
+	Action   string         `json:"action"`
+	Resource *AuthzResource `json:"resource,omitempty"`
+	Reason   string         `json:"reason"`
+}
@@ -0,0 +1,70 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Bulk invitation job states
+const (
+	BulkInviteQueued    = "queued"
+	BulkInviteRunning   = "running"
+	BulkInviteCompleted = "completed"
+)
+
+// Outcomes of a bulk invitation row
+const (
+	BulkInviteRowInvited = "invited"
+	BulkInviteRowSkipped = "skipped"
+	BulkInviteRowFailed  = "failed"
+)
+
+// BulkInviteRow is a validated row of an uploaded CSV. Line is its line in the file.
+type BulkInviteRow struct {
+	Line  int    `json:"line"`
+	Email string `json:"email"`
+	Role  string `json:"role"`
+	Group string `json:"group,omitempty"`
+}
+
+// BulkInviteRowError explains why a row of an upload was rejected
+type BulkInviteRowError struct {
+	Line  int    `json:"line"`
+	Email string `json:"email,omitempty"`
+	Error string `json:"error"`
+}
+
+// BulkInviteRowResult is what happened to a row once it was processed
+type BulkInviteRowResult struct {
+	Line   int    `json:"line"`
+	Email  string `json:"email"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// BulkInviteJob invites the rows of an uploaded CSV in the background. Rows holds the
+// validated rows and Results the outcome of each processed row, both as JSON arrays.
+// ClaimedBy is the replica running the job; only it saves the job's progress.
+type BulkInviteJob struct {
+	ID          uuid.UUID       `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	TenantID    uuid.UUID       `gorm:"type:uuid;not null" json:"tenantId"`
+	CreatedBy   *uuid.UUID      `gorm:"type:uuid" json:"createdBy,omitempty"`
+	Status      string          `gorm:"type:varchar(20);not null;default:'queued'" json:"status"`
+	Total       int             `gorm:"not null;default:0" json:"total"`
+	Processed   int             `gorm:"not null;default:0" json:"processed"`
+	Invited     int             `gorm:"not null;default:0" json:"invited"`
+	Skipped     int             `gorm:"not null;default:0" json:"skipped"`
+	Failed      int             `gorm:"not null;default:0" json:"failed"`
+	Rows        json.RawMessage `gorm:"type:jsonb" json:"-"`
+	Results     json.RawMessage `gorm:"type:jsonb" json:"results,omitempty"`
+	ClaimedBy   *uuid.UUID      `gorm:"type:uuid" json:"-"`
+	CreatedAt   time.Time       `json:"createdAt"`
+	UpdatedAt   time.Time       `json:"updatedAt"`
+	CompletedAt *time.Time      `json:"completedAt,omitempty"`
+}
+
+func (BulkInviteJob) TableName() string {
+	return "tenant_bulk_invite_jobs"
+}
@@ -0,0 +1,73 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// GrantTypeDeviceCode is the device authorization grant (RFC 8628 section 3.4)
+const GrantTypeDeviceCode = "urn:ietf:params:oauth:grant-type:device_code"
+
+// Device code states
+const (
+	DeviceCodePending  = "pending"
+	DeviceCodeApproved = "approved"
+	DeviceCodeDenied   = "denied"
+	DeviceCodeConsumed = "consumed"
+)
+
+// DeviceCode is a pending device authorization. The device polls with the device code, which
+// is stored by SHA-256 hash only, while the user enters the user code on another device.
+type DeviceCode struct {
+	ID             uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	DeviceCodeHash string     `gorm:"type:varchar(64);unique;not null" json:"-"`
+	UserCode       string     `gorm:"type:varchar(8);unique;not null" json:"userCode"`
+	ClientID       string     `gorm:"type:varchar(64);not null" json:"clientId"`
+	Scope          string     `gorm:"type:text" json:"scope"`
+	Status         string     `gorm:"type:varchar(16);not null;default:'pending'" json:"status"`
+	UserID         *uuid.UUID `gorm:"type:uuid" json:"userId,omitempty"`
+	TenantID       *uuid.UUID `gorm:"type:uuid" json:"tenantId,omitempty"`
+	AuthTime       *time.Time `gorm:"type:timestamp" json:"authTime,omitempty"`
+	Interval       int        `gorm:"column:poll_interval;not null" json:"interval"`
+	LastPolledAt   *time.Time `gorm:"type:timestamp" json:"lastPolledAt,omitempty"`
+	ExpiresAt      time.Time  `gorm:"type:timestamp;not null" json:"expiresAt"`
+	CreatedAt      time.Time  `gorm:"type:timestamp;default:current_timestamp" json:"createdAt"`
+}
+
+func (DeviceCode) TableName() string {
+	return "oauth_device_codes"
+}
+
+// DeviceAuthorizationRequest starts the device flow (RFC 8628 section 3.1)
+type DeviceAuthorizationRequest struct {
+	ClientID     string `form:"client_id"`
+	ClientSecret string `form:"client_secret"`
+	Scope        string `form:"scope"`
+}
+
+// DeviceAuthorizationResponse tells the device what to show the user (RFC 8628 section 3.2)
+type DeviceAuthorizationResponse struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int    `json:"expires_in"`
+	Interval                int    `json:"interval"`
+}
+
+// DeviceVerification describes a pending device authorization to the user approving it
+type DeviceVerification struct {
+	UserCode   string `json:"userCode"`
+	ClientID   string `json:"clientId"`
+	ClientName string `json:"clientName"`
+	Scope      string `json:"scope"`
+}
+
+// DeviceDecision approves or denies a device. The tokens are issued for the chosen tenant,
+// which defaults to the client's tenant.
+type DeviceDecision struct {
+	UserCode string     `json:"userCode" binding:"required"`
+	TenantID *uuid.UUID `json:"tenantId"`
+	Approve  bool       `json:"approve"`
+}
@@ -0,0 +1,61 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Ways a tenant can prove it controls a domain
+const (
+	// DomainVerificationDNS is a TXT record on the domain
+	DomainVerificationDNS = "dns"
+	// DomainVerificationFile is a file served over HTTPS under /.well-known
+	DomainVerificationFile = "file"
+)
+
+// Domain verification states
+const (
+	DomainVerificationPending  = "pending"
+	DomainVerificationVerified = "verified"
+	// DomainVerificationLapsed means the proof was removed after the domain was verified
+	DomainVerificationLapsed = "lapsed"
+)
+
+// DomainVerification is a tenant's claim on a domain and the token proving it. Once
+// verified, the domain is the tenant's Domain and is checked again periodically.
+type DomainVerification struct {
+	ID        uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	TenantID  uuid.UUID `gorm:"type:uuid;not null" json:"tenantId"`
+	Domain    string    `gorm:"type:varchar(255);not null" json:"domain"`
+	Method    string    `gorm:"type:varchar(20);not null" json:"method"`
+	Token     string    `gorm:"type:text;not null" json:"token"`
+	Status    string    `gorm:"type:varchar(20);not null;default:'pending'" json:"status"`
+	ExpiresAt time.Time `gorm:"not null" json:"expiresAt"`
+	// VerifiedAt is when the token was last found on the domain
+	VerifiedAt    *time.Time `json:"verifiedAt,omitempty"`
+	LastCheckedAt *time.Time `json:"lastCheckedAt,omitempty"`
+	LastError     string     `gorm:"type:text" json:"lastError,omitempty"`
+	CreatedAt     time.Time  `json:"createdAt"`
+	UpdatedAt     time.Time  `json:"updatedAt"`
+
+	// Where the token must be published, filled in for responses
+	TXTRecord string `gorm:"-" json:"txtRecord,omitempty"`
+	FileURL   string `gorm:"-" json:"fileUrl,omitempty"`
+}
+
+func (DomainVerification) TableName() string {
+	return "domain_verifications"
+}
+
+// Expired reports whether a pending verification's token can no longer be used
+func (v *DomainVerification) Expired(now time.Time) bool {
+	return v.Status != DomainVerificationVerified && now.After(v.ExpiresAt)
+}
+
+// DomainVerificationRequest starts the verification of a domain
+type DomainVerificationRequest struct {
+	Domain string `json:"domain" binding:"required"`
+	// Method is dns (the default) or file
+	Method string `json:"method,omitempty"`
+}
@@ -0,0 +1,47 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Auto-join policies for users in a tenant's verified domain
+const (
+	// AutoJoinOff leaves membership to invitations and administrators
+	AutoJoinOff = "off"
+	// AutoJoinAuto adds users to the tenant with the tenant's auto-join role
+	AutoJoinAuto = "auto"
+	// AutoJoinRequest files a join request for an administrator to approve
+	AutoJoinRequest = "request"
+)
+
+// Join request states
+const (
+	JoinRequestPending  = "pending"
+	JoinRequestApproved = "approved"
+	JoinRequestDenied   = "denied"
+)
+
+// AutoJoinSettings is a tenant's auto-join policy
+type AutoJoinSettings struct {
+	Policy      string `json:"policy" binding:"required,oneof=off auto request"`
+	DefaultRole string `json:"defaultRole,omitempty"`
+}
+
+// JoinRequest asks a tenant's administrators to let a user in the tenant's domain join
+type JoinRequest struct {
+	ID         uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	TenantID   uuid.UUID  `gorm:"type:uuid;not null" json:"tenantId"`
+	UserID     uuid.UUID  `gorm:"type:uuid;not null" json:"userId"`
+	Status     string     `gorm:"type:varchar(20);not null;default:'pending'" json:"status"`
+	ReviewedBy *uuid.UUID `gorm:"type:uuid" json:"reviewedBy,omitempty"`
+	ReviewedAt *time.Time `json:"reviewedAt,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	UpdatedAt  time.Time  `json:"updatedAt"`
+	User       *User      `gorm:"foreignKey:UserID" json:"user,omitempty"`
+}
+
+func (JoinRequest) TableName() string {
+	return "tenant_join_requests"
+}
@@ -0,0 +1,89 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// LDAPProvider is the provider name recorded for LDAP logins
+const LDAPProvider = "ldap"
+
+// LDAPGroupRoles maps directory groups, by DN or CN, to the tenant roles their members get
+type LDAPGroupRoles map[string][]string
+
+// Scan implements the sql.Scanner interface for LDAPGroupRoles
+func (m *LDAPGroupRoles) Scan(value interface{}) error {
+	if value == nil {
+		*m = LDAPGroupRoles{}
+		return nil
+	}
+
+	bytes, ok := value.([]byte)
+	if !ok {
+		return json.Unmarshal([]byte(value.(string)), m)
+	}
+	return json.Unmarshal(bytes, m)
+}
+
+// Value implements the driver.Valuer interface for LDAPGroupRoles
+func (m LDAPGroupRoles) Value() (driver.Value, error) {
+	if m == nil {
+		return []byte("{}"), nil
+	}
+	return json.Marshal(m)
+}
+
+// LDAPConnection is a tenant's LDAP or Active Directory server, which password logins for the
+// tenant's domain are checked against instead of stored credentials
+type LDAPConnection struct {
+	ID       uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	TenantID uuid.UUID `gorm:"type:uuid;not null;unique" json:"tenantId"`
+	// URL is an ldap:// or ldaps:// URL
+	URL                string `gorm:"type:text;not null" json:"url"`
+	StartTLS           bool   `gorm:"default:false" json:"startTls"`
+	InsecureSkipVerify bool   `gorm:"default:false" json:"insecureSkipVerify"`
+	// RootCA is a PEM encoded CA certificate the server certificate is verified against
+	RootCA string `gorm:"column:root_ca;type:text" json:"rootCa,omitempty"`
+	// BindDN and its password are the service account users are searched with
+	BindDN                string `gorm:"column:bind_dn;type:text;not null" json:"bindDn"`
+	BindPasswordEncrypted []byte `gorm:"type:bytea" json:"-"`
+	UserSearchBase        string `gorm:"type:text;not null" json:"userSearchBase"`
+	// UserFilter finds the user logging in. {email} and {username}, the local part of the
+	// email, are replaced with the escaped login.
+	UserFilter     string `gorm:"type:text;not null" json:"userFilter"`
+	EmailAttribute string `gorm:"type:varchar(255);not null" json:"emailAttribute"`
+	NameAttribute  string `gorm:"type:varchar(255);not null" json:"nameAttribute"`
+	// GroupSearchBase enables searching groups with GroupFilter, in which {dn} is replaced with
+	// the user's DN. Without it, groups are read from the user's memberOf attribute.
+	GroupSearchBase string         `gorm:"type:text" json:"groupSearchBase,omitempty"`
+	GroupFilter     string         `gorm:"type:text" json:"groupFilter,omitempty"`
+	GroupRoles      LDAPGroupRoles `gorm:"type:jsonb" json:"groupRoles"`
+	Enabled         bool           `gorm:"default:true" json:"enabled"`
+	CreatedAt       time.Time      `json:"createdAt"`
+	UpdatedAt       time.Time      `json:"updatedAt"`
+}
+
+func (LDAPConnection) TableName() string {
+	return "ldap_connections"
+}
+
+// LDAPConnectionUpdate configures a tenant's LDAP server. The bind password is write-only.
+type LDAPConnectionUpdate struct {
+	URL                *string         `json:"url,omitempty"`
+	StartTLS           *bool           `json:"startTls,omitempty"`
+	InsecureSkipVerify *bool           `json:"insecureSkipVerify,omitempty"`
+	RootCA             *string         `json:"rootCa,omitempty"`
+	BindDN             *string         `json:"bindDn,omitempty"`
+	BindPassword       *string         `json:"bindPassword,omitempty"`
+	UserSearchBase     *string         `json:"userSearchBase,omitempty"`
+	UserFilter         *string         `json:"userFilter,omitempty"`
+	EmailAttribute     *string         `json:"emailAttribute,omitempty"`
+	NameAttribute      *string         `json:"nameAttribute,omitempty"`
+	GroupSearchBase    *string         `json:"groupSearchBase,omitempty"`
+	GroupFilter        *string         `json:"groupFilter,omitempty"`
+	GroupRoles         *LDAPGroupRoles `json:"groupRoles,omitempty"`
+	Enabled            *bool           `json:"enabled,omitempty"`
+}
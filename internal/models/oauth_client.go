@@ -0,0 +1,196 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// OpenID Connect scopes (OpenID Connect Core 1.0 section 5.4), and the groups scope adding the
+// names of the user's groups in the token's tenant to tokens
+const (
+	ScopeOpenID  = "openid"
+	ScopeProfile = "profile"
+	ScopeEmail   = "email"
+	ScopeGroups  = "groups"
+)
+
+// ScopeRelationsWrite lets a confidential client change its tenant's relationship namespaces
+// and tuples, rather than only check them
+const ScopeRelationsWrite = "relations:write"
+
+// OAuth 2.0 grant types supported by the authorization server
+const (
+	GrantTypeAuthorizationCode = "authorization_code"
+	GrantTypeRefreshToken      = "refresh_token"
+	GrantTypeClientCredentials = "client_credentials"
+	GrantTypeTokenExchange     = "urn:ietf:params:oauth:grant-type:token-exchange"
+)
+
+// OAuthClient is an application registered by a tenant to use this service as its authorization server
+type OAuthClient struct {
+	ID               uuid.UUID      `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	TenantID         uuid.UUID      `gorm:"type:uuid;not null" json:"tenantId"`
+	ClientID         string         `gorm:"type:varchar(64);unique;not null" json:"clientId"`
+	ClientSecretHash string         `gorm:"type:text" json:"-"`
+	Name             string         `gorm:"type:varchar(255);not null" json:"name"`
+	Public           bool           `gorm:"type:boolean;default:false" json:"public"`
+	RedirectURIs     pq.StringArray `gorm:"type:text[]" json:"redirectUris"`
+	AllowedScopes    pq.StringArray `gorm:"type:text[]" json:"allowedScopes"`
+	GrantTypes       pq.StringArray `gorm:"type:text[]" json:"grantTypes"`
+	// TokenExchangeAudiences are the services the client may exchange user tokens for
+	TokenExchangeAudiences pq.StringArray `gorm:"type:text[]" json:"tokenExchangeAudiences"`
+	// SigningAlgorithm signs the client's id_tokens. The deployment default is used when empty.
+	SigningAlgorithm string    `gorm:"type:varchar(10)" json:"signingAlgorithm,omitempty"`
+	CreatedAt        time.Time `gorm:"type:timestamp;default:current_timestamp" json:"createdAt"`
+	UpdatedAt        time.Time `gorm:"type:timestamp;default:current_timestamp" json:"updatedAt"`
+}
+
+func (OAuthClient) TableName() string {
+	return "oauth_clients"
+}
+
+// HasGrantType reports whether the client may use the given grant type
+func (c *OAuthClient) HasGrantType(grantType string) bool {
+	for _, g := range c.GrantTypes {
+		if g == grantType {
+			return true
+		}
+	}
+	return false
+}
+
+// OAuthClientCreate represents a client registration request
+type OAuthClientCreate struct {
+	Name                   string   `json:"name" binding:"required"`
+	Public                 bool     `json:"public"`
+	RedirectURIs           []string `json:"redirectUris"`
+	AllowedScopes          []string `json:"allowedScopes"`
+	GrantTypes             []string `json:"grantTypes"`
+	SigningAlgorithm       string   `json:"signingAlgorithm"`
+	TokenExchangeAudiences []string `json:"tokenExchangeAudiences"`
+}
+
+// OAuthClientUpdate represents the fields that can be updated on a client
+type OAuthClientUpdate struct {
+	Name                   *string   `json:"name,omitempty"`
+	RedirectURIs           *[]string `json:"redirectUris,omitempty"`
+	AllowedScopes          *[]string `json:"allowedScopes,omitempty"`
+	GrantTypes             *[]string `json:"grantTypes,omitempty"`
+	SigningAlgorithm       *string   `json:"signingAlgorithm,omitempty"`
+	TokenExchangeAudiences *[]string `json:"tokenExchangeAudiences,omitempty"`
+}
+
+// AuthorizationCode is a one-time code issued by /oauth/authorize
+type AuthorizationCode struct {
+	ID                  uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	CodeHash            string    `gorm:"type:varchar(64);unique;not null" json:"-"`
+	ClientID            string    `gorm:"type:varchar(64);not null" json:"clientId"`
+	UserID              uuid.UUID `gorm:"type:uuid;not null" json:"userId"`
+	TenantID            uuid.UUID `gorm:"type:uuid;not null" json:"tenantId"`
+	RedirectURI         string    `gorm:"type:text" json:"redirectUri"`
+	Scope               string    `gorm:"type:text" json:"scope"`
+	CodeChallenge       string    `gorm:"type:varchar(128)" json:"-"`
+	CodeChallengeMethod string    `gorm:"type:varchar(10)" json:"-"`
+	Nonce               string    `gorm:"type:varchar(255)" json:"-"`
+	AuthTime            time.Time `gorm:"type:timestamp" json:"authTime"`
+	Used                bool      `gorm:"type:boolean;not null;default:false" json:"used"`
+	ExpiresAt           time.Time `gorm:"type:timestamp;not null" json:"expiresAt"`
+	CreatedAt           time.Time `gorm:"type:timestamp;default:current_timestamp" json:"createdAt"`
+}
+
+func (AuthorizationCode) TableName() string {
+	return "oauth_authorization_codes"
+}
+
+// AuthorizeRequest holds the parameters of an authorization request (RFC 6749 section 4.1.1)
+type AuthorizeRequest struct {
+	ResponseType        string `form:"response_type" json:"response_type"`
+	ClientID            string `form:"client_id" json:"client_id"`
+	RedirectURI         string `form:"redirect_uri" json:"redirect_uri"`
+	Scope               string `form:"scope" json:"scope"`
+	State               string `form:"state" json:"state"`
+	CodeChallenge       string `form:"code_challenge" json:"code_challenge"`
+	CodeChallengeMethod string `form:"code_challenge_method" json:"code_challenge_method"`
+	Nonce               string `form:"nonce" json:"nonce"`
+}
+
+// OAuthTokenRequest holds the parameters of a token request (RFC 6749 sections 4.1.3, 4.4.2 and 6)
+type OAuthTokenRequest struct {
+	GrantType    string `form:"grant_type"`
+	Code         string `form:"code"`
+	RedirectURI  string `form:"redirect_uri"`
+	CodeVerifier string `form:"code_verifier"`
+	RefreshToken string `form:"refresh_token"`
+	DeviceCode   string `form:"device_code"`
+	// Token exchange parameters (RFC 8693 section 2.1)
+	SubjectToken       string   `form:"subject_token"`
+	SubjectTokenType   string   `form:"subject_token_type"`
+	ActorToken         string   `form:"actor_token"`
+	RequestedTokenType string   `form:"requested_token_type"`
+	Audience           []string `form:"audience"`
+	Resource           []string `form:"resource"`
+	Scope              string   `form:"scope"`
+	ClientID           string   `form:"client_id"`
+	ClientSecret       string   `form:"client_secret"`
+}
+
+// OAuthTokenResponse is the successful token response (RFC 6749 section 5.1)
+type OAuthTokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+	IDToken      string `json:"id_token,omitempty"`
+	// IssuedTokenType is only set by token exchange (RFC 8693 section 2.2.1)
+	IssuedTokenType string `json:"issued_token_type,omitempty"`
+}
+
+// TokenActor is the act claim of a delegated token: the party acting on behalf of the
+// subject, and the actor before it when a delegated token was exchanged again (RFC 8693 section 4.1)
+type TokenActor struct {
+	Subject string      `json:"sub"`
+	Actor   *TokenActor `json:"act,omitempty"`
+}
+
+// TokenTypeAccessToken identifies access tokens in token exchange (RFC 8693 section 3)
+const TokenTypeAccessToken = "urn:ietf:params:oauth:token-type:access_token"
+
+// Token type hints (RFC 7009 section 2.1)
+const (
+	TokenTypeHintAccessToken  = "access_token"
+	TokenTypeHintRefreshToken = "refresh_token"
+)
+
+// IntrospectionRequest is a token introspection request (RFC 7662 section 2.1)
+type IntrospectionRequest struct {
+	Token         string `form:"token"`
+	TokenTypeHint string `form:"token_type_hint"`
+	ClientID      string `form:"client_id"`
+	ClientSecret  string `form:"client_secret"`
+}
+
+// IntrospectionResponse describes a token (RFC 7662 section 2.2). Inactive tokens only
+// have Active set.
+type IntrospectionResponse struct {
+	Active    bool        `json:"active"`
+	Scope     string      `json:"scope,omitempty"`
+	ClientID  string      `json:"client_id,omitempty"`
+	TokenType string      `json:"token_type,omitempty"`
+	Subject   string      `json:"sub,omitempty"`
+	Tenant    string      `json:"tenant,omitempty"`
+	ExpiresAt int64       `json:"exp,omitempty"`
+	IssuedAt  int64       `json:"iat,omitempty"`
+	Audience  []string    `json:"aud,omitempty"`
+	Actor     *TokenActor `json:"act,omitempty"`
+}
+
+// RevocationRequest is a token revocation request (RFC 7009 section 2.1)
+type RevocationRequest struct {
+	Token         string `form:"token"`
+	TokenTypeHint string `form:"token_type_hint"`
+	ClientID      string `form:"client_id"`
+	ClientSecret  string `form:"client_secret"`
+}
@@ -0,0 +1,135 @@
+package models
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Permissions a tenant membership can grant, named resource:action. PermissionAll grants
+// every permission and "resource:*" every action on a resource.
+const (
+	PermissionAll = "*"
+
+	PermTenantRead     = "tenant:read"
+	PermTenantUpdate   = "tenant:update"
+	PermTenantDelete   = "tenant:delete"
+	PermTenantBilling  = "tenant:billing"
+	PermMembersRead    = "members:read"
+	PermMembersInvite  = "members:invite"
+	PermMembersManage  = "members:manage"
+	PermRolesManage    = "roles:manage"
+	PermSSOManage      = "sso:manage"
+	PermClientsManage  = "clients:manage"
+	PermAPIKeysManage  = "apikeys:manage"
+	PermSecurityRead   = "security:read"
+	PermSecurityManage = "security:manage"
+	PermAuditRead      = "audit:read"
+)
+
+var permissionPattern = regexp.MustCompile(`^[a-z][a-z0-9_-]*:([a-z][a-z0-9_.-]*|\*)$`)
+
+// Permissions lists the permissions this service checks
+var Permissions = []string{
+	PermTenantRead,
+	PermTenantUpdate,
+	PermTenantDelete,
+	PermTenantBilling,
+	PermMembersRead,
+	PermMembersInvite,
+	PermMembersManage,
+	PermRolesManage,
+	PermSSOManage,
+	PermClientsManage,
+	PermAPIKeysManage,
+	PermSecurityRead,
+	PermSecurityManage,
+	PermAuditRead,
+}
+
+// Built-in tenant roles, distinct from the platform-wide user roles
+const (
+	TenantRoleOwner  = "owner"
+	TenantRoleAdmin  = "admin"
+	TenantRoleMember = "member"
+	TenantRoleViewer = "viewer"
+)
+
+// BuiltinRoles maps the built-in tenant roles to the permissions they grant
+var BuiltinRoles = map[string][]string{
+	TenantRoleOwner: {PermissionAll},
+	TenantRoleAdmin: {
+		PermTenantRead,
+		PermTenantUpdate,
+		"members:*",
+		PermRolesManage,
+		PermSSOManage,
+		PermClientsManage,
+		PermAPIKeysManage,
+		"security:*",
+		PermAuditRead,
+	},
+	TenantRoleMember: {PermTenantRead, PermMembersRead},
+	TenantRoleViewer: {PermTenantRead},
+}
+
+// EffectivePermissions returns the permissions granted by a membership's roles, built in or
+// among the tenant's custom roles, together with those granted to it directly. Unknown roles
+// grant nothing.
+func EffectivePermissions(roles, permissions []string, customRoles []TenantRole) []string {
+	seen := make(map[string]bool)
+	var effective []string
+	grant := func(permission string) {
+		if !seen[permission] {
+			seen[permission] = true
+			effective = append(effective, permission)
+		}
+	}
+	for _, role := range roles {
+		for _, permission := range BuiltinRoles[role] {
+			grant(permission)
+		}
+		for _, custom := range customRoles {
+			if custom.Name == role {
+				for _, permission := range custom.Permissions {
+					grant(permission)
+				}
+			}
+		}
+	}
+	for _, permission := range permissions {
+		grant(permission)
+	}
+	return effective
+}
+
+// HasPermission reports whether granted includes permission, directly or through a wildcard
+func HasPermission(granted []string, permission string) bool {
+	resource, _, _ := strings.Cut(permission, ":")
+	for _, p := range granted {
+		if p == PermissionAll || p == permission || p == resource+":*" {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidPermission reports whether permission is well formed. Permissions on this service's own
+// resources must be among Permissions or a wildcard over them; other services are free to name
+// their own.
+func ValidPermission(permission string) bool {
+	if permission == PermissionAll {
+		return true
+	}
+	if !permissionPattern.MatchString(permission) {
+		return false
+	}
+	resource, action, _ := strings.Cut(permission, ":")
+	own := false
+	for _, known := range Permissions {
+		if known == permission {
+			return true
+		}
+		own = own || strings.HasPrefix(known, resource+":")
+	}
+	return !own || action == "*"
+}
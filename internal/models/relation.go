@@ -0,0 +1,212 @@
+package models
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	relationNamePattern     = regexp.MustCompile(`^[a-z][a-z0-9_]{0,63}$`)
+	relationObjectIDPattern = regexp.MustCompile(`^[A-Za-z0-9_.:/|+=-]{1,255}$`)
+)
+
+// RelationNamespace configures the relations objects of a namespace, such as doc, have to their
+// subjects. Users have a relation through tuples naming them, or through the relation's rewrite.
+type RelationNamespace struct {
+	ID        uuid.UUID                  `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	TenantID  uuid.UUID                  `gorm:"type:uuid;not null" json:"tenantId"`
+	Name      string                     `gorm:"type:varchar(64);not null" json:"name"`
+	Relations map[string]RelationRewrite `gorm:"type:jsonb;serializer:json" json:"relations"`
+	CreatedAt *time.Time                 `json:"createdAt,omitempty"`
+	UpdatedAt *time.Time                 `json:"updatedAt,omitempty"`
+}
+
+func (RelationNamespace) TableName() string {
+	return "relation_namespaces"
+}
+
+// RelationRewrite gives a relation to more subjects than its tuples name. Subjects have the
+// relation if any of the tuples or rules give it to them.
+type RelationRewrite struct {
+	// ComputedUsersets gives the relation to subjects with the named relations to the same
+	// object, such as editors being viewers
+	ComputedUsersets []string `json:"computedUsersets,omitempty"`
+	// TupleToUsersets gives the relation to subjects with a relation to the objects this object
+	// is related to, such as viewers of a document's parent folder being viewers of the document
+	TupleToUsersets []TupleToUserset `json:"tupleToUsersets,omitempty"`
+}
+
+// TupleToUserset follows the Tupleset relation of an object to other objects and gives their
+// ComputedUserset relation's subjects the relation being rewritten
+type TupleToUserset struct {
+	Tupleset        string `json:"tupleset"`
+	ComputedUserset string `json:"computedUserset"`
+}
+
+// RelationNamespaceRequest configures a namespace
+type RelationNamespaceRequest struct {
+	Relations map[string]RelationRewrite `json:"relations" binding:"required"`
+}
+
+// RelationTuple stores that the subject has the relation to the object. Tuples are kept after
+// they are deleted so that past revisions can be read.
+type RelationTuple struct {
+	ID               uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	TenantID         uuid.UUID `gorm:"type:uuid;not null"`
+	Namespace        string    `gorm:"type:varchar(64);not null"`
+	ObjectID         string    `gorm:"type:varchar(255);not null"`
+	Relation         string    `gorm:"type:varchar(64);not null"`
+	SubjectNamespace string    `gorm:"type:varchar(64);not null"`
+	SubjectObjectID  string    `gorm:"type:varchar(255);not null"`
+	SubjectRelation  string    `gorm:"type:varchar(64);not null;default:''"`
+	CreatedRevision  int64     `gorm:"not null"`
+	DeletedRevision  *int64
+	CreatedAt        *time.Time
+	DeletedAt        *time.Time
+}
+
+func (RelationTuple) TableName() string {
+	return "relation_tuples"
+}
+
+// Object returns the object of the tuple
+func (t *RelationTuple) Object() RelationSubject {
+	return RelationSubject{Namespace: t.Namespace, ObjectID: t.ObjectID}
+}
+
+// Subject returns the subject of the tuple
+func (t *RelationTuple) Subject() RelationSubject {
+	return RelationSubject{Namespace: t.SubjectNamespace, ObjectID: t.SubjectObjectID, Relation: t.SubjectRelation}
+}
+
+// Relationship returns the tuple as the API describes it
+func (t *RelationTuple) Relationship() Relationship {
+	return Relationship{
+		Object:   t.Object().String(),
+		Relation: t.Relation,
+		Subject:  t.Subject().String(),
+	}
+}
+
+// RelationSubject is an object, such as user:abc, or with a relation the set of subjects having
+// that relation to the object, such as group:eng#member
+type RelationSubject struct {
+	Namespace string
+	ObjectID  string
+	Relation  string
+}
+
+// ParseRelationSubject parses namespace:id or namespace:id#relation
+func ParseRelationSubject(s string) (RelationSubject, bool) {
+	object, relation, hasRelation := strings.Cut(s, "#")
+	namespace, id, ok := strings.Cut(object, ":")
+	subject := RelationSubject{Namespace: namespace, ObjectID: id, Relation: relation}
+	if !ok || !ValidRelationName(namespace) || !relationObjectIDPattern.MatchString(id) {
+		return subject, false
+	}
+	if hasRelation && !ValidRelationName(relation) {
+		return subject, false
+	}
+	return subject, true
+}
+
+// ParseRelationObject parses namespace:id
+func ParseRelationObject(s string) (RelationSubject, bool) {
+	object, ok := ParseRelationSubject(s)
+	return object, ok && object.Relation == ""
+}
+
+func (s RelationSubject) String() string {
+	if s.Relation == "" {
+		return s.Namespace + ":" + s.ObjectID
+	}
+	return s.Namespace + ":" + s.ObjectID + "#" + s.Relation
+}
+
+// ValidRelationName reports whether name is a valid namespace or relation name
+func ValidRelationName(name string) bool {
+	return relationNamePattern.MatchString(name)
+}
+
+// Relationship is a relation tuple as the API describes it: object#relation@subject, such as
+// doc:123#viewer@user:abc or doc:123#viewer@group:eng#member
+type Relationship struct {
+	Object   string `json:"object" binding:"required"`
+	Relation string `json:"relation" binding:"required"`
+	Subject  string `json:"subject" binding:"required"`
+}
+
+func (r Relationship) String() string {
+	return r.Object + "#" + r.Relation + "@" + r.Subject
+}
+
+// RelationConsistency chooses the revision a request is evaluated at. By default, and with
+// AtLeastAsFresh, it is the latest revision; AtExactSnapshot evaluates at the token's revision.
+type RelationConsistency struct {
+	AtLeastAsFresh  string `json:"atLeastAsFresh,omitempty" form:"atLeastAsFresh"`
+	AtExactSnapshot string `json:"atExactSnapshot,omitempty" form:"atExactSnapshot"`
+}
+
+// RelationWrite adds and deletes tuples in one revision. Deletes are applied first.
+type RelationWrite struct {
+	Writes  []Relationship `json:"writes" binding:"max=100,dive"`
+	Deletes []Relationship `json:"deletes" binding:"max=100,dive"`
+}
+
+// RelationFilter selects tuples. Empty fields match anything.
+type RelationFilter struct {
+	Namespace string `form:"namespace"`
+	Object    string `form:"object"`
+	Relation  string `form:"relation"`
+	Subject   string `form:"subject"`
+}
+
+// RelationCheck asks whether the subject has the relation to the object
+type RelationCheck struct {
+	Object      string              `json:"object" binding:"required"`
+	Relation    string              `json:"relation" binding:"required"`
+	Subject     string              `json:"subject" binding:"required"`
+	Consistency RelationConsistency `json:"consistency"`
+}
+
+// RelationExpand asks for the subjects with the relation to the object
+type RelationExpand struct {
+	Object      string              `json:"object" binding:"required"`
+	Relation    string              `json:"relation" binding:"required"`
+	Consistency RelationConsistency `json:"consistency"`
+}
+
+// RelationListObjects asks for the objects of a namespace the subject has the relation to
+type RelationListObjects struct {
+	Namespace   string              `json:"namespace" binding:"required"`
+	Relation    string              `json:"relation" binding:"required"`
+	Subject     string              `json:"subject" binding:"required"`
+	Consistency RelationConsistency `json:"consistency"`
+}
+
+// RelationTree expands the subjects with a relation to an object. Subjects are those named by
+// tuples, including sets of subjects such as group:eng#member, and those reached through
+// tuple-to-userset rules, such as folder:1#viewer; each can be expanded in turn. Children
+// expand the computed usersets.
+type RelationTree struct {
+	Object   string         `json:"object"`
+	Relation string         `json:"relation"`
+	Subjects []string       `json:"subjects"`
+	Children []RelationTree `json:"children,omitempty"`
+}
+
+// RelationRevision is the latest revision of a tenant's tuples, and the oldest revision they can
+// still be read at
+type RelationRevision struct {
+	TenantID       uuid.UUID `gorm:"type:uuid;primaryKey"`
+	Revision       int64     `gorm:"not null"`
+	OldestRevision int64     `gorm:"not null"`
+	UpdatedAt      *time.Time
+}
+
+func (RelationRevision) TableName() string {
+	return "relation_revisions"
+}
@@ -0,0 +1,67 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SAMLProvider is the provider name recorded for SAML logins
+const SAMLProvider = "saml"
+
+// SAMLAttributeMapping names the assertion attributes that hold user fields. An empty
+// email attribute means the email is taken from the assertion's NameID.
+type SAMLAttributeMapping struct {
+	Email string `json:"email,omitempty"`
+	Name  string `json:"name,omitempty"`
+}
+
+// Scan implements the sql.Scanner interface for SAMLAttributeMapping
+func (m *SAMLAttributeMapping) Scan(value interface{}) error {
+	if value == nil {
+		*m = SAMLAttributeMapping{}
+		return nil
+	}
+
+	bytes, ok := value.([]byte)
+	if !ok {
+		return json.Unmarshal([]byte(value.(string)), m)
+	}
+	return json.Unmarshal(bytes, m)
+}
+
+// Value implements the driver.Valuer interface for SAMLAttributeMapping
+func (m SAMLAttributeMapping) Value() (driver.Value, error) {
+	return json.Marshal(m)
+}
+
+// SAMLConnection is a tenant's SAML 2.0 identity provider, for which this service acts as
+// the service provider
+type SAMLConnection struct {
+	ID                uuid.UUID            `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	TenantID          uuid.UUID            `gorm:"type:uuid;not null;unique" json:"tenantId"`
+	IdPEntityID       string               `gorm:"column:idp_entity_id;type:text;not null" json:"idpEntityId"`
+	IdPMetadataURL    string               `gorm:"column:idp_metadata_url;type:text" json:"idpMetadataUrl,omitempty"`
+	IdPMetadata       string               `gorm:"column:idp_metadata;type:text;not null" json:"idpMetadata"`
+	AttributeMapping  SAMLAttributeMapping `gorm:"type:jsonb" json:"attributeMapping"`
+	AllowIdPInitiated bool                 `gorm:"column:allow_idp_initiated;default:false" json:"allowIdpInitiated"`
+	Enabled           bool                 `gorm:"default:true" json:"enabled"`
+	CreatedAt         time.Time            `json:"createdAt"`
+	UpdatedAt         time.Time            `json:"updatedAt"`
+}
+
+func (SAMLConnection) TableName() string {
+	return "saml_connections"
+}
+
+// SAMLConnectionUpdate configures a tenant's identity provider. The IdP metadata is given
+// either as XML or as a URL it is fetched from.
+type SAMLConnectionUpdate struct {
+	IdPMetadataURL    *string               `json:"idpMetadataUrl,omitempty"`
+	IdPMetadata       *string               `json:"idpMetadata,omitempty"`
+	AttributeMapping  *SAMLAttributeMapping `json:"attributeMapping,omitempty"`
+	AllowIdPInitiated *bool                 `json:"allowIdpInitiated,omitempty"`
+	Enabled           *bool                 `json:"enabled,omitempty"`
+}
@@ -0,0 +1,47 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// SCIMToken is a bearer token a tenant's identity provider uses to provision users over SCIM.
+// Roles are the roles it may give users, fixed when it is issued.
+type SCIMToken struct {
+	ID         uuid.UUID      `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	TenantID   uuid.UUID      `gorm:"type:uuid;not null;index" json:"tenantId"`
+	Name       string         `gorm:"type:varchar(255);not null" json:"name"`
+	TokenHash  string         `gorm:"type:text;unique;not null" json:"-"`
+	Roles      pq.StringArray `gorm:"type:text[];not null" json:"roles"`
+	LastUsedAt *time.Time     `json:"lastUsedAt,omitempty"`
+	RevokedAt  *time.Time     `json:"revokedAt,omitempty"`
+	CreatedAt  time.Time      `json:"createdAt"`
+}
+
+func (SCIMToken) TableName() string {
+	return "scim_tokens"
+}
+
+// SCIMTokenCreate names a new SCIM token. Roles defaults to every role the creator can grant
+// except owner.
+type SCIMTokenCreate struct {
+	Name  string   `json:"name" binding:"required"`
+	Roles []string `json:"roles"`
+}
+
+// SCIMGroup is a group provisioned by a tenant's identity provider. Members of the group hold
+// its display name as a role in the tenant.
+type SCIMGroup struct {
+	ID          uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	TenantID    uuid.UUID `gorm:"type:uuid;not null" json:"tenantId"`
+	DisplayName string    `gorm:"type:varchar(255);not null" json:"displayName"`
+	ExternalID  string    `gorm:"type:text" json:"externalId,omitempty"`
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+func (SCIMGroup) TableName() string {
+	return "scim_groups"
+}
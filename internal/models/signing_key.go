@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// SigningKey is a token signing key shared by all instances. The private key is encrypted.
+type SigningKey struct {
+	KeyID               string     `gorm:"type:varchar(64);primaryKey;column:kid" json:"kid"`
+	Algorithm           string     `gorm:"type:varchar(10);not null;default:'RS256'" json:"alg"`
+	EncryptedPrivateKey []byte     `gorm:"type:bytea;not null" json:"-"`
+	CreatedAt           time.Time  `gorm:"type:timestamp;not null" json:"createdAt"`
+	ActivatesAt         time.Time  `gorm:"type:timestamp;not null" json:"activatesAt"`
+	ExpiresAt           time.Time  `gorm:"type:timestamp;not null" json:"expiresAt"`
+	RevokedAt           *time.Time `gorm:"type:timestamp" json:"revokedAt,omitempty"`
+}
+
+func (SigningKey) TableName() string {
+	return "signing_keys"
+}
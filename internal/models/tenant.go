@@ -6,10 +6,15 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 type TenantType string
 
+// EnforceSSOSetting is the tenant setting that turns off password login for users in the
+// tenant's verified domain
+const EnforceSSOSetting = "enforceSso"
+
 const (
 	PersonalTenant   TenantType = "personal"
 	TeamTenant       TenantType = "team"
@@ -64,8 +69,13 @@ type Tenant struct {
 	SubscriptionPlan      string          `gorm:"type:varchar(50)" json:"subscriptionPlan,omitempty"`
 	SubscriptionExpiresAt *time.Time      `gorm:"type:timestamp" json:"subscriptionExpiresAt,omitempty"`
 	UsageStats            json.RawMessage `gorm:"type:jsonb" json:"usageStats,omitempty"`
-	CreatedAt             time.Time       `gorm:"type:timestamp;default:current_timestamp"`
-	UpdatedAt             time.Time       `gorm:"type:timestamp;default:current_timestamp on update current_timestamp"`
+	AllowedRedirectURIs   pq.StringArray  `gorm:"type:text[]" json:"allowedRedirectUris"`
+	// AutoJoinPolicy decides what happens when a user with an email in the verified domain
+	// signs up or signs in
+	AutoJoinPolicy string    `gorm:"type:varchar(20);not null;default:'off'" json:"autoJoinPolicy"`
+	AutoJoinRole   string    `gorm:"type:varchar(50);not null;default:'member'" json:"autoJoinRole"`
+	CreatedAt      time.Time `gorm:"type:timestamp;default:current_timestamp"`
+	UpdatedAt      time.Time `gorm:"type:timestamp;default:current_timestamp on update current_timestamp"`
 }
 
 // TenantUpdate represents the fields that can be updated in a tenant
@@ -82,6 +92,7 @@ type TenantUpdate struct {
 	SubscriptionStatus    *string          `json:"subscriptionStatus,omitempty"`
 	SubscriptionPlan      *string          `json:"subscriptionPlan,omitempty"`
 	SubscriptionExpiresAt *time.Time       `json:"subscriptionExpiresAt,omitempty"`
+	AllowedRedirectURIs   *[]string        `json:"allowedRedirectUris,omitempty"`
 }
 
 // TenantUpgrade represents a tenant subscription upgrade request
@@ -90,6 +101,14 @@ type TenantUpgrade struct {
 	ExpiresAt *time.Time `json:"expires_at,omitempty"`
 }
 
+// Invitation states. Pending invitations past their expiry are reported as expired.
+const (
+	InvitePending  = "pending"
+	InviteAccepted = "accepted"
+	InviteDeclined = "declined"
+	InviteExpired  = "expired"
+)
+
 // TenantInvite represents an invitation to join a tenant
 type TenantInvite struct {
 	ID        uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
@@ -97,9 +116,58 @@ type TenantInvite struct {
 	Email     string     `gorm:"type:varchar(255);not null" json:"email"`
 	Role      string     `gorm:"type:varchar(50);not null" json:"role"`
 	Status    string     `gorm:"type:varchar(50);not null;default:'pending'" json:"status"`
-	ExpiresAt *time.Time `gorm:"type:timestamp" json:"expiresAt,omitempty"`
-	CreatedAt time.Time  `gorm:"type:timestamp;default:current_timestamp" json:"createdAt"`
-	UpdatedAt time.Time  `gorm:"type:timestamp;default:current_timestamp" json:"updatedAt"`
+	InvitedBy *uuid.UUID `gorm:"type:uuid" json:"invitedBy,omitempty"`
+	// GroupName is the tenant group, matched ignoring case, the user is put in once they accept
+	GroupName string `gorm:"type:varchar(255)" json:"group,omitempty"`
+	// TokenNonce is part of the signed invite token. Resending replaces it, so earlier
+	// links stop working.
+	TokenNonce  string     `gorm:"type:varchar(64);not null" json:"-"`
+	SendCount   int        `gorm:"not null;default:0" json:"sendCount"`
+	LastSentAt  *time.Time `gorm:"type:timestamp" json:"lastSentAt,omitempty"`
+	AcceptedBy  *uuid.UUID `gorm:"type:uuid" json:"acceptedBy,omitempty"`
+	RespondedAt *time.Time `gorm:"type:timestamp" json:"respondedAt,omitempty"`
+	ExpiresAt   *time.Time `gorm:"type:timestamp" json:"expiresAt,omitempty"`
+	CreatedAt   time.Time  `gorm:"type:timestamp;default:current_timestamp" json:"createdAt"`
+	UpdatedAt   time.Time  `gorm:"type:timestamp;default:current_timestamp" json:"updatedAt"`
+}
+
+func (TenantInvite) TableName() string {
+	return "tenant_invites"
+}
+
+// Expired reports whether a pending invitation can no longer be accepted
+func (i *TenantInvite) Expired(now time.Time) bool {
+	return i.Status == InvitePending && i.ExpiresAt != nil && now.After(*i.ExpiresAt)
+}
+
+// TenantInviteRequest invites an email address to a tenant. Role defaults to member.
+type TenantInviteRequest struct {
+	Email string `json:"email" binding:"required,email"`
+	Role  string `json:"role"`
+	Group string `json:"group,omitempty"`
+}
+
+// InviteToken identifies an invitation by the token from its email
+type InviteToken struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// InviteSignup creates an account for the invited email and accepts the invitation
+type InviteSignup struct {
+	Token    string `json:"token" binding:"required"`
+	Name     string `json:"name" binding:"required"`
+	Password string `json:"password" binding:"required,min=8"`
+}
+
+// InvitationDetails describes an invitation to its recipient. AccountExists tells whether
+// to sign in and accept or to sign up.
+type InvitationDetails struct {
+	TenantID      uuid.UUID  `json:"tenantId"`
+	TenantName    string     `json:"tenantName"`
+	Email         string     `json:"email"`
+	Role          string     `json:"role"`
+	ExpiresAt     *time.Time `json:"expiresAt,omitempty"`
+	AccountExists bool       `json:"accountExists"`
 }
 
 type TenantFeatures struct {
@@ -175,6 +243,12 @@ func (t *Tenant) GetSettings() map[string]interface{} {
 	return settings
 }
 
+// EnforcesSSO reports whether users in the tenant's domain must sign in with single sign-on
+func (t *Tenant) EnforcesSSO() bool {
+	enforced, _ := t.GetSettings()[EnforceSSOSetting].(bool)
+	return enforced
+}
+
 func (t *Tenant) UpdateSettings(settings map[string]interface{}) error {
 	settingsJSON, err := json.Marshal(settings)
 	if err != nil {
@@ -0,0 +1,92 @@
+package models
+
+import (
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// TenantGroup is a group of a tenant's members. Members of the group, directly or through the
+// groups nested in it, hold its roles.
+type TenantGroup struct {
+	ID          uuid.UUID      `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	TenantID    uuid.UUID      `gorm:"type:uuid;not null" json:"tenantId"`
+	Name        string         `gorm:"type:varchar(255);not null" json:"name"`
+	Description string         `gorm:"type:text" json:"description"`
+	Roles       pq.StringArray `gorm:"type:text[]" json:"roles"`
+	CreatedAt   *time.Time     `json:"createdAt,omitempty"`
+	UpdatedAt   *time.Time     `json:"updatedAt,omitempty"`
+}
+
+func (TenantGroup) TableName() string {
+	return "tenant_groups"
+}
+
+// TenantGroupMember puts a user, or another group of the tenant, in a group
+type TenantGroupMember struct {
+	ID            uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	GroupID       uuid.UUID  `gorm:"type:uuid;not null"`
+	UserID        *uuid.UUID `gorm:"type:uuid"`
+	MemberGroupID *uuid.UUID `gorm:"type:uuid"`
+	CreatedAt     *time.Time
+}
+
+func (TenantGroupMember) TableName() string {
+	return "tenant_group_members"
+}
+
+// TenantGroupRequest creates a group
+type TenantGroupRequest struct {
+	Name        string   `json:"name" binding:"required"`
+	Description string   `json:"description"`
+	Roles       []string `json:"roles"`
+}
+
+// TenantGroupUpdate changes a group. A group's name cannot change, since invitations name
+// the group their users join.
+type TenantGroupUpdate struct {
+	Description *string  `json:"description,omitempty"`
+	Roles       []string `json:"roles,omitempty"`
+}
+
+// TenantGroupDetails is a group with its members
+type TenantGroupDetails struct {
+	TenantGroup
+	Users  []*User        `json:"users"`
+	Groups []*TenantGroup `json:"groups"`
+}
+
+// MemberGroup is a group a user is in, directly or through a group nested in it
+type MemberGroup struct {
+	UserID   uuid.UUID      `json:"-"`
+	TenantID uuid.UUID      `json:"-"`
+	ID       uuid.UUID      `json:"id"`
+	Name     string         `json:"name"`
+	Roles    pq.StringArray `gorm:"type:text[]" json:"-"`
+	// Direct is false for groups the user is only in through a nested group
+	Direct bool `json:"direct"`
+}
+
+// TenantMember is a member of a tenant with the groups they are in
+type TenantMember struct {
+	*User
+	Groups []MemberGroup `json:"groups"`
+}
+
+// GroupRoles returns the roles the groups give their members in the tenant
+func GroupRoles(groups []MemberGroup, tenantID uuid.UUID) []string {
+	var roles []string
+	for _, group := range groups {
+		if group.TenantID != tenantID {
+			continue
+		}
+		for _, role := range group.Roles {
+			if !slices.Contains(roles, role) {
+				roles = append(roles, role)
+			}
+		}
+	}
+	return roles
+}
@@ -0,0 +1,59 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// TenantRole is a role a tenant defines in addition to the built-in roles. Members hold it by
+// name in their roles, like a built-in role.
+type TenantRole struct {
+	ID          uuid.UUID       `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id,omitempty"`
+	TenantID    uuid.UUID       `gorm:"type:uuid;not null" json:"tenantId,omitempty"`
+	Name        string          `gorm:"type:varchar(50);not null" json:"name"`
+	Description string          `gorm:"type:text" json:"description"`
+	Permissions pq.StringArray  `gorm:"type:text[]" json:"permissions"`
+	Conditions  *RoleConditions `gorm:"type:jsonb;serializer:json" json:"conditions,omitempty"`
+	BuiltIn     bool            `gorm:"-" json:"builtIn"`
+	CreatedAt   *time.Time      `json:"createdAt,omitempty"`
+	UpdatedAt   *time.Time      `json:"updatedAt,omitempty"`
+}
+
+func (TenantRole) TableName() string {
+	return "tenant_roles"
+}
+
+// RoleConditions limit where a custom role's permissions apply. They are evaluated by
+// authorization checks, which know the resource and request; on this service's own endpoints a
+// role with conditions grants nothing.
+type RoleConditions struct {
+	// ResourceOwner limits the role to resources the user owns
+	ResourceOwner bool `json:"resourceOwner,omitempty"`
+	// Plans limits the role to tenants with an active subscription to one of the plans
+	Plans []string `json:"plans,omitempty"`
+	// IPRanges limits the role to requests from the addresses, given as CIDRs or single IPs
+	IPRanges []string `json:"ipRanges,omitempty"`
+}
+
+// Empty reports whether the conditions leave the role unrestricted
+func (c *RoleConditions) Empty() bool {
+	return c == nil || (!c.ResourceOwner && len(c.Plans) == 0 && len(c.IPRanges) == 0)
+}
+
+// TenantRoleRequest creates a custom role
+type TenantRoleRequest struct {
+	Name        string          `json:"name" binding:"required"`
+	Description string          `json:"description"`
+	Permissions []string        `json:"permissions" binding:"required"`
+	Conditions  *RoleConditions `json:"conditions,omitempty"`
+}
+
+// TenantRoleUpdate changes a custom role. A role's name cannot change, since members hold it
+// by name.
+type TenantRoleUpdate struct {
+	Description *string         `json:"description,omitempty"`
+	Permissions []string        `json:"permissions,omitempty"`
+	Conditions  *RoleConditions `json:"conditions,omitempty"`
+}
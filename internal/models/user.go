@@ -7,17 +7,24 @@ import (
 	"github.com/google/uuid"
 )
 
+// Platform-wide user roles, distinct from a user's roles within a tenant
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
 type User struct {
-	ID          uuid.UUID       `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
-	Email       string          `gorm:"type:varchar(255);unique;not null" json:"email"`
-	Name        string          `gorm:"type:varchar(255);not null" json:"name"`
-	Status      string          `gorm:"type:varchar(50);not null;default:'active'" json:"status"`
-	Role        string          `gorm:"type:varchar(50);not null;default:'user'" json:"role"`
-	Settings    json.RawMessage `gorm:"type:jsonb" json:"settings,omitempty"`
-	MFAEnabled  bool            `json:"mfaEnabled" gorm:"default:false"`
-	LastLoginAt time.Time       `json:"lastLoginAt"`
-	CreatedAt   time.Time       `gorm:"type:timestamp;default:current_timestamp"`
-	UpdatedAt   time.Time       `gorm:"type:timestamp;default:current_timestamp on update current_timestamp"`
+	ID            uuid.UUID       `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	Email         string          `gorm:"type:varchar(255);unique;not null" json:"email"`
+	EmailVerified bool            `gorm:"default:false" json:"emailVerified"`
+	Name          string          `gorm:"type:varchar(255);not null" json:"name"`
+	Status        string          `gorm:"type:varchar(50);not null;default:'active'" json:"status"`
+	Role          string          `gorm:"type:varchar(50);not null;default:'user'" json:"role"`
+	Settings      json.RawMessage `gorm:"type:jsonb" json:"settings,omitempty"`
+	MFAEnabled    bool            `json:"mfaEnabled" gorm:"default:false"`
+	LastLoginAt   time.Time       `json:"lastLoginAt"`
+	CreatedAt     time.Time       `gorm:"type:timestamp;default:current_timestamp"`
+	UpdatedAt     time.Time       `gorm:"type:timestamp;default:current_timestamp on update current_timestamp"`
 }
 
 type UserUpdate struct {
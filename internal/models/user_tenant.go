@@ -13,10 +13,13 @@ type UserTenantAccess struct {
 	TenantID    uuid.UUID      `gorm:"type:uuid;not null" json:"tenantId"`
 	Roles       pq.StringArray `gorm:"type:text[]" json:"roles"`
 	Permissions pq.StringArray `gorm:"type:text[]" json:"permissions"`
-	CreatedAt   time.Time      `gorm:"type:timestamp;default:current_timestamp"`
-	UpdatedAt   time.Time      `gorm:"type:timestamp;default:current_timestamp on update current_timestamp"`
-	User        User           `gorm:"foreignKey:UserID" json:"user"`
-	Tenant      Tenant         `gorm:"foreignKey:TenantID" json:"tenant"`
+	// Active is false once the tenant's identity provider deprovisions the member
+	Active     bool      `gorm:"not null;default:true" json:"active"`
+	ExternalID string    `gorm:"type:text" json:"externalId,omitempty"`
+	CreatedAt  time.Time `gorm:"type:timestamp;default:current_timestamp"`
+	UpdatedAt  time.Time `gorm:"type:timestamp;default:current_timestamp on update current_timestamp"`
+	User       User      `gorm:"foreignKey:UserID" json:"user"`
+	Tenant     Tenant    `gorm:"foreignKey:TenantID" json:"tenant"`
 }
 
 func (UserTenantAccess) TableName() string {
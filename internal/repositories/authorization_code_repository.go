@@ -0,0 +1,44 @@
+package repositories
+
+import (
+	"identity-service/internal/models"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type AuthorizationCodeRepository interface {
+	CreateCode(code *models.AuthorizationCode) error
+	ConsumeCode(codeHash string) (*models.AuthorizationCode, error)
+}
+
+type authorizationCodeRepository struct {
+	db GormDB
+}
+
+func NewAuthorizationCodeRepository(db GormDB) AuthorizationCodeRepository {
+	return &authorizationCodeRepository{
+		db: db,
+	}
+}
+
+func (r *authorizationCodeRepository) CreateCode(code *models.AuthorizationCode) error {
+	return r.db.Create(code).Error
+}
+
+// ConsumeCode marks an unused, unexpired code as used and returns it in a single statement
+func (r *authorizationCodeRepository) ConsumeCode(codeHash string) (*models.AuthorizationCode, error) {
+	var code models.AuthorizationCode
+	result := r.db.Model(&code).
+		Clauses(clause.Returning{}).
+		Where("code_hash = ? AND used = ? AND expires_at > ?", codeHash, false, time.Now()).
+		Update("used", true)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &code, nil
+}
@@ -0,0 +1,88 @@
+package repositories
+
+import (
+	"identity-service/internal/models"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type BulkInviteRepository interface {
+	CreateJob(job *models.BulkInviteJob) error
+	GetJob(tenantID, id uuid.UUID) (*models.BulkInviteJob, error)
+	ListJobs(tenantID uuid.UUID) ([]*models.BulkInviteJob, error)
+	// ClaimStalledJob claims the oldest queued or running job not saved since updatedBefore
+	// for claimant, returning gorm.ErrRecordNotFound if there is none. Jobs being claimed by
+	// another replica are skipped, so each job is claimed once.
+	ClaimStalledJob(updatedBefore time.Time, claimant uuid.UUID) (*models.BulkInviteJob, error)
+	// SaveJob saves the job's progress, returning gorm.ErrRecordNotFound if another replica
+	// has since claimed it
+	SaveJob(job *models.BulkInviteJob) error
+}
+
+type bulkInviteRepository struct {
+	db GormDB
+}
+
+func NewBulkInviteRepository(db GormDB) BulkInviteRepository {
+	return &bulkInviteRepository{
+		db: db,
+	}
+}
+
+func (r *bulkInviteRepository) CreateJob(job *models.BulkInviteJob) error {
+	return r.db.Create(job).Error
+}
+
+func (r *bulkInviteRepository) GetJob(tenantID, id uuid.UUID) (*models.BulkInviteJob, error) {
+	var job models.BulkInviteJob
+	if err := r.db.First(&job, "id = ? AND tenant_id = ?", id, tenantID).Error; err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// ListJobs returns the tenant's jobs, newest first, without their per-row results
+func (r *bulkInviteRepository) ListJobs(tenantID uuid.UUID) ([]*models.BulkInviteJob, error) {
+	var jobs []*models.BulkInviteJob
+	err := r.db.Where("tenant_id = ?", tenantID).
+		Select("id", "tenant_id", "created_by", "status", "total", "processed", "invited", "skipped", "failed", "created_at", "updated_at", "completed_at").
+		Order("created_at DESC").
+		Find(&jobs).Error
+	return jobs, err
+}
+
+func (r *bulkInviteRepository) ClaimStalledJob(updatedBefore time.Time, claimant uuid.UUID) (*models.BulkInviteJob, error) {
+	var job models.BulkInviteJob
+	result := r.db.Raw(`
+		UPDATE tenant_bulk_invite_jobs SET claimed_by = ?, updated_at = ?
+		WHERE id = (
+			SELECT id FROM tenant_bulk_invite_jobs
+			WHERE status IN ? AND updated_at < ?
+			ORDER BY created_at
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING *`,
+		claimant, time.Now(), []string{models.BulkInviteQueued, models.BulkInviteRunning}, updatedBefore).
+		Scan(&job)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &job, nil
+}
+
+func (r *bulkInviteRepository) SaveJob(job *models.BulkInviteJob) error {
+	result := r.db.Model(job).Select("*").Where("claimed_by = ?", job.ClaimedBy).Updates(job)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package repositories
+
+import (
+	"identity-service/internal/models"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type DeviceCodeRepository interface {
+	CreateDeviceCode(code *models.DeviceCode) error
+	GetDeviceCodeByHash(deviceCodeHash string) (*models.DeviceCode, error)
+	GetPendingDeviceCode(userCode string) (*models.DeviceCode, error)
+	DecideDeviceCode(id uuid.UUID, status string, userID, tenantID uuid.UUID, authTime time.Time) error
+	RecordPoll(id uuid.UUID, polledAt time.Time, interval int) error
+	ConsumeDeviceCode(deviceCodeHash string) (*models.DeviceCode, error)
+	DeleteExpiredDeviceCodes(before time.Time) error
+}
+
+type deviceCodeRepository struct {
+	db GormDB
+}
+
+func NewDeviceCodeRepository(db GormDB) DeviceCodeRepository {
+	return &deviceCodeRepository{
+		db: db,
+	}
+}
+
+func (r *deviceCodeRepository) CreateDeviceCode(code *models.DeviceCode) error {
+	return r.db.Create(code).Error
+}
+
+func (r *deviceCodeRepository) GetDeviceCodeByHash(deviceCodeHash string) (*models.DeviceCode, error) {
+	var code models.DeviceCode
+	if err := r.db.First(&code, "device_code_hash = ?", deviceCodeHash).Error; err != nil {
+		return nil, err
+	}
+	return &code, nil
+}
+
+// GetPendingDeviceCode returns the unexpired, undecided authorization a user code belongs to
+func (r *deviceCodeRepository) GetPendingDeviceCode(userCode string) (*models.DeviceCode, error) {
+	var code models.DeviceCode
+	err := r.db.Where("user_code = ? AND status = ? AND expires_at > ?", userCode, models.DeviceCodePending, time.Now()).
+		First(&code).Error
+	if err != nil {
+		return nil, err
+	}
+	return &code, nil
+}
+
+// DecideDeviceCode records the user's decision, unless the code was already decided or expired
+func (r *deviceCodeRepository) DecideDeviceCode(id uuid.UUID, status string, userID, tenantID uuid.UUID, authTime time.Time) error {
+	result := r.db.Model(&models.DeviceCode{}).
+		Where("id = ? AND status = ? AND expires_at > ?", id, models.DeviceCodePending, time.Now()).
+		Updates(map[string]interface{}{
+			"status":    status,
+			"user_id":   userID,
+			"tenant_id": tenantID,
+			"auth_time": authTime,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// RecordPoll stores when the device last polled and the interval it must keep to
+func (r *deviceCodeRepository) RecordPoll(id uuid.UUID, polledAt time.Time, interval int) error {
+	return r.db.Model(&models.DeviceCode{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"last_polled_at": polledAt,
+		"poll_interval":  interval,
+	}).Error
+}
+
+// ConsumeDeviceCode marks an approved, unexpired code as consumed and returns it in a single statement
+func (r *deviceCodeRepository) ConsumeDeviceCode(deviceCodeHash string) (*models.DeviceCode, error) {
+	var code models.DeviceCode
+	result := r.db.Model(&code).
+		Clauses(clause.Returning{}).
+		Where("device_code_hash = ? AND status = ? AND expires_at > ?", deviceCodeHash, models.DeviceCodeApproved, time.Now()).
+		Update("status", models.DeviceCodeConsumed)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &code, nil
+}
+
+func (r *deviceCodeRepository) DeleteExpiredDeviceCodes(before time.Time) error {
+	return r.db.Delete(&models.DeviceCode{}, "expires_at < ?", before).Error
+}
@@ -0,0 +1,99 @@
+package repositories
+
+import (
+	"identity-service/internal/models"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type DomainVerificationRepository interface {
+	CreateVerification(verification *models.DomainVerification) error
+	GetVerification(tenantID uuid.UUID, domain string) (*models.DomainVerification, error)
+	ListVerifications(tenantID uuid.UUID) ([]*models.DomainVerification, error)
+	ListDueVerifications(checkedBefore time.Time, limit int) ([]*models.DomainVerification, error)
+	SaveVerification(verification *models.DomainVerification) error
+	DeleteVerification(verification *models.DomainVerification) error
+
+	// MarkVerified saves a verified domain and makes it the tenant's domain
+	MarkVerified(verification *models.DomainVerification) error
+	// MarkLapsed saves a domain that failed re-verification and unverifies the tenant's domain
+	MarkLapsed(verification *models.DomainVerification) error
+}
+
+type domainVerificationRepository struct {
+	db GormDB
+}
+
+func NewDomainVerificationRepository(db GormDB) DomainVerificationRepository {
+	return &domainVerificationRepository{
+		db: db,
+	}
+}
+
+func (r *domainVerificationRepository) CreateVerification(verification *models.DomainVerification) error {
+	return r.db.Create(verification).Error
+}
+
+func (r *domainVerificationRepository) GetVerification(tenantID uuid.UUID, domain string) (*models.DomainVerification, error) {
+	var verification models.DomainVerification
+	if err := r.db.First(&verification, "tenant_id = ? AND domain = ?", tenantID, domain).Error; err != nil {
+		return nil, err
+	}
+	return &verification, nil
+}
+
+func (r *domainVerificationRepository) ListVerifications(tenantID uuid.UUID) ([]*models.DomainVerification, error) {
+	var verifications []*models.DomainVerification
+	err := r.db.Where("tenant_id = ?", tenantID).Order("created_at").Find(&verifications).Error
+	return verifications, err
+}
+
+// ListDueVerifications returns verified domains last checked before checkedBefore, oldest first
+func (r *domainVerificationRepository) ListDueVerifications(checkedBefore time.Time, limit int) ([]*models.DomainVerification, error) {
+	var verifications []*models.DomainVerification
+	err := r.db.Where("status = ? AND (last_checked_at IS NULL OR last_checked_at < ?)", models.DomainVerificationVerified, checkedBefore).
+		Order("last_checked_at NULLS FIRST").
+		Limit(limit).
+		Find(&verifications).Error
+	return verifications, err
+}
+
+func (r *domainVerificationRepository) SaveVerification(verification *models.DomainVerification) error {
+	return r.db.Save(verification).Error
+}
+
+// DeleteVerification removes a domain, unverifying it if it is the tenant's domain
+func (r *domainVerificationRepository) DeleteVerification(verification *models.DomainVerification) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(verification).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.Tenant{}).
+			Where("id = ? AND LOWER(domain) = LOWER(?)", verification.TenantID, verification.Domain).
+			Updates(map[string]interface{}{"domain": "", "domain_verified": false}).Error
+	})
+}
+
+func (r *domainVerificationRepository) MarkVerified(verification *models.DomainVerification) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(verification).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.Tenant{}).
+			Where("id = ?", verification.TenantID).
+			Updates(map[string]interface{}{"domain": verification.Domain, "domain_verified": true}).Error
+	})
+}
+
+func (r *domainVerificationRepository) MarkLapsed(verification *models.DomainVerification) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(verification).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.Tenant{}).
+			Where("id = ? AND LOWER(domain) = LOWER(?)", verification.TenantID, verification.Domain).
+			Update("domain_verified", false).Error
+	})
+}
@@ -0,0 +1,200 @@
+package repositories
+
+import (
+	"identity-service/internal/models"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// memberGroupsQuery finds the groups users are in, following nested groups up to the groups
+// containing them. UNION stops at groups already found, should nesting ever form a cycle.
+const memberGroupsQuery = `
+	WITH RECURSIVE member_groups(user_id, group_id, direct) AS (
+		SELECT user_id, group_id, TRUE FROM tenant_group_members WHERE user_id IN ?
+		UNION
+		SELECT member_groups.user_id, tenant_group_members.group_id, FALSE
+		FROM tenant_group_members JOIN member_groups ON tenant_group_members.member_group_id = member_groups.group_id
+	)
+	SELECT member_groups.user_id, tenant_groups.tenant_id, tenant_groups.id, tenant_groups.name, tenant_groups.roles,
+		BOOL_OR(member_groups.direct) AS direct
+	FROM member_groups JOIN tenant_groups ON tenant_groups.id = member_groups.group_id
+	GROUP BY member_groups.user_id, tenant_groups.id
+	ORDER BY tenant_groups.name`
+
+// getMemberGroups returns the groups of every tenant the users are in
+func getMemberGroups(db GormDB, userIDs []uuid.UUID) ([]models.MemberGroup, error) {
+	var groups []models.MemberGroup
+	if len(userIDs) == 0 {
+		return groups, nil
+	}
+	err := db.Raw(memberGroupsQuery, userIDs).Scan(&groups).Error
+	return groups, err
+}
+
+type GroupRepository interface {
+	ListGroups(tenantID uuid.UUID) ([]*models.TenantGroup, error)
+	GetGroup(tenantID, id uuid.UUID) (*models.TenantGroup, error)
+	// GetGroupByName finds a group by name, ignoring case
+	GetGroupByName(tenantID uuid.UUID, name string) (*models.TenantGroup, error)
+	CreateGroup(group *models.TenantGroup) error
+	// SaveGroup updates the group's description and roles
+	SaveGroup(group *models.TenantGroup) error
+	DeleteGroup(tenantID, id uuid.UUID) error
+
+	// GetGroupUsers returns the users directly in the group
+	GetGroupUsers(groupID uuid.UUID) ([]*models.User, error)
+	// GetNestedGroups returns the groups directly nested in the group
+	GetNestedGroups(groupID uuid.UUID) ([]*models.TenantGroup, error)
+	AddUser(groupID, userID uuid.UUID) error
+	RemoveUser(groupID, userID uuid.UUID) error
+	AddGroup(groupID, memberGroupID uuid.UUID) error
+	RemoveGroup(groupID, memberGroupID uuid.UUID) error
+	// GetContainingGroups returns the groups the group is nested in, directly or not
+	GetContainingGroups(groupID uuid.UUID) ([]*models.TenantGroup, error)
+	// IsNestedIn reports whether the group is nested in the other group, directly or not
+	IsNestedIn(groupID, otherGroupID uuid.UUID) (bool, error)
+
+	// GetMemberGroups returns the groups of every tenant the users are in, directly or through
+	// nested groups
+	GetMemberGroups(userIDs []uuid.UUID) ([]models.MemberGroup, error)
+}
+
+type groupRepository struct {
+	db GormDB
+}
+
+func NewGroupRepository(db GormDB) GroupRepository {
+	return &groupRepository{
+		db: db,
+	}
+}
+
+func (r *groupRepository) ListGroups(tenantID uuid.UUID) ([]*models.TenantGroup, error) {
+	var groups []*models.TenantGroup
+	err := r.db.Where("tenant_id = ?", tenantID).Order("name").Find(&groups).Error
+	return groups, err
+}
+
+func (r *groupRepository) GetGroup(tenantID, id uuid.UUID) (*models.TenantGroup, error) {
+	var group models.TenantGroup
+	if err := r.db.First(&group, "id = ? AND tenant_id = ?", id, tenantID).Error; err != nil {
+		return nil, err
+	}
+	return &group, nil
+}
+
+func (r *groupRepository) GetGroupByName(tenantID uuid.UUID, name string) (*models.TenantGroup, error) {
+	var group models.TenantGroup
+	if err := r.db.First(&group, "tenant_id = ? AND LOWER(name) = LOWER(?)", tenantID, name).Error; err != nil {
+		return nil, err
+	}
+	return &group, nil
+}
+
+func (r *groupRepository) CreateGroup(group *models.TenantGroup) error {
+	return r.db.Create(group).Error
+}
+
+func (r *groupRepository) SaveGroup(group *models.TenantGroup) error {
+	now := time.Now()
+	group.UpdatedAt = &now
+	return r.db.Model(group).
+		Where("tenant_id = ?", group.TenantID).
+		Select("description", "roles", "updated_at").
+		Updates(group).Error
+}
+
+func (r *groupRepository) DeleteGroup(tenantID, id uuid.UUID) error {
+	return r.db.Delete(&models.TenantGroup{}, "id = ? AND tenant_id = ?", id, tenantID).Error
+}
+
+func (r *groupRepository) GetGroupUsers(groupID uuid.UUID) ([]*models.User, error) {
+	var users []*models.User
+	err := r.db.Joins("JOIN tenant_group_members ON tenant_group_members.user_id = users.id").
+		Where("tenant_group_members.group_id = ?", groupID).
+		Order("users.name").
+		Find(&users).Error
+	return users, err
+}
+
+func (r *groupRepository) GetNestedGroups(groupID uuid.UUID) ([]*models.TenantGroup, error) {
+	var groups []*models.TenantGroup
+	err := r.db.Joins("JOIN tenant_group_members ON tenant_group_members.member_group_id = tenant_groups.id").
+		Where("tenant_group_members.group_id = ?", groupID).
+		Order("tenant_groups.name").
+		Find(&groups).Error
+	return groups, err
+}
+
+func (r *groupRepository) AddUser(groupID, userID uuid.UUID) error {
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.TenantGroupMember{
+		ID:      uuid.New(),
+		GroupID: groupID,
+		UserID:  &userID,
+	}).Error
+}
+
+func (r *groupRepository) RemoveUser(groupID, userID uuid.UUID) error {
+	result := r.db.Delete(&models.TenantGroupMember{}, "group_id = ? AND user_id = ?", groupID, userID)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *groupRepository) AddGroup(groupID, memberGroupID uuid.UUID) error {
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.TenantGroupMember{
+		ID:            uuid.New(),
+		GroupID:       groupID,
+		MemberGroupID: &memberGroupID,
+	}).Error
+}
+
+func (r *groupRepository) RemoveGroup(groupID, memberGroupID uuid.UUID) error {
+	result := r.db.Delete(&models.TenantGroupMember{}, "group_id = ? AND member_group_id = ?", groupID, memberGroupID)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *groupRepository) GetContainingGroups(groupID uuid.UUID) ([]*models.TenantGroup, error) {
+	var groups []*models.TenantGroup
+	err := r.db.Raw(`
+		WITH RECURSIVE containing(id) AS (
+			SELECT group_id FROM tenant_group_members WHERE member_group_id = ?
+			UNION
+			SELECT tenant_group_members.group_id
+			FROM tenant_group_members JOIN containing ON tenant_group_members.member_group_id = containing.id
+		)
+		SELECT tenant_groups.* FROM tenant_groups JOIN containing ON tenant_groups.id = containing.id
+		ORDER BY tenant_groups.name`, groupID).Scan(&groups).Error
+	return groups, err
+}
+
+func (r *groupRepository) IsNestedIn(groupID, otherGroupID uuid.UUID) (bool, error) {
+	var count int64
+	err := r.db.Raw(`
+		WITH RECURSIVE nested(id) AS (
+			SELECT member_group_id FROM tenant_group_members WHERE group_id = ? AND member_group_id IS NOT NULL
+			UNION
+			SELECT tenant_group_members.member_group_id
+			FROM tenant_group_members JOIN nested ON tenant_group_members.group_id = nested.id
+			WHERE tenant_group_members.member_group_id IS NOT NULL
+		)
+		SELECT COUNT(*) FROM nested WHERE id = ?`, otherGroupID, groupID).Scan(&count).Error
+	return count > 0, err
+}
+
+func (r *groupRepository) GetMemberGroups(userIDs []uuid.UUID) ([]models.MemberGroup, error) {
+	return getMemberGroups(r.db, userIDs)
+}
@@ -0,0 +1,244 @@
+package repositories
+
+import (
+	"errors"
+	"identity-service/internal/models"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type InviteRepository interface {
+	CreateInvite(invite *models.TenantInvite) error
+	GetInvite(tenantID, id uuid.UUID) (*models.TenantInvite, error)
+	GetInviteByID(id uuid.UUID) (*models.TenantInvite, error)
+	GetPendingInvite(tenantID uuid.UUID, email string) (*models.TenantInvite, error)
+	ListInvites(tenantID uuid.UUID, status string) ([]*models.TenantInvite, error)
+	SaveInvite(invite *models.TenantInvite) error
+	// SaveInviteWithinLimit saves a new or renewed pending invitation unless the tenant's
+	// members and other unexpired pending invitations already number maxUsers, in which case
+	// it returns false. The tenant is locked while counting, so concurrent invitations take
+	// the remaining seats in turn.
+	SaveInviteWithinLimit(invite *models.TenantInvite, maxUsers int) (bool, error)
+	DeleteInvite(tenantID, id uuid.UUID) error
+
+	// AcceptInvite marks a pending invitation accepted and adds the user to the tenant with the
+	// invited role, and to the invitation's group if the tenant has it. It returns
+	// gorm.ErrRecordNotFound if the invitation is no longer pending.
+	AcceptInvite(invite *models.TenantInvite, userID uuid.UUID) error
+	// AcceptInviteAsNewUser creates the user and their password, then accepts the invitation
+	AcceptInviteAsNewUser(invite *models.TenantInvite, user *models.User, passwordHash string) error
+	// DeclineInvite marks a pending invitation declined, returning gorm.ErrRecordNotFound if it
+	// is no longer pending
+	DeclineInvite(invite *models.TenantInvite) error
+
+	HasMembership(userID, tenantID uuid.UUID) (bool, error)
+	CountMembers(tenantID uuid.UUID) (int64, error)
+	// CountPendingInvites counts the tenant's unexpired pending invitations
+	CountPendingInvites(tenantID uuid.UUID) (int64, error)
+}
+
+type inviteRepository struct {
+	db GormDB
+}
+
+func NewInviteRepository(db GormDB) InviteRepository {
+	return &inviteRepository{
+		db: db,
+	}
+}
+
+func (r *inviteRepository) CreateInvite(invite *models.TenantInvite) error {
+	return r.db.Create(invite).Error
+}
+
+func (r *inviteRepository) GetInvite(tenantID, id uuid.UUID) (*models.TenantInvite, error) {
+	var invite models.TenantInvite
+	if err := r.db.First(&invite, "id = ? AND tenant_id = ?", id, tenantID).Error; err != nil {
+		return nil, err
+	}
+	return &invite, nil
+}
+
+func (r *inviteRepository) GetInviteByID(id uuid.UUID) (*models.TenantInvite, error) {
+	var invite models.TenantInvite
+	if err := r.db.First(&invite, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &invite, nil
+}
+
+func (r *inviteRepository) GetPendingInvite(tenantID uuid.UUID, email string) (*models.TenantInvite, error) {
+	var invite models.TenantInvite
+	err := r.db.First(&invite, "tenant_id = ? AND LOWER(email) = LOWER(?) AND status = ?", tenantID, email, models.InvitePending).Error
+	if err != nil {
+		return nil, err
+	}
+	return &invite, nil
+}
+
+// ListInvites returns the tenant's invitations, newest first. The expired status matches
+// pending invitations past their expiry.
+func (r *inviteRepository) ListInvites(tenantID uuid.UUID, status string) ([]*models.TenantInvite, error) {
+	var invites []*models.TenantInvite
+	query := r.db.Where("tenant_id = ?", tenantID)
+	switch status {
+	case "":
+	case models.InviteExpired:
+		query = query.Where("status = ? AND expires_at < ?", models.InvitePending, time.Now())
+	case models.InvitePending:
+		query = query.Where("status = ? AND (expires_at IS NULL OR expires_at >= ?)", models.InvitePending, time.Now())
+	default:
+		query = query.Where("status = ?", status)
+	}
+	err := query.Order("created_at DESC").Find(&invites).Error
+	return invites, err
+}
+
+func (r *inviteRepository) SaveInvite(invite *models.TenantInvite) error {
+	return r.db.Save(invite).Error
+}
+
+func (r *inviteRepository) SaveInviteWithinLimit(invite *models.TenantInvite, maxUsers int) (bool, error) {
+	saved := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var tenant models.Tenant
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id").First(&tenant, "id = ?", invite.TenantID).Error; err != nil {
+			return err
+		}
+		members, err := countMembers(WrapDB(tx), invite.TenantID)
+		if err != nil {
+			return err
+		}
+		var pending int64
+		err = tx.Model(&models.TenantInvite{}).
+			Where("tenant_id = ? AND id <> ? AND status = ? AND (expires_at IS NULL OR expires_at >= ?)",
+				invite.TenantID, invite.ID, models.InvitePending, time.Now()).
+			Count(&pending).Error
+		if err != nil {
+			return err
+		}
+		if members+pending >= int64(maxUsers) {
+			return nil
+		}
+		saved = true
+		return tx.Save(invite).Error
+	})
+	return saved && err == nil, err
+}
+
+func (r *inviteRepository) DeleteInvite(tenantID, id uuid.UUID) error {
+	return r.db.Delete(&models.TenantInvite{}, "tenant_id = ? AND id = ?", tenantID, id).Error
+}
+
+func (r *inviteRepository) AcceptInvite(invite *models.TenantInvite, userID uuid.UUID) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return acceptInvite(tx, invite, userID)
+	})
+}
+
+func (r *inviteRepository) AcceptInviteAsNewUser(invite *models.TenantInvite, user *models.User, passwordHash string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		// The personal tenant is created by a database trigger
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		credential := &models.UserCredential{
+			UserID:       user.ID,
+			PasswordHash: passwordHash,
+		}
+		if err := tx.Create(credential).Error; err != nil {
+			return err
+		}
+		return acceptInvite(tx, invite, user.ID)
+	})
+}
+
+func acceptInvite(tx *gorm.DB, invite *models.TenantInvite, userID uuid.UUID) error {
+	now := time.Now()
+	result := tx.Model(&models.TenantInvite{}).
+		Where("id = ? AND status = ?", invite.ID, models.InvitePending).
+		Updates(map[string]interface{}{
+			"status":       models.InviteAccepted,
+			"accepted_by":  userID,
+			"responded_at": now,
+			"updated_at":   now,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+
+	access := &models.UserTenantAccess{
+		UserID:   userID,
+		TenantID: invite.TenantID,
+		Roles:    []string{invite.Role},
+		Active:   true,
+	}
+	if err := tx.Omit("User", "Tenant").Create(access).Error; err != nil {
+		return err
+	}
+
+	// The invitation's group may have been deleted since, in which case the user joins without it
+	if invite.GroupName != "" {
+		var group models.TenantGroup
+		err := tx.First(&group, "tenant_id = ? AND LOWER(name) = LOWER(?)", invite.TenantID, invite.GroupName).Error
+		if err == nil {
+			err = tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.TenantGroupMember{
+				ID:      uuid.New(),
+				GroupID: group.ID,
+				UserID:  &userID,
+			}).Error
+		}
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+	}
+
+	invite.Status = models.InviteAccepted
+	invite.AcceptedBy = &userID
+	invite.RespondedAt = &now
+	return nil
+}
+
+func (r *inviteRepository) DeclineInvite(invite *models.TenantInvite) error {
+	now := time.Now()
+	result := r.db.Model(&models.TenantInvite{}).
+		Where("id = ? AND status = ?", invite.ID, models.InvitePending).
+		Updates(map[string]interface{}{
+			"status":       models.InviteDeclined,
+			"responded_at": now,
+			"updated_at":   now,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+
+	invite.Status = models.InviteDeclined
+	invite.RespondedAt = &now
+	return nil
+}
+
+func (r *inviteRepository) HasMembership(userID, tenantID uuid.UUID) (bool, error) {
+	return hasMembership(r.db, userID, tenantID)
+}
+
+func (r *inviteRepository) CountMembers(tenantID uuid.UUID) (int64, error) {
+	return countMembers(r.db, tenantID)
+}
+
+func (r *inviteRepository) CountPendingInvites(tenantID uuid.UUID) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.TenantInvite{}).
+		Where("tenant_id = ? AND status = ? AND (expires_at IS NULL OR expires_at >= ?)", tenantID, models.InvitePending, time.Now()).
+		Count(&count).Error
+	return count, err
+}
@@ -0,0 +1,125 @@
+package repositories
+
+import (
+	"identity-service/internal/models"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type JoinRequestRepository interface {
+	CreateRequest(request *models.JoinRequest) error
+	GetRequest(tenantID, id uuid.UUID) (*models.JoinRequest, error)
+	GetUserRequest(tenantID, userID uuid.UUID) (*models.JoinRequest, error)
+	ListRequests(tenantID uuid.UUID, status string) ([]*models.JoinRequest, error)
+	SaveRequest(request *models.JoinRequest) error
+	// ApproveRequest saves the approved request and adds the user to the tenant with roles
+	ApproveRequest(request *models.JoinRequest, roles []string) error
+
+	// HasMembership reports whether the user has an access row in the tenant, including a
+	// deprovisioned one
+	HasMembership(userID, tenantID uuid.UUID) (bool, error)
+	CountMembers(tenantID uuid.UUID) (int64, error)
+}
+
+type joinRequestRepository struct {
+	db GormDB
+}
+
+func NewJoinRequestRepository(db GormDB) JoinRequestRepository {
+	return &joinRequestRepository{
+		db: db,
+	}
+}
+
+func (r *joinRequestRepository) CreateRequest(request *models.JoinRequest) error {
+	return r.db.Create(request).Error
+}
+
+func (r *joinRequestRepository) GetRequest(tenantID, id uuid.UUID) (*models.JoinRequest, error) {
+	var request models.JoinRequest
+	if err := r.db.Preload("User").First(&request, "id = ? AND tenant_id = ?", id, tenantID).Error; err != nil {
+		return nil, err
+	}
+	return &request, nil
+}
+
+func (r *joinRequestRepository) GetUserRequest(tenantID, userID uuid.UUID) (*models.JoinRequest, error) {
+	var request models.JoinRequest
+	if err := r.db.First(&request, "tenant_id = ? AND user_id = ?", tenantID, userID).Error; err != nil {
+		return nil, err
+	}
+	return &request, nil
+}
+
+func (r *joinRequestRepository) ListRequests(tenantID uuid.UUID, status string) ([]*models.JoinRequest, error) {
+	var requests []*models.JoinRequest
+	query := r.db.Preload("User").Where("tenant_id = ?", tenantID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	err := query.Order("created_at").Find(&requests).Error
+	return requests, err
+}
+
+// SaveRequest updates the request's status and review
+func (r *joinRequestRepository) SaveRequest(request *models.JoinRequest) error {
+	return r.db.Model(&models.JoinRequest{}).
+		Where("id = ?", request.ID).
+		Updates(map[string]interface{}{
+			"status":      request.Status,
+			"reviewed_by": request.ReviewedBy,
+			"reviewed_at": request.ReviewedAt,
+			"updated_at":  time.Now(),
+		}).Error
+}
+
+func (r *joinRequestRepository) ApproveRequest(request *models.JoinRequest, roles []string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.JoinRequest{}).
+			Where("id = ? AND status = ?", request.ID, models.JoinRequestPending).
+			Updates(map[string]interface{}{
+				"status":      request.Status,
+				"reviewed_by": request.ReviewedBy,
+				"reviewed_at": request.ReviewedAt,
+				"updated_at":  time.Now(),
+			}).Error
+		if err != nil {
+			return err
+		}
+		access := &models.UserTenantAccess{
+			UserID:   request.UserID,
+			TenantID: request.TenantID,
+			Roles:    roles,
+			Active:   true,
+		}
+		return tx.Omit("User", "Tenant").Create(access).Error
+	})
+}
+
+func (r *joinRequestRepository) HasMembership(userID, tenantID uuid.UUID) (bool, error) {
+	return hasMembership(r.db, userID, tenantID)
+}
+
+func (r *joinRequestRepository) CountMembers(tenantID uuid.UUID) (int64, error) {
+	return countMembers(r.db, tenantID)
+}
+
+// hasMembership reports whether the user has an access row in the tenant, active or not
+func hasMembership(db GormDB, userID, tenantID uuid.UUID) (bool, error) {
+	var count int64
+	err := db.Model(&models.UserTenantAccess{}).
+		Where("user_id = ? AND tenant_id = ?", userID, tenantID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// countMembers counts the tenant's active members
+func countMembers(db GormDB, tenantID uuid.UUID) (int64, error) {
+	var count int64
+	err := db.Model(&models.UserTenantAccess{}).
+		Where("tenant_id = ? AND active", tenantID).
+		Count(&count).Error
+	return count, err
+}
@@ -0,0 +1,52 @@
+package repositories
+
+import (
+	"identity-service/internal/models"
+
+	"github.com/google/uuid"
+)
+
+type LDAPConnectionRepository interface {
+	GetConnection(tenantID uuid.UUID) (*models.LDAPConnection, error)
+	GetConnectionByDomain(domain string) (*models.LDAPConnection, error)
+	SaveConnection(connection *models.LDAPConnection) error
+	DeleteConnection(tenantID uuid.UUID) error
+}
+
+type ldapConnectionRepository struct {
+	db GormDB
+}
+
+func NewLDAPConnectionRepository(db GormDB) LDAPConnectionRepository {
+	return &ldapConnectionRepository{
+		db: db,
+	}
+}
+
+func (r *ldapConnectionRepository) GetConnection(tenantID uuid.UUID) (*models.LDAPConnection, error) {
+	var connection models.LDAPConnection
+	if err := r.db.First(&connection, "tenant_id = ?", tenantID).Error; err != nil {
+		return nil, err
+	}
+	return &connection, nil
+}
+
+// GetConnectionByDomain returns the enabled connection of the tenant that verified the domain
+func (r *ldapConnectionRepository) GetConnectionByDomain(domain string) (*models.LDAPConnection, error) {
+	var connection models.LDAPConnection
+	err := r.db.Joins("JOIN tenants ON tenants.id = ldap_connections.tenant_id").
+		Where("LOWER(tenants.domain) = LOWER(?) AND tenants.domain_verified AND ldap_connections.enabled", domain).
+		First(&connection).Error
+	if err != nil {
+		return nil, err
+	}
+	return &connection, nil
+}
+
+func (r *ldapConnectionRepository) SaveConnection(connection *models.LDAPConnection) error {
+	return r.db.Save(connection).Error
+}
+
+func (r *ldapConnectionRepository) DeleteConnection(tenantID uuid.UUID) error {
+	return r.db.Delete(&models.LDAPConnection{}, "tenant_id = ?", tenantID).Error
+}
@@ -0,0 +1,60 @@
+package repositories
+
+import (
+	"identity-service/internal/models"
+
+	"github.com/google/uuid"
+)
+
+type OAuthClientRepository interface {
+	CreateClient(client *models.OAuthClient) error
+	GetClientByClientID(clientID string) (*models.OAuthClient, error)
+	GetClient(tenantID, id uuid.UUID) (*models.OAuthClient, error)
+	ListClients(tenantID uuid.UUID) ([]*models.OAuthClient, error)
+	UpdateClient(client *models.OAuthClient) error
+	DeleteClient(tenantID, id uuid.UUID) error
+}
+
+type oauthClientRepository struct {
+	db GormDB
+}
+
+func NewOAuthClientRepository(db GormDB) OAuthClientRepository {
+	return &oauthClientRepository{
+		db: db,
+	}
+}
+
+func (r *oauthClientRepository) CreateClient(client *models.OAuthClient) error {
+	return r.db.Create(client).Error
+}
+
+func (r *oauthClientRepository) GetClientByClientID(clientID string) (*models.OAuthClient, error) {
+	var client models.OAuthClient
+	if err := r.db.First(&client, "client_id = ?", clientID).Error; err != nil {
+		return nil, err
+	}
+	return &client, nil
+}
+
+func (r *oauthClientRepository) GetClient(tenantID, id uuid.UUID) (*models.OAuthClient, error) {
+	var client models.OAuthClient
+	if err := r.db.Where("tenant_id = ? AND id = ?", tenantID, id).First(&client).Error; err != nil {
+		return nil, err
+	}
+	return &client, nil
+}
+
+func (r *oauthClientRepository) ListClients(tenantID uuid.UUID) ([]*models.OAuthClient, error) {
+	var clients []*models.OAuthClient
+	err := r.db.Where("tenant_id = ?", tenantID).Find(&clients).Error
+	return clients, err
+}
+
+func (r *oauthClientRepository) UpdateClient(client *models.OAuthClient) error {
+	return r.db.Save(client).Error
+}
+
+func (r *oauthClientRepository) DeleteClient(tenantID, id uuid.UUID) error {
+	return r.db.Delete(&models.OAuthClient{}, "tenant_id = ? AND id = ?", tenantID, id).Error
+}
@@ -0,0 +1,40 @@
+package repositories
+
+import (
+	"identity-service/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type OAuthStateRepository interface {
+	CreateState(state *models.OAuthState) error
+	ConsumeState(state string) (*models.OAuthState, error)
+}
+
+type oauthStateRepository struct {
+	db GormDB
+}
+
+func NewOAuthStateRepository(db GormDB) OAuthStateRepository {
+	return &oauthStateRepository{
+		db: db,
+	}
+}
+
+func (r *oauthStateRepository) CreateState(state *models.OAuthState) error {
+	return r.db.Create(state).Error
+}
+
+// ConsumeState deletes the state and returns it, so a state can only be used once
+func (r *oauthStateRepository) ConsumeState(state string) (*models.OAuthState, error) {
+	var oauthState models.OAuthState
+	result := r.db.Clauses(clause.Returning{}).Where("state = ?", state).Delete(&oauthState)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &oauthState, nil
+}
@@ -5,12 +5,13 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type PKCERepository interface {
 	CreateChallenge(challenge *models.PKCEChallenge) error
-	GetChallenge(id uuid.UUID) (*models.PKCEChallenge, error)
-	MarkChallengeAsUsed(id uuid.UUID) error
+	ConsumeChallenge(id uuid.UUID) (*models.PKCEChallenge, error)
 }
 
 type pkceRepository struct {
@@ -27,14 +28,19 @@ func (r *pkceRepository) CreateChallenge(challenge *models.PKCEChallenge) error
 	return r.db.Create(challenge).Error
 }
 
-func (r *pkceRepository) GetChallenge(id uuid.UUID) (*models.PKCEChallenge, error) {
+// ConsumeChallenge marks an unused, unexpired challenge as used and returns it in a single statement,
+// so concurrent exchanges of the same code cannot both succeed
+func (r *pkceRepository) ConsumeChallenge(id uuid.UUID) (*models.PKCEChallenge, error) {
 	var challenge models.PKCEChallenge
-	if err := r.db.Where("id = ? AND used = ? AND expires_at > ?", id, false, time.Now()).First(&challenge).Error; err != nil {
-		return nil, err
+	result := r.db.Model(&challenge).
+		Clauses(clause.Returning{}).
+		Where("id = ? AND used = ? AND expires_at > ?", id, false, time.Now()).
+		Update("used", true)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
 	}
 	return &challenge, nil
 }
-
-func (r *pkceRepository) MarkChallengeAsUsed(id uuid.UUID) error {
-	return r.db.Model(&models.PKCEChallenge{}).Where("id = ?", id).Update("used", true).Error
-}
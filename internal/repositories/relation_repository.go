@@ -0,0 +1,229 @@
+package repositories
+
+import (
+	"errors"
+	"identity-service/internal/models"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// TupleQuery selects a tenant's tuples as of a revision. Empty fields match anything.
+type TupleQuery struct {
+	Namespace        string
+	ObjectID         string
+	Relation         string
+	SubjectNamespace string
+	SubjectObjectID  string
+	// SubjectRelation, when set, matches subjects with exactly that relation, "" matching
+	// plain objects
+	SubjectRelation *string
+	// UsersetsOnly matches only subjects that are sets of subjects, such as group:eng#member
+	UsersetsOnly bool
+	Offset       int
+	Limit        int
+}
+
+type RelationRepository interface {
+	ListNamespaces(tenantID uuid.UUID) ([]models.RelationNamespace, error)
+	GetNamespace(tenantID uuid.UUID, name string) (*models.RelationNamespace, error)
+	CreateNamespace(namespace *models.RelationNamespace) error
+	// SaveNamespace updates the namespace's relations
+	SaveNamespace(namespace *models.RelationNamespace) error
+	DeleteNamespace(tenantID uuid.UUID, name string) error
+	// CountTuples counts the live tuples using the relation of the namespace, for their object
+	// or their set of subjects. An empty relation counts those using any relation.
+	CountTuples(tenantID uuid.UUID, namespace, relation string) (int64, error)
+
+	// GetRevision returns the tenant's latest revision, which is zero before the first write
+	GetRevision(tenantID uuid.UUID) (*models.RelationRevision, error)
+	// WriteTuples deletes and then adds tuples in a new revision, returning it. Writes are
+	// serialized per tenant so revisions commit in order. Adding a live tuple or deleting a
+	// missing one does nothing.
+	WriteTuples(tenantID uuid.UUID, writes, deletes []models.RelationTuple) (int64, error)
+	// HasTuple reports whether the tuple was live at the revision
+	HasTuple(tenantID uuid.UUID, revision int64, tuple *models.RelationTuple) (bool, error)
+	FindTuples(tenantID uuid.UUID, revision int64, query *TupleQuery) ([]models.RelationTuple, error)
+	// PurgeDeletedTuples removes tuples deleted before the time, advancing the oldest revision
+	// of their tenants past them
+	PurgeDeletedTuples(before time.Time) error
+}
+
+type relationRepository struct {
+	db GormDB
+}
+
+func NewRelationRepository(db GormDB) RelationRepository {
+	return &relationRepository{
+		db: db,
+	}
+}
+
+func (r *relationRepository) ListNamespaces(tenantID uuid.UUID) ([]models.RelationNamespace, error) {
+	var namespaces []models.RelationNamespace
+	err := r.db.Where("tenant_id = ?", tenantID).Order("name").Find(&namespaces).Error
+	return namespaces, err
+}
+
+func (r *relationRepository) GetNamespace(tenantID uuid.UUID, name string) (*models.RelationNamespace, error) {
+	var namespace models.RelationNamespace
+	if err := r.db.First(&namespace, "tenant_id = ? AND name = ?", tenantID, name).Error; err != nil {
+		return nil, err
+	}
+	return &namespace, nil
+}
+
+func (r *relationRepository) CreateNamespace(namespace *models.RelationNamespace) error {
+	return r.db.Create(namespace).Error
+}
+
+func (r *relationRepository) SaveNamespace(namespace *models.RelationNamespace) error {
+	now := time.Now()
+	namespace.UpdatedAt = &now
+	return r.db.Model(namespace).
+		Where("tenant_id = ?", namespace.TenantID).
+		Select("relations", "updated_at").
+		Updates(namespace).Error
+}
+
+func (r *relationRepository) DeleteNamespace(tenantID uuid.UUID, name string) error {
+	return r.db.Delete(&models.RelationNamespace{}, "tenant_id = ? AND name = ?", tenantID, name).Error
+}
+
+func (r *relationRepository) CountTuples(tenantID uuid.UUID, namespace, relation string) (int64, error) {
+	query := r.db.Model(&models.RelationTuple{}).Where("tenant_id = ? AND deleted_revision IS NULL", tenantID)
+	if relation == "" {
+		query = query.Where("(namespace = ? OR (subject_namespace = ? AND subject_relation <> ''))", namespace, namespace)
+	} else {
+		query = query.Where("((namespace = ? AND relation = ?) OR (subject_namespace = ? AND subject_relation = ?))",
+			namespace, relation, namespace, relation)
+	}
+
+	var count int64
+	err := query.Count(&count).Error
+	return count, err
+}
+
+func (r *relationRepository) GetRevision(tenantID uuid.UUID) (*models.RelationRevision, error) {
+	var revision models.RelationRevision
+	err := r.db.First(&revision, "tenant_id = ?", tenantID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &models.RelationRevision{TenantID: tenantID}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &revision, nil
+}
+
+func (r *relationRepository) WriteTuples(tenantID uuid.UUID, writes, deletes []models.RelationTuple) (int64, error) {
+	var revision models.RelationRevision
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		// Lock the tenant's revision so concurrent writes take the next revisions in turn
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&models.RelationRevision{TenantID: tenantID}).Error; err != nil {
+			return err
+		}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&revision, "tenant_id = ?", tenantID).Error; err != nil {
+			return err
+		}
+		revision.Revision++
+		now := time.Now()
+
+		for i := range deletes {
+			tuple := &deletes[i]
+			err := tx.Model(&models.RelationTuple{}).
+				Where("tenant_id = ? AND deleted_revision IS NULL", tenantID).
+				Where("namespace = ? AND object_id = ? AND relation = ?", tuple.Namespace, tuple.ObjectID, tuple.Relation).
+				Where("subject_namespace = ? AND subject_object_id = ? AND subject_relation = ?",
+					tuple.SubjectNamespace, tuple.SubjectObjectID, tuple.SubjectRelation).
+				Updates(map[string]interface{}{"deleted_revision": revision.Revision, "deleted_at": now}).Error
+			if err != nil {
+				return err
+			}
+		}
+		for i := range writes {
+			tuple := writes[i]
+			tuple.ID = uuid.New()
+			tuple.TenantID = tenantID
+			tuple.CreatedRevision = revision.Revision
+			tuple.CreatedAt = &now
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&tuple).Error; err != nil {
+				return err
+			}
+		}
+
+		revision.UpdatedAt = &now
+		return tx.Model(&revision).Select("revision", "updated_at").Updates(&revision).Error
+	})
+	return revision.Revision, err
+}
+
+func (r *relationRepository) HasTuple(tenantID uuid.UUID, revision int64, tuple *models.RelationTuple) (bool, error) {
+	var count int64
+	err := r.asOf(tenantID, revision).
+		Where("namespace = ? AND object_id = ? AND relation = ?", tuple.Namespace, tuple.ObjectID, tuple.Relation).
+		Where("subject_namespace = ? AND subject_object_id = ? AND subject_relation = ?",
+			tuple.SubjectNamespace, tuple.SubjectObjectID, tuple.SubjectRelation).
+		Limit(1).
+		Count(&count).Error
+	return count > 0, err
+}
+
+func (r *relationRepository) FindTuples(tenantID uuid.UUID, revision int64, query *TupleQuery) ([]models.RelationTuple, error) {
+	db := r.asOf(tenantID, revision)
+	for _, field := range []struct{ column, value string }{
+		{"namespace", query.Namespace},
+		{"object_id", query.ObjectID},
+		{"relation", query.Relation},
+		{"subject_namespace", query.SubjectNamespace},
+		{"subject_object_id", query.SubjectObjectID},
+	} {
+		if field.value != "" {
+			db = db.Where(field.column+" = ?", field.value)
+		}
+	}
+	if query.SubjectRelation != nil {
+		db = db.Where("subject_relation = ?", *query.SubjectRelation)
+	}
+	if query.UsersetsOnly {
+		db = db.Where("subject_relation <> ''")
+	}
+	if query.Offset > 0 {
+		db = db.Offset(query.Offset)
+	}
+	if query.Limit > 0 {
+		db = db.Limit(query.Limit)
+	}
+
+	var tuples []models.RelationTuple
+	err := db.Order("namespace, object_id, relation, subject_namespace, subject_object_id, subject_relation").
+		Find(&tuples).Error
+	return tuples, err
+}
+
+func (r *relationRepository) PurgeDeletedTuples(before time.Time) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		// Snapshots from a purged tuple's deletion on never included it, so only they remain
+		// readable
+		return tx.Exec(`
+			WITH purged AS (
+				DELETE FROM relation_tuples WHERE deleted_at < ?
+				RETURNING tenant_id, deleted_revision
+			), oldest AS (
+				SELECT tenant_id, MAX(deleted_revision) AS revision FROM purged GROUP BY tenant_id
+			)
+			UPDATE relation_revisions SET oldest_revision = GREATEST(relation_revisions.oldest_revision, oldest.revision)
+			FROM oldest WHERE relation_revisions.tenant_id = oldest.tenant_id`, before).Error
+	})
+}
+
+// asOf selects the tenant's tuples that were live at the revision
+func (r *relationRepository) asOf(tenantID uuid.UUID, revision int64) *gorm.DB {
+	return r.db.Model(&models.RelationTuple{}).
+		Where("tenant_id = ? AND created_revision <= ?", tenantID, revision).
+		Where("(deleted_revision IS NULL OR deleted_revision > ?)", revision)
+}
@@ -1,6 +1,11 @@
 package repositories
 
-import "gorm.io/gorm"
+import (
+	"database/sql"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
 
 // GormDB interface defines the required database operations
 type GormDB interface {
@@ -18,6 +23,9 @@ type GormDB interface {
 	Preload(query string, args ...interface{}) *gorm.DB
 	Pluck(column string, value interface{}) *gorm.DB
 	Update(column string, value interface{}) *gorm.DB
+	Clauses(conds ...clause.Expression) *gorm.DB
+	Raw(sql string, values ...interface{}) *gorm.DB
+	Transaction(fc func(tx *gorm.DB) error, opts ...*sql.TxOptions) error
 	Error() error
 }
 
@@ -0,0 +1,85 @@
+package repositories
+
+import (
+	"identity-service/internal/models"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type RoleRepository interface {
+	CreateRole(role *models.TenantRole) error
+	GetRole(tenantID, id uuid.UUID) (*models.TenantRole, error)
+	GetRolesByName(tenantID uuid.UUID, names []string) ([]models.TenantRole, error)
+	ListRoles(tenantID uuid.UUID) ([]models.TenantRole, error)
+	SaveRole(role *models.TenantRole) error
+	DeleteRole(tenantID, id uuid.UUID) error
+	// CountRoleMembers counts the tenant's members holding the role, including deprovisioned ones
+	CountRoleMembers(tenantID uuid.UUID, name string) (int64, error)
+	// CountRoleGroups counts the tenant's groups giving their members the role
+	CountRoleGroups(tenantID uuid.UUID, name string) (int64, error)
+}
+
+type roleRepository struct {
+	db GormDB
+}
+
+func NewRoleRepository(db GormDB) RoleRepository {
+	return &roleRepository{
+		db: db,
+	}
+}
+
+func (r *roleRepository) CreateRole(role *models.TenantRole) error {
+	return r.db.Create(role).Error
+}
+
+func (r *roleRepository) GetRole(tenantID, id uuid.UUID) (*models.TenantRole, error) {
+	var role models.TenantRole
+	if err := r.db.First(&role, "id = ? AND tenant_id = ?", id, tenantID).Error; err != nil {
+		return nil, err
+	}
+	return &role, nil
+}
+
+func (r *roleRepository) GetRolesByName(tenantID uuid.UUID, names []string) ([]models.TenantRole, error) {
+	var roles []models.TenantRole
+	err := r.db.Where("tenant_id = ? AND name IN ?", tenantID, names).Find(&roles).Error
+	return roles, err
+}
+
+func (r *roleRepository) ListRoles(tenantID uuid.UUID) ([]models.TenantRole, error) {
+	var roles []models.TenantRole
+	err := r.db.Where("tenant_id = ?", tenantID).Order("name").Find(&roles).Error
+	return roles, err
+}
+
+// SaveRole updates the role's description, permissions and conditions
+func (r *roleRepository) SaveRole(role *models.TenantRole) error {
+	now := time.Now()
+	role.UpdatedAt = &now
+	return r.db.Model(role).
+		Where("tenant_id = ?", role.TenantID).
+		Select("description", "permissions", "conditions", "updated_at").
+		Updates(role).Error
+}
+
+func (r *roleRepository) DeleteRole(tenantID, id uuid.UUID) error {
+	return r.db.Delete(&models.TenantRole{}, "id = ? AND tenant_id = ?", id, tenantID).Error
+}
+
+func (r *roleRepository) CountRoleMembers(tenantID uuid.UUID, name string) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.UserTenantAccess{}).
+		Where("tenant_id = ? AND ? = ANY(roles)", tenantID, name).
+		Count(&count).Error
+	return count, err
+}
+
+func (r *roleRepository) CountRoleGroups(tenantID uuid.UUID, name string) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.TenantGroup{}).
+		Where("tenant_id = ? AND ? = ANY(roles)", tenantID, name).
+		Count(&count).Error
+	return count, err
+}
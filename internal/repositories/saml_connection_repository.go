@@ -0,0 +1,39 @@
+package repositories
+
+import (
+	"identity-service/internal/models"
+
+	"github.com/google/uuid"
+)
+
+type SAMLConnectionRepository interface {
+	GetConnection(tenantID uuid.UUID) (*models.SAMLConnection, error)
+	SaveConnection(connection *models.SAMLConnection) error
+	DeleteConnection(tenantID uuid.UUID) error
+}
+
+type samlConnectionRepository struct {
+	db GormDB
+}
+
+func NewSAMLConnectionRepository(db GormDB) SAMLConnectionRepository {
+	return &samlConnectionRepository{
+		db: db,
+	}
+}
+
+func (r *samlConnectionRepository) GetConnection(tenantID uuid.UUID) (*models.SAMLConnection, error) {
+	var connection models.SAMLConnection
+	if err := r.db.First(&connection, "tenant_id = ?", tenantID).Error; err != nil {
+		return nil, err
+	}
+	return &connection, nil
+}
+
+func (r *samlConnectionRepository) SaveConnection(connection *models.SAMLConnection) error {
+	return r.db.Save(connection).Error
+}
+
+func (r *samlConnectionRepository) DeleteConnection(tenantID uuid.UUID) error {
+	return r.db.Delete(&models.SAMLConnection{}, "tenant_id = ?", tenantID).Error
+}
@@ -0,0 +1,260 @@
+package repositories
+
+import (
+	"identity-service/internal/models"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type SCIMRepository interface {
+	CreateToken(token *models.SCIMToken) error
+	GetTokenByHash(hash string) (*models.SCIMToken, error)
+	ListTokens(tenantID uuid.UUID) ([]*models.SCIMToken, error)
+	RevokeToken(tenantID, id uuid.UUID) error
+	TouchToken(id uuid.UUID, usedAt time.Time) error
+
+	// Members are the users of a tenant, including deactivated ones. Filters are SQL
+	// conditions over the users and user_tenant_access tables.
+	ListMembers(tenantID uuid.UUID, filter string, args []interface{}, offset, limit int) ([]*models.UserTenantAccess, int64, error)
+	GetMember(tenantID, userID uuid.UUID) (*models.UserTenantAccess, error)
+	CreateMember(access *models.UserTenantAccess) error
+	SaveMember(access *models.UserTenantAccess) error
+	DeleteMember(tenantID, userID uuid.UUID) error
+	RevokeMemberTokens(tenantID, userID uuid.UUID) error
+
+	ListGroups(tenantID uuid.UUID, filter string, args []interface{}, offset, limit int) ([]*models.SCIMGroup, int64, error)
+	GetGroup(tenantID, id uuid.UUID) (*models.SCIMGroup, error)
+	GetGroupsByName(tenantID uuid.UUID, names []string) ([]*models.SCIMGroup, error)
+	CreateGroup(group *models.SCIMGroup) error
+	SaveGroup(group *models.SCIMGroup, previousName string) error
+	DeleteGroup(group *models.SCIMGroup) error
+	GetGroupMembers(tenantID uuid.UUID, role string) ([]*models.UserTenantAccess, error)
+	SetGroupMembers(tenantID uuid.UUID, role string, userIDs []uuid.UUID) error
+}
+
+type scimRepository struct {
+	db GormDB
+}
+
+func NewSCIMRepository(db GormDB) SCIMRepository {
+	return &scimRepository{
+		db: db,
+	}
+}
+
+func (r *scimRepository) CreateToken(token *models.SCIMToken) error {
+	return r.db.Create(token).Error
+}
+
+func (r *scimRepository) GetTokenByHash(hash string) (*models.SCIMToken, error) {
+	var token models.SCIMToken
+	if err := r.db.First(&token, "token_hash = ?", hash).Error; err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+func (r *scimRepository) ListTokens(tenantID uuid.UUID) ([]*models.SCIMToken, error) {
+	var tokens []*models.SCIMToken
+	err := r.db.Where("tenant_id = ?", tenantID).Order("created_at").Find(&tokens).Error
+	return tokens, err
+}
+
+func (r *scimRepository) RevokeToken(tenantID, id uuid.UUID) error {
+	result := r.db.Model(&models.SCIMToken{}).
+		Where("id = ? AND tenant_id = ? AND revoked_at IS NULL", id, tenantID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *scimRepository) TouchToken(id uuid.UUID, usedAt time.Time) error {
+	return r.db.Model(&models.SCIMToken{}).Where("id = ?", id).Update("last_used_at", usedAt).Error
+}
+
+func (r *scimRepository) ListMembers(tenantID uuid.UUID, filter string, args []interface{}, offset, limit int) ([]*models.UserTenantAccess, int64, error) {
+	var members []*models.UserTenantAccess
+	var total int64
+
+	query := r.db.Model(&models.UserTenantAccess{}).
+		Joins("JOIN users ON users.id = user_tenant_access.user_id").
+		Where("user_tenant_access.tenant_id = ?", tenantID)
+	if filter != "" {
+		query = query.Where(filter, args...)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	if limit == 0 {
+		return members, total, nil
+	}
+
+	err := query.Preload("User").
+		Order("user_tenant_access.created_at, user_tenant_access.id").
+		Offset(offset).Limit(limit).
+		Find(&members).Error
+	return members, total, err
+}
+
+func (r *scimRepository) GetMember(tenantID, userID uuid.UUID) (*models.UserTenantAccess, error) {
+	var access models.UserTenantAccess
+	err := r.db.Preload("User").
+		Where("tenant_id = ? AND user_id = ?", tenantID, userID).
+		First(&access).Error
+	if err != nil {
+		return nil, err
+	}
+	return &access, nil
+}
+
+func (r *scimRepository) CreateMember(access *models.UserTenantAccess) error {
+	return r.db.Create(access).Error
+}
+
+func (r *scimRepository) SaveMember(access *models.UserTenantAccess) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&access.User).Error; err != nil {
+			return err
+		}
+		return tx.Omit("User", "Tenant").Save(access).Error
+	})
+}
+
+// DeleteMember removes the user from the tenant and ends their sessions in it
+func (r *scimRepository) DeleteMember(tenantID, userID uuid.UUID) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Delete(&models.UserTenantAccess{}, "tenant_id = ? AND user_id = ?", tenantID, userID)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return endMemberSessions(tx, tenantID, userID)
+	})
+}
+
+// RevokeMemberTokens ends the user's sessions in the tenant
+func (r *scimRepository) RevokeMemberTokens(tenantID, userID uuid.UUID) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return endMemberSessions(tx, tenantID, userID)
+	})
+}
+
+// endMemberSessions deletes the user's sessions in the tenant, which is what stops their
+// tokens from authenticating, and marks the tokens issued for it revoked
+func endMemberSessions(tx *gorm.DB, tenantID, userID uuid.UUID) error {
+	if err := tx.Delete(&models.Session{}, "tenant_id = ? AND user_id = ?", tenantID, userID).Error; err != nil {
+		return err
+	}
+	return tx.Model(&models.JWTToken{}).
+		Where("tenant_id = ? AND user_id = ?", tenantID, userID).
+		Update("is_revoked", true).Error
+}
+
+func (r *scimRepository) ListGroups(tenantID uuid.UUID, filter string, args []interface{}, offset, limit int) ([]*models.SCIMGroup, int64, error) {
+	var groups []*models.SCIMGroup
+	var total int64
+
+	query := r.db.Model(&models.SCIMGroup{}).Where("tenant_id = ?", tenantID)
+	if filter != "" {
+		query = query.Where(filter, args...)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	if limit == 0 {
+		return groups, total, nil
+	}
+
+	err := query.Order("created_at, id").Offset(offset).Limit(limit).Find(&groups).Error
+	return groups, total, err
+}
+
+func (r *scimRepository) GetGroup(tenantID, id uuid.UUID) (*models.SCIMGroup, error) {
+	var group models.SCIMGroup
+	if err := r.db.First(&group, "id = ? AND tenant_id = ?", id, tenantID).Error; err != nil {
+		return nil, err
+	}
+	return &group, nil
+}
+
+func (r *scimRepository) GetGroupsByName(tenantID uuid.UUID, names []string) ([]*models.SCIMGroup, error) {
+	var groups []*models.SCIMGroup
+	if len(names) == 0 {
+		return groups, nil
+	}
+	err := r.db.Where("tenant_id = ? AND display_name IN ?", tenantID, names).Find(&groups).Error
+	return groups, err
+}
+
+func (r *scimRepository) CreateGroup(group *models.SCIMGroup) error {
+	return r.db.Create(group).Error
+}
+
+// SaveGroup updates a group. Renaming a group renames the role its members hold.
+func (r *scimRepository) SaveGroup(group *models.SCIMGroup, previousName string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(group).Error; err != nil {
+			return err
+		}
+		if previousName == group.DisplayName {
+			return nil
+		}
+		return tx.Model(&models.UserTenantAccess{}).
+			Where("tenant_id = ? AND ? = ANY(roles)", group.TenantID, previousName).
+			Update("roles", gorm.Expr("array_replace(roles, ?, ?)", previousName, group.DisplayName)).Error
+	})
+}
+
+// DeleteGroup deletes a group and takes its role away from the members
+func (r *scimRepository) DeleteGroup(group *models.SCIMGroup) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.UserTenantAccess{}).
+			Where("tenant_id = ? AND ? = ANY(roles)", group.TenantID, group.DisplayName).
+			Update("roles", gorm.Expr("array_remove(roles, ?)", group.DisplayName)).Error
+		if err != nil {
+			return err
+		}
+		return tx.Delete(group).Error
+	})
+}
+
+func (r *scimRepository) GetGroupMembers(tenantID uuid.UUID, role string) ([]*models.UserTenantAccess, error) {
+	var members []*models.UserTenantAccess
+	err := r.db.Preload("User").
+		Where("tenant_id = ? AND ? = ANY(roles)", tenantID, role).
+		Order("created_at").
+		Find(&members).Error
+	return members, err
+}
+
+// SetGroupMembers gives role to exactly the listed users of the tenant
+func (r *scimRepository) SetGroupMembers(tenantID uuid.UUID, role string, userIDs []uuid.UUID) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		removed := tx.Model(&models.UserTenantAccess{}).
+			Where("tenant_id = ? AND ? = ANY(roles)", tenantID, role)
+		if len(userIDs) > 0 {
+			removed = removed.Where("user_id NOT IN ?", userIDs)
+		}
+		if err := removed.Update("roles", gorm.Expr("array_remove(roles, ?)", role)).Error; err != nil {
+			return err
+		}
+		if len(userIDs) == 0 {
+			return nil
+		}
+
+		return tx.Model(&models.UserTenantAccess{}).
+			Where("tenant_id = ? AND user_id IN ? AND NOT (? = ANY(COALESCE(roles, '{}')))", tenantID, userIDs, role).
+			Update("roles", gorm.Expr("array_append(COALESCE(roles, '{}'), ?)", role)).Error
+	})
+}
@@ -19,6 +19,7 @@ type SecurityRepository interface {
 	RevokeAPIKey(tenantID uuid.UUID, keyID uuid.UUID) error
 	GetSecurityAuditLogs(tenantID uuid.UUID, page, limit int, filter map[string]string) ([]*models.AuditLog, int64, error)
 	GetAuditLogEntry(tenantID uuid.UUID, logID uuid.UUID) (*models.AuditLog, error)
+	CreateAuditLog(log *models.AuditLog) error
 	GetSecurityPolicies(tenantID uuid.UUID) (*models.SecurityPolicies, error)
 	UpdateSecurityPolicies(tenantID uuid.UUID, policies *models.SecurityPolicies) error
 	GetSecurityMetrics(tenantID uuid.UUID) (*models.SecurityMetrics, error)
@@ -165,6 +166,10 @@ func (r *securityRepository) GetAuditLogEntry(tenantID uuid.UUID, logID uuid.UUI
 	return &log, nil
 }
 
+func (r *securityRepository) CreateAuditLog(log *models.AuditLog) error {
+	return r.db.Create(log).Error
+}
+
 // Helper function to generate API key
 func generateAPIKey() string {
 	// This is a placeholder implementation
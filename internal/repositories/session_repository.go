@@ -4,6 +4,7 @@ import (
 	"identity-service/internal/models"
 
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 type SessionRepository interface {
@@ -11,6 +12,9 @@ type SessionRepository interface {
 	GetSession(id uuid.UUID) (*models.Session, error)
 	UpdateSession(session *models.Session) error
 	DeleteSession(id uuid.UUID) error
+	// ConsumeSession deletes the session if refreshToken is still its refresh token, returning
+	// gorm.ErrRecordNotFound if it is not, so that a refresh token is only ever used once
+	ConsumeSession(id uuid.UUID, refreshToken string) error
 	ListUserSessions(userID uuid.UUID) ([]*models.Session, error)
 }
 
@@ -44,6 +48,17 @@ func (r *sessionRepository) DeleteSession(id uuid.UUID) error {
 	return r.db.Delete(&models.Session{}, "id = ?", id).Error
 }
 
+func (r *sessionRepository) ConsumeSession(id uuid.UUID, refreshToken string) error {
+	result := r.db.Delete(&models.Session{}, "id = ? AND refresh_token = ?", id, refreshToken)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
 func (r *sessionRepository) ListUserSessions(userID uuid.UUID) ([]*models.Session, error) {
 	var sessions []*models.Session
 	if err := r.db.Where("user_id = ?", userID).Find(&sessions).Error; err != nil {
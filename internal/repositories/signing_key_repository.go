@@ -0,0 +1,94 @@
+package repositories
+
+import (
+	"identity-service/internal/auth/jwt"
+	"identity-service/internal/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// signingKeyRotationLock is the Postgres advisory lock key guarding key generation
+const signingKeyRotationLock int64 = 0x6a776b726f74 // "jwkrot"
+
+// SigningKeyRepository stores signing keys in Postgres so every instance shares them
+type SigningKeyRepository interface {
+	jwt.KeyStore
+}
+
+type signingKeyRepository struct {
+	db GormDB
+}
+
+func NewSigningKeyRepository(db GormDB) SigningKeyRepository {
+	return &signingKeyRepository{
+		db: db,
+	}
+}
+
+func (r *signingKeyRepository) LoadKeys() ([]*jwt.StoredKey, error) {
+	var rows []*models.SigningKey
+	if err := r.db.Where("expires_at > ?", time.Now()).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	keys := make([]*jwt.StoredKey, 0, len(rows))
+	for _, row := range rows {
+		keys = append(keys, &jwt.StoredKey{
+			KeyID:               row.KeyID,
+			Algorithm:           row.Algorithm,
+			EncryptedPrivateKey: row.EncryptedPrivateKey,
+			CreatedAt:           row.CreatedAt,
+			ActivatesAt:         row.ActivatesAt,
+			ExpiresAt:           row.ExpiresAt,
+			RevokedAt:           row.RevokedAt,
+		})
+	}
+	return keys, nil
+}
+
+func (r *signingKeyRepository) SaveKey(key *jwt.StoredKey) error {
+	return r.db.Create(&models.SigningKey{
+		KeyID:               key.KeyID,
+		Algorithm:           key.Algorithm,
+		EncryptedPrivateKey: key.EncryptedPrivateKey,
+		CreatedAt:           key.CreatedAt,
+		ActivatesAt:         key.ActivatesAt,
+		ExpiresAt:           key.ExpiresAt,
+	}).Error
+}
+
+func (r *signingKeyRepository) UpdateKey(key *jwt.StoredKey) error {
+	result := r.db.Model(&models.SigningKey{}).Where("kid = ?", key.KeyID).Updates(map[string]interface{}{
+		"activates_at": key.ActivatesAt,
+		"expires_at":   key.ExpiresAt,
+		"revoked_at":   key.RevokedAt,
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return jwt.ErrKeyNotFound
+	}
+	return nil
+}
+
+func (r *signingKeyRepository) DeleteExpiredKeys(before time.Time) error {
+	return r.db.Delete(&models.SigningKey{}, "expires_at < ?", before).Error
+}
+
+// WithRotationLock holds a transaction-scoped advisory lock while fn runs, so only one
+// instance generates keys. The lock is released when the transaction ends.
+func (r *signingKeyRepository) WithRotationLock(fn func() error) (bool, error) {
+	acquired := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Raw("SELECT pg_try_advisory_xact_lock(?)", signingKeyRotationLock).Scan(&acquired).Error; err != nil {
+			return err
+		}
+		if !acquired {
+			return nil
+		}
+		return fn()
+	})
+	return acquired, err
+}
@@ -4,6 +4,7 @@ import (
 	"identity-service/internal/models"
 
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 type TenantRepository interface {
@@ -11,12 +12,11 @@ type TenantRepository interface {
 	CreateTenant(tenant *models.Tenant) error
 	GetTenantByID(id uuid.UUID) (*models.Tenant, error)
 	GetTenantBySlug(slug string) (*models.Tenant, error)
+	GetTenantByVerifiedDomain(domain string) (*models.Tenant, error)
 	UpdateTenant(tenant *models.Tenant) error
 	DeleteTenant(id uuid.UUID) error
 	GetTenantMembers(tenantID uuid.UUID, page, limit int, search string, filter map[string]string) ([]*models.UserTenantAccess, int64, error)
 	GetUserTenantAccess(userID, tenantID uuid.UUID) (*models.UserTenantAccess, error)
-	CreateTenantInvite(tenantID uuid.UUID, invite *models.TenantInvite) (*models.TenantInvite, error)
-	DeleteTenantInvite(tenantID, inviteID uuid.UUID) error
 }
 
 type tenantRepository struct {
@@ -59,8 +59,23 @@ func (r *tenantRepository) ListTenants(page, limit int, search string, filter ma
 	return tenants, total, nil
 }
 
+// CreateTenant creates a tenant, adding its owner, if it has one, as a member with the owner role
 func (r *tenantRepository) CreateTenant(tenant *models.Tenant) error {
-	return r.db.Create(tenant).Error
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(tenant).Error; err != nil {
+			return err
+		}
+		if tenant.OwnerID == nil {
+			return nil
+		}
+		access := &models.UserTenantAccess{
+			UserID:   *tenant.OwnerID,
+			TenantID: tenant.ID,
+			Roles:    []string{models.TenantRoleOwner},
+			Active:   true,
+		}
+		return tx.Omit("User", "Tenant").Create(access).Error
+	})
 }
 
 func (r *tenantRepository) GetTenantByID(id uuid.UUID) (*models.Tenant, error) {
@@ -81,6 +96,16 @@ func (r *tenantRepository) GetTenantBySlug(slug string) (*models.Tenant, error)
 	return &tenant, nil
 }
 
+// GetTenantByVerifiedDomain returns the tenant that verified the email domain
+func (r *tenantRepository) GetTenantByVerifiedDomain(domain string) (*models.Tenant, error) {
+	var tenant models.Tenant
+	err := r.db.First(&tenant, "LOWER(domain) = LOWER(?) AND domain_verified", domain).Error
+	if err != nil {
+		return nil, err
+	}
+	return &tenant, nil
+}
+
 func (r *tenantRepository) UpdateTenant(tenant *models.Tenant) error {
 	return r.db.Save(tenant).Error
 }
@@ -122,21 +147,9 @@ func (r *tenantRepository) GetTenantMembers(tenantID uuid.UUID, page, limit int,
 
 func (r *tenantRepository) GetUserTenantAccess(userID, tenantID uuid.UUID) (*models.UserTenantAccess, error) {
 	var access models.UserTenantAccess
-	err := r.db.Where("user_id = ? AND tenant_id = ?", userID, tenantID).First(&access).Error
+	err := r.db.Where("user_id = ? AND tenant_id = ? AND active", userID, tenantID).First(&access).Error
 	if err != nil {
 		return nil, err
 	}
 	return &access, nil
 }
-
-func (r *tenantRepository) CreateTenantInvite(tenantID uuid.UUID, invite *models.TenantInvite) (*models.TenantInvite, error) {
-	invite.TenantID = tenantID
-	if err := r.db.Create(invite).Error; err != nil {
-		return nil, err
-	}
-	return invite, nil
-}
-
-func (r *tenantRepository) DeleteTenantInvite(tenantID, inviteID uuid.UUID) error {
-	return r.db.Delete(&models.TenantInvite{}, "tenant_id = ? AND id = ?", tenantID, inviteID).Error
-}
@@ -19,7 +19,13 @@ type UserRepository interface {
 	AddUserToTenant(userID, tenantID uuid.UUID, roles []string) error
 	RemoveUserFromTenant(userID, tenantID uuid.UUID) error
 	UpdateUserRole(userID, tenantID uuid.UUID, role string) error
+	UpdateUserRoles(userID, tenantID uuid.UUID, roles []string) error
 	GetUserTenantAccess(userID uuid.UUID) ([]models.UserTenantAccess, error)
+	// GetTenantRoles returns the custom roles of the tenants
+	GetTenantRoles(tenantIDs []uuid.UUID) ([]models.TenantRole, error)
+	// GetMemberGroups returns the groups of every tenant the users are in, directly or through
+	// nested groups
+	GetMemberGroups(userIDs []uuid.UUID) ([]models.MemberGroup, error)
 	GetTenantByID(id uuid.UUID) (*models.Tenant, error)
 	GetUserCredentials(userID uuid.UUID) (*models.UserCredential, error)
 	UpdateUserCredentials(cred *models.UserCredential) error
@@ -112,7 +118,7 @@ func (r *userRepository) UpdateUserProfile(id uuid.UUID, profile *models.UserPro
 func (r *userRepository) GetUserTenants(id uuid.UUID) ([]*models.Tenant, error) {
 	var tenants []*models.Tenant
 	err := r.db.Joins("JOIN user_tenant_access ON user_tenant_access.tenant_id = tenants.id").
-		Where("user_tenant_access.user_id = ?", id).
+		Where("user_tenant_access.user_id = ? AND user_tenant_access.active", id).
 		Find(&tenants).Error
 	return tenants, err
 }
@@ -136,12 +142,31 @@ func (r *userRepository) UpdateUserRole(userID, tenantID uuid.UUID, role string)
 		Update("roles", []string{role}).Error
 }
 
+func (r *userRepository) UpdateUserRoles(userID, tenantID uuid.UUID, roles []string) error {
+	return r.db.Model(&models.UserTenantAccess{}).
+		Where("user_id = ? AND tenant_id = ?", userID, tenantID).
+		Update("roles", roles).Error
+}
+
 func (r *userRepository) GetUserTenantAccess(userID uuid.UUID) ([]models.UserTenantAccess, error) {
 	var accesses []models.UserTenantAccess
-	err := r.db.Where("user_id = ?", userID).Find(&accesses).Error
+	err := r.db.Where("user_id = ? AND active", userID).Find(&accesses).Error
 	return accesses, err
 }
 
+func (r *userRepository) GetTenantRoles(tenantIDs []uuid.UUID) ([]models.TenantRole, error) {
+	var roles []models.TenantRole
+	if len(tenantIDs) == 0 {
+		return roles, nil
+	}
+	err := r.db.Where("tenant_id IN ?", tenantIDs).Find(&roles).Error
+	return roles, err
+}
+
+func (r *userRepository) GetMemberGroups(userIDs []uuid.UUID) ([]models.MemberGroup, error) {
+	return getMemberGroups(r.db, userIDs)
+}
+
 func (r *userRepository) GetTenantByID(id uuid.UUID) (*models.Tenant, error) {
 	var tenant models.Tenant
 	err := r.db.Where("id = ?", id).First(&tenant).Error
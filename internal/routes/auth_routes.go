@@ -9,7 +9,7 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func AuthRoutes(router *gin.Engine, authHandler *handlers.AuthHandler, oauthHandler *handlers.OAuthHandler, keyManager *jwt.KeyManager, userRepo repositories.UserRepository) {
+func AuthRoutes(router *gin.Engine, authHandler *handlers.AuthHandler, oauthHandler *handlers.OAuthHandler, keyManager *jwt.KeyManager, userRepo repositories.UserRepository, sessionRepo repositories.SessionRepository) {
 	// Public auth routes (no authentication required)
 	authGroup := router.Group("/api/auth")
 	{
@@ -24,7 +24,8 @@ func AuthRoutes(router *gin.Engine, authHandler *handlers.AuthHandler, oauthHand
 		authGroup.POST("/token", oauthHandler.HandleTokenExchange)
 
 		// Login
-		authGroup.POST("/login", authHandler.Login) // User login with credentials
+		authGroup.POST("/discover", authHandler.Discover) // Login methods for an email
+		authGroup.POST("/login", authHandler.Login)       // User login with credentials
 
 		// Session management
 		authGroup.POST("/logout", authHandler.Logout)        // Logout
@@ -39,7 +40,7 @@ func AuthRoutes(router *gin.Engine, authHandler *handlers.AuthHandler, oauthHand
 
 	// Protected auth routes
 	protectedGroup := authGroup.Group("")
-	jwtMiddleware := middleware.NewJWTAuthMiddleware(keyManager, userRepo)
+	jwtMiddleware := middleware.NewJWTAuthMiddleware(keyManager, userRepo, sessionRepo)
 	protectedGroup.Use(jwtMiddleware.RequireAuth())
 	{
 		// Session management
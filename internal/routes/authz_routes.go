@@ -0,0 +1,30 @@
+package routes
+
+import (
+	"identity-service/internal/handlers"
+	"identity-service/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func AuthzRoutes(router *gin.Engine, handler *handlers.AuthzHandler, relationHandler *handlers.RelationHandler) {
+	// Authorization checks for other services, authenticated as an OAuth client of the tenant
+	authzGroup := router.Group("/api/authz")
+	authzGroup.Use(handler.RequireClient())
+	{
+		authzGroup.POST("/check", handler.Check)            // Decide one check
+		authzGroup.POST("/check/batch", handler.CheckBatch) // Decide up to 100 checks
+
+		// Relationship-based authorization. Changes need the relations:write scope.
+		writeRelations := handler.RequireClientScope(models.ScopeRelationsWrite)
+		authzGroup.GET("/namespaces", relationHandler.ListNamespaces)
+		authzGroup.GET("/namespaces/:name", relationHandler.GetNamespace)
+		authzGroup.PUT("/namespaces/:name", writeRelations, relationHandler.SaveNamespace)
+		authzGroup.DELETE("/namespaces/:name", writeRelations, relationHandler.DeleteNamespace)
+		authzGroup.GET("/tuples", relationHandler.ReadTuples)
+		authzGroup.POST("/tuples", writeRelations, relationHandler.WriteTuples)
+		authzGroup.POST("/relations/check", relationHandler.Check)
+		authzGroup.POST("/relations/expand", relationHandler.Expand)
+		authzGroup.POST("/relations/list-objects", relationHandler.ListObjects)
+	}
+}
@@ -0,0 +1,25 @@
+package routes
+
+import (
+	"identity-service/internal/auth/jwt"
+	"identity-service/internal/handlers"
+	"identity-service/internal/middleware"
+	"identity-service/internal/models"
+	"identity-service/internal/repositories"
+
+	"github.com/gin-gonic/gin"
+)
+
+func AutoJoinRoutes(router *gin.Engine, handler *handlers.AutoJoinHandler, keyManager *jwt.KeyManager, userRepo repositories.UserRepository, sessionRepo repositories.SessionRepository) {
+	tenantGroup := router.Group("/api/tenants/:id")
+	jwtMiddleware := middleware.NewJWTAuthMiddleware(keyManager, userRepo, sessionRepo)
+	tenantGroup.Use(jwtMiddleware.RequireAuth())
+	{
+		tenantGroup.GET("/auto-join", middleware.RequireTenantPermission("id", models.PermTenantRead), handler.GetPolicy)      // Get auto-join policy
+		tenantGroup.PUT("/auto-join", middleware.RequireTenantPermission("id", models.PermTenantUpdate), handler.UpdatePolicy) // Update auto-join policy
+
+		tenantGroup.GET("/join-requests", middleware.RequireTenantPermission("id", models.PermMembersManage), handler.ListJoinRequests)                       // List join requests
+		tenantGroup.POST("/join-requests/:requestId/approve", middleware.RequireTenantPermission("id", models.PermMembersManage), handler.ApproveJoinRequest) // Approve a join request
+		tenantGroup.POST("/join-requests/:requestId/deny", middleware.RequireTenantPermission("id", models.PermMembersManage), handler.DenyJoinRequest)       // Deny a join request
+	}
+}
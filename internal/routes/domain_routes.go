@@ -0,0 +1,24 @@
+package routes
+
+import (
+	"identity-service/internal/auth/jwt"
+	"identity-service/internal/handlers"
+	"identity-service/internal/middleware"
+	"identity-service/internal/models"
+	"identity-service/internal/repositories"
+
+	"github.com/gin-gonic/gin"
+)
+
+func DomainRoutes(router *gin.Engine, handler *handlers.DomainHandler, keyManager *jwt.KeyManager, userRepo repositories.UserRepository, sessionRepo repositories.SessionRepository) {
+	domainGroup := router.Group("/api/tenants/:id/domains")
+	jwtMiddleware := middleware.NewJWTAuthMiddleware(keyManager, userRepo, sessionRepo)
+	domainGroup.Use(jwtMiddleware.RequireAuth())
+	{
+		domainGroup.GET("", middleware.RequireTenantPermission("id", models.PermTenantRead), handler.ListDomains)                    // List domains
+		domainGroup.POST("", middleware.RequireTenantPermission("id", models.PermTenantUpdate), handler.AddDomain)                   // Start verifying a domain
+		domainGroup.GET("/:domain", middleware.RequireTenantPermission("id", models.PermTenantRead), handler.GetDomain)              // Get verification status
+		domainGroup.POST("/:domain/verify", middleware.RequireTenantPermission("id", models.PermTenantUpdate), handler.VerifyDomain) // Check for the token now
+		domainGroup.DELETE("/:domain", middleware.RequireTenantPermission("id", models.PermTenantUpdate), handler.RemoveDomain)      // Remove a domain
+	}
+}
@@ -0,0 +1,28 @@
+package routes
+
+import (
+	"identity-service/internal/auth/jwt"
+	"identity-service/internal/handlers"
+	"identity-service/internal/middleware"
+	"identity-service/internal/models"
+	"identity-service/internal/repositories"
+
+	"github.com/gin-gonic/gin"
+)
+
+func GroupRoutes(router *gin.Engine, handler *handlers.GroupHandler, keyManager *jwt.KeyManager, userRepo repositories.UserRepository, sessionRepo repositories.SessionRepository) {
+	groupGroup := router.Group("/api/tenants/:id/groups")
+	jwtMiddleware := middleware.NewJWTAuthMiddleware(keyManager, userRepo, sessionRepo)
+	groupGroup.Use(jwtMiddleware.RequireAuth())
+	{
+		groupGroup.GET("", middleware.RequireTenantPermission("id", models.PermMembersRead), handler.ListGroups)                                      // List the tenant's groups
+		groupGroup.POST("", middleware.RequireTenantPermission("id", models.PermMembersManage), handler.CreateGroup)                                  // Create a group
+		groupGroup.GET("/:groupId", middleware.RequireTenantPermission("id", models.PermMembersRead), handler.GetGroup)                               // Get a group and its members
+		groupGroup.PUT("/:groupId", middleware.RequireTenantPermission("id", models.PermMembersManage), handler.UpdateGroup)                          // Update a group's description or roles
+		groupGroup.DELETE("/:groupId", middleware.RequireTenantPermission("id", models.PermMembersManage), handler.DeleteGroup)                       // Delete a group
+		groupGroup.PUT("/:groupId/users/:userId", middleware.RequireTenantPermission("id", models.PermMembersManage), handler.AddUser)                // Add a member to a group
+		groupGroup.DELETE("/:groupId/users/:userId", middleware.RequireTenantPermission("id", models.PermMembersManage), handler.RemoveUser)          // Remove a member from a group
+		groupGroup.PUT("/:groupId/groups/:memberGroupId", middleware.RequireTenantPermission("id", models.PermMembersManage), handler.AddGroup)       // Nest a group in a group
+		groupGroup.DELETE("/:groupId/groups/:memberGroupId", middleware.RequireTenantPermission("id", models.PermMembersManage), handler.RemoveGroup) // Remove a nested group
+	}
+}
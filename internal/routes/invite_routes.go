@@ -0,0 +1,38 @@
+package routes
+
+import (
+	"identity-service/internal/auth/jwt"
+	"identity-service/internal/handlers"
+	"identity-service/internal/middleware"
+	"identity-service/internal/models"
+	"identity-service/internal/repositories"
+
+	"github.com/gin-gonic/gin"
+)
+
+func InviteRoutes(router *gin.Engine, handler *handlers.InviteHandler, keyManager *jwt.KeyManager, userRepo repositories.UserRepository, sessionRepo repositories.SessionRepository) {
+	jwtMiddleware := middleware.NewJWTAuthMiddleware(keyManager, userRepo, sessionRepo)
+
+	// Invitations sent by a tenant
+	tenantGroup := router.Group("/api/tenants/:id/invites")
+	tenantGroup.Use(jwtMiddleware.RequireAuth(), middleware.RequireTenantPermission("id", models.PermMembersInvite))
+	{
+		tenantGroup.GET("", handler.ListInvites)                    // List invitations
+		tenantGroup.POST("", handler.CreateInvite)                  // Invite an email address
+		tenantGroup.POST("/:inviteId/resend", handler.ResendInvite) // Resend an invitation
+		tenantGroup.DELETE("/:inviteId", handler.DeleteInvite)      // Revoke an invitation
+
+		tenantGroup.POST("/bulk", handler.BulkInvite)             // Upload a CSV of invitations
+		tenantGroup.GET("/bulk", handler.ListBulkInviteJobs)      // List CSV uploads
+		tenantGroup.GET("/bulk/:jobId", handler.GetBulkInviteJob) // Get the progress of an upload
+	}
+
+	// Invitations received, identified by the token from the email
+	inviteGroup := router.Group("/api/invites")
+	{
+		inviteGroup.POST("/lookup", handler.LookupInvitation)                          // Describe an invitation
+		inviteGroup.POST("/signup", handler.SignUp)                                    // Sign up and accept
+		inviteGroup.POST("/decline", handler.DeclineInvite)                            // Decline an invitation
+		inviteGroup.POST("/accept", jwtMiddleware.RequireAuth(), handler.AcceptInvite) // Accept as the signed in user
+	}
+}
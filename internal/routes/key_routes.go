@@ -0,0 +1,25 @@
+package routes
+
+import (
+	"identity-service/internal/auth/jwt"
+	"identity-service/internal/handlers"
+	"identity-service/internal/middleware"
+	"identity-service/internal/repositories"
+
+	"github.com/gin-gonic/gin"
+)
+
+func KeyRoutes(router *gin.Engine, handler *handlers.KeyHandler, keyManager *jwt.KeyManager, userRepo repositories.UserRepository, sessionRepo repositories.SessionRepository) {
+	// Public signing keys, used by downstream services to verify tokens
+	router.GET("/.well-known/jwks.json", handler.JWKS)
+
+	// Key management is restricted to platform administrators
+	adminGroup := router.Group("/api/admin/keys")
+	jwtMiddleware := middleware.NewJWTAuthMiddleware(keyManager, userRepo, sessionRepo)
+	adminGroup.Use(jwtMiddleware.RequireAuth(), middleware.RequireAdmin())
+	{
+		adminGroup.GET("", handler.ListKeys)               // List signing keys and their states
+		adminGroup.POST("/rotate", handler.RotateKeys)     // Force a key rotation
+		adminGroup.POST("/:kid/revoke", handler.RevokeKey) // Emergency-revoke a key
+	}
+}
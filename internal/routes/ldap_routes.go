@@ -0,0 +1,24 @@
+package routes
+
+import (
+	"identity-service/internal/auth/jwt"
+	"identity-service/internal/handlers"
+	"identity-service/internal/middleware"
+	"identity-service/internal/models"
+	"identity-service/internal/repositories"
+
+	"github.com/gin-gonic/gin"
+)
+
+func LDAPRoutes(router *gin.Engine, handler *handlers.LDAPHandler, keyManager *jwt.KeyManager, userRepo repositories.UserRepository, sessionRepo repositories.SessionRepository) {
+	// Directory configuration, scoped to a tenant. Users sign in with POST /api/auth/login.
+	connectionGroup := router.Group("/api/tenants/:id/ldap")
+	jwtMiddleware := middleware.NewJWTAuthMiddleware(keyManager, userRepo, sessionRepo)
+	connectionGroup.Use(jwtMiddleware.RequireAuth(), middleware.RequireTenantPermission("id", models.PermSSOManage))
+	{
+		connectionGroup.GET("", handler.GetConnection)        // Get LDAP connection
+		connectionGroup.PUT("", handler.SaveConnection)       // Create or update LDAP connection
+		connectionGroup.DELETE("", handler.DeleteConnection)  // Delete LDAP connection
+		connectionGroup.POST("/test", handler.TestConnection) // Test LDAP connection
+	}
+}
@@ -0,0 +1,39 @@
+package routes
+
+import (
+	"identity-service/internal/auth/jwt"
+	"identity-service/internal/handlers"
+	"identity-service/internal/middleware"
+	"identity-service/internal/models"
+	"identity-service/internal/repositories"
+
+	"github.com/gin-gonic/gin"
+)
+
+func OAuthServerRoutes(router *gin.Engine, serverHandler *handlers.OAuthServerHandler, clientHandler *handlers.OAuthClientHandler, keyManager *jwt.KeyManager, userRepo repositories.UserRepository, sessionRepo repositories.SessionRepository) {
+	jwtMiddleware := middleware.NewJWTAuthMiddleware(keyManager, userRepo, sessionRepo)
+
+	// Authorization server endpoints used by registered clients
+	oauthGroup := router.Group("/oauth")
+	{
+		oauthGroup.GET("/authorize", serverHandler.Authorize)                                  // Start authorization, redirects to the login page
+		oauthGroup.POST("/authorize", jwtMiddleware.RequireAuth(), serverHandler.Approve)      // Issue a code for the signed-in user
+		oauthGroup.POST("/token", serverHandler.Token)                                         // Token endpoint
+		oauthGroup.POST("/introspect", serverHandler.Introspect)                               // Token introspection (RFC 7662)
+		oauthGroup.POST("/revoke", serverHandler.Revoke)                                       // Token revocation (RFC 7009)
+		oauthGroup.POST("/device_authorization", serverHandler.DeviceAuthorization)            // Start the device flow (RFC 8628)
+		oauthGroup.GET("/device", jwtMiddleware.RequireAuth(), serverHandler.GetDeviceRequest) // Look up a user code
+		oauthGroup.POST("/device", jwtMiddleware.RequireAuth(), serverHandler.DecideDevice)    // Approve or deny a device
+	}
+
+	// Client registration, scoped to a tenant
+	clientGroup := router.Group("/api/tenants/:id/oauth-clients")
+	clientGroup.Use(jwtMiddleware.RequireAuth(), middleware.RequireTenantPermission("id", models.PermClientsManage))
+	{
+		clientGroup.GET("", clientHandler.ListClients)               // List OAuth clients
+		clientGroup.POST("", clientHandler.CreateClient)             // Register OAuth client
+		clientGroup.GET("/:clientId", clientHandler.GetClient)       // Get OAuth client
+		clientGroup.PUT("/:clientId", clientHandler.UpdateClient)    // Update OAuth client
+		clientGroup.DELETE("/:clientId", clientHandler.DeleteClient) // Delete OAuth client
+	}
+}
@@ -0,0 +1,14 @@
+package routes
+
+import (
+	"identity-service/internal/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+func OIDCRoutes(router *gin.Engine, handler *handlers.OIDCHandler) {
+	// OpenID Connect endpoints authenticate with the access token itself
+	router.GET("/.well-known/openid-configuration", handler.Discovery) // Provider metadata
+	router.GET("/userinfo", handler.UserInfo)                          // Claims about the signed-in user
+	router.POST("/userinfo", handler.UserInfo)                         // Claims about the signed-in user
+}
@@ -0,0 +1,24 @@
+package routes
+
+import (
+	"identity-service/internal/auth/jwt"
+	"identity-service/internal/handlers"
+	"identity-service/internal/middleware"
+	"identity-service/internal/models"
+	"identity-service/internal/repositories"
+
+	"github.com/gin-gonic/gin"
+)
+
+func RoleRoutes(router *gin.Engine, handler *handlers.RoleHandler, keyManager *jwt.KeyManager, userRepo repositories.UserRepository, sessionRepo repositories.SessionRepository) {
+	roleGroup := router.Group("/api/tenants/:id/roles")
+	jwtMiddleware := middleware.NewJWTAuthMiddleware(keyManager, userRepo, sessionRepo)
+	roleGroup.Use(jwtMiddleware.RequireAuth())
+	{
+		roleGroup.GET("", middleware.RequireTenantPermission("id", models.PermTenantRead), handler.ListRoles)              // List built-in and custom roles
+		roleGroup.POST("", middleware.RequireTenantPermission("id", models.PermRolesManage), handler.CreateRole)           // Define a custom role
+		roleGroup.GET("/:roleId", middleware.RequireTenantPermission("id", models.PermTenantRead), handler.GetRole)        // Get a custom role
+		roleGroup.PUT("/:roleId", middleware.RequireTenantPermission("id", models.PermRolesManage), handler.UpdateRole)    // Update a custom role
+		roleGroup.DELETE("/:roleId", middleware.RequireTenantPermission("id", models.PermRolesManage), handler.DeleteRole) // Delete an unused custom role
+	}
+}
@@ -0,0 +1,31 @@
+package routes
+
+import (
+	"identity-service/internal/auth/jwt"
+	"identity-service/internal/handlers"
+	"identity-service/internal/middleware"
+	"identity-service/internal/models"
+	"identity-service/internal/repositories"
+
+	"github.com/gin-gonic/gin"
+)
+
+func SAMLRoutes(router *gin.Engine, handler *handlers.SAMLHandler, keyManager *jwt.KeyManager, userRepo repositories.UserRepository, sessionRepo repositories.SessionRepository) {
+	// Service provider endpoints, one entity per tenant
+	samlGroup := router.Group("/api/auth/saml/:tenantId")
+	{
+		samlGroup.GET("/metadata", handler.Metadata) // SP metadata for the tenant's IdP
+		samlGroup.GET("/login", handler.Login)       // Start an SP-initiated login
+		samlGroup.POST("/acs", handler.ACS)          // Assertion consumer service
+	}
+
+	// Identity provider configuration, scoped to a tenant
+	connectionGroup := router.Group("/api/tenants/:id/saml")
+	jwtMiddleware := middleware.NewJWTAuthMiddleware(keyManager, userRepo, sessionRepo)
+	connectionGroup.Use(jwtMiddleware.RequireAuth(), middleware.RequireTenantPermission("id", models.PermSSOManage))
+	{
+		connectionGroup.GET("", handler.GetConnection)       // Get SAML connection
+		connectionGroup.PUT("", handler.SaveConnection)      // Create or update SAML connection
+		connectionGroup.DELETE("", handler.DeleteConnection) // Delete SAML connection
+	}
+}
@@ -0,0 +1,44 @@
+package routes
+
+import (
+	"identity-service/internal/auth/jwt"
+	"identity-service/internal/handlers"
+	"identity-service/internal/middleware"
+	"identity-service/internal/models"
+	"identity-service/internal/repositories"
+
+	"github.com/gin-gonic/gin"
+)
+
+func SCIMRoutes(router *gin.Engine, handler *handlers.SCIMHandler, keyManager *jwt.KeyManager, userRepo repositories.UserRepository, sessionRepo repositories.SessionRepository) {
+	// SCIM 2.0 API, scoped to the tenant of the bearer token
+	scimGroup := router.Group("/scim/v2")
+	scimGroup.Use(handler.RequireToken())
+	{
+		scimGroup.GET("/ServiceProviderConfig", handler.ServiceProviderConfig) // Supported features
+		scimGroup.GET("/ResourceTypes", handler.ResourceTypes)                 // Supported resources
+
+		scimGroup.GET("/Users", handler.ListUsers)           // List and filter users
+		scimGroup.POST("/Users", handler.CreateUser)         // Provision user
+		scimGroup.GET("/Users/:id", handler.GetUser)         // Get user
+		scimGroup.PUT("/Users/:id", handler.ReplaceUser)     // Replace user
+		scimGroup.PATCH("/Users/:id", handler.PatchUser)     // Update or deactivate user
+		scimGroup.DELETE("/Users/:id", handler.DeleteUser)   // Remove user from tenant
+		scimGroup.GET("/Groups", handler.ListGroups)         // List and filter groups
+		scimGroup.POST("/Groups", handler.CreateGroup)       // Provision group
+		scimGroup.GET("/Groups/:id", handler.GetGroup)       // Get group
+		scimGroup.PUT("/Groups/:id", handler.ReplaceGroup)   // Replace group
+		scimGroup.PATCH("/Groups/:id", handler.PatchGroup)   // Update group members
+		scimGroup.DELETE("/Groups/:id", handler.DeleteGroup) // Delete group
+	}
+
+	// SCIM token management, scoped to a tenant
+	tokenGroup := router.Group("/api/tenants/:id/scim-tokens")
+	jwtMiddleware := middleware.NewJWTAuthMiddleware(keyManager, userRepo, sessionRepo)
+	tokenGroup.Use(jwtMiddleware.RequireAuth(), middleware.RequireTenantPermission("id", models.PermSSOManage))
+	{
+		tokenGroup.GET("", handler.ListTokens)              // List SCIM tokens
+		tokenGroup.POST("", handler.CreateToken)            // Issue SCIM token
+		tokenGroup.DELETE("/:tokenId", handler.RevokeToken) // Revoke SCIM token
+	}
+}
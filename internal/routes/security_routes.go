@@ -4,27 +4,29 @@ import (
 	"identity-service/internal/auth/jwt"
 	"identity-service/internal/handlers"
 	"identity-service/internal/middleware"
+	"identity-service/internal/models"
 	"identity-service/internal/repositories"
 
 	"github.com/gin-gonic/gin"
 )
 
-func SecurityRoutes(router *gin.Engine, handler *handlers.SecurityHandler, keyManager *jwt.KeyManager, userRepo repositories.UserRepository) {
+func SecurityRoutes(router *gin.Engine, handler *handlers.SecurityHandler, keyManager *jwt.KeyManager, userRepo repositories.UserRepository, sessionRepo repositories.SessionRepository) {
 	// All security routes require authentication
 	securityGroup := router.Group("/api/security")
-	jwtMiddleware := middleware.NewJWTAuthMiddleware(keyManager, userRepo)
+	jwtMiddleware := middleware.NewJWTAuthMiddleware(keyManager, userRepo, sessionRepo)
 	securityGroup.Use(jwtMiddleware.RequireAuth())
 	{
 		// IP Whitelist management
 		whitelistGroup := securityGroup.Group("/whitelist")
 		{
-			whitelistGroup.GET("", handler.ListWhitelistedIPs)         // List whitelisted IPs
-			whitelistGroup.POST("", handler.AddWhitelistedIP)          // Add IP to whitelist
-			whitelistGroup.DELETE("/:id", handler.RemoveWhitelistedIP) // Remove IP from whitelist
+			whitelistGroup.GET("", middleware.RequirePermission(models.PermSecurityRead), handler.ListWhitelistedIPs)           // List whitelisted IPs
+			whitelistGroup.POST("", middleware.RequirePermission(models.PermSecurityManage), handler.AddWhitelistedIP)          // Add IP to whitelist
+			whitelistGroup.DELETE("/:id", middleware.RequirePermission(models.PermSecurityManage), handler.RemoveWhitelistedIP) // Remove IP from whitelist
 		}
 
 		// API Keys management
 		apiKeyGroup := securityGroup.Group("/api-keys")
+		apiKeyGroup.Use(middleware.RequirePermission(models.PermAPIKeysManage))
 		{
 			apiKeyGroup.GET("", handler.ListAPIKeys)         // List API keys
 			apiKeyGroup.POST("", handler.CreateAPIKey)       // Create new API key
@@ -34,12 +36,12 @@ func SecurityRoutes(router *gin.Engine, handler *handlers.SecurityHandler, keyMa
 		}
 
 		// Audit logs
-		securityGroup.GET("/audit-logs", handler.ListAuditLogs)        // List audit logs
-		securityGroup.GET("/audit-logs/:id", handler.GetAuditLogEntry) // Get audit log entry
+		securityGroup.GET("/audit-logs", middleware.RequirePermission(models.PermAuditRead), handler.ListAuditLogs)        // List audit logs
+		securityGroup.GET("/audit-logs/:id", middleware.RequirePermission(models.PermAuditRead), handler.GetAuditLogEntry) // Get audit log entry
 
 		// Security policies
-		securityGroup.GET("/policies", handler.ListSecurityPolicies)     // List security policies
-		securityGroup.PUT("/policies", handler.UpdateSecurityPolicies)   // Update security policies
-		securityGroup.POST("/policies/test", handler.TestSecurityPolicy) // Test security policy
+		securityGroup.GET("/policies", middleware.RequirePermission(models.PermSecurityRead), handler.ListSecurityPolicies)     // List security policies
+		securityGroup.PUT("/policies", middleware.RequirePermission(models.PermSecurityManage), handler.UpdateSecurityPolicies) // Update security policies
+		securityGroup.POST("/policies/test", middleware.RequirePermission(models.PermSecurityRead), handler.TestSecurityPolicy) // Test security policy
 	}
 }
@@ -4,28 +4,30 @@ import (
 	"identity-service/internal/auth/jwt"
 	"identity-service/internal/handlers"
 	"identity-service/internal/middleware"
+	"identity-service/internal/models"
 	"identity-service/internal/repositories"
 
 	"github.com/gin-gonic/gin"
 )
 
-func UserRoutes(router *gin.Engine, handler *handlers.UserHandler, keyManager *jwt.KeyManager, userRepo repositories.UserRepository) {
+func UserRoutes(router *gin.Engine, handler *handlers.UserHandler, keyManager *jwt.KeyManager, userRepo repositories.UserRepository, sessionRepo repositories.SessionRepository) {
 	// All user routes require authentication
 	userGroup := router.Group("/api/users")
-	jwtMiddleware := middleware.NewJWTAuthMiddleware(keyManager, userRepo)
+	jwtMiddleware := middleware.NewJWTAuthMiddleware(keyManager, userRepo, sessionRepo)
 	userGroup.Use(jwtMiddleware.RequireAuth())
 	{
 		// User management
-		userGroup.GET("", handler.ListUsers)         // List users (with pagination and filters)
-		userGroup.POST("", handler.CreateUser)       // Create new user
-		userGroup.GET("/:id", handler.GetUser)       // Get user details
-		userGroup.PUT("/:id", handler.UpdateUser)    // Update user
-		userGroup.DELETE("/:id", handler.DeleteUser) // Delete user
+		userGroup.GET("", middleware.RequireAdmin(), handler.ListUsers)         // List users (with pagination and filters)
+		userGroup.POST("", middleware.RequireAdmin(), handler.CreateUser)       // Create new user
+		userGroup.GET("/:id", middleware.RequireAdmin(), handler.GetUser)       // Get user details
+		userGroup.PUT("/:id", middleware.RequireAdmin(), handler.UpdateUser)    // Update user
+		userGroup.DELETE("/:id", middleware.RequireAdmin(), handler.DeleteUser) // Delete user
 
 		// User-tenant relationships
-		userGroup.GET("/:id/tenants", handler.ListUserTenants)                   // List user's tenants
-		userGroup.POST("/:id/tenants", handler.AddUserToTenant)                  // Add user to tenant
-		userGroup.DELETE("/:id/tenants/:tenantId", handler.RemoveUserFromTenant) // Remove user from tenant
+		userGroup.GET("/:id/tenants", handler.ListUserTenants)                                                                                             // List user's tenants (own, or platform administrators)
+		userGroup.POST("/:id/tenants", handler.AddUserToTenant)                                                                                            // Add user to tenant (members:manage in the tenant)
+		userGroup.PUT("/:id/tenants/:tenantId", middleware.RequireTenantPermission("tenantId", models.PermMembersManage), handler.UpdateUserRole)          // Change user's role in tenant
+		userGroup.DELETE("/:id/tenants/:tenantId", middleware.RequireTenantPermission("tenantId", models.PermMembersManage), handler.RemoveUserFromTenant) // Remove user from tenant
 
 		// User profile
 		userGroup.GET("/me", handler.GetProfile)              // Get own profile
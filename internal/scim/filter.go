@@ -0,0 +1,591 @@
+package scim
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Filter is a parsed filter expression (RFC 7644 section 3.4.2.2)
+type Filter interface {
+	isFilter()
+}
+
+// Comparison compares an attribute with a value. Operator "pr" has no value.
+type Comparison struct {
+	Attribute string
+	Operator  string
+	Value     interface{}
+}
+
+// Logical joins two filters with "and" or "or"
+type Logical struct {
+	Operator string
+	Left     Filter
+	Right    Filter
+}
+
+// Not negates a filter
+type Not struct {
+	Filter Filter
+}
+
+// ValuePath filters the elements of a multi-valued attribute, e.g. emails[type eq "work"]
+type ValuePath struct {
+	Attribute string
+	Filter    Filter
+}
+
+func (*Comparison) isFilter() {}
+func (*Logical) isFilter()    {}
+func (*Not) isFilter()        {}
+func (*ValuePath) isFilter()  {}
+
+var comparisonOperators = map[string]bool{
+	"eq": true, "ne": true, "co": true, "sw": true, "ew": true,
+	"gt": true, "ge": true, "lt": true, "le": true,
+}
+
+// ParseFilter parses a filter. Attribute names are lower-cased and stripped of the core
+// schema URN, since SCIM attribute names are case-insensitive.
+func ParseFilter(filter string) (Filter, error) {
+	tokens, err := tokenize(filter)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos != len(p.tokens) {
+		return nil, fmt.Errorf("%w: unexpected %q", ErrInvalidFilter, p.tokens[p.pos].text)
+	}
+	return expr, nil
+}
+
+// NormalizeAttribute lower-cases an attribute path and strips the core schema URNs
+func NormalizeAttribute(attribute string) string {
+	lower := strings.ToLower(attribute)
+	for _, schema := range []string{SchemaUser, SchemaGroup} {
+		prefix := strings.ToLower(schema) + ":"
+		if strings.HasPrefix(lower, prefix) {
+			return lower[len(prefix):]
+		}
+	}
+	return lower
+}
+
+type tokenKind int
+
+const (
+	tokenWord tokenKind = iota
+	tokenString
+	tokenOpen
+	tokenClose
+	tokenOpenBracket
+	tokenCloseBracket
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+func tokenize(input string) ([]token, error) {
+	var tokens []token
+	runes := []rune(input)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			tokens = append(tokens, token{tokenOpen, "("})
+			i++
+		case r == ')':
+			tokens = append(tokens, token{tokenClose, ")"})
+			i++
+		case r == '[':
+			tokens = append(tokens, token{tokenOpenBracket, "["})
+			i++
+		case r == ']':
+			tokens = append(tokens, token{tokenCloseBracket, "]"})
+			i++
+		case r == '"':
+			// Strings are JSON strings
+			end := i + 1
+			for ; end < len(runes) && runes[end] != '"'; end++ {
+				if runes[end] == '\\' {
+					end++
+				}
+			}
+			if end >= len(runes) {
+				return nil, fmt.Errorf("%w: unterminated string", ErrInvalidFilter)
+			}
+			value, err := strconv.Unquote(string(runes[i : end+1]))
+			if err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrInvalidFilter, err)
+			}
+			tokens = append(tokens, token{tokenString, value})
+			i = end + 1
+		default:
+			end := i
+			for ; end < len(runes); end++ {
+				c := runes[end]
+				if unicode.IsSpace(c) || c == '(' || c == ')' || c == '[' || c == ']' || c == '"' {
+					break
+				}
+			}
+			tokens = append(tokens, token{tokenWord, string(runes[i:end])})
+			i = end
+		}
+	}
+	return tokens, nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peekKeyword(keyword string) bool {
+	return p.pos < len(p.tokens) && p.tokens[p.pos].kind == tokenWord && strings.EqualFold(p.tokens[p.pos].text, keyword)
+}
+
+func (p *parser) expect(kind tokenKind) error {
+	if p.pos >= len(p.tokens) || p.tokens[p.pos].kind != kind {
+		return fmt.Errorf("%w: unexpected end of filter", ErrInvalidFilter)
+	}
+	p.pos++
+	return nil
+}
+
+func (p *parser) parseOr() (Filter, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peekKeyword("or") {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &Logical{Operator: "or", Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (Filter, error) {
+	left, err := p.parseFactor()
+	if err != nil {
+		return nil, err
+	}
+	for p.peekKeyword("and") {
+		p.pos++
+		right, err := p.parseFactor()
+		if err != nil {
+			return nil, err
+		}
+		left = &Logical{Operator: "and", Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseFactor() (Filter, error) {
+	if p.pos >= len(p.tokens) {
+		return nil, fmt.Errorf("%w: unexpected end of filter", ErrInvalidFilter)
+	}
+
+	if p.peekKeyword("not") {
+		p.pos++
+		if err := p.expect(tokenOpen); err != nil {
+			return nil, err
+		}
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(tokenClose); err != nil {
+			return nil, err
+		}
+		return &Not{Filter: inner}, nil
+	}
+
+	if p.tokens[p.pos].kind == tokenOpen {
+		p.pos++
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(tokenClose); err != nil {
+			return nil, err
+		}
+		return inner, nil
+	}
+
+	attr := p.tokens[p.pos]
+	if attr.kind != tokenWord {
+		return nil, fmt.Errorf("%w: expected attribute, got %q", ErrInvalidFilter, attr.text)
+	}
+	p.pos++
+	attribute := NormalizeAttribute(attr.text)
+
+	if p.pos < len(p.tokens) && p.tokens[p.pos].kind == tokenOpenBracket {
+		p.pos++
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(tokenCloseBracket); err != nil {
+			return nil, err
+		}
+		return &ValuePath{Attribute: attribute, Filter: inner}, nil
+	}
+
+	if p.pos >= len(p.tokens) || p.tokens[p.pos].kind != tokenWord {
+		return nil, fmt.Errorf("%w: expected operator after %q", ErrInvalidFilter, attr.text)
+	}
+	operator := strings.ToLower(p.tokens[p.pos].text)
+	p.pos++
+	if operator == "pr" {
+		return &Comparison{Attribute: attribute, Operator: operator}, nil
+	}
+	if !comparisonOperators[operator] {
+		return nil, fmt.Errorf("%w: unknown operator %q", ErrInvalidFilter, operator)
+	}
+
+	if p.pos >= len(p.tokens) {
+		return nil, fmt.Errorf("%w: expected value after %q", ErrInvalidFilter, operator)
+	}
+	valueToken := p.tokens[p.pos]
+	p.pos++
+	var value interface{}
+	switch {
+	case valueToken.kind == tokenString:
+		value = valueToken.text
+	case valueToken.kind == tokenWord && valueToken.text == "true":
+		value = true
+	case valueToken.kind == tokenWord && valueToken.text == "false":
+		value = false
+	case valueToken.kind == tokenWord && valueToken.text == "null":
+		value = nil
+	case valueToken.kind == tokenWord:
+		number, err := strconv.ParseFloat(valueToken.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid value %q", ErrInvalidFilter, valueToken.text)
+		}
+		value = number
+	default:
+		return nil, fmt.Errorf("%w: invalid value %q", ErrInvalidFilter, valueToken.text)
+	}
+
+	return &Comparison{Attribute: attribute, Operator: operator, Value: value}, nil
+}
+
+// ColumnType decides how comparisons against a column are written
+type ColumnType int
+
+const (
+	// StringColumn compares case-insensitively
+	StringColumn ColumnType = iota
+	// CaseExactColumn compares case-sensitively
+	CaseExactColumn
+	BooleanColumn
+	DateTimeColumn
+	// StringArrayColumn matches if any element matches
+	StringArrayColumn
+)
+
+// Column maps a filterable attribute onto a SQL expression
+type Column struct {
+	Expr string
+	Type ColumnType
+}
+
+// ToSQL translates a filter into a SQL condition over the given columns, keyed by
+// normalized attribute name
+func ToSQL(filter Filter, columns map[string]Column) (string, []interface{}, error) {
+	switch f := filter.(type) {
+	case *Logical:
+		left, leftArgs, err := ToSQL(f.Left, columns)
+		if err != nil {
+			return "", nil, err
+		}
+		right, rightArgs, err := ToSQL(f.Right, columns)
+		if err != nil {
+			return "", nil, err
+		}
+		return fmt.Sprintf("(%s %s %s)", left, strings.ToUpper(f.Operator), right), append(leftArgs, rightArgs...), nil
+	case *Not:
+		inner, args, err := ToSQL(f.Filter, columns)
+		if err != nil {
+			return "", nil, err
+		}
+		return fmt.Sprintf("NOT (%s)", inner), args, nil
+	case *Comparison:
+		column, ok := columns[f.Attribute]
+		if !ok {
+			return "", nil, fmt.Errorf("%w: %q is not filterable", ErrInvalidFilter, f.Attribute)
+		}
+		return comparisonSQL(column, f)
+	case *ValuePath:
+		// Only sub-attributes stored in their own column can be filtered on, e.g. emails[value eq "x"]
+		prefix := f.Attribute + "."
+		subColumns := make(map[string]Column)
+		for name, column := range columns {
+			if strings.HasPrefix(name, prefix) {
+				subColumns[name[len(prefix):]] = column
+			}
+		}
+		return ToSQL(f.Filter, subColumns)
+	default:
+		return "", nil, fmt.Errorf("%w: unsupported filter", ErrInvalidFilter)
+	}
+}
+
+func comparisonSQL(column Column, f *Comparison) (string, []interface{}, error) {
+	if f.Operator == "pr" {
+		switch column.Type {
+		case StringColumn, CaseExactColumn:
+			return fmt.Sprintf("(%s IS NOT NULL AND %s <> '')", column.Expr, column.Expr), nil, nil
+		case StringArrayColumn:
+			return fmt.Sprintf("cardinality(%s) > 0", column.Expr), nil, nil
+		default:
+			return fmt.Sprintf("%s IS NOT NULL", column.Expr), nil, nil
+		}
+	}
+
+	switch column.Type {
+	case BooleanColumn:
+		value, ok := f.Value.(bool)
+		if !ok || (f.Operator != "eq" && f.Operator != "ne") {
+			return "", nil, fmt.Errorf("%w: %q only supports eq and ne with a boolean", ErrInvalidFilter, f.Attribute)
+		}
+		return fmt.Sprintf("%s %s ?", column.Expr, sqlOperator(f.Operator)), []interface{}{value}, nil
+
+	case DateTimeColumn:
+		text, ok := f.Value.(string)
+		if !ok {
+			return "", nil, fmt.Errorf("%w: %q must be compared with a date", ErrInvalidFilter, f.Attribute)
+		}
+		value, err := time.Parse(time.RFC3339, text)
+		if err != nil || !isOrderingOperator(f.Operator) && f.Operator != "eq" && f.Operator != "ne" {
+			return "", nil, fmt.Errorf("%w: invalid date comparison on %q", ErrInvalidFilter, f.Attribute)
+		}
+		return fmt.Sprintf("%s %s ?", column.Expr, sqlOperator(f.Operator)), []interface{}{value}, nil
+
+	case StringArrayColumn:
+		text, ok := f.Value.(string)
+		if !ok {
+			return "", nil, fmt.Errorf("%w: %q must be compared with a string", ErrInvalidFilter, f.Attribute)
+		}
+		switch f.Operator {
+		case "eq":
+			return fmt.Sprintf("? = ANY(%s)", column.Expr), []interface{}{text}, nil
+		case "ne":
+			return fmt.Sprintf("NOT (? = ANY(%s))", column.Expr), []interface{}{text}, nil
+		case "co", "sw", "ew":
+			return fmt.Sprintf("EXISTS (SELECT 1 FROM unnest(%s) AS element WHERE element LIKE ?)", column.Expr),
+				[]interface{}{likePattern(f.Operator, text)}, nil
+		default:
+			return "", nil, fmt.Errorf("%w: %q does not support %s", ErrInvalidFilter, f.Attribute, f.Operator)
+		}
+
+	default:
+		text, ok := f.Value.(string)
+		if !ok {
+			return "", nil, fmt.Errorf("%w: %q must be compared with a string", ErrInvalidFilter, f.Attribute)
+		}
+		expr, placeholder, like := column.Expr, "?", "LIKE"
+		if column.Type == StringColumn {
+			expr, placeholder, like = "LOWER("+column.Expr+")", "LOWER(?)", "ILIKE"
+		}
+		switch f.Operator {
+		case "co", "sw", "ew":
+			return fmt.Sprintf("%s %s ?", column.Expr, like), []interface{}{likePattern(f.Operator, text)}, nil
+		default:
+			return fmt.Sprintf("%s %s %s", expr, sqlOperator(f.Operator), placeholder), []interface{}{text}, nil
+		}
+	}
+}
+
+func isOrderingOperator(operator string) bool {
+	switch operator {
+	case "gt", "ge", "lt", "le":
+		return true
+	}
+	return false
+}
+
+func sqlOperator(operator string) string {
+	switch operator {
+	case "ne":
+		return "<>"
+	case "gt":
+		return ">"
+	case "ge":
+		return ">="
+	case "lt":
+		return "<"
+	case "le":
+		return "<="
+	default:
+		return "="
+	}
+}
+
+// likePattern escapes a value for LIKE and anchors it for co, sw or ew
+func likePattern(operator, value string) string {
+	escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+	switch operator {
+	case "sw":
+		return escaped + "%"
+	case "ew":
+		return "%" + escaped
+	default:
+		return "%" + escaped + "%"
+	}
+}
+
+// Matches evaluates a filter against a JSON resource, as decoded into a map. String
+// comparisons are case-insensitive.
+func Matches(filter Filter, resource map[string]interface{}) bool {
+	switch f := filter.(type) {
+	case *Logical:
+		if f.Operator == "and" {
+			return Matches(f.Left, resource) && Matches(f.Right, resource)
+		}
+		return Matches(f.Left, resource) || Matches(f.Right, resource)
+	case *Not:
+		return !Matches(f.Filter, resource)
+	case *ValuePath:
+		elements, _ := lookup(resource, f.Attribute).([]interface{})
+		for _, element := range elements {
+			if object, ok := element.(map[string]interface{}); ok && Matches(f.Filter, object) {
+				return true
+			}
+		}
+		return false
+	case *Comparison:
+		return compare(lookupPath(resource, f.Attribute), f)
+	}
+	return false
+}
+
+// lookupPath resolves a dotted attribute path. Multi-valued attributes match if any element does.
+func lookupPath(resource map[string]interface{}, path string) interface{} {
+	name, sub, hasSub := strings.Cut(path, ".")
+	value := lookup(resource, name)
+	if !hasSub {
+		return value
+	}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return lookupPath(v, sub)
+	case []interface{}:
+		var values []interface{}
+		for _, element := range v {
+			if object, ok := element.(map[string]interface{}); ok {
+				values = append(values, lookupPath(object, sub))
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+func compare(actual interface{}, f *Comparison) bool {
+	if values, ok := actual.([]interface{}); ok {
+		for _, value := range values {
+			if compare(value, f) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if f.Operator == "pr" {
+		return actual != nil && actual != ""
+	}
+
+	switch expected := f.Value.(type) {
+	case string:
+		text, ok := actual.(string)
+		if !ok {
+			return false
+		}
+		text, expected = strings.ToLower(text), strings.ToLower(expected)
+		switch f.Operator {
+		case "eq":
+			return text == expected
+		case "ne":
+			return text != expected
+		case "co":
+			return strings.Contains(text, expected)
+		case "sw":
+			return strings.HasPrefix(text, expected)
+		case "ew":
+			return strings.HasSuffix(text, expected)
+		case "gt":
+			return text > expected
+		case "ge":
+			return text >= expected
+		case "lt":
+			return text < expected
+		case "le":
+			return text <= expected
+		}
+	case bool:
+		value, ok := actual.(bool)
+		if !ok {
+			return false
+		}
+		return (f.Operator == "eq") == (value == expected)
+	case float64:
+		value, ok := actual.(float64)
+		if !ok {
+			return false
+		}
+		switch f.Operator {
+		case "eq":
+			return value == expected
+		case "ne":
+			return value != expected
+		case "gt":
+			return value > expected
+		case "ge":
+			return value >= expected
+		case "lt":
+			return value < expected
+		case "le":
+			return value <= expected
+		}
+	case nil:
+		return (f.Operator == "eq") == (actual == nil)
+	}
+	return false
+}
+
+// lookup finds an attribute by case-insensitive name
+func lookup(resource map[string]interface{}, name string) interface{} {
+	if key, ok := findKey(resource, name); ok {
+		return resource[key]
+	}
+	return nil
+}
+
+func findKey(resource map[string]interface{}, name string) (string, bool) {
+	if _, ok := resource[name]; ok {
+		return name, true
+	}
+	for key := range resource {
+		if strings.EqualFold(key, name) {
+			return key, true
+		}
+	}
+	return name, false
+}
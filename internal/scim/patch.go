@@ -0,0 +1,226 @@
+package scim
+
+import (
+	"fmt"
+	"strings"
+)
+
+// PatchRequest is the body of a PATCH request (RFC 7644 section 3.5.2)
+type PatchRequest struct {
+	Schemas    []string         `json:"schemas"`
+	Operations []PatchOperation `json:"Operations" binding:"required"`
+}
+
+// PatchOperation adds, replaces or removes the attribute at Path. Without a path, Value holds
+// the attributes to change.
+type PatchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path,omitempty"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// attributePath is a parsed PATCH path: attribute[filter].subAttribute
+type attributePath struct {
+	attribute    string
+	filter       Filter
+	subAttribute string
+}
+
+func parsePath(path string) (*attributePath, error) {
+	path = strings.TrimSpace(path)
+	parsed := &attributePath{}
+
+	if open := strings.Index(path, "["); open >= 0 {
+		closing := strings.LastIndex(path, "]")
+		if closing < open {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidPath, path)
+		}
+		filter, err := ParseFilter(path[open+1 : closing])
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidPath, path)
+		}
+		parsed.attribute = NormalizeAttribute(path[:open])
+		parsed.filter = filter
+		rest := path[closing+1:]
+		if rest != "" {
+			if !strings.HasPrefix(rest, ".") {
+				return nil, fmt.Errorf("%w: %q", ErrInvalidPath, path)
+			}
+			parsed.subAttribute = strings.ToLower(rest[1:])
+		}
+	} else {
+		attribute := NormalizeAttribute(path)
+		parsed.attribute, parsed.subAttribute, _ = strings.Cut(attribute, ".")
+	}
+
+	if parsed.attribute == "" || strings.ContainsAny(parsed.attribute, " ()") {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidPath, path)
+	}
+	return parsed, nil
+}
+
+// ApplyPatch applies one operation to a resource decoded from JSON. Operation names are
+// case-insensitive, as some identity providers send "Replace".
+func ApplyPatch(resource map[string]interface{}, operation PatchOperation) error {
+	op := strings.ToLower(operation.Op)
+	if op != "add" && op != "replace" && op != "remove" {
+		return fmt.Errorf("%w: unknown op %q", ErrInvalidPath, operation.Op)
+	}
+
+	if operation.Path == "" {
+		if op == "remove" {
+			return fmt.Errorf("%w: remove requires a path", ErrNoTarget)
+		}
+		values, ok := operation.Value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%w: value must be an object when no path is given", ErrInvalidPath)
+		}
+		for key, value := range values {
+			if err := ApplyPatch(resource, PatchOperation{Op: op, Path: key, Value: value}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	path, err := parsePath(operation.Path)
+	if err != nil {
+		return err
+	}
+	key, _ := findKey(resource, path.attribute)
+
+	if path.filter != nil {
+		return patchFiltered(resource, key, path, op, operation.Value)
+	}
+
+	if path.subAttribute != "" {
+		object, _ := resource[key].(map[string]interface{})
+		if object == nil {
+			if op == "remove" {
+				return nil
+			}
+			object = map[string]interface{}{}
+			resource[key] = object
+		}
+		return ApplyPatch(object, PatchOperation{Op: op, Path: path.subAttribute, Value: operation.Value})
+	}
+
+	switch op {
+	case "remove":
+		// Removing listed values from a multi-valued attribute, e.g. group members
+		if values, ok := operation.Value.([]interface{}); ok {
+			if existing, ok := resource[key].([]interface{}); ok {
+				resource[key] = withoutValues(existing, values)
+				return nil
+			}
+		}
+		delete(resource, key)
+	case "add":
+		existing, isArray := resource[key].([]interface{})
+		if isArray {
+			if values, ok := operation.Value.([]interface{}); ok {
+				resource[key] = appendValues(existing, values)
+				return nil
+			}
+		}
+		if existing, ok := resource[key].(map[string]interface{}); ok {
+			if values, ok := operation.Value.(map[string]interface{}); ok {
+				for k, v := range values {
+					existing[k] = v
+				}
+				return nil
+			}
+		}
+		resource[key] = operation.Value
+	default:
+		resource[key] = operation.Value
+	}
+	return nil
+}
+
+// patchFiltered changes the elements of a multi-valued attribute selected by a filter
+func patchFiltered(resource map[string]interface{}, key string, path *attributePath, op string, value interface{}) error {
+	elements, _ := resource[key].([]interface{})
+	matched := false
+	kept := elements[:0:0]
+
+	for _, element := range elements {
+		object, ok := element.(map[string]interface{})
+		if !ok || !Matches(path.filter, object) {
+			kept = append(kept, element)
+			continue
+		}
+		matched = true
+
+		switch {
+		case op == "remove" && path.subAttribute == "":
+			continue
+		case op == "remove":
+			subKey, _ := findKey(object, path.subAttribute)
+			delete(object, subKey)
+		case path.subAttribute != "":
+			subKey, _ := findKey(object, path.subAttribute)
+			object[subKey] = value
+		default:
+			if replacement, ok := value.(map[string]interface{}); ok {
+				object = replacement
+			}
+		}
+		kept = append(kept, object)
+	}
+
+	if !matched {
+		if op == "remove" {
+			return ErrNoTarget
+		}
+		// Setting emails[type eq "work"].value creates the work email if it is missing
+		comparison, ok := path.filter.(*Comparison)
+		if !ok || comparison.Operator != "eq" || path.subAttribute == "" {
+			return ErrNoTarget
+		}
+		kept = append(kept, map[string]interface{}{
+			comparison.Attribute: comparison.Value,
+			path.subAttribute:    value,
+		})
+	}
+
+	resource[key] = kept
+	return nil
+}
+
+// appendValues adds values to a multi-valued attribute, skipping those already present
+func appendValues(existing, values []interface{}) []interface{} {
+	for _, value := range values {
+		if !containsValue(existing, value) {
+			existing = append(existing, value)
+		}
+	}
+	return existing
+}
+
+func withoutValues(existing, values []interface{}) []interface{} {
+	kept := existing[:0:0]
+	for _, element := range existing {
+		if !containsValue(values, element) {
+			kept = append(kept, element)
+		}
+	}
+	return kept
+}
+
+// containsValue compares complex values by their "value" sub-attribute
+func containsValue(values []interface{}, value interface{}) bool {
+	for _, element := range values {
+		if fmt.Sprint(valueOf(element)) == fmt.Sprint(valueOf(value)) {
+			return true
+		}
+	}
+	return false
+}
+
+func valueOf(element interface{}) interface{} {
+	if object, ok := element.(map[string]interface{}); ok {
+		return lookup(object, "value")
+	}
+	return element
+}
@@ -0,0 +1,119 @@
+package scim
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// User is the core User resource (RFC 7643 section 4.1)
+type User struct {
+	Schemas     []string      `json:"schemas"`
+	ID          string        `json:"id,omitempty"`
+	ExternalID  string        `json:"externalId,omitempty"`
+	UserName    string        `json:"userName"`
+	Name        *Name         `json:"name,omitempty"`
+	DisplayName string        `json:"displayName,omitempty"`
+	Emails      []MultiValued `json:"emails,omitempty"`
+	Active      *bool         `json:"active,omitempty"`
+	Roles       []MultiValued `json:"roles,omitempty"`
+	Groups      []GroupRef    `json:"groups,omitempty"`
+	Meta        *Meta         `json:"meta,omitempty"`
+}
+
+// Name holds the components of a user's name
+type Name struct {
+	Formatted  string `json:"formatted,omitempty"`
+	GivenName  string `json:"givenName,omitempty"`
+	FamilyName string `json:"familyName,omitempty"`
+}
+
+// MultiValued is an element of a multi-valued attribute such as emails or roles
+type MultiValued struct {
+	Value   string `json:"value"`
+	Type    string `json:"type,omitempty"`
+	Display string `json:"display,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+}
+
+// GroupRef is a group a user belongs to. It is read-only on users.
+type GroupRef struct {
+	Value   string `json:"value"`
+	Ref     string `json:"$ref,omitempty"`
+	Display string `json:"display,omitempty"`
+}
+
+// Group is the core Group resource (RFC 7643 section 4.2)
+type Group struct {
+	Schemas     []string `json:"schemas"`
+	ID          string   `json:"id,omitempty"`
+	ExternalID  string   `json:"externalId,omitempty"`
+	DisplayName string   `json:"displayName"`
+	Members     []Member `json:"members,omitempty"`
+	Meta        *Meta    `json:"meta,omitempty"`
+}
+
+// Member is a user in a group
+type Member struct {
+	Value   string `json:"value"`
+	Ref     string `json:"$ref,omitempty"`
+	Display string `json:"display,omitempty"`
+}
+
+// Meta describes a resource (RFC 7643 section 3.1)
+type Meta struct {
+	ResourceType string    `json:"resourceType"`
+	Created      time.Time `json:"created"`
+	LastModified time.Time `json:"lastModified"`
+	Location     string    `json:"location,omitempty"`
+}
+
+// ListResponse is a page of query results (RFC 7644 section 3.4.2)
+type ListResponse struct {
+	Schemas      []string      `json:"schemas"`
+	TotalResults int64         `json:"totalResults"`
+	StartIndex   int           `json:"startIndex"`
+	ItemsPerPage int           `json:"itemsPerPage"`
+	Resources    []interface{} `json:"Resources"`
+}
+
+// NewListResponse creates a list response for one page of resources
+func NewListResponse(resources []interface{}, total int64, startIndex int) *ListResponse {
+	if resources == nil {
+		resources = []interface{}{}
+	}
+	return &ListResponse{
+		Schemas:      []string{SchemaListResponse},
+		TotalResults: total,
+		StartIndex:   startIndex,
+		ItemsPerPage: len(resources),
+		Resources:    resources,
+	}
+}
+
+// Pagination limits (RFC 7644 section 3.4.2.4)
+const (
+	DefaultCount = 100
+	MaxCount     = 200
+)
+
+// ParsePagination parses the 1-based startIndex and count query parameters. Values below 1
+// are treated as 1 and 0 respectively, and count is capped at MaxCount.
+func ParsePagination(startIndex, count string) (int, int, error) {
+	start, size := 1, DefaultCount
+	if startIndex != "" {
+		value, err := strconv.Atoi(startIndex)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid startIndex %q", startIndex)
+		}
+		start = max(value, 1)
+	}
+	if count != "" {
+		value, err := strconv.Atoi(count)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid count %q", count)
+		}
+		size = min(max(value, 0), MaxCount)
+	}
+	return start, size, nil
+}
@@ -0,0 +1,22 @@
+// Package scim implements the protocol parts of SCIM 2.0 (RFC 7643 and RFC 7644): resource
+// representations, filters and PATCH operations.
+package scim
+
+import "errors"
+
+var (
+	ErrInvalidFilter = errors.New("invalid filter")
+	ErrInvalidPath   = errors.New("invalid path")
+	ErrNoTarget      = errors.New("no target matched the path")
+)
+
+// Schema URNs (RFC 7643 section 8.7)
+const (
+	SchemaUser                  = "urn:ietf:params:scim:schemas:core:2.0:User"
+	SchemaGroup                 = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	SchemaListResponse          = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	SchemaPatchOp               = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	SchemaError                 = "urn:ietf:params:scim:api:messages:2.0:Error"
+	SchemaServiceProviderConfig = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+	SchemaResourceType          = "urn:ietf:params:scim:schemas:core:2.0:ResourceType"
+)
@@ -0,0 +1,33 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"identity-service/internal/models"
+	"identity-service/internal/repositories"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// recordAudit records an action in the tenant's audit log. A failure to record is logged
+// rather than undoing the action.
+func recordAudit(repo repositories.SecurityRepository, tenantID, userID uuid.UUID, action, resource string, details map[string]string) {
+	detailsJSON, err := json.Marshal(details)
+	if err != nil {
+		detailsJSON = []byte(fmt.Sprint(details))
+	}
+	entry := &models.AuditLog{
+		ID:        uuid.New(),
+		TenantID:  tenantID,
+		UserID:    userID,
+		Action:    action,
+		Resource:  resource,
+		Details:   string(detailsJSON),
+		CreatedAt: time.Now(),
+	}
+	if err := repo.CreateAuditLog(entry); err != nil {
+		log.Printf("Failed to write audit log %s for tenant %s: %v", action, tenantID, err)
+	}
+}
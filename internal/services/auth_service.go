@@ -16,6 +16,14 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	// AccessTokenTTL is the lifetime of access tokens
+	AccessTokenTTL = 15 * time.Minute
+	// RefreshTokenTTL is the lifetime of refresh tokens
+	RefreshTokenTTL = 7 * 24 * time.Hour
 )
 
 type AuthService interface {
@@ -39,16 +47,25 @@ type AuthService interface {
 	DisableMFA(ctx *gin.Context, password string) error
 	VerifyMFA(ctx *gin.Context, token string) error
 	CreateSession(ctx *gin.Context, user *models.User, tenantID uuid.UUID) (*models.Session, error)
+	CreateClientSession(ctx *gin.Context, user *models.User, tenantID uuid.UUID, clientID string, scope string) (*models.Session, error)
+	RefreshClientSession(ctx *gin.Context, refreshToken string, clientID string, scope string) (*models.Session, error)
+	IssueClientToken(client *models.OAuthClient, scope string) (string, error)
+	IssueDelegatedToken(subject *Claims, clientID string, audience []string, scope string, expiresAt time.Time) (string, error)
+	InspectToken(token string) (*Claims, error)
+	EndSession(sessionID uuid.UUID) error
 }
 
 type authService struct {
 	userService    UserService
 	sessionRepo    repositories.SessionRepository
 	keyManager     *jwtmanager.KeyManager
+	ldapService    LDAPService
+	discovery      DiscoveryService
+	groupRepo      repositories.GroupRepository
 	oauthProviders map[string]auth.OAuthProviderInterface
 }
 
-func NewAuthService(userService UserService, sessionRepo repositories.SessionRepository, keyManager *jwtmanager.KeyManager) AuthService {
+func NewAuthService(userService UserService, sessionRepo repositories.SessionRepository, keyManager *jwtmanager.KeyManager, ldapService LDAPService, discovery DiscoveryService, groupRepo repositories.GroupRepository) AuthService {
 	providers := map[string]auth.OAuthProviderInterface{
 		"google": auth.NewGoogleProvider(),
 		// Add more providers here as needed
@@ -60,6 +77,9 @@ func NewAuthService(userService UserService, sessionRepo repositories.SessionRep
 		userService:    userService,
 		sessionRepo:    sessionRepo,
 		keyManager:     keyManager,
+		ldapService:    ldapService,
+		discovery:      discovery,
+		groupRepo:      groupRepo,
 		oauthProviders: providers,
 	}
 }
@@ -93,8 +113,21 @@ func (s *authService) HandleOAuthCallback(ctx *gin.Context, provider string, cod
 }
 
 func (s *authService) Login(ctx *gin.Context, credentials *models.LoginCredentials) (*models.Session, error) {
+	// Users in a domain with an LDAP connection are checked against the directory and
+	// signed in to the connection's tenant
+	user, tenantID, err := s.ldapService.Authenticate(credentials.Email, credentials.Password)
+	if err == nil {
+		return s.CreateSession(ctx, user, tenantID)
+	}
+	if !errors.Is(err, ErrLDAPNotConfigured) {
+		return nil, err
+	}
+	if err := s.discovery.CheckPasswordLogin(credentials.Email); err != nil {
+		return nil, err
+	}
+
 	// Get user by email
-	user, err := s.userService.GetUserByEmail(credentials.Email)
+	user, err = s.userService.GetUserByEmail(credentials.Email)
 	if err != nil {
 		return nil, errors.New("invalid credentials")
 	}
@@ -144,6 +177,14 @@ func (s *authService) Logout(ctx *gin.Context) error {
 }
 
 func (s *authService) RefreshToken(ctx *gin.Context, refreshToken string) (*models.Session, error) {
+	return s.RefreshClientSession(ctx, refreshToken, "", "")
+}
+
+// RefreshClientSession rotates the session behind a refresh token, which can only be used once.
+// The session must have been issued to clientID; first-party sessions use an empty client ID.
+// A non-empty scope narrows the new access token; the new session and refresh token keep the
+// scope originally granted (RFC 6749 section 6).
+func (s *authService) RefreshClientSession(ctx *gin.Context, refreshToken string, clientID string, scope string) (*models.Session, error) {
 	// Parse refresh token
 	claims, err := s.parseToken(refreshToken)
 	if err != nil {
@@ -161,14 +202,35 @@ func (s *authService) RefreshToken(ctx *gin.Context, refreshToken string) (*mode
 		return nil, err
 	}
 
+	if session.ClientID != clientID {
+		return nil, errors.New("refresh token was issued to another client")
+	}
+
+	grantedScope, err := narrowScope(scope, session.Scope)
+	if err != nil {
+		return nil, err
+	}
+
 	// Get user to verify they still exist
 	user, err := s.userService.GetUser(session.UserID)
 	if err != nil {
 		return nil, fmt.Errorf("user not found: %v", err)
 	}
+	if err := s.requireMembership(session.UserID, session.TenantID); err != nil {
+		return nil, err
+	}
 
-	// Create new session with same tenant
-	return s.CreateSession(ctx, user, session.TenantID)
+	// Invalidate the old refresh token before issuing a new one. Of concurrent refreshes
+	// with the same token only one consumes the session.
+	if err := s.sessionRepo.ConsumeSession(session.ID, strings.TrimPrefix(refreshToken, "Bearer ")); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("refresh token has already been used")
+		}
+		return nil, fmt.Errorf("failed to rotate session: %v", err)
+	}
+
+	// Create new session with same tenant, client and scope
+	return s.createSession(ctx, user, session.TenantID, session.ClientID, session.Scope, grantedScope)
 }
 
 func (s *authService) ValidateToken(token string) (*models.Session, error) {
@@ -211,9 +273,9 @@ func (s *authService) SwitchTenant(ctx *gin.Context, tenantID uuid.UUID) (*model
 		ID:           sessionID,
 		UserID:       claims.UserID,
 		TenantID:     tenantID,
-		AccessToken:  s.generateToken(claims.UserID, sessionID, "access", tenantID, 15*time.Minute),
-		RefreshToken: s.generateToken(claims.UserID, sessionID, "refresh", tenantID, 7*24*time.Hour),
-		ExpiresAt:    time.Now().Add(15 * time.Minute),
+		AccessToken:  s.generateToken(claims.UserID, sessionID, "access", tenantID, "", "", AccessTokenTTL),
+		RefreshToken: s.generateToken(claims.UserID, sessionID, "refresh", tenantID, "", "", RefreshTokenTTL),
+		ExpiresAt:    time.Now().Add(AccessTokenTTL),
 		CreatedAt:    time.Now(),
 		LastUsedAt:   time.Now(),
 		IPAddress:    ctx.ClientIP(),
@@ -431,19 +493,33 @@ func (s *authService) VerifyMFA(ctx *gin.Context, token string) error {
 }
 
 func (s *authService) CreateSession(ctx *gin.Context, user *models.User, tenantID uuid.UUID) (*models.Session, error) {
+	return s.CreateClientSession(ctx, user, tenantID, "", "")
+}
+
+// CreateClientSession creates a session whose tokens are issued to an OAuth client with the granted scope
+func (s *authService) CreateClientSession(ctx *gin.Context, user *models.User, tenantID uuid.UUID, clientID string, scope string) (*models.Session, error) {
+	return s.createSession(ctx, user, tenantID, clientID, scope, scope)
+}
+
+// createSession creates a session granted scope whose access token carries accessScope, a
+// subset of it
+func (s *authService) createSession(ctx *gin.Context, user *models.User, tenantID uuid.UUID, clientID string, scope string, accessScope string) (*models.Session, error) {
 	// Create session with a new ID
 	sessionID := uuid.New()
 	session := &models.Session{
 		ID:           sessionID,
 		UserID:       user.ID,
 		TenantID:     tenantID,
-		AccessToken:  s.generateToken(user.ID, sessionID, "access", tenantID, 15*time.Minute),
-		RefreshToken: s.generateToken(user.ID, sessionID, "refresh", tenantID, 7*24*time.Hour),
-		ExpiresAt:    time.Now().Add(15 * time.Minute),
+		AccessToken:  s.generateToken(user.ID, sessionID, "access", tenantID, clientID, accessScope, AccessTokenTTL),
+		RefreshToken: s.generateToken(user.ID, sessionID, "refresh", tenantID, clientID, scope, RefreshTokenTTL),
+		ExpiresAt:    time.Now().Add(AccessTokenTTL),
 		CreatedAt:    time.Now(),
 		LastUsedAt:   time.Now(),
 		IPAddress:    ctx.ClientIP(),
 		UserAgent:    ctx.GetHeader("User-Agent"),
+		ClientID:     clientID,
+		Scope:        scope,
+		AccessScope:  accessScope,
 	}
 
 	// Save session
@@ -454,11 +530,115 @@ func (s *authService) CreateSession(ctx *gin.Context, user *models.User, tenantI
 	return session, nil
 }
 
+// IssueClientToken issues an access token to a client acting on its own behalf (client_credentials grant)
+func (s *authService) IssueClientToken(client *models.OAuthClient, scope string) (string, error) {
+	claims := Claims{
+		TokenType: "access",
+		TenantID:  client.TenantID,
+		ClientID:  client.ClientID,
+		Scope:     scope,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   client.ClientID,
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(AccessTokenTTL)),
+		},
+	}
+
+	return s.keyManager.SignToken(claims)
+}
+
+// IssueDelegatedToken issues an access token that lets a client act on behalf of the subject
+// of another access token. The client is recorded in the act claim, after any earlier actors.
+func (s *authService) IssueDelegatedToken(subject *Claims, clientID string, audience []string, scope string, expiresAt time.Time) (string, error) {
+	claims := Claims{
+		UserID:    subject.UserID,
+		SessionID: subject.SessionID,
+		TokenType: "access",
+		TenantID:  subject.TenantID,
+		ClientID:  clientID,
+		Scope:     scope,
+		Actor: &models.TokenActor{
+			Subject: clientID,
+			Actor:   subject.Actor,
+		},
+		RegisteredClaims: jwt.RegisteredClaims{
+			Audience:  jwt.ClaimStrings(audience),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	}
+	// The exchanged scope can only narrow the subject's, so its groups claim still applies
+	if containsScope(strings.Fields(scope), models.ScopeGroups) {
+		claims.Groups = subject.Groups
+	}
+
+	return s.keyManager.SignToken(claims)
+}
+
+// InspectToken returns the claims of a token that is still valid: correctly signed, unexpired
+// and, when it was issued with a session, still the current token of that session. Delegated
+// tokens only require the session to exist.
+func (s *authService) InspectToken(token string) (*Claims, error) {
+	claims, err := s.parseToken(token)
+	if err != nil {
+		return nil, err
+	}
+
+	// Client credentials tokens have no session and stay valid until they expire
+	if claims.SessionID == uuid.Nil {
+		return claims, nil
+	}
+
+	session, err := s.sessionRepo.GetSession(claims.SessionID)
+	if err != nil {
+		return nil, errors.New("session not found")
+	}
+	if err := s.requireMembership(session.UserID, session.TenantID); err != nil {
+		return nil, err
+	}
+	if claims.Actor != nil {
+		return claims, nil
+	}
+
+	current := session.AccessToken
+	if claims.TokenType == "refresh" {
+		current = session.RefreshToken
+	}
+	if strings.TrimPrefix(token, "Bearer ") != current {
+		return nil, errors.New("token has been replaced")
+	}
+
+	return claims, nil
+}
+
+// requireMembership checks the user still has active access to the tenant, so that a session
+// outliving a removed or deactivated membership can neither be refreshed nor introspected
+func (s *authService) requireMembership(userID, tenantID uuid.UUID) error {
+	if _, err := s.userService.GetUserTenantRoles(userID, tenantID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("user is no longer a member of the tenant")
+		}
+		return err
+	}
+	return nil
+}
+
+// EndSession deletes a session, invalidating both its access and refresh token
+func (s *authService) EndSession(sessionID uuid.UUID) error {
+	return s.sessionRepo.DeleteSession(sessionID)
+}
+
 type Claims struct {
 	UserID    uuid.UUID `json:"userId"`
 	SessionID uuid.UUID `json:"sessionId"`
 	TokenType string    `json:"tokenType"`
 	TenantID  uuid.UUID `json:"tenantId"`
+	ClientID  string    `json:"clientId,omitempty"`
+	Scope     string    `json:"scope,omitempty"`
+	// Actor is set on delegated tokens issued by token exchange
+	Actor *models.TokenActor `json:"act,omitempty"`
+	// Groups names the user's groups in the tenant on access tokens granted the groups scope
+	Groups []string `json:"groups,omitempty"`
 	jwt.RegisteredClaims
 }
 
@@ -466,16 +646,27 @@ func (c Claims) Valid() error {
 	return c.RegisteredClaims.Valid()
 }
 
-func (s *authService) generateToken(userID uuid.UUID, sessionID uuid.UUID, tokenType string, tenantID uuid.UUID, expiry time.Duration) string {
+func (s *authService) generateToken(userID uuid.UUID, sessionID uuid.UUID, tokenType string, tenantID uuid.UUID, clientID string, scope string, expiry time.Duration) string {
 	claims := Claims{
 		UserID:    userID,
 		SessionID: sessionID,
 		TokenType: tokenType,
 		TenantID:  tenantID,
+		ClientID:  clientID,
+		Scope:     scope,
 		RegisteredClaims: jwt.RegisteredClaims{
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiry)),
 		},
 	}
+	if tokenType == "access" && containsScope(strings.Fields(scope), models.ScopeGroups) {
+		groups, err := memberGroupNames(s.groupRepo, userID, tenantID)
+		if err != nil {
+			log.Printf("Error getting groups for token: %v", err)
+			return ""
+		}
+		claims.Groups = groups
+	}
 
 	// Log claims for debugging
 	log.Printf("Generating token for user %s, session %s, type %s", userID, sessionID, tokenType)
@@ -0,0 +1,252 @@
+package services
+
+import (
+	"fmt"
+	"identity-service/internal/models"
+	"identity-service/internal/repositories"
+	"net"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// AuthzService answers other services' questions of whether a user may perform an action in a
+// tenant. Decisions come from the user's roles and permissions in the tenant, including the roles
+// of the groups they are in, with the
+// conditions of custom roles checked against the resource, the tenant's plan and the request.
+type AuthzService interface {
+	// Check decides a check made by a client of the tenant
+	Check(tenantID uuid.UUID, check *models.AuthzCheck) (*models.AuthzDecision, error)
+	// CheckBatch decides several checks, returning the decisions in the same order
+	CheckBatch(tenantID uuid.UUID, checks []models.AuthzCheck) ([]*models.AuthzDecision, error)
+}
+
+type authzService struct {
+	userRepo   repositories.UserRepository
+	tenantRepo repositories.TenantRepository
+	roleRepo   repositories.RoleRepository
+}
+
+func NewAuthzService(
+	userRepo repositories.UserRepository,
+	tenantRepo repositories.TenantRepository,
+	roleRepo repositories.RoleRepository,
+) AuthzService {
+	return &authzService{
+		userRepo:   userRepo,
+		tenantRepo: tenantRepo,
+		roleRepo:   roleRepo,
+	}
+}
+
+func (s *authzService) Check(tenantID uuid.UUID, check *models.AuthzCheck) (*models.AuthzDecision, error) {
+	decisions, err := s.CheckBatch(tenantID, []models.AuthzCheck{*check})
+	if err != nil {
+		return nil, err
+	}
+	return decisions[0], nil
+}
+
+func (s *authzService) CheckBatch(tenantID uuid.UUID, checks []models.AuthzCheck) ([]*models.AuthzDecision, error) {
+	tenant, err := s.tenantRepo.GetTenantByID(tenantID)
+	if err != nil {
+		return nil, err
+	}
+	customRoles, err := s.roleRepo.ListRoles(tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Users often appear in several checks of a batch
+	memberships := make(map[uuid.UUID]*member)
+	decisions := make([]*models.AuthzDecision, 0, len(checks))
+	for i := range checks {
+		check := &checks[i]
+		decision := &models.AuthzDecision{
+			UserID:   check.UserID,
+			TenantID: tenantID,
+			Action:   check.Action,
+			Resource: check.Resource,
+		}
+		decisions = append(decisions, decision)
+
+		if check.TenantID != uuid.Nil && check.TenantID != tenantID {
+			decision.TenantID = check.TenantID
+			decision.Reason = "the tenant is not the tenant of the calling client"
+			continue
+		}
+		if !models.ValidPermission(check.Action) {
+			decision.Reason = "the action is not a valid permission name"
+			continue
+		}
+
+		m, cached := memberships[check.UserID]
+		if !cached {
+			if m, err = s.membership(check.UserID, tenantID); err != nil {
+				return nil, err
+			}
+			memberships[check.UserID] = m
+		}
+		decision.Allowed, decision.Reason = authorize(tenant, customRoles, m, check)
+	}
+	return decisions, nil
+}
+
+// member is a user's active membership of a tenant and the groups they are in there
+type member struct {
+	access *models.UserTenantAccess
+	groups []models.MemberGroup
+}
+
+// heldRole is a role a member holds, through their membership or through a group
+type heldRole struct {
+	name  string
+	group string
+}
+
+// describe names the role and, for a group's role, the group giving it
+func (r heldRole) describe(kind string) string {
+	if r.group == "" {
+		return kind + " " + r.name
+	}
+	return fmt.Sprintf("%s %s through group %s", kind, r.name, r.group)
+}
+
+// roles returns the member's own roles, then those of their groups
+func (m *member) roles() []heldRole {
+	var roles []heldRole
+	for _, name := range m.access.Roles {
+		roles = append(roles, heldRole{name: name})
+	}
+	for _, group := range m.groups {
+		for _, name := range group.Roles {
+			roles = append(roles, heldRole{name: name, group: group.Name})
+		}
+	}
+	return roles
+}
+
+// membership returns the user's active membership of the tenant, or nil if they have none
+func (s *authzService) membership(userID, tenantID uuid.UUID) (*member, error) {
+	accesses, err := s.userRepo.GetUserTenantAccess(userID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range accesses {
+		if accesses[i].TenantID != tenantID {
+			continue
+		}
+		groups, err := s.userRepo.GetMemberGroups([]uuid.UUID{userID})
+		if err != nil {
+			return nil, err
+		}
+		m := &member{access: &accesses[i]}
+		for _, group := range groups {
+			if group.TenantID == tenantID {
+				m.groups = append(m.groups, group)
+			}
+		}
+		return m, nil
+	}
+	return nil, nil
+}
+
+// authorize allows the check if the membership's own permissions, a built-in role or a custom
+// role whose conditions hold grant the action, and explains why. Roles count the same whether
+// the user holds them or a group they are in does.
+func authorize(tenant *models.Tenant, customRoles []models.TenantRole, m *member, check *models.AuthzCheck) (bool, string) {
+	if m == nil {
+		return false, "the user is not an active member of the tenant"
+	}
+	if models.HasPermission(m.access.Permissions, check.Action) {
+		return true, "granted to the user's membership directly"
+	}
+
+	var unmet []string
+	roles := m.roles()
+	for _, held := range roles {
+		if models.HasPermission(models.BuiltinRoles[held.name], check.Action) {
+			return true, "granted by " + held.describe("built-in role")
+		}
+		for _, role := range customRoles {
+			if role.Name != held.name || !models.HasPermission(role.Permissions, check.Action) {
+				continue
+			}
+			if failure := unmetCondition(tenant, role.Conditions, check); failure != "" {
+				unmet = append(unmet, fmt.Sprintf("%s grants it but %s", held.describe("role"), failure))
+				continue
+			}
+			if role.Conditions.Empty() {
+				return true, "granted by " + held.describe("role")
+			}
+			return true, fmt.Sprintf("granted by %s, whose conditions are met", held.describe("role"))
+		}
+	}
+
+	if len(unmet) > 0 {
+		return false, strings.Join(unmet, "; ")
+	}
+	if len(roles) == 0 {
+		return false, "the user has no roles in the tenant that grant " + check.Action
+	}
+	names := make([]string, 0, len(roles))
+	for _, held := range roles {
+		if !slices.Contains(names, held.name) {
+			names = append(names, held.name)
+		}
+	}
+	return false, fmt.Sprintf("none of the user's roles (%s) grants %s", strings.Join(names, ", "), check.Action)
+}
+
+// unmetCondition returns why the conditions do not hold for the check, or "" if they do
+func unmetCondition(tenant *models.Tenant, conditions *models.RoleConditions, check *models.AuthzCheck) string {
+	if conditions.Empty() {
+		return ""
+	}
+
+	if conditions.ResourceOwner {
+		if check.Resource == nil || check.Resource.OwnerID == nil {
+			return "only to the resource's owner, which the check does not give"
+		}
+		if *check.Resource.OwnerID != check.UserID {
+			return "only to the resource's owner, who is another user"
+		}
+	}
+
+	if len(conditions.Plans) > 0 && (!slices.Contains(conditions.Plans, tenant.SubscriptionPlan) || !tenant.IsSubscriptionActive()) {
+		plan := "no plan"
+		if tenant.SubscriptionPlan != "" {
+			plan = "plan " + tenant.SubscriptionPlan
+			if !tenant.IsSubscriptionActive() {
+				plan = "an inactive subscription to " + plan
+			}
+		}
+		return fmt.Sprintf("only on an active subscription to %s, and the tenant has %s", strings.Join(conditions.Plans, " or "), plan)
+	}
+
+	if len(conditions.IPRanges) > 0 {
+		ip := net.ParseIP(check.Context.IP)
+		if ip == nil {
+			return "only from certain IP addresses, and the check does not give one"
+		}
+		if !ipInRanges(ip, conditions.IPRanges) {
+			return fmt.Sprintf("only from %s, not %s", strings.Join(conditions.IPRanges, ", "), ip)
+		}
+	}
+	return ""
+}
+
+// ipInRanges reports whether ip is one of the CIDRs or single IPs
+func ipInRanges(ip net.IP, ranges []string) bool {
+	for _, r := range ranges {
+		if _, network, err := net.ParseCIDR(r); err == nil {
+			if network.Contains(ip) {
+				return true
+			}
+		} else if ip.Equal(net.ParseIP(r)) {
+			return true
+		}
+	}
+	return false
+}
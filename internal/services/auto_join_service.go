@@ -0,0 +1,242 @@
+package services
+
+import (
+	"errors"
+	"identity-service/internal/models"
+	"identity-service/internal/repositories"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Audit log actions recorded for domain-based membership
+const (
+	auditAutoJoined        = "member.auto_joined"
+	auditAutoJoinSkipped   = "member.auto_join_skipped"
+	auditJoinRequested     = "member.join_requested"
+	auditJoinApproved      = "member.join_approved"
+	auditJoinDenied        = "member.join_denied"
+	auditAutoJoinPolicySet = "tenant.auto_join_policy_updated"
+)
+
+var (
+	ErrJoinRequestReviewed = errors.New("join request has already been reviewed")
+	ErrTenantFull          = errors.New("the tenant has reached its user limit")
+)
+
+// AutoJoinService adds users with an email in a tenant's verified domain to the tenant, or
+// asks the tenant's administrators to, according to the tenant's auto-join policy
+type AutoJoinService interface {
+	GetPolicy(tenantID uuid.UUID) (*models.AutoJoinSettings, error)
+	UpdatePolicy(tenantID uuid.UUID, settings *models.AutoJoinSettings, actorID uuid.UUID) (*models.AutoJoinSettings, error)
+	Evaluate(user *models.User) error
+	ListJoinRequests(tenantID uuid.UUID, status string) ([]*models.JoinRequest, error)
+	ReviewJoinRequest(tenantID, requestID, reviewerID uuid.UUID, approve bool) (*models.JoinRequest, error)
+}
+
+type autoJoinService struct {
+	repo         repositories.JoinRequestRepository
+	tenantRepo   repositories.TenantRepository
+	userRepo     repositories.UserRepository
+	securityRepo repositories.SecurityRepository
+}
+
+func NewAutoJoinService(
+	repo repositories.JoinRequestRepository,
+	tenantRepo repositories.TenantRepository,
+	userRepo repositories.UserRepository,
+	securityRepo repositories.SecurityRepository,
+) AutoJoinService {
+	return &autoJoinService{
+		repo:         repo,
+		tenantRepo:   tenantRepo,
+		userRepo:     userRepo,
+		securityRepo: securityRepo,
+	}
+}
+
+func (s *autoJoinService) GetPolicy(tenantID uuid.UUID) (*models.AutoJoinSettings, error) {
+	tenant, err := s.tenantRepo.GetTenantByID(tenantID)
+	if err != nil {
+		return nil, err
+	}
+	return autoJoinSettings(tenant), nil
+}
+
+func (s *autoJoinService) UpdatePolicy(tenantID uuid.UUID, settings *models.AutoJoinSettings, actorID uuid.UUID) (*models.AutoJoinSettings, error) {
+	tenant, err := s.tenantRepo.GetTenantByID(tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	tenant.AutoJoinPolicy = settings.Policy
+	if settings.DefaultRole != "" {
+		tenant.AutoJoinRole = settings.DefaultRole
+	}
+	if tenant.AutoJoinRole == "" {
+		tenant.AutoJoinRole = ssoMemberRole
+	}
+	if err := s.tenantRepo.UpdateTenant(tenant); err != nil {
+		return nil, err
+	}
+
+	updated := autoJoinSettings(tenant)
+	s.audit(tenant.ID, actorID, auditAutoJoinPolicySet, "tenants/"+tenant.ID.String(), map[string]string{
+		"policy":      updated.Policy,
+		"defaultRole": updated.DefaultRole,
+	})
+	return updated, nil
+}
+
+// Evaluate applies the auto-join policy of the tenant that verified the user's email domain.
+// Only verified emails are considered, since anyone can sign up with any address.
+func (s *autoJoinService) Evaluate(user *models.User) error {
+	if !user.EmailVerified {
+		return nil
+	}
+	at := strings.LastIndex(user.Email, "@")
+	if at < 0 {
+		return nil
+	}
+	tenant, err := s.tenantRepo.GetTenantByVerifiedDomain(user.Email[at+1:])
+	if err != nil || tenant.Type == models.PersonalTenant {
+		return nil
+	}
+	if tenant.AutoJoinPolicy != models.AutoJoinAuto && tenant.AutoJoinPolicy != models.AutoJoinRequest {
+		return nil
+	}
+
+	// Existing members, including ones the tenant deprovisioned, are left alone
+	member, err := s.repo.HasMembership(user.ID, tenant.ID)
+	if err != nil {
+		return err
+	}
+	if member {
+		return nil
+	}
+
+	if tenant.AutoJoinPolicy == models.AutoJoinRequest {
+		return s.requestJoin(tenant, user)
+	}
+
+	resource := "users/" + user.ID.String()
+	if err := checkSeats(tenant, s.repo.CountMembers); err != nil {
+		s.audit(tenant.ID, user.ID, auditAutoJoinSkipped, resource, map[string]string{
+			"email":  user.Email,
+			"reason": err.Error(),
+		})
+		return nil
+	}
+	if err := s.userRepo.AddUserToTenant(user.ID, tenant.ID, []string{tenant.AutoJoinRole}); err != nil {
+		return err
+	}
+	s.audit(tenant.ID, user.ID, auditAutoJoined, resource, map[string]string{
+		"email":  user.Email,
+		"domain": tenant.Domain,
+		"role":   tenant.AutoJoinRole,
+	})
+	return nil
+}
+
+// requestJoin files a join request, unless the user already has one. Denied requests stay
+// denied, so a user cannot keep asking.
+func (s *autoJoinService) requestJoin(tenant *models.Tenant, user *models.User) error {
+	if _, err := s.repo.GetUserRequest(tenant.ID, user.ID); err == nil {
+		return nil
+	}
+
+	request := &models.JoinRequest{
+		ID:       uuid.New(),
+		TenantID: tenant.ID,
+		UserID:   user.ID,
+		Status:   models.JoinRequestPending,
+	}
+	if err := s.repo.CreateRequest(request); err != nil {
+		return err
+	}
+	s.audit(tenant.ID, user.ID, auditJoinRequested, "join-requests/"+request.ID.String(), map[string]string{
+		"email":  user.Email,
+		"domain": tenant.Domain,
+	})
+	return nil
+}
+
+func (s *autoJoinService) ListJoinRequests(tenantID uuid.UUID, status string) ([]*models.JoinRequest, error) {
+	return s.repo.ListRequests(tenantID, status)
+}
+
+// ReviewJoinRequest approves a request, adding the user with the tenant's auto-join role, or
+// denies it
+func (s *autoJoinService) ReviewJoinRequest(tenantID, requestID, reviewerID uuid.UUID, approve bool) (*models.JoinRequest, error) {
+	request, err := s.repo.GetRequest(tenantID, requestID)
+	if err != nil {
+		return nil, err
+	}
+	if request.Status != models.JoinRequestPending {
+		return nil, ErrJoinRequestReviewed
+	}
+	tenant, err := s.tenantRepo.GetTenantByID(tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	request.ReviewedBy = &reviewerID
+	request.ReviewedAt = &now
+	resource := "join-requests/" + request.ID.String()
+
+	if !approve {
+		request.Status = models.JoinRequestDenied
+		if err := s.repo.SaveRequest(request); err != nil {
+			return nil, err
+		}
+		s.audit(tenantID, reviewerID, auditJoinDenied, resource, map[string]string{
+			"userId": request.UserID.String(),
+		})
+		return request, nil
+	}
+
+	if err := checkSeats(tenant, s.repo.CountMembers); err != nil {
+		return nil, err
+	}
+	request.Status = models.JoinRequestApproved
+	if err := s.repo.ApproveRequest(request, []string{tenant.AutoJoinRole}); err != nil {
+		return nil, err
+	}
+	s.audit(tenantID, reviewerID, auditJoinApproved, resource, map[string]string{
+		"userId": request.UserID.String(),
+		"role":   tenant.AutoJoinRole,
+	})
+	return request, nil
+}
+
+// checkSeats returns ErrTenantFull if the tenant has no room for another active member
+func checkSeats(tenant *models.Tenant, countMembers func(tenantID uuid.UUID) (int64, error)) error {
+	if tenant.MaxUsers == nil {
+		return nil
+	}
+	members, err := countMembers(tenant.ID)
+	if err != nil {
+		return err
+	}
+	if members >= int64(*tenant.MaxUsers) {
+		return ErrTenantFull
+	}
+	return nil
+}
+
+func (s *autoJoinService) audit(tenantID, userID uuid.UUID, action, resource string, details map[string]string) {
+	recordAudit(s.securityRepo, tenantID, userID, action, resource, details)
+}
+
+func autoJoinSettings(tenant *models.Tenant) *models.AutoJoinSettings {
+	policy := tenant.AutoJoinPolicy
+	if policy == "" {
+		policy = models.AutoJoinOff
+	}
+	return &models.AutoJoinSettings{
+		Policy:      policy,
+		DefaultRole: tenant.AutoJoinRole,
+	}
+}
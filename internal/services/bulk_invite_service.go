@@ -0,0 +1,446 @@
+package services
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"identity-service/internal/models"
+	"identity-service/internal/repositories"
+	"io"
+	"log"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	// bulkInviteFeature is the enterprise feature that allows CSV invitation uploads
+	bulkInviteFeature = "bulkInviteSystem"
+	// maxBulkInviteRows bounds the rows of one upload
+	maxBulkInviteRows = 1000
+	// Progress is saved every bulkInviteSaveRows rows or bulkInviteSaveInterval, whichever
+	// comes first
+	bulkInviteSaveRows     = 25
+	bulkInviteSaveInterval = 30 * time.Second
+	// bulkInviteStallTimeout is how long a job goes without saving progress before it is
+	// considered interrupted
+	bulkInviteStallTimeout = 5 * time.Minute
+)
+
+var (
+	ErrBulkInviteNotEnabled = errors.New("bulk invitations are not enabled for this tenant")
+	ErrBulkInviteSeats      = errors.New("the upload would exceed the tenant's user limit")
+)
+
+// BulkInviteValidationError rejects an upload, listing the problem with each bad row
+type BulkInviteValidationError struct {
+	Rows []models.BulkInviteRowError
+}
+
+func (e *BulkInviteValidationError) Error() string {
+	return fmt.Sprintf("%d rows of the upload are invalid", len(e.Rows))
+}
+
+// BulkInviteService invites the rows of an uploaded CSV of email, role and group in the
+// background. Uploads are validated in full before anything is sent.
+type BulkInviteService interface {
+	// StartJob rejects rows whose role is unknown or grants a permission for which ungrantable,
+	// given the role's permissions, returns the first the uploader may not grant
+	StartJob(tenantID uuid.UUID, upload io.Reader, actorID uuid.UUID, ungrantable func(permissions []string) string) (*models.BulkInviteJob, error)
+	GetJob(tenantID, jobID uuid.UUID) (*models.BulkInviteJob, error)
+	ListJobs(tenantID uuid.UUID) ([]*models.BulkInviteJob, error)
+	// ResumeJobs finishes jobs interrupted by a restart, claiming each so only one replica
+	// resumes it. Jobs still saving progress are left to the replica running them.
+	ResumeJobs()
+}
+
+type bulkInviteService struct {
+	repo          repositories.BulkInviteRepository
+	inviteRepo    repositories.InviteRepository
+	tenantRepo    repositories.TenantRepository
+	inviteService InviteService
+	roleService   RoleService
+	groupService  GroupService
+	// claimant identifies this replica's claims on the jobs it runs
+	claimant uuid.UUID
+}
+
+func NewBulkInviteService(
+	repo repositories.BulkInviteRepository,
+	inviteRepo repositories.InviteRepository,
+	tenantRepo repositories.TenantRepository,
+	inviteService InviteService,
+	roleService RoleService,
+	groupService GroupService,
+) BulkInviteService {
+	return &bulkInviteService{
+		repo:          repo,
+		inviteRepo:    inviteRepo,
+		tenantRepo:    tenantRepo,
+		inviteService: inviteService,
+		roleService:   roleService,
+		groupService:  groupService,
+		claimant:      uuid.New(),
+	}
+}
+
+// StartJob validates an upload and starts inviting its rows. The upload is rejected if any
+// row is invalid or if the tenant has fewer free seats than rows, counting members and
+// pending invitations.
+func (s *bulkInviteService) StartJob(tenantID uuid.UUID, upload io.Reader, actorID uuid.UUID, ungrantable func(permissions []string) string) (*models.BulkInviteJob, error) {
+	tenant, err := s.tenantRepo.GetTenantByID(tenantID)
+	if err != nil {
+		return nil, err
+	}
+	if !tenant.HasFeatures()[bulkInviteFeature] {
+		return nil, ErrBulkInviteNotEnabled
+	}
+
+	rows, rowErrors := parseBulkInvites(upload)
+	if len(rowErrors) > 0 {
+		return nil, &BulkInviteValidationError{Rows: rowErrors}
+	}
+	rowErrors, err = s.checkRoles(tenantID, rows, ungrantable)
+	if err != nil {
+		return nil, err
+	}
+	if len(rowErrors) > 0 {
+		return nil, &BulkInviteValidationError{Rows: rowErrors}
+	}
+
+	free, err := s.freeSeats(tenant)
+	if err != nil {
+		return nil, err
+	}
+	if free >= 0 && int64(len(rows)) > free {
+		return nil, fmt.Errorf("%w: %d rows, %d seats available", ErrBulkInviteSeats, len(rows), free)
+	}
+
+	rowsJSON, err := json.Marshal(rows)
+	if err != nil {
+		return nil, err
+	}
+	job := &models.BulkInviteJob{
+		ID:        uuid.New(),
+		TenantID:  tenantID,
+		CreatedBy: &actorID,
+		Status:    models.BulkInviteQueued,
+		Total:     len(rows),
+		Rows:      rowsJSON,
+		ClaimedBy: &s.claimant,
+	}
+	if err := s.repo.CreateJob(job); err != nil {
+		return nil, err
+	}
+
+	go s.run(job, rows, nil)
+	return job, nil
+}
+
+func (s *bulkInviteService) GetJob(tenantID, jobID uuid.UUID) (*models.BulkInviteJob, error) {
+	return s.repo.GetJob(tenantID, jobID)
+}
+
+func (s *bulkInviteService) ListJobs(tenantID uuid.UUID) ([]*models.BulkInviteJob, error) {
+	return s.repo.ListJobs(tenantID)
+}
+
+func (s *bulkInviteService) ResumeJobs() {
+	for {
+		job, err := s.repo.ClaimStalledJob(time.Now().Add(-bulkInviteStallTimeout), s.claimant)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return
+		}
+		if err != nil {
+			log.Printf("Failed to claim interrupted bulk invitation jobs: %v", err)
+			return
+		}
+
+		var rows []models.BulkInviteRow
+		var results []models.BulkInviteRowResult
+		if err := json.Unmarshal(job.Rows, &rows); err != nil {
+			log.Printf("Failed to read rows of bulk invitation job %s: %v", job.ID, err)
+			continue
+		}
+		if len(job.Results) > 0 {
+			if err := json.Unmarshal(job.Results, &results); err != nil {
+				log.Printf("Failed to read results of bulk invitation job %s: %v", job.ID, err)
+				continue
+			}
+		}
+		s.run(job, rows, results)
+	}
+}
+
+// run invites the rows after those already in results, saving progress as it goes. It stops
+// if another replica claims the job.
+func (s *bulkInviteService) run(job *models.BulkInviteJob, rows []models.BulkInviteRow, results []models.BulkInviteRowResult) {
+	job.Status = models.BulkInviteRunning
+	if !s.save(job, results) {
+		return
+	}
+	lastSave := time.Now()
+
+	for i := len(results); i < len(rows); i++ {
+		result := s.inviteRow(job, &rows[i])
+		results = append(results, result)
+
+		job.Processed = len(results)
+		switch result.Status {
+		case models.BulkInviteRowInvited:
+			job.Invited++
+		case models.BulkInviteRowSkipped:
+			job.Skipped++
+		default:
+			job.Failed++
+		}
+		if job.Processed%bulkInviteSaveRows == 0 || time.Since(lastSave) >= bulkInviteSaveInterval {
+			if !s.save(job, results) {
+				return
+			}
+			lastSave = time.Now()
+		}
+	}
+
+	now := time.Now()
+	job.Status = models.BulkInviteCompleted
+	job.CompletedAt = &now
+	s.save(job, results)
+}
+
+// inviteRow invites one row, skipping it once the tenant has no free seats left. Seats are
+// counted in the same transaction as the invitation, so concurrent jobs cannot overshoot.
+func (s *bulkInviteService) inviteRow(job *models.BulkInviteJob, row *models.BulkInviteRow) models.BulkInviteRowResult {
+	result := models.BulkInviteRowResult{
+		Line:  row.Line,
+		Email: row.Email,
+	}
+
+	var inviterID uuid.UUID
+	if job.CreatedBy != nil {
+		inviterID = *job.CreatedBy
+	}
+	_, err := s.inviteService.CreateInviteWithinLimit(job.TenantID, &models.TenantInviteRequest{
+		Email: row.Email,
+		Role:  row.Role,
+		Group: row.Group,
+	}, inviterID)
+	switch {
+	case err == nil:
+		result.Status = models.BulkInviteRowInvited
+	case errors.Is(err, ErrAlreadyMember), errors.Is(err, ErrInviteExists), errors.Is(err, ErrTenantFull):
+		result.Status = models.BulkInviteRowSkipped
+		result.Error = err.Error()
+	default:
+		result.Status = models.BulkInviteRowFailed
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// checkRoles returns an error for each row whose role or group is not defined in the tenant
+// or grants a permission the uploader does not hold. A group grants its own roles and those of
+// the groups it is nested in.
+func (s *bulkInviteService) checkRoles(tenantID uuid.UUID, rows []models.BulkInviteRow, ungrantable func(permissions []string) string) ([]models.BulkInviteRowError, error) {
+	roleProblems := make(map[string]string)
+	groupProblems := make(map[string]string)
+	var rowErrors []models.BulkInviteRowError
+	for _, row := range rows {
+		problem, checked := roleProblems[row.Role]
+		if !checked {
+			permission, err := s.ungrantable(tenantID, []string{row.Role}, ungrantable)
+			switch {
+			case errors.Is(err, ErrUnknownRole):
+				problem = "role is not defined in the tenant"
+			case err != nil:
+				return nil, err
+			case permission != "":
+				problem = "role grants a permission you do not hold: " + permission
+			}
+			roleProblems[row.Role] = problem
+		}
+
+		group := strings.ToLower(row.Group)
+		if problem == "" && group != "" {
+			problem, checked = groupProblems[group]
+			if !checked {
+				roles, err := s.groupService.GroupRolesByName(tenantID, row.Group)
+				var permission string
+				if err == nil {
+					permission, err = s.ungrantable(tenantID, roles, ungrantable)
+				}
+				switch {
+				case errors.Is(err, ErrUnknownGroup):
+					problem = "group is not defined in the tenant"
+				case errors.Is(err, ErrUnknownRole):
+					problem = "group grants a role that is not defined in the tenant"
+				case err != nil:
+					return nil, err
+				case permission != "":
+					problem = "group grants a permission you do not hold: " + permission
+				}
+				groupProblems[group] = problem
+			}
+		}
+
+		if problem != "" {
+			rowErrors = append(rowErrors, models.BulkInviteRowError{Line: row.Line, Email: row.Email, Error: problem})
+		}
+	}
+	return rowErrors, nil
+}
+
+// ungrantable returns a permission of the roles the uploader does not hold, or ""
+func (s *bulkInviteService) ungrantable(tenantID uuid.UUID, roles []string, ungrantable func(permissions []string) string) (string, error) {
+	permissions, err := s.roleService.RolePermissions(tenantID, roles)
+	if err != nil {
+		return "", err
+	}
+	return ungrantable(permissions), nil
+}
+
+// freeSeats returns how many more members and pending invitations the tenant has room for,
+// or -1 if it has no user limit
+func (s *bulkInviteService) freeSeats(tenant *models.Tenant) (int64, error) {
+	if tenant.MaxUsers == nil {
+		return -1, nil
+	}
+	members, err := s.inviteRepo.CountMembers(tenant.ID)
+	if err != nil {
+		return 0, err
+	}
+	pending, err := s.inviteRepo.CountPendingInvites(tenant.ID)
+	if err != nil {
+		return 0, err
+	}
+	return max(int64(*tenant.MaxUsers)-members-pending, 0), nil
+}
+
+// save saves the job's progress, returning false if another replica has claimed the job
+func (s *bulkInviteService) save(job *models.BulkInviteJob, results []models.BulkInviteRowResult) bool {
+	resultsJSON, err := json.Marshal(results)
+	if err != nil {
+		log.Printf("Failed to encode results of bulk invitation job %s: %v", job.ID, err)
+		return true
+	}
+	job.Results = resultsJSON
+	err = s.repo.SaveJob(job)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		log.Printf("Bulk invitation job %s was claimed by another replica; stopping", job.ID)
+		return false
+	}
+	if err != nil {
+		log.Printf("Failed to save bulk invitation job %s: %v", job.ID, err)
+	}
+	return true
+}
+
+// parseBulkInvites reads an upload of email, role and group columns. A header row naming the
+// columns, in any order, is optional; without one the columns are in that order. Role
+// defaults to member.
+func parseBulkInvites(upload io.Reader) ([]models.BulkInviteRow, []models.BulkInviteRowError) {
+	reader := csv.NewReader(upload)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	columns := map[string]int{"email": 0, "role": 1, "group": 2}
+	var rows []models.BulkInviteRow
+	var rowErrors []models.BulkInviteRowError
+	seen := make(map[string]int)
+
+	for first := true; ; first = false {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			line := 0
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				line = parseErr.Line
+			}
+			rowErrors = append(rowErrors, models.BulkInviteRowError{Line: line, Error: err.Error()})
+			break
+		}
+		line, _ := reader.FieldPos(0)
+
+		if first {
+			if header := bulkInviteColumns(record); header != nil {
+				columns = header
+				continue
+			}
+		}
+
+		field := func(name string) string {
+			index, ok := columns[name]
+			if !ok || index >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[index])
+		}
+		row := models.BulkInviteRow{
+			Line:  line,
+			Email: field("email"),
+			Role:  field("role"),
+			Group: field("group"),
+		}
+		if row.Role == "" {
+			row.Role = ssoMemberRole
+		}
+
+		if problem := validateBulkInviteRow(&row, seen); problem != "" {
+			rowErrors = append(rowErrors, models.BulkInviteRowError{Line: line, Email: row.Email, Error: problem})
+			continue
+		}
+		seen[strings.ToLower(row.Email)] = line
+		rows = append(rows, row)
+	}
+
+	if len(rowErrors) == 0 && len(rows) == 0 {
+		rowErrors = append(rowErrors, models.BulkInviteRowError{Error: "the upload has no rows"})
+	}
+	if len(rows)+len(rowErrors) > maxBulkInviteRows {
+		rowErrors = append(rowErrors, models.BulkInviteRowError{Error: fmt.Sprintf("the upload has more than %d rows", maxBulkInviteRows)})
+	}
+	return rows, rowErrors
+}
+
+// bulkInviteColumns maps the columns named in a header row, or returns nil if the row has no
+// email column and so is not a header
+func bulkInviteColumns(record []string) map[string]int {
+	columns := make(map[string]int)
+	for index, name := range record {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if _, ok := columns[name]; !ok {
+			columns[name] = index
+		}
+	}
+	if _, ok := columns["email"]; !ok {
+		return nil
+	}
+	return columns
+}
+
+func validateBulkInviteRow(row *models.BulkInviteRow, seen map[string]int) string {
+	if row.Email == "" {
+		return "email is required"
+	}
+	address, err := mail.ParseAddress(row.Email)
+	if err != nil || address.Address != row.Email {
+		return "email is not a valid email address"
+	}
+	if line, ok := seen[strings.ToLower(row.Email)]; ok {
+		return fmt.Sprintf("email is a duplicate of line %d", line)
+	}
+	if !roleNamePattern.MatchString(row.Role) {
+		return "role must be up to 50 letters, digits or ._:- characters"
+	}
+	if len(row.Group) > 255 {
+		return "group must be at most 255 characters"
+	}
+	return ""
+}
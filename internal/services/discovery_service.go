@@ -0,0 +1,109 @@
+package services
+
+import (
+	"errors"
+	"identity-service/config"
+	"identity-service/internal/models"
+	"identity-service/internal/repositories"
+	"strings"
+)
+
+var ErrSSORequired = errors.New("this domain requires single sign-on")
+
+// DiscoveryService routes users to their organization's login by the domain of their email
+type DiscoveryService interface {
+	Discover(email string) *models.LoginDiscovery
+	CheckPasswordLogin(email string) error
+}
+
+type discoveryService struct {
+	tenantRepo repositories.TenantRepository
+	samlRepo   repositories.SAMLConnectionRepository
+	ldapRepo   repositories.LDAPConnectionRepository
+}
+
+func NewDiscoveryService(
+	tenantRepo repositories.TenantRepository,
+	samlRepo repositories.SAMLConnectionRepository,
+	ldapRepo repositories.LDAPConnectionRepository,
+) DiscoveryService {
+	return &discoveryService{
+		tenantRepo: tenantRepo,
+		samlRepo:   samlRepo,
+		ldapRepo:   ldapRepo,
+	}
+}
+
+// Discover returns the login methods for an email. It only looks at the domain, so it does
+// not reveal whether the user has an account.
+func (s *discoveryService) Discover(email string) *models.LoginDiscovery {
+	discovery := &models.LoginDiscovery{}
+
+	tenant := s.domainTenant(email)
+	if tenant == nil {
+		discovery.Methods = []models.LoginMethod{{Type: models.PasswordLoginMethod}}
+		return discovery
+	}
+	discovery.TenantID = &tenant.ID
+	discovery.TenantName = tenant.Name
+
+	ssoMethods := s.ssoMethods(tenant)
+	discovery.Methods = ssoMethods
+	// Login checks LDAP domains against the directory, so stored passwords cannot be used
+	// there. Enforcement only applies once the tenant has a working SSO method, so a
+	// misconfigured tenant cannot lock its users out.
+	discovery.SSORequired = tenant.EnforcesSSO() && len(ssoMethods) > 0
+	if !discovery.SSORequired && !hasLoginMethod(ssoMethods, models.LDAPLoginMethod) {
+		discovery.Methods = append(discovery.Methods, models.LoginMethod{Type: models.PasswordLoginMethod})
+	}
+	return discovery
+}
+
+// CheckPasswordLogin returns ErrSSORequired if the email's domain does not allow password login
+func (s *discoveryService) CheckPasswordLogin(email string) error {
+	if s.Discover(email).SSORequired {
+		return ErrSSORequired
+	}
+	return nil
+}
+
+// domainTenant returns the tenant that verified the email's domain, if any
+func (s *discoveryService) domainTenant(email string) *models.Tenant {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return nil
+	}
+	tenant, err := s.tenantRepo.GetTenantByVerifiedDomain(strings.TrimSpace(email[at+1:]))
+	if err != nil {
+		return nil
+	}
+	return tenant
+}
+
+// ssoMethods returns the tenant's enabled SSO connections, if SSO is part of its plan
+func (s *discoveryService) ssoMethods(tenant *models.Tenant) []models.LoginMethod {
+	methods := []models.LoginMethod{}
+	if !tenant.HasFeatures()[ssoFeature] {
+		return methods
+	}
+
+	if connection, err := s.samlRepo.GetConnection(tenant.ID); err == nil && connection.Enabled {
+		methods = append(methods, models.LoginMethod{
+			Type:     models.SAMLLoginMethod,
+			LoginURL: config.AuthServer.Issuer + "/api/auth/saml/" + tenant.ID.String() + "/login",
+		})
+	}
+	if connection, err := s.ldapRepo.GetConnection(tenant.ID); err == nil && connection.Enabled {
+		methods = append(methods, models.LoginMethod{Type: models.LDAPLoginMethod})
+	}
+	return methods
+}
+
+func hasLoginMethod(methods []models.LoginMethod, methodType string) bool {
+	for _, method := range methods {
+		if method.Type == methodType {
+			return true
+		}
+	}
+	return false
+}
@@ -1,126 +1,207 @@
 package services
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/base64"
 	"errors"
 	"fmt"
+	"identity-service/config"
 	"identity-service/internal/models"
+	"identity-service/internal/repositories"
+	"io"
+	"log"
 	"net"
+	"net/http"
 	"strings"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+const (
+	// domainTXTPrefix starts the TXT record value that carries the verification token
+	domainTXTPrefix = "identity-verification="
+	// domainFilePath is where the file method expects the token
+	domainFilePath = "/.well-known/identity-verification.txt"
+	// domainCheckTimeout bounds a single DNS or HTTP check
+	domainCheckTimeout = 10 * time.Second
+	// domainReverifyTick is how often re-verification looks for domains that are due
+	domainReverifyTick = time.Hour
+	// domainReverifyBatch is how many domains are re-verified per query
+	domainReverifyBatch = 100
+	// domainMaxFileSize bounds the verification file we read
+	domainMaxFileSize = 1024
+)
+
+var (
+	ErrVerificationFailed        = errors.New("domain verification failed")
+	ErrVerificationExpired       = errors.New("domain verification token has expired; start the verification again")
+	ErrInvalidDomain             = errors.New("invalid domain format")
+	ErrInvalidVerificationMethod = errors.New("invalid verification method")
+	ErrDomainTaken               = errors.New("domain is verified by another tenant")
+	ErrTenantHasDomain           = errors.New("tenant already has a verified domain; remove it first")
+)
+
+// TXTResolver looks up DNS TXT records. *net.Resolver implements it.
+type TXTResolver interface {
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+}
+
 // DomainService handles domain verification and management
 type DomainService interface {
-	InitiateDomainVerification(tenantID uuid.UUID, domain string) (*DomainVerification, error)
-	CheckVerificationStatus(tenantID uuid.UUID, domain string) (bool, error)
-	VerifyDomain(tenantID uuid.UUID, domain string, method string) error
+	InitiateDomainVerification(tenantID uuid.UUID, domain string, method string) (*models.DomainVerification, error)
+	GetVerification(tenantID uuid.UUID, domain string) (*models.DomainVerification, error)
+	ListVerifications(tenantID uuid.UUID) ([]*models.DomainVerification, error)
+	VerifyDomain(tenantID uuid.UUID, domain string) (*models.DomainVerification, error)
 	GetVerifiedDomains(tenantID uuid.UUID) ([]string, error)
 	RemoveDomain(tenantID uuid.UUID, domain string) error
-}
-
-// DomainVerification represents a domain verification request
-type DomainVerification struct {
-	Domain    string    `json:"domain"`
-	Token     string    `json:"token"`
-	Method    string    `json:"method"`
-	CreatedAt time.Time `json:"createdAt"`
-	ExpiresAt time.Time `json:"expiresAt"`
+	ReverifyDomains() error
+	StartReverification()
 }
 
 type domainService struct {
-	tenantService TenantService
+	repo       repositories.DomainVerificationRepository
+	tenantRepo repositories.TenantRepository
+	resolver   TXTResolver
+	httpClient *http.Client
 }
 
-var ErrVerificationFailed = errors.New("domain verification failed")
-
-func NewDomainService(tenantService TenantService) DomainService {
+// NewDomainService creates a domain service that looks up TXT records with resolver and
+// fetches verification files with httpClient
+func NewDomainService(
+	repo repositories.DomainVerificationRepository,
+	tenantRepo repositories.TenantRepository,
+	resolver TXTResolver,
+	httpClient *http.Client,
+) DomainService {
 	return &domainService{
-		tenantService: tenantService,
+		repo:       repo,
+		tenantRepo: tenantRepo,
+		resolver:   resolver,
+		httpClient: httpClient,
 	}
 }
 
-func (s *domainService) InitiateDomainVerification(tenantID uuid.UUID, domain string) (*DomainVerification, error) {
-	// Validate domain format
+// InitiateDomainVerification issues a token for the tenant to publish on the domain. Starting
+// again replaces the token of a pending or lapsed verification.
+func (s *domainService) InitiateDomainVerification(tenantID uuid.UUID, domain string, method string) (*models.DomainVerification, error) {
+	domain = normalizeDomain(domain)
 	if !isValidDomain(domain) {
-		return nil, errors.New("invalid domain format")
+		return nil, ErrInvalidDomain
+	}
+	if method == "" {
+		method = models.DomainVerificationDNS
+	}
+	if method != models.DomainVerificationDNS && method != models.DomainVerificationFile {
+		return nil, ErrInvalidVerificationMethod
+	}
+	if owner, err := s.tenantRepo.GetTenantByVerifiedDomain(domain); err == nil && owner.ID != tenantID {
+		return nil, ErrDomainTaken
 	}
 
-	// Generate verification token
 	token, err := generateVerificationToken()
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate verification token: %v", err)
 	}
+	expiresAt := time.Now().Add(config.Domain.VerificationTTL)
 
-	// Create verification record
-	verification := &DomainVerification{
-		Domain:    domain,
-		Token:     token,
-		Method:    "dns",
-		CreatedAt: time.Now(),
-		ExpiresAt: time.Now().Add(24 * time.Hour),
+	verification, err := s.repo.GetVerification(tenantID, domain)
+	if err != nil {
+		verification = &models.DomainVerification{
+			ID:        uuid.New(),
+			TenantID:  tenantID,
+			Domain:    domain,
+			Method:    method,
+			Token:     token,
+			Status:    models.DomainVerificationPending,
+			ExpiresAt: expiresAt,
+		}
+		if err := s.repo.CreateVerification(verification); err != nil {
+			return nil, err
+		}
+		return withInstructions(verification), nil
 	}
 
-	// Store verification details in tenant settings
-	_, err = s.tenantService.UpdateTenant(tenantID, &models.TenantUpdate{
-		Domain: &domain,
-	})
-	if err != nil {
+	if verification.Status == models.DomainVerificationVerified {
+		return withInstructions(verification), nil
+	}
+	verification.Method = method
+	verification.Token = token
+	verification.Status = models.DomainVerificationPending
+	verification.ExpiresAt = expiresAt
+	verification.LastError = ""
+	if err := s.repo.SaveVerification(verification); err != nil {
 		return nil, err
 	}
-
-	return verification, nil
+	return withInstructions(verification), nil
 }
 
-func (s *domainService) CheckVerificationStatus(tenantID uuid.UUID, domain string) (bool, error) {
-	tenant, err := s.tenantService.GetTenant(tenantID)
+func (s *domainService) GetVerification(tenantID uuid.UUID, domain string) (*models.DomainVerification, error) {
+	verification, err := s.repo.GetVerification(tenantID, normalizeDomain(domain))
 	if err != nil {
-		return false, err
+		return nil, err
 	}
+	return withInstructions(verification), nil
+}
 
-	if tenant.Domain != domain {
-		return false, errors.New("domain mismatch")
+func (s *domainService) ListVerifications(tenantID uuid.UUID) ([]*models.DomainVerification, error) {
+	verifications, err := s.repo.ListVerifications(tenantID)
+	if err != nil {
+		return nil, err
 	}
-
-	return tenant.DomainVerified, nil
+	for _, verification := range verifications {
+		withInstructions(verification)
+	}
+	return verifications, nil
 }
 
-func (s *domainService) VerifyDomain(tenantID uuid.UUID, domain string, method string) error {
-	tenant, err := s.tenantService.GetTenant(tenantID)
+// VerifyDomain checks the token is published and, if it is, makes the domain the tenant's
+// verified domain
+func (s *domainService) VerifyDomain(tenantID uuid.UUID, domain string) (*models.DomainVerification, error) {
+	verification, err := s.repo.GetVerification(tenantID, normalizeDomain(domain))
 	if err != nil {
-		return err
+		return nil, err
 	}
-
-	if tenant.Domain != domain {
-		return errors.New("domain mismatch")
+	now := time.Now()
+	if verification.Expired(now) {
+		return nil, ErrVerificationExpired
 	}
 
-	var verified bool
-	switch method {
-	case "dns":
-		verified = s.verifyDNSRecord(domain)
-	case "file":
-		verified = s.verifyFileToken(domain)
-	default:
-		return errors.New("invalid verification method")
+	checkErr := s.check(verification)
+	verification.LastCheckedAt = &now
+	if checkErr != nil {
+		verification.LastError = checkErr.Error()
+		if err := s.repo.SaveVerification(verification); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: %v", ErrVerificationFailed, checkErr)
 	}
 
-	if !verified {
-		return ErrVerificationFailed
+	if verification.Status != models.DomainVerificationVerified {
+		if owner, err := s.tenantRepo.GetTenantByVerifiedDomain(verification.Domain); err == nil && owner.ID != tenantID {
+			return nil, ErrDomainTaken
+		}
+		tenant, err := s.tenantRepo.GetTenantByID(tenantID)
+		if err != nil {
+			return nil, err
+		}
+		if tenant.DomainVerified && !strings.EqualFold(tenant.Domain, verification.Domain) {
+			return nil, ErrTenantHasDomain
+		}
 	}
 
-	// Update tenant with verified domain
-	_, err = s.tenantService.UpdateTenant(tenantID, &models.TenantUpdate{
-		DomainVerified: &verified,
-	})
-	return err
+	verification.Status = models.DomainVerificationVerified
+	verification.VerifiedAt = &now
+	verification.LastError = ""
+	if err := s.repo.MarkVerified(verification); err != nil {
+		return nil, err
+	}
+	return withInstructions(verification), nil
 }
 
 func (s *domainService) GetVerifiedDomains(tenantID uuid.UUID) ([]string, error) {
-	tenant, err := s.tenantService.GetTenant(tenantID)
+	tenant, err := s.tenantRepo.GetTenantByID(tenantID)
 	if err != nil {
 		return nil, err
 	}
@@ -132,33 +213,114 @@ func (s *domainService) GetVerifiedDomains(tenantID uuid.UUID) ([]string, error)
 	return []string{}, nil
 }
 
+// RemoveDomain deletes the tenant's claim on a domain, unverifying it
 func (s *domainService) RemoveDomain(tenantID uuid.UUID, domain string) error {
-	tenant, err := s.tenantService.GetTenant(tenantID)
+	verification, err := s.repo.GetVerification(tenantID, normalizeDomain(domain))
 	if err != nil {
 		return err
 	}
+	return s.repo.DeleteVerification(verification)
+}
+
+// ReverifyDomains checks verified domains that are due. A domain whose token has been
+// missing for longer than the grace period is unverified, which ends single sign-on and
+// home realm discovery for it.
+func (s *domainService) ReverifyDomains() error {
+	for {
+		now := time.Now()
+		due, err := s.repo.ListDueVerifications(now.Add(-config.Domain.ReverifyInterval), domainReverifyBatch)
+		if err != nil {
+			return err
+		}
+		if len(due) == 0 {
+			return nil
+		}
 
-	if tenant.Domain != domain {
-		return errors.New("domain mismatch")
+		for _, verification := range due {
+			if err := s.reverify(verification, now); err != nil {
+				return err
+			}
+		}
+		if len(due) < domainReverifyBatch {
+			return nil
+		}
 	}
+}
 
-	emptyDomain := ""
-	verified := false
-	_, err = s.tenantService.UpdateTenant(tenantID, &models.TenantUpdate{
-		Domain:         &emptyDomain,
-		DomainVerified: &verified,
-	})
-	return err
+func (s *domainService) reverify(verification *models.DomainVerification, now time.Time) error {
+	verification.LastCheckedAt = &now
+
+	checkErr := s.check(verification)
+	if checkErr == nil {
+		verification.VerifiedAt = &now
+		verification.LastError = ""
+		return s.repo.SaveVerification(verification)
+	}
+
+	verification.LastError = checkErr.Error()
+	if verification.VerifiedAt != nil && now.Sub(*verification.VerifiedAt) <= config.Domain.ReverifyGracePeriod {
+		return s.repo.SaveVerification(verification)
+	}
+
+	log.Printf("Domain %s of tenant %s lapsed: %v", verification.Domain, verification.TenantID, checkErr)
+	verification.Status = models.DomainVerificationLapsed
+	return s.repo.MarkLapsed(verification)
+}
+
+// StartReverification re-verifies domains in the background until the process exits
+func (s *domainService) StartReverification() {
+	ticker := time.NewTicker(domainReverifyTick)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := s.ReverifyDomains(); err != nil {
+			log.Printf("Failed to re-verify domains: %v", err)
+		}
+	}
+}
+
+// check looks for the token with the verification's method
+func (s *domainService) check(verification *models.DomainVerification) error {
+	ctx, cancel := context.WithTimeout(context.Background(), domainCheckTimeout)
+	defer cancel()
+
+	switch verification.Method {
+	case models.DomainVerificationDNS:
+		return s.verifyDNSRecord(ctx, verification.Domain, verification.Token)
+	case models.DomainVerificationFile:
+		return s.verifyFileToken(ctx, verification.Domain, verification.Token)
+	default:
+		return ErrInvalidVerificationMethod
+	}
 }
 
 // Helper functions
 
+func normalizeDomain(domain string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(domain)), ".")
+}
+
+// isValidDomain accepts DNS names with at least two labels whose top-level label is not numeric,
+// which rules out IP addresses
 func isValidDomain(domain string) bool {
-	// Basic domain validation
-	if strings.HasPrefix(domain, ".") || strings.HasSuffix(domain, ".") {
+	if len(domain) == 0 || len(domain) > 253 {
+		return false
+	}
+	labels := strings.Split(domain, ".")
+	if len(labels) < 2 {
 		return false
 	}
-	return true
+	for _, label := range labels {
+		if len(label) == 0 || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, c := range label {
+			if (c < 'a' || c > 'z') && (c < '0' || c > '9') && c != '-' {
+				return false
+			}
+		}
+	}
+	return strings.ContainsFunc(labels[len(labels)-1], func(c rune) bool { return c >= 'a' && c <= 'z' })
 }
 
 func generateVerificationToken() (string, error) {
@@ -167,29 +329,91 @@ func generateVerificationToken() (string, error) {
 	if err != nil {
 		return "", err
 	}
-	return base64.URLEncoding.EncodeToString(bytes), nil
+	return base64.RawURLEncoding.EncodeToString(bytes), nil
+}
+
+// withInstructions fills in where the token must be published
+func withInstructions(verification *models.DomainVerification) *models.DomainVerification {
+	switch verification.Method {
+	case models.DomainVerificationDNS:
+		verification.TXTRecord = domainTXTPrefix + verification.Token
+	case models.DomainVerificationFile:
+		verification.FileURL = "https://" + verification.Domain + domainFilePath
+	}
+	return verification
 }
 
-func (s *domainService) verifyDNSRecord(domain string) bool {
-	// Implement DNS record verification logic
-	// This is a placeholder implementation
-	records, err := net.LookupTXT(domain)
+func (s *domainService) verifyDNSRecord(ctx context.Context, domain string, token string) error {
+	records, err := s.resolver.LookupTXT(ctx, domain)
 	if err != nil {
-		return false
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			return errors.New("no TXT records found")
+		}
+		return fmt.Errorf("TXT lookup failed: %v", err)
 	}
 
-	// Check if any of the TXT records match our verification token
 	for _, record := range records {
-		if strings.HasPrefix(record, "identity-verification=") {
-			return true
+		if strings.TrimSpace(record) == domainTXTPrefix+token {
+			return nil
 		}
 	}
+	return errors.New("verification TXT record not found")
+}
+
+func (s *domainService) verifyFileToken(ctx context.Context, domain string, token string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://"+domain+domainFilePath, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to fetch verification file: %v", err)
+	}
+	defer resp.Body.Close()
 
-	return false
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("verification file returned %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, domainMaxFileSize))
+	if err != nil {
+		return fmt.Errorf("failed to read verification file: %v", err)
+	}
+	if strings.TrimSpace(string(body)) != token {
+		return errors.New("verification file does not contain the token")
+	}
+	return nil
+}
+
+// NewDomainResolver returns the resolver TXT records are looked up with: the DNS server at
+// address (host:port), or the system resolver if address is empty
+func NewDomainResolver(address string) *net.Resolver {
+	if address == "" {
+		return net.DefaultResolver
+	}
+
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, network, address)
+		},
+	}
 }
 
-func (s *domainService) verifyFileToken(_ string) bool {
-	// Implement file-based verification logic
-	// This is a placeholder implementation
-	return false
+// NewDomainHTTPClient returns the client verification files are fetched with. Redirects are
+// followed only within the domain being verified, over HTTPS.
+func NewDomainHTTPClient() *http.Client {
+	return &http.Client{
+		Timeout: domainCheckTimeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 5 {
+				return errors.New("too many redirects")
+			}
+			if req.URL.Scheme != "https" || !strings.EqualFold(req.URL.Hostname(), via[0].URL.Hostname()) {
+				return errors.New("verification file redirected to another host")
+			}
+			return nil
+		},
+	}
 }
@@ -0,0 +1,372 @@
+package services
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"identity-service/config"
+	"identity-service/internal/models"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/net/dns/dnsmessage"
+	"gorm.io/gorm"
+)
+
+// dnsStub is a local DNS server answering TXT queries from its records. Names without records
+// do not exist.
+type dnsStub struct {
+	conn    net.PacketConn
+	mu      sync.Mutex
+	records map[string][]string
+}
+
+func startDNSStub(t *testing.T) *dnsStub {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	stub := &dnsStub{conn: conn, records: make(map[string][]string)}
+	t.Cleanup(func() { conn.Close() })
+	go stub.serve()
+	return stub
+}
+
+// publish sets the TXT records of a domain
+func (s *dnsStub) publish(domain string, records ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records[domain+"."] = records
+}
+
+// remove deletes a domain, so lookups of it get NXDOMAIN
+func (s *dnsStub) remove(domain string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.records, domain+".")
+}
+
+func (s *dnsStub) serve() {
+	buf := make([]byte, 512)
+	for {
+		n, addr, err := s.conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		if response, err := s.answer(buf[:n]); err == nil {
+			s.conn.WriteTo(response, addr)
+		}
+	}
+}
+
+func (s *dnsStub) answer(query []byte) ([]byte, error) {
+	var parser dnsmessage.Parser
+	header, err := parser.Start(query)
+	if err != nil {
+		return nil, err
+	}
+	question, err := parser.Question()
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	records, found := s.records[strings.ToLower(question.Name.String())]
+	s.mu.Unlock()
+
+	header.Response = true
+	header.Authoritative = true
+	if !found {
+		header.RCode = dnsmessage.RCodeNameError
+	}
+	builder := dnsmessage.NewBuilder(nil, header)
+	builder.EnableCompression()
+	if err := builder.StartQuestions(); err != nil {
+		return nil, err
+	}
+	if err := builder.Question(question); err != nil {
+		return nil, err
+	}
+	if err := builder.StartAnswers(); err != nil {
+		return nil, err
+	}
+	if question.Type == dnsmessage.TypeTXT {
+		for _, record := range records {
+			resource := dnsmessage.ResourceHeader{Name: question.Name, Class: dnsmessage.ClassINET, TTL: 60}
+			if err := builder.TXTResource(resource, dnsmessage.TXTResource{TXT: []string{record}}); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return builder.Finish()
+}
+
+type fakeDomainVerificationRepository struct {
+	verifications map[string]*models.DomainVerification
+	tenants       *fakeTenantRepository
+}
+
+func domainVerificationKey(tenantID uuid.UUID, domain string) string {
+	return tenantID.String() + "/" + domain
+}
+
+func (r *fakeDomainVerificationRepository) CreateVerification(verification *models.DomainVerification) error {
+	r.verifications[domainVerificationKey(verification.TenantID, verification.Domain)] = verification
+	return nil
+}
+
+func (r *fakeDomainVerificationRepository) GetVerification(tenantID uuid.UUID, domain string) (*models.DomainVerification, error) {
+	verification, ok := r.verifications[domainVerificationKey(tenantID, domain)]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return verification, nil
+}
+
+func (r *fakeDomainVerificationRepository) ListVerifications(tenantID uuid.UUID) ([]*models.DomainVerification, error) {
+	var verifications []*models.DomainVerification
+	for _, verification := range r.verifications {
+		if verification.TenantID == tenantID {
+			verifications = append(verifications, verification)
+		}
+	}
+	return verifications, nil
+}
+
+func (r *fakeDomainVerificationRepository) ListDueVerifications(checkedBefore time.Time, limit int) ([]*models.DomainVerification, error) {
+	var due []*models.DomainVerification
+	for _, verification := range r.verifications {
+		if verification.Status == models.DomainVerificationVerified && len(due) < limit &&
+			(verification.LastCheckedAt == nil || verification.LastCheckedAt.Before(checkedBefore)) {
+			due = append(due, verification)
+		}
+	}
+	return due, nil
+}
+
+func (r *fakeDomainVerificationRepository) SaveVerification(verification *models.DomainVerification) error {
+	return r.CreateVerification(verification)
+}
+
+func (r *fakeDomainVerificationRepository) DeleteVerification(verification *models.DomainVerification) error {
+	delete(r.verifications, domainVerificationKey(verification.TenantID, verification.Domain))
+	return nil
+}
+
+func (r *fakeDomainVerificationRepository) MarkVerified(verification *models.DomainVerification) error {
+	tenant := r.tenants.tenants[verification.TenantID]
+	tenant.Domain = verification.Domain
+	tenant.DomainVerified = true
+	return r.SaveVerification(verification)
+}
+
+func (r *fakeDomainVerificationRepository) MarkLapsed(verification *models.DomainVerification) error {
+	r.tenants.tenants[verification.TenantID].DomainVerified = false
+	return r.SaveVerification(verification)
+}
+
+// domainFixture is a tenant without a domain, whose TXT records are looked up on a DNS stub
+type domainFixture struct {
+	service DomainService
+	dns     *dnsStub
+	tenants *fakeTenantRepository
+	tenant  *models.Tenant
+}
+
+func newDomainFixture(t *testing.T, httpClient *http.Client) *domainFixture {
+	t.Helper()
+	config.LoadDomainConfig()
+
+	f := &domainFixture{dns: startDNSStub(t), tenants: newFakeTenantRepository()}
+	f.tenant = f.tenants.addTenant(t, "")
+	f.tenant.DomainVerified = false
+
+	repo := &fakeDomainVerificationRepository{verifications: make(map[string]*models.DomainVerification), tenants: f.tenants}
+	resolver := NewDomainResolver(f.dns.conn.LocalAddr().String())
+	f.service = NewDomainService(repo, f.tenants, resolver, httpClient)
+	return f
+}
+
+func (f *domainFixture) initiate(t *testing.T, domain, method string) *models.DomainVerification {
+	t.Helper()
+	verification, err := f.service.InitiateDomainVerification(f.tenant.ID, domain, method)
+	if err != nil {
+		t.Fatalf("InitiateDomainVerification: %v", err)
+	}
+	return verification
+}
+
+func TestDomainVerifyDNSRecordMatches(t *testing.T) {
+	f := newDomainFixture(t, nil)
+	verification := f.initiate(t, "Example.com", models.DomainVerificationDNS)
+	f.dns.publish("example.com", "v=spf1 -all", verification.TXTRecord)
+
+	verified, err := f.service.VerifyDomain(f.tenant.ID, "example.com")
+	if err != nil {
+		t.Fatalf("VerifyDomain: %v", err)
+	}
+	if verified.Status != models.DomainVerificationVerified || verified.VerifiedAt == nil {
+		t.Errorf("verification status = %s, want %s", verified.Status, models.DomainVerificationVerified)
+	}
+	if !f.tenant.DomainVerified || f.tenant.Domain != "example.com" {
+		t.Errorf("tenant domain = %q verified=%v, want example.com verified", f.tenant.Domain, f.tenant.DomainVerified)
+	}
+}
+
+func TestDomainVerifyDNSRecordFailures(t *testing.T) {
+	tests := []struct {
+		name    string
+		publish func(stub *dnsStub, verification *models.DomainVerification)
+		want    string
+	}{
+		{
+			name: "other token",
+			publish: func(stub *dnsStub, verification *models.DomainVerification) {
+				stub.publish("example.com", domainTXTPrefix+"someone-elses-token")
+			},
+			want: "verification TXT record not found",
+		},
+		{
+			name: "record on another name",
+			publish: func(stub *dnsStub, verification *models.DomainVerification) {
+				stub.publish("www.example.com", verification.TXTRecord)
+			},
+			want: "no TXT records found",
+		},
+		{
+			name:    "NXDOMAIN",
+			publish: func(stub *dnsStub, verification *models.DomainVerification) {},
+			want:    "no TXT records found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newDomainFixture(t, nil)
+			verification := f.initiate(t, "example.com", models.DomainVerificationDNS)
+			tt.publish(f.dns, verification)
+
+			_, err := f.service.VerifyDomain(f.tenant.ID, "example.com")
+			if !errors.Is(err, ErrVerificationFailed) || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("VerifyDomain error = %v, want %v: %s", err, ErrVerificationFailed, tt.want)
+			}
+			if verification.LastError == "" || verification.Status != models.DomainVerificationPending {
+				t.Errorf("verification = %s %q, want pending with the error recorded", verification.Status, verification.LastError)
+			}
+			if f.tenant.DomainVerified {
+				t.Error("tenant domain was verified")
+			}
+		})
+	}
+}
+
+func TestDomainReverifyLapsesRemovedRecord(t *testing.T) {
+	f := newDomainFixture(t, nil)
+	verification := f.initiate(t, "example.com", models.DomainVerificationDNS)
+	f.dns.publish("example.com", verification.TXTRecord)
+	if _, err := f.service.VerifyDomain(f.tenant.ID, "example.com"); err != nil {
+		t.Fatalf("VerifyDomain: %v", err)
+	}
+
+	// The record is removed, and the domain is due again after the grace period
+	f.dns.remove("example.com")
+	checked := time.Now().Add(-config.Domain.ReverifyInterval - time.Minute)
+	verifiedAt := time.Now().Add(-config.Domain.ReverifyGracePeriod - time.Minute)
+	verification.LastCheckedAt, verification.VerifiedAt = &checked, &verifiedAt
+
+	if err := f.service.ReverifyDomains(); err != nil {
+		t.Fatalf("ReverifyDomains: %v", err)
+	}
+	if verification.Status != models.DomainVerificationLapsed || f.tenant.DomainVerified {
+		t.Fatalf("verification = %s, tenant verified=%v, want lapsed and unverified", verification.Status, f.tenant.DomainVerified)
+	}
+}
+
+// newVerificationFileServer serves the verification file for example.com over HTTPS. The
+// returned client reaches it for any host and trusts its certificate.
+func newVerificationFileServer(t *testing.T, handler http.HandlerFunc) *http.Client {
+	t.Helper()
+	server := httptest.NewTLSServer(handler)
+	t.Cleanup(server.Close)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate())
+	client := NewDomainHTTPClient()
+	client.Transport = &http.Transport{
+		TLSClientConfig: &tls.Config{RootCAs: roots},
+		DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, network, server.Listener.Addr().String())
+		},
+	}
+	return client
+}
+
+func TestDomainVerifyFile(t *testing.T) {
+	var token string
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		want    string
+	}{
+		{
+			name: "token served",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != domainFilePath {
+					http.NotFound(w, r)
+					return
+				}
+				fmt.Fprintln(w, token)
+			},
+		},
+		{
+			name: "other token",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, "someone-elses-token")
+			},
+			want: "does not contain the token",
+		},
+		{
+			name:    "file missing",
+			handler: http.NotFound,
+			want:    "returned 404",
+		},
+		{
+			name: "redirect to another host",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				http.Redirect(w, r, "https://attacker.example"+domainFilePath, http.StatusFound)
+			},
+			want: "redirected to another host",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newDomainFixture(t, newVerificationFileServer(t, tt.handler))
+			token = f.initiate(t, "example.com", models.DomainVerificationFile).Token
+
+			_, err := f.service.VerifyDomain(f.tenant.ID, "example.com")
+			if tt.want == "" {
+				if err != nil {
+					t.Fatalf("VerifyDomain: %v", err)
+				}
+				if !f.tenant.DomainVerified {
+					t.Error("tenant domain was not verified")
+				}
+				return
+			}
+			if !errors.Is(err, ErrVerificationFailed) || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("VerifyDomain error = %v, want %v: %s", err, ErrVerificationFailed, tt.want)
+			}
+		})
+	}
+}
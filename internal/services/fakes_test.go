@@ -0,0 +1,161 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"identity-service/internal/models"
+	"identity-service/internal/repositories"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// fakeTenantRepository keeps tenants and memberships in memory. Methods the tests do not use
+// are left to the embedded interface and panic if called.
+type fakeTenantRepository struct {
+	repositories.TenantRepository
+	tenants map[uuid.UUID]*models.Tenant
+	access  map[uuid.UUID]map[uuid.UUID]*models.UserTenantAccess
+}
+
+func newFakeTenantRepository() *fakeTenantRepository {
+	return &fakeTenantRepository{
+		tenants: make(map[uuid.UUID]*models.Tenant),
+		access:  make(map[uuid.UUID]map[uuid.UUID]*models.UserTenantAccess),
+	}
+}
+
+// addTenant stores an SSO tenant that has verified the domain
+func (r *fakeTenantRepository) addTenant(t *testing.T, domain string) *models.Tenant {
+	t.Helper()
+	features, err := json.Marshal(map[string]bool{ssoFeature: true})
+	if err != nil {
+		t.Fatalf("marshal features: %v", err)
+	}
+	tenant := &models.Tenant{
+		ID:             uuid.New(),
+		Name:           domain,
+		Domain:         domain,
+		DomainVerified: true,
+		Features:       features,
+	}
+	r.tenants[tenant.ID] = tenant
+	return tenant
+}
+
+func (r *fakeTenantRepository) GetTenantByID(id uuid.UUID) (*models.Tenant, error) {
+	tenant, ok := r.tenants[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return tenant, nil
+}
+
+func (r *fakeTenantRepository) GetTenantByVerifiedDomain(domain string) (*models.Tenant, error) {
+	for _, tenant := range r.tenants {
+		if tenant.DomainVerified && strings.EqualFold(tenant.Domain, domain) {
+			return tenant, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *fakeTenantRepository) GetUserTenantAccess(userID, tenantID uuid.UUID) (*models.UserTenantAccess, error) {
+	access, ok := r.access[tenantID][userID]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return access, nil
+}
+
+// fakeUserService keeps users in memory by email and records memberships in a tenant repository
+type fakeUserService struct {
+	UserService
+	tenantRepo *fakeTenantRepository
+	users      map[string]*models.User
+}
+
+func newFakeUserService(tenantRepo *fakeTenantRepository) *fakeUserService {
+	return &fakeUserService{tenantRepo: tenantRepo, users: make(map[string]*models.User)}
+}
+
+func (s *fakeUserService) CreateOrUpdateUser(oauthUser *models.OAuthUser) (*models.User, error) {
+	user, ok := s.users[oauthUser.Email]
+	if !ok {
+		user = &models.User{
+			ID:            uuid.New(),
+			Email:         oauthUser.Email,
+			Name:          oauthUser.Name,
+			EmailVerified: oauthUser.VerifiedEmail,
+		}
+		s.users[oauthUser.Email] = user
+	}
+	return user, nil
+}
+
+func (s *fakeUserService) UpdateUser(id uuid.UUID, update *models.UserUpdate) error {
+	for _, user := range s.users {
+		if user.ID == id {
+			if update.Name != nil {
+				user.Name = *update.Name
+			}
+			return nil
+		}
+	}
+	return gorm.ErrRecordNotFound
+}
+
+func (s *fakeUserService) AddUserToTenant(userID, tenantID uuid.UUID, roles []string) error {
+	if s.tenantRepo.access[tenantID] == nil {
+		s.tenantRepo.access[tenantID] = make(map[uuid.UUID]*models.UserTenantAccess)
+	}
+	s.tenantRepo.access[tenantID][userID] = &models.UserTenantAccess{
+		ID:       uuid.New(),
+		UserID:   userID,
+		TenantID: tenantID,
+		Roles:    roles,
+		Active:   true,
+	}
+	return nil
+}
+
+func (s *fakeUserService) UpdateUserRoles(userID, tenantID uuid.UUID, roles []string) error {
+	access, err := s.tenantRepo.GetUserTenantAccess(userID, tenantID)
+	if err != nil {
+		return err
+	}
+	access.Roles = roles
+	return nil
+}
+
+// roles returns the user's roles in the tenant, or nil if they are not a member
+func (s *fakeUserService) roles(userID, tenantID uuid.UUID) []string {
+	access, err := s.tenantRepo.GetUserTenantAccess(userID, tenantID)
+	if err != nil {
+		return nil
+	}
+	return access.Roles
+}
+
+// fakeRoleService knows the built-in roles and the custom roles it is given, by name
+type fakeRoleService struct {
+	RoleService
+	custom map[string][]string
+}
+
+func (s *fakeRoleService) RolePermissions(tenantID uuid.UUID, roles []string) ([]string, error) {
+	var permissions []string
+	for _, role := range roles {
+		granted, ok := models.BuiltinRoles[role]
+		if !ok {
+			granted, ok = s.custom[role]
+		}
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownRole, role)
+		}
+		permissions = append(permissions, granted...)
+	}
+	return permissions, nil
+}
@@ -0,0 +1,314 @@
+package services
+
+import (
+	"errors"
+	"identity-service/internal/models"
+	"identity-service/internal/repositories"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Audit log actions recorded for groups
+const (
+	auditGroupCreated       = "group.created"
+	auditGroupUpdated       = "group.updated"
+	auditGroupDeleted       = "group.deleted"
+	auditGroupMemberAdded   = "group.member_added"
+	auditGroupMemberRemoved = "group.member_removed"
+)
+
+var (
+	ErrInvalidGroupName = errors.New("group name must be 1 to 255 characters")
+	ErrGroupExists      = errors.New("a group with this name already exists")
+	ErrNotTenantMember  = errors.New("user is not an active member of the tenant")
+	ErrGroupCycle       = errors.New("a group cannot be nested in itself or in a group nested in it")
+	ErrUnknownGroup     = errors.New("group is not defined in the tenant")
+)
+
+// GroupService manages the groups of a tenant's members. Groups can be nested: the members of
+// a group nested in another are members of both, and hold the roles of both.
+type GroupService interface {
+	ListGroups(tenantID uuid.UUID) ([]*models.TenantGroup, error)
+	// GetGroup returns a group with the users and groups directly in it
+	GetGroup(tenantID, groupID uuid.UUID) (*models.TenantGroupDetails, error)
+	CreateGroup(tenantID uuid.UUID, request *models.TenantGroupRequest, actorID uuid.UUID) (*models.TenantGroup, error)
+	UpdateGroup(tenantID, groupID uuid.UUID, update *models.TenantGroupUpdate, actorID uuid.UUID) (*models.TenantGroup, error)
+	DeleteGroup(tenantID, groupID, actorID uuid.UUID) error
+	// GroupRoles returns the roles members of the group hold through it: its own and those of
+	// the groups it is nested in
+	GroupRoles(tenantID, groupID uuid.UUID) ([]string, error)
+	// GroupRolesByName is GroupRoles for the group with the name, ignoring case, returning
+	// ErrUnknownGroup if the tenant has no such group
+	GroupRolesByName(tenantID uuid.UUID, name string) ([]string, error)
+
+	// AddUser puts an active member of the tenant in the group
+	AddUser(tenantID, groupID, userID, actorID uuid.UUID) error
+	RemoveUser(tenantID, groupID, userID, actorID uuid.UUID) error
+	// AddGroup nests another of the tenant's groups in the group, unless that forms a cycle
+	AddGroup(tenantID, groupID, memberGroupID, actorID uuid.UUID) error
+	RemoveGroup(tenantID, groupID, memberGroupID, actorID uuid.UUID) error
+}
+
+type groupService struct {
+	repo         repositories.GroupRepository
+	tenantRepo   repositories.TenantRepository
+	securityRepo repositories.SecurityRepository
+}
+
+func NewGroupService(
+	repo repositories.GroupRepository,
+	tenantRepo repositories.TenantRepository,
+	securityRepo repositories.SecurityRepository,
+) GroupService {
+	return &groupService{
+		repo:         repo,
+		tenantRepo:   tenantRepo,
+		securityRepo: securityRepo,
+	}
+}
+
+func (s *groupService) ListGroups(tenantID uuid.UUID) ([]*models.TenantGroup, error) {
+	return s.repo.ListGroups(tenantID)
+}
+
+func (s *groupService) GetGroup(tenantID, groupID uuid.UUID) (*models.TenantGroupDetails, error) {
+	group, err := s.repo.GetGroup(tenantID, groupID)
+	if err != nil {
+		return nil, err
+	}
+	users, err := s.repo.GetGroupUsers(groupID)
+	if err != nil {
+		return nil, err
+	}
+	groups, err := s.repo.GetNestedGroups(groupID)
+	if err != nil {
+		return nil, err
+	}
+	return &models.TenantGroupDetails{TenantGroup: *group, Users: users, Groups: groups}, nil
+}
+
+func (s *groupService) CreateGroup(tenantID uuid.UUID, request *models.TenantGroupRequest, actorID uuid.UUID) (*models.TenantGroup, error) {
+	name := strings.TrimSpace(request.Name)
+	if name == "" || len(name) > 255 {
+		return nil, ErrInvalidGroupName
+	}
+	_, err := s.repo.GetGroupByName(tenantID, name)
+	if err == nil {
+		return nil, ErrGroupExists
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	group := &models.TenantGroup{
+		ID:          uuid.New(),
+		TenantID:    tenantID,
+		Name:        name,
+		Description: request.Description,
+		Roles:       normalizeGroupRoles(request.Roles),
+	}
+	if err := s.repo.CreateGroup(group); err != nil {
+		return nil, err
+	}
+	s.audit(tenantID, actorID, auditGroupCreated, group, map[string]string{
+		"name":  group.Name,
+		"roles": strings.Join(group.Roles, " "),
+	})
+	return group, nil
+}
+
+func (s *groupService) UpdateGroup(tenantID, groupID uuid.UUID, update *models.TenantGroupUpdate, actorID uuid.UUID) (*models.TenantGroup, error) {
+	group, err := s.repo.GetGroup(tenantID, groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	details := map[string]string{"name": group.Name}
+	if update.Description != nil {
+		group.Description = *update.Description
+		details["description"] = group.Description
+	}
+	if update.Roles != nil {
+		roles := normalizeGroupRoles(update.Roles)
+		details["previousRoles"] = strings.Join(group.Roles, " ")
+		details["roles"] = strings.Join(roles, " ")
+		group.Roles = roles
+	}
+	if err := s.repo.SaveGroup(group); err != nil {
+		return nil, err
+	}
+	s.audit(tenantID, actorID, auditGroupUpdated, group, details)
+	return group, nil
+}
+
+func (s *groupService) DeleteGroup(tenantID, groupID, actorID uuid.UUID) error {
+	group, err := s.repo.GetGroup(tenantID, groupID)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.DeleteGroup(tenantID, groupID); err != nil {
+		return err
+	}
+	s.audit(tenantID, actorID, auditGroupDeleted, group, map[string]string{
+		"name":  group.Name,
+		"roles": strings.Join(group.Roles, " "),
+	})
+	return nil
+}
+
+func (s *groupService) GroupRoles(tenantID, groupID uuid.UUID) ([]string, error) {
+	group, err := s.repo.GetGroup(tenantID, groupID)
+	if err != nil {
+		return nil, err
+	}
+	return s.groupRoles(group)
+}
+
+func (s *groupService) GroupRolesByName(tenantID uuid.UUID, name string) ([]string, error) {
+	group, err := s.repo.GetGroupByName(tenantID, name)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrUnknownGroup
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.groupRoles(group)
+}
+
+func (s *groupService) groupRoles(group *models.TenantGroup) ([]string, error) {
+	containing, err := s.repo.GetContainingGroups(group.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	roles := append([]string(nil), group.Roles...)
+	for _, other := range containing {
+		roles = append(roles, other.Roles...)
+	}
+	return normalizeGroupRoles(roles), nil
+}
+
+func (s *groupService) AddUser(tenantID, groupID, userID, actorID uuid.UUID) error {
+	group, err := s.repo.GetGroup(tenantID, groupID)
+	if err != nil {
+		return err
+	}
+	if _, err := s.tenantRepo.GetUserTenantAccess(userID, tenantID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrNotTenantMember
+		}
+		return err
+	}
+	if err := s.repo.AddUser(groupID, userID); err != nil {
+		return err
+	}
+	s.audit(tenantID, actorID, auditGroupMemberAdded, group, map[string]string{
+		"name":   group.Name,
+		"userId": userID.String(),
+	})
+	return nil
+}
+
+func (s *groupService) RemoveUser(tenantID, groupID, userID, actorID uuid.UUID) error {
+	group, err := s.repo.GetGroup(tenantID, groupID)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.RemoveUser(groupID, userID); err != nil {
+		return err
+	}
+	s.audit(tenantID, actorID, auditGroupMemberRemoved, group, map[string]string{
+		"name":   group.Name,
+		"userId": userID.String(),
+	})
+	return nil
+}
+
+func (s *groupService) AddGroup(tenantID, groupID, memberGroupID, actorID uuid.UUID) error {
+	group, err := s.repo.GetGroup(tenantID, groupID)
+	if err != nil {
+		return err
+	}
+	memberGroup, err := s.repo.GetGroup(tenantID, memberGroupID)
+	if err != nil {
+		return err
+	}
+	if groupID == memberGroupID {
+		return ErrGroupCycle
+	}
+	// Nesting the group in one of its own nested groups would make each a member of the other
+	cycle, err := s.repo.IsNestedIn(groupID, memberGroupID)
+	if err != nil {
+		return err
+	}
+	if cycle {
+		return ErrGroupCycle
+	}
+
+	if err := s.repo.AddGroup(groupID, memberGroupID); err != nil {
+		return err
+	}
+	s.audit(tenantID, actorID, auditGroupMemberAdded, group, map[string]string{
+		"name":        group.Name,
+		"memberGroup": memberGroup.Name,
+	})
+	return nil
+}
+
+func (s *groupService) RemoveGroup(tenantID, groupID, memberGroupID, actorID uuid.UUID) error {
+	group, err := s.repo.GetGroup(tenantID, groupID)
+	if err != nil {
+		return err
+	}
+	memberGroup, err := s.repo.GetGroup(tenantID, memberGroupID)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.RemoveGroup(groupID, memberGroupID); err != nil {
+		return err
+	}
+	s.audit(tenantID, actorID, auditGroupMemberRemoved, group, map[string]string{
+		"name":        group.Name,
+		"memberGroup": memberGroup.Name,
+	})
+	return nil
+}
+
+// memberGroupNames returns the sorted names of the tenant's groups the user is in, directly or
+// through nested groups, for the groups claim of tokens
+func memberGroupNames(repo repositories.GroupRepository, userID, tenantID uuid.UUID) ([]string, error) {
+	groups, err := repo.GetMemberGroups([]uuid.UUID{userID})
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for _, group := range groups {
+		if group.TenantID == tenantID {
+			names = append(names, group.Name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (s *groupService) audit(tenantID, actorID uuid.UUID, action string, group *models.TenantGroup, details map[string]string) {
+	recordAudit(s.securityRepo, tenantID, actorID, action, "groups/"+group.ID.String(), details)
+}
+
+// normalizeGroupRoles trims roles and removes blanks and duplicates, sorting the rest
+func normalizeGroupRoles(roles []string) []string {
+	seen := make(map[string]bool)
+	normalized := make([]string, 0, len(roles))
+	for _, role := range roles {
+		role = strings.TrimSpace(role)
+		if role != "" && !seen[role] {
+			seen[role] = true
+			normalized = append(normalized, role)
+		}
+	}
+	sort.Strings(normalized)
+	return normalized
+}
@@ -0,0 +1,436 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"identity-service/config"
+	"identity-service/internal/mail"
+	"identity-service/internal/models"
+	"identity-service/internal/repositories"
+	"identity-service/pkg/utils"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// inviteNonceLength is the length of the random part of invite tokens
+const inviteNonceLength = 32
+
+var (
+	ErrInvalidInviteToken  = errors.New("invalid invitation token")
+	ErrInviteExpired       = errors.New("invitation has expired")
+	ErrInviteNotPending    = errors.New("invitation has already been answered")
+	ErrInviteEmailMismatch = errors.New("invitation was sent to a different email address")
+	ErrInviteExists        = errors.New("email already has a pending invitation to the tenant")
+	ErrInviteNotSent       = errors.New("invitation email could not be sent")
+	ErrAlreadyMember       = errors.New("user is already a member of the tenant")
+	ErrAccountExists       = errors.New("an account already exists for this email; sign in to accept the invitation")
+)
+
+// InviteService invites people to tenants by email. Invitations are accepted with a signed
+// token from the email, by a signed in user with the invited email or by signing up.
+type InviteService interface {
+	CreateInvite(tenantID uuid.UUID, request *models.TenantInviteRequest, inviterID uuid.UUID) (*models.TenantInvite, error)
+	// CreateInviteWithinLimit is CreateInvite, returning ErrTenantFull if the tenant's members
+	// and pending invitations already fill its user limit
+	CreateInviteWithinLimit(tenantID uuid.UUID, request *models.TenantInviteRequest, inviterID uuid.UUID) (*models.TenantInvite, error)
+	ListInvites(tenantID uuid.UUID, status string) ([]*models.TenantInvite, error)
+	ResendInvite(tenantID, inviteID uuid.UUID) (*models.TenantInvite, error)
+	RevokeInvite(tenantID, inviteID uuid.UUID) error
+
+	GetInvitation(token string) (*models.InvitationDetails, error)
+	AcceptInvite(token string, user *models.User) (*models.TenantInvite, error)
+	SignUp(signup *models.InviteSignup) (*models.User, error)
+	DeclineInvite(token string) error
+}
+
+type inviteService struct {
+	repo       repositories.InviteRepository
+	tenantRepo repositories.TenantRepository
+	userRepo   repositories.UserRepository
+	autoJoin   AutoJoinService
+	roles      RoleService
+	groups     GroupService
+	mailer     mail.Mailer
+	signingKey []byte
+}
+
+func NewInviteService(
+	repo repositories.InviteRepository,
+	tenantRepo repositories.TenantRepository,
+	userRepo repositories.UserRepository,
+	autoJoin AutoJoinService,
+	roles RoleService,
+	groups GroupService,
+	mailer mail.Mailer,
+	signingKey []byte,
+) InviteService {
+	return &inviteService{
+		repo:       repo,
+		tenantRepo: tenantRepo,
+		userRepo:   userRepo,
+		autoJoin:   autoJoin,
+		roles:      roles,
+		groups:     groups,
+		mailer:     mailer,
+		signingKey: signingKey,
+	}
+}
+
+// CreateInvite saves an invitation and emails it. The role must be built in or defined by the
+// tenant and the group, if any, must exist; callers check the inviter may grant the roles of
+// both. An invitation that could not be emailed is
+// kept, without lastSentAt, so it can be resent.
+func (s *inviteService) CreateInvite(tenantID uuid.UUID, request *models.TenantInviteRequest, inviterID uuid.UUID) (*models.TenantInvite, error) {
+	return s.createInvite(tenantID, request, inviterID, false)
+}
+
+func (s *inviteService) CreateInviteWithinLimit(tenantID uuid.UUID, request *models.TenantInviteRequest, inviterID uuid.UUID) (*models.TenantInvite, error) {
+	return s.createInvite(tenantID, request, inviterID, true)
+}
+
+func (s *inviteService) createInvite(tenantID uuid.UUID, request *models.TenantInviteRequest, inviterID uuid.UUID, withinLimit bool) (*models.TenantInvite, error) {
+	tenant, err := s.tenantRepo.GetTenantByID(tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	email := strings.TrimSpace(request.Email)
+	if user, err := s.userRepo.GetUserByEmail(email); err == nil {
+		member, err := s.repo.HasMembership(user.ID, tenantID)
+		if err != nil {
+			return nil, err
+		}
+		if member {
+			return nil, ErrAlreadyMember
+		}
+	}
+
+	role := request.Role
+	if role == "" {
+		role = ssoMemberRole
+	}
+	if _, err := s.roles.RolePermissions(tenantID, []string{role}); err != nil {
+		return nil, err
+	}
+	if request.Group != "" {
+		if _, err := s.groups.GroupRolesByName(tenantID, request.Group); err != nil {
+			return nil, err
+		}
+	}
+	expiresAt := time.Now().Add(config.Invite.TTL)
+	var invitedBy *uuid.UUID
+	if inviterID != uuid.Nil {
+		invitedBy = &inviterID
+	}
+
+	// An expired invitation is renewed rather than left blocking a new one
+	invite, err := s.repo.GetPendingInvite(tenantID, email)
+	switch {
+	case err == nil && !invite.Expired(time.Now()):
+		return nil, ErrInviteExists
+	case err == nil:
+		invite.Role = role
+		invite.GroupName = request.Group
+		invite.InvitedBy = invitedBy
+		invite.TokenNonce = utils.GenerateRandomString(inviteNonceLength)
+		invite.ExpiresAt = &expiresAt
+		if err := s.saveInvite(tenant, invite, false, withinLimit); err != nil {
+			return nil, err
+		}
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		invite = &models.TenantInvite{
+			ID:         uuid.New(),
+			TenantID:   tenantID,
+			Email:      email,
+			Role:       role,
+			GroupName:  request.Group,
+			Status:     models.InvitePending,
+			InvitedBy:  invitedBy,
+			TokenNonce: utils.GenerateRandomString(inviteNonceLength),
+			ExpiresAt:  &expiresAt,
+		}
+		if err := s.saveInvite(tenant, invite, true, withinLimit); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, err
+	}
+
+	if err := s.send(tenant, invite); err != nil {
+		log.Printf("Failed to send invitation %s: %v", invite.ID, err)
+	}
+	return invite, nil
+}
+
+func (s *inviteService) ListInvites(tenantID uuid.UUID, status string) ([]*models.TenantInvite, error) {
+	invites, err := s.repo.ListInvites(tenantID, status)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	for _, invite := range invites {
+		if invite.Expired(now) {
+			invite.Status = models.InviteExpired
+		}
+	}
+	return invites, nil
+}
+
+// ResendInvite emails a pending invitation again with a new token and expiry. Links from
+// earlier emails stop working.
+func (s *inviteService) ResendInvite(tenantID, inviteID uuid.UUID) (*models.TenantInvite, error) {
+	invite, err := s.repo.GetInvite(tenantID, inviteID)
+	if err != nil {
+		return nil, err
+	}
+	if invite.Status != models.InvitePending {
+		return nil, ErrInviteNotPending
+	}
+	tenant, err := s.tenantRepo.GetTenantByID(tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	expiresAt := time.Now().Add(config.Invite.TTL)
+	invite.TokenNonce = utils.GenerateRandomString(inviteNonceLength)
+	invite.ExpiresAt = &expiresAt
+	if err := s.send(tenant, invite); err != nil {
+		log.Printf("Failed to resend invitation %s: %v", invite.ID, err)
+		return nil, ErrInviteNotSent
+	}
+	return invite, nil
+}
+
+func (s *inviteService) RevokeInvite(tenantID, inviteID uuid.UUID) error {
+	if _, err := s.repo.GetInvite(tenantID, inviteID); err != nil {
+		return err
+	}
+	return s.repo.DeleteInvite(tenantID, inviteID)
+}
+
+// GetInvitation describes the invitation of a token so its recipient can decide to sign in
+// or sign up
+func (s *inviteService) GetInvitation(token string) (*models.InvitationDetails, error) {
+	invite, err := s.openInvite(token)
+	if err != nil {
+		return nil, err
+	}
+	tenant, err := s.tenantRepo.GetTenantByID(invite.TenantID)
+	if err != nil {
+		return nil, err
+	}
+	_, err = s.userRepo.GetUserByEmail(invite.Email)
+
+	return &models.InvitationDetails{
+		TenantID:      tenant.ID,
+		TenantName:    tenant.Name,
+		Email:         invite.Email,
+		Role:          invite.Role,
+		ExpiresAt:     invite.ExpiresAt,
+		AccountExists: err == nil,
+	}, nil
+}
+
+// AcceptInvite adds a signed in user to the invitation's tenant. The user must have the
+// invited email address.
+func (s *inviteService) AcceptInvite(token string, user *models.User) (*models.TenantInvite, error) {
+	invite, err := s.openInvite(token)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.EqualFold(invite.Email, user.Email) {
+		return nil, ErrInviteEmailMismatch
+	}
+	if err := s.checkJoin(invite, user.ID); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.AcceptInvite(invite, user.ID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInviteNotPending
+		}
+		return nil, err
+	}
+	return invite, nil
+}
+
+// SignUp creates an account for the invited email and accepts the invitation. The token
+// proves the address belongs to the new user, so it is marked verified.
+func (s *inviteService) SignUp(signup *models.InviteSignup) (*models.User, error) {
+	invite, err := s.openInvite(signup.Token)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.userRepo.GetUserByEmail(invite.Email); err == nil {
+		return nil, ErrAccountExists
+	}
+	if err := s.checkJoin(invite, uuid.Nil); err != nil {
+		return nil, err
+	}
+
+	passwordHash, err := utils.HashPassword(signup.Password)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	user := &models.User{
+		ID:            uuid.New(),
+		Email:         invite.Email,
+		EmailVerified: true,
+		Name:          signup.Name,
+		Status:        "active",
+		Role:          "user",
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	if err := s.repo.AcceptInviteAsNewUser(invite, user, passwordHash); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInviteNotPending
+		}
+		return nil, err
+	}
+
+	// Signing up through an invitation is still a registration for domain auto-join
+	if err := s.autoJoin.Evaluate(user); err != nil {
+		log.Printf("Failed to apply auto-join policy for user %s: %v", user.ID, err)
+	}
+	return user, nil
+}
+
+// DeclineInvite turns down an invitation. Holding the token is enough, so recipients can
+// decline without an account.
+func (s *inviteService) DeclineInvite(token string) error {
+	invite, err := s.openInvite(token)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.DeclineInvite(invite); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInviteNotPending
+		}
+		return err
+	}
+	return nil
+}
+
+// checkJoin makes sure the invitation's tenant can take the user. A zero userID is a user
+// who does not exist yet.
+func (s *inviteService) checkJoin(invite *models.TenantInvite, userID uuid.UUID) error {
+	if userID != uuid.Nil {
+		member, err := s.repo.HasMembership(userID, invite.TenantID)
+		if err != nil {
+			return err
+		}
+		if member {
+			return ErrAlreadyMember
+		}
+	}
+	tenant, err := s.tenantRepo.GetTenantByID(invite.TenantID)
+	if err != nil {
+		return err
+	}
+	return checkSeats(tenant, s.repo.CountMembers)
+}
+
+// saveInvite stores a new or renewed invitation, checking the tenant's user limit in the same
+// transaction when withinLimit is set
+func (s *inviteService) saveInvite(tenant *models.Tenant, invite *models.TenantInvite, isNew, withinLimit bool) error {
+	if withinLimit && tenant.MaxUsers != nil {
+		saved, err := s.repo.SaveInviteWithinLimit(invite, *tenant.MaxUsers)
+		if err != nil {
+			return err
+		}
+		if !saved {
+			return ErrTenantFull
+		}
+		return nil
+	}
+	if isNew {
+		return s.repo.CreateInvite(invite)
+	}
+	return s.repo.SaveInvite(invite)
+}
+
+// send emails the invitation with a link carrying its token and records the delivery
+func (s *inviteService) send(tenant *models.Tenant, invite *models.TenantInvite) error {
+	link, err := url.Parse(config.Invite.AcceptURL)
+	if err != nil {
+		return err
+	}
+	query := link.Query()
+	query.Set("token", s.signToken(invite))
+	link.RawQuery = query.Encode()
+
+	message := &mail.Message{
+		To:      invite.Email,
+		Subject: fmt.Sprintf("You have been invited to join %s", tenant.Name),
+		Body: fmt.Sprintf("You have been invited to join %s as %s.\n\n"+
+			"Accept or decline the invitation here:\n%s\n\n"+
+			"The invitation expires on %s. If you were not expecting it, you can ignore this email.\n",
+			tenant.Name, invite.Role, link.String(), invite.ExpiresAt.UTC().Format(time.RFC1123)),
+	}
+	if err := s.mailer.Send(message); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	invite.SendCount++
+	invite.LastSentAt = &now
+	return s.repo.SaveInvite(invite)
+}
+
+// signToken returns the invitation's token: its ID and nonce, signed
+func (s *inviteService) signToken(invite *models.TenantInvite) string {
+	payload := invite.ID.String() + "." + invite.TokenNonce
+	return payload + "." + base64.RawURLEncoding.EncodeToString(s.mac(payload))
+}
+
+// openInvite returns the pending, unexpired invitation of a token
+func (s *inviteService) openInvite(token string) (*models.TenantInvite, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidInviteToken
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || !hmac.Equal(signature, s.mac(parts[0]+"."+parts[1])) {
+		return nil, ErrInvalidInviteToken
+	}
+	id, err := uuid.Parse(parts[0])
+	if err != nil {
+		return nil, ErrInvalidInviteToken
+	}
+
+	invite, err := s.repo.GetInviteByID(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrInvalidInviteToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	// A resent invitation has a new nonce
+	if subtle.ConstantTimeCompare([]byte(parts[1]), []byte(invite.TokenNonce)) != 1 {
+		return nil, ErrInvalidInviteToken
+	}
+	if invite.Status != models.InvitePending {
+		return nil, ErrInviteNotPending
+	}
+	if invite.Expired(time.Now()) {
+		return nil, ErrInviteExpired
+	}
+	return invite, nil
+}
+
+func (s *inviteService) mac(payload string) []byte {
+	mac := hmac.New(sha256.New, s.signingKey)
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,311 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"identity-service/config"
+	"identity-service/internal/auth"
+	"identity-service/internal/models"
+	"identity-service/internal/repositories"
+	"log"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Defaults for connections that do not set them, suitable for Active Directory
+const (
+	ldapDefaultUserFilter     = "(&(objectClass=person)(|(mail={email})(userPrincipalName={email})))"
+	ldapDefaultEmailAttribute = "mail"
+	ldapDefaultNameAttribute  = "displayName"
+	ldapDefaultGroupFilter    = "(member={dn})"
+)
+
+var (
+	ErrLDAPNotConfigured      = errors.New("LDAP is not configured for this domain")
+	ErrInvalidLDAPConnection  = errors.New("invalid LDAP connection")
+	ErrLDAPUnavailable        = errors.New("LDAP server is unavailable")
+	ErrInvalidLDAPCredentials = errors.New("invalid credentials")
+)
+
+// LDAPService checks the passwords of users in a tenant's domain against the tenant's LDAP
+// or Active Directory server, and keeps their profile and tenant roles in sync with it
+type LDAPService interface {
+	GetConnection(tenantID uuid.UUID) (*models.LDAPConnection, error)
+	SaveConnection(tenantID uuid.UUID, update *models.LDAPConnectionUpdate) (*models.LDAPConnection, error)
+	DeleteConnection(tenantID uuid.UUID) error
+	TestConnection(tenantID uuid.UUID) error
+	Authenticate(email, password string) (*models.User, uuid.UUID, error)
+}
+
+type ldapService struct {
+	repo          repositories.LDAPConnectionRepository
+	tenantRepo    repositories.TenantRepository
+	userService   UserService
+	encryptionKey []byte
+	dial          auth.LDAPDialer
+}
+
+// NewLDAPService creates an LDAP service that encrypts bind passwords with encryptionKey and
+// connects to servers with dial
+func NewLDAPService(
+	repo repositories.LDAPConnectionRepository,
+	tenantRepo repositories.TenantRepository,
+	userService UserService,
+	encryptionKey []byte,
+	dial auth.LDAPDialer,
+) LDAPService {
+	return &ldapService{
+		repo:          repo,
+		tenantRepo:    tenantRepo,
+		userService:   userService,
+		encryptionKey: encryptionKey,
+		dial:          dial,
+	}
+}
+
+func (s *ldapService) GetConnection(tenantID uuid.UUID) (*models.LDAPConnection, error) {
+	return s.repo.GetConnection(tenantID)
+}
+
+// SaveConnection creates or updates the tenant's LDAP server
+func (s *ldapService) SaveConnection(tenantID uuid.UUID, update *models.LDAPConnectionUpdate) (*models.LDAPConnection, error) {
+	tenant, err := s.tenantRepo.GetTenantByID(tenantID)
+	if err != nil {
+		return nil, err
+	}
+	if !tenant.HasFeatures()[ssoFeature] {
+		return nil, ErrSSONotEnabled
+	}
+
+	connection, err := s.repo.GetConnection(tenantID)
+	if err != nil {
+		connection = &models.LDAPConnection{
+			ID:             uuid.New(),
+			TenantID:       tenantID,
+			UserFilter:     ldapDefaultUserFilter,
+			EmailAttribute: ldapDefaultEmailAttribute,
+			NameAttribute:  ldapDefaultNameAttribute,
+			GroupFilter:    ldapDefaultGroupFilter,
+			GroupRoles:     models.LDAPGroupRoles{},
+			Enabled:        true,
+		}
+	}
+
+	if update.URL != nil {
+		connection.URL = *update.URL
+	}
+	if update.StartTLS != nil {
+		connection.StartTLS = *update.StartTLS
+	}
+	if update.InsecureSkipVerify != nil {
+		connection.InsecureSkipVerify = *update.InsecureSkipVerify
+	}
+	if update.RootCA != nil {
+		connection.RootCA = *update.RootCA
+	}
+	if update.BindDN != nil {
+		connection.BindDN = *update.BindDN
+	}
+	if update.UserSearchBase != nil {
+		connection.UserSearchBase = *update.UserSearchBase
+	}
+	if update.UserFilter != nil {
+		connection.UserFilter = *update.UserFilter
+	}
+	if update.EmailAttribute != nil {
+		connection.EmailAttribute = *update.EmailAttribute
+	}
+	if update.NameAttribute != nil {
+		connection.NameAttribute = *update.NameAttribute
+	}
+	if update.GroupSearchBase != nil {
+		connection.GroupSearchBase = *update.GroupSearchBase
+	}
+	if update.GroupFilter != nil {
+		connection.GroupFilter = *update.GroupFilter
+	}
+	if update.GroupRoles != nil {
+		connection.GroupRoles = *update.GroupRoles
+	}
+	if update.Enabled != nil {
+		connection.Enabled = *update.Enabled
+	}
+
+	if update.BindPassword != nil {
+		sealed, err := auth.SealLDAPSecret(s.encryptionKey, tenantID, *update.BindPassword)
+		if err != nil {
+			return nil, err
+		}
+		connection.BindPasswordEncrypted = sealed
+	}
+
+	if err := validateLDAPConnection(connection); err != nil {
+		return nil, err
+	}
+	if err := s.repo.SaveConnection(connection); err != nil {
+		return nil, err
+	}
+	return connection, nil
+}
+
+func (s *ldapService) DeleteConnection(tenantID uuid.UUID) error {
+	return s.repo.DeleteConnection(tenantID)
+}
+
+// TestConnection connects to the tenant's server and binds with the service account
+func (s *ldapService) TestConnection(tenantID uuid.UUID) error {
+	connection, err := s.repo.GetConnection(tenantID)
+	if err != nil {
+		return err
+	}
+	bindPassword, err := auth.OpenLDAPSecret(s.encryptionKey, tenantID, connection.BindPasswordEncrypted)
+	if err != nil {
+		return err
+	}
+
+	conn, err := s.dial(connection, config.LDAP.Timeout)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrLDAPUnavailable, err)
+	}
+	defer conn.Close()
+
+	return auth.TestLDAPConnection(conn, connection, bindPassword)
+}
+
+// Authenticate checks a password against the LDAP server of the tenant that verified the
+// email's domain. It returns ErrLDAPNotConfigured if there is none, in which case the
+// password is checked against stored credentials instead. The user is created on first
+// login, and their name, email and tenant roles are updated from the directory.
+func (s *ldapService) Authenticate(email, password string) (*models.User, uuid.UUID, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return nil, uuid.Nil, ErrLDAPNotConfigured
+	}
+	connection, err := s.repo.GetConnectionByDomain(email[at+1:])
+	if err != nil {
+		return nil, uuid.Nil, ErrLDAPNotConfigured
+	}
+	tenant, err := s.tenantRepo.GetTenantByID(connection.TenantID)
+	if err != nil || !tenant.HasFeatures()[ssoFeature] {
+		return nil, uuid.Nil, ErrLDAPNotConfigured
+	}
+
+	bindPassword, err := auth.OpenLDAPSecret(s.encryptionKey, tenant.ID, connection.BindPasswordEncrypted)
+	if err != nil {
+		log.Printf("LDAP bind password of tenant %s cannot be decrypted: %v", tenant.ID, err)
+		return nil, uuid.Nil, ErrLDAPUnavailable
+	}
+
+	conn, err := s.dial(connection, config.LDAP.Timeout)
+	if err != nil {
+		log.Printf("Failed to connect to LDAP server of tenant %s: %v", tenant.ID, err)
+		return nil, uuid.Nil, ErrLDAPUnavailable
+	}
+	defer conn.Close()
+
+	entry, err := auth.AuthenticateLDAP(conn, connection, bindPassword, email, password)
+	switch {
+	case errors.Is(err, auth.ErrLDAPInvalidCredentials), errors.Is(err, auth.ErrLDAPUserNotFound):
+		return nil, uuid.Nil, ErrInvalidLDAPCredentials
+	case err != nil:
+		log.Printf("LDAP login failed for tenant %s: %v", tenant.ID, err)
+		return nil, uuid.Nil, ErrLDAPUnavailable
+	}
+
+	// Users are matched by email, so the directory may only hold addresses in the domain
+	// the tenant has proven it owns
+	directoryEmail := strings.ToLower(strings.TrimSpace(entry.Email))
+	if directoryEmail == "" {
+		directoryEmail = email
+	}
+	if !emailInVerifiedDomain(tenant, directoryEmail) {
+		log.Printf("LDAP entry %s of tenant %s has an email outside the verified domain", entry.DN, tenant.ID)
+		return nil, uuid.Nil, ErrInvalidLDAPCredentials
+	}
+	name := strings.TrimSpace(entry.Name)
+	if name == "" {
+		name = directoryEmail
+	}
+
+	user, err := s.userService.CreateOrUpdateUser(&models.OAuthUser{
+		ID:            entry.DN,
+		Email:         directoryEmail,
+		VerifiedEmail: true,
+		Name:          name,
+		Provider:      models.LDAPProvider,
+	})
+	if err != nil {
+		return nil, uuid.Nil, err
+	}
+	if user.Name != name {
+		if err := s.userService.UpdateUser(user.ID, &models.UserUpdate{Name: &name}); err != nil {
+			return nil, uuid.Nil, err
+		}
+		user.Name = name
+	}
+
+	if err := s.syncRoles(connection, user, entry.Groups); err != nil {
+		return nil, uuid.Nil, err
+	}
+	return user, tenant.ID, nil
+}
+
+// syncRoles adds the user to the tenant with the roles their groups map to. Once a group
+// mapping is configured, the directory is the source of truth for the roles of existing
+// members too.
+func (s *ldapService) syncRoles(connection *models.LDAPConnection, user *models.User, groups []string) error {
+	roles := auth.LDAPRoles(connection.GroupRoles, groups)
+	if len(roles) == 0 {
+		roles = []string{ssoMemberRole}
+	}
+
+	access, err := s.tenantRepo.GetUserTenantAccess(user.ID, connection.TenantID)
+	if err != nil {
+		return s.userService.AddUserToTenant(user.ID, connection.TenantID, roles)
+	}
+	if len(connection.GroupRoles) == 0 || slices.Equal(sortedRoles(access.Roles), roles) {
+		return nil
+	}
+	return s.userService.UpdateUserRoles(user.ID, connection.TenantID, roles)
+}
+
+func sortedRoles(roles []string) []string {
+	sorted := slices.Clone(roles)
+	slices.Sort(sorted)
+	return sorted
+}
+
+func validateLDAPConnection(connection *models.LDAPConnection) error {
+	serverURL, err := url.Parse(connection.URL)
+	if err != nil || serverURL.Host == "" {
+		return fmt.Errorf("%w: url must be an ldap:// or ldaps:// URL", ErrInvalidLDAPConnection)
+	}
+	switch serverURL.Scheme {
+	case "ldaps":
+		if connection.StartTLS {
+			return fmt.Errorf("%w: startTls cannot be used with ldaps://", ErrInvalidLDAPConnection)
+		}
+	case "ldap":
+	default:
+		return fmt.Errorf("%w: url must be an ldap:// or ldaps:// URL", ErrInvalidLDAPConnection)
+	}
+
+	switch {
+	case connection.BindDN == "" || len(connection.BindPasswordEncrypted) == 0:
+		return fmt.Errorf("%w: bindDn and bindPassword are required", ErrInvalidLDAPConnection)
+	case connection.UserSearchBase == "":
+		return fmt.Errorf("%w: userSearchBase is required", ErrInvalidLDAPConnection)
+	case !strings.Contains(connection.UserFilter, "{email}") && !strings.Contains(connection.UserFilter, "{username}"):
+		return fmt.Errorf("%w: userFilter must contain {email} or {username}", ErrInvalidLDAPConnection)
+	case connection.EmailAttribute == "" || connection.NameAttribute == "":
+		return fmt.Errorf("%w: emailAttribute and nameAttribute are required", ErrInvalidLDAPConnection)
+	case connection.GroupSearchBase != "" && !strings.Contains(connection.GroupFilter, "{dn}") &&
+		!strings.Contains(connection.GroupFilter, "{username}"):
+		return fmt.Errorf("%w: groupFilter must contain {dn} or {username}", ErrInvalidLDAPConnection)
+	}
+	return nil
+}
@@ -0,0 +1,429 @@
+package services
+
+import (
+	"errors"
+	"identity-service/internal/auth"
+	"identity-service/internal/models"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	ldapTestBindDN       = "cn=service,dc=example,dc=com"
+	ldapTestBindPassword = "service-secret"
+	ldapTestUsers        = "ou=users,dc=example,dc=com"
+	ldapTestGroups       = "ou=groups,dc=example,dc=com"
+	ldapTestJaneDN       = "uid=jane,ou=users,dc=example,dc=com"
+)
+
+var ldapTestEncryptionKey = []byte("0123456789abcdef0123456789abcdef")
+
+// ldapDirectoryEntry is an entry of the in-process directory, with the password it binds with
+type ldapDirectoryEntry struct {
+	dn         string
+	password   string
+	attributes map[string][]string
+}
+
+// ldapDirectory is an in-process LDAP server. Connections bind against its entries and
+// search them with real filters, compiled as a server would receive them.
+type ldapDirectory struct {
+	entries []*ldapDirectoryEntry
+	// filters records every search filter received
+	filters []string
+	dials   int
+}
+
+func newLDAPDirectory() *ldapDirectory {
+	return &ldapDirectory{entries: []*ldapDirectoryEntry{
+		{dn: "dc=example,dc=com", attributes: map[string][]string{"objectClass": {"domain"}}},
+		{dn: ldapTestUsers, attributes: map[string][]string{"objectClass": {"organizationalUnit"}}},
+		{dn: ldapTestGroups, attributes: map[string][]string{"objectClass": {"organizationalUnit"}}},
+		{dn: ldapTestBindDN, password: ldapTestBindPassword, attributes: map[string][]string{"objectClass": {"person"}}},
+		{dn: ldapTestJaneDN, password: "jane-secret", attributes: map[string][]string{
+			"objectClass": {"person"},
+			"uid":         {"jane"},
+			"mail":        {"jane@example.com"},
+			"displayName": {"Jane Doe"},
+			"memberOf":    {"cn=engineering,ou=groups,dc=example,dc=com"},
+		}},
+		{dn: "uid=john,ou=users,dc=example,dc=com", password: "john-secret", attributes: map[string][]string{
+			"objectClass": {"person"},
+			"uid":         {"john"},
+			"mail":        {"john@example.com"},
+			"displayName": {"John Roe"},
+		}},
+		{dn: "cn=admins,ou=groups,dc=example,dc=com", attributes: map[string][]string{
+			"objectClass": {"groupOfNames"},
+			"member":      {ldapTestJaneDN},
+		}},
+		{dn: "cn=engineering,ou=groups,dc=example,dc=com", attributes: map[string][]string{
+			"objectClass": {"groupOfNames"},
+			"member":      {ldapTestJaneDN, "uid=john,ou=users,dc=example,dc=com"},
+		}},
+	}}
+}
+
+// dial is the auth.LDAPDialer the service under test connects with
+func (d *ldapDirectory) dial(connection *models.LDAPConnection, timeout time.Duration) (auth.LDAPConn, error) {
+	d.dials++
+	return &ldapDirectoryConn{directory: d}, nil
+}
+
+func (d *ldapDirectory) entry(dn string) *ldapDirectoryEntry {
+	for _, entry := range d.entries {
+		if strings.EqualFold(entry.dn, dn) {
+			return entry
+		}
+	}
+	return nil
+}
+
+// removeMember takes a DN out of a group
+func (d *ldapDirectory) removeMember(group, dn string) {
+	entry := d.entry(group)
+	entry.attributes["member"] = slices.DeleteFunc(entry.attributes["member"], func(member string) bool {
+		return strings.EqualFold(member, dn)
+	})
+}
+
+type ldapDirectoryConn struct {
+	directory *ldapDirectory
+	bound     string
+}
+
+func (c *ldapDirectoryConn) Bind(username, password string) error {
+	entry := c.directory.entry(username)
+	if entry == nil || password == "" || entry.password != password {
+		c.bound = ""
+		return ldap.NewError(ldap.LDAPResultInvalidCredentials, errors.New("invalid credentials"))
+	}
+	c.bound = entry.dn
+	return nil
+}
+
+func (c *ldapDirectoryConn) Search(request *ldap.SearchRequest) (*ldap.SearchResult, error) {
+	c.directory.filters = append(c.directory.filters, request.Filter)
+	// Only the service account may search, as on a directory without anonymous access
+	if c.bound != ldapTestBindDN {
+		return nil, ldap.NewError(ldap.LDAPResultInsufficientAccessRights, errors.New("search not allowed"))
+	}
+	if c.directory.entry(request.BaseDN) == nil {
+		return nil, ldap.NewError(ldap.LDAPResultNoSuchObject, errors.New("no such object"))
+	}
+	filter, err := ldap.CompileFilter(request.Filter)
+	if err != nil {
+		return nil, ldap.NewError(ldap.LDAPResultFilterError, err)
+	}
+
+	result := &ldap.SearchResult{}
+	for _, entry := range c.directory.entries {
+		inScope := strings.EqualFold(entry.dn, request.BaseDN)
+		if request.Scope == ldap.ScopeWholeSubtree {
+			inScope = inScope || strings.HasSuffix(strings.ToLower(entry.dn), ","+strings.ToLower(request.BaseDN))
+		}
+		if !inScope || !ldapFilterMatches(filter, entry.attributes) {
+			continue
+		}
+		if request.SizeLimit > 0 && len(result.Entries) == request.SizeLimit {
+			return result, ldap.NewError(ldap.LDAPResultSizeLimitExceeded, errors.New("size limit exceeded"))
+		}
+		result.Entries = append(result.Entries, ldap.NewEntry(entry.dn, entry.attributes))
+	}
+	return result, nil
+}
+
+func (c *ldapDirectoryConn) Close() error {
+	return nil
+}
+
+// ldapFilterMatches evaluates a compiled filter against an entry's attributes
+func ldapFilterMatches(filter *ber.Packet, attributes map[string][]string) bool {
+	values := func(name interface{}) []string {
+		for attribute, values := range attributes {
+			if strings.EqualFold(attribute, name.(string)) {
+				return values
+			}
+		}
+		return nil
+	}
+
+	switch filter.Tag {
+	case ldap.FilterAnd:
+		for _, child := range filter.Children {
+			if !ldapFilterMatches(child, attributes) {
+				return false
+			}
+		}
+		return true
+	case ldap.FilterOr:
+		for _, child := range filter.Children {
+			if ldapFilterMatches(child, attributes) {
+				return true
+			}
+		}
+		return false
+	case ldap.FilterNot:
+		return !ldapFilterMatches(filter.Children[0], attributes)
+	case ldap.FilterPresent:
+		return len(values(filter.Value)) > 0
+	case ldap.FilterEqualityMatch:
+		return slices.ContainsFunc(values(filter.Children[0].Value), func(value string) bool {
+			return strings.EqualFold(value, filter.Children[1].Value.(string))
+		})
+	case ldap.FilterSubstrings:
+		return slices.ContainsFunc(values(filter.Children[0].Value), func(value string) bool {
+			value = strings.ToLower(value)
+			for _, part := range filter.Children[1].Children {
+				substring := strings.ToLower(part.Value.(string))
+				switch part.Tag {
+				case ldap.FilterSubstringsInitial:
+					if !strings.HasPrefix(value, substring) {
+						return false
+					}
+					value = value[len(substring):]
+				case ldap.FilterSubstringsAny:
+					i := strings.Index(value, substring)
+					if i < 0 {
+						return false
+					}
+					value = value[i+len(substring):]
+				case ldap.FilterSubstringsFinal:
+					if !strings.HasSuffix(value, substring) {
+						return false
+					}
+				}
+			}
+			return true
+		})
+	}
+	return false
+}
+
+type fakeLDAPConnectionRepository struct {
+	connections map[uuid.UUID]*models.LDAPConnection
+	tenants     *fakeTenantRepository
+}
+
+func (r *fakeLDAPConnectionRepository) GetConnection(tenantID uuid.UUID) (*models.LDAPConnection, error) {
+	connection, ok := r.connections[tenantID]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return connection, nil
+}
+
+func (r *fakeLDAPConnectionRepository) GetConnectionByDomain(domain string) (*models.LDAPConnection, error) {
+	for _, connection := range r.connections {
+		tenant := r.tenants.tenants[connection.TenantID]
+		if connection.Enabled && tenant.DomainVerified && strings.EqualFold(tenant.Domain, domain) {
+			return connection, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *fakeLDAPConnectionRepository) SaveConnection(connection *models.LDAPConnection) error {
+	r.connections[connection.TenantID] = connection
+	return nil
+}
+
+func (r *fakeLDAPConnectionRepository) DeleteConnection(tenantID uuid.UUID) error {
+	delete(r.connections, tenantID)
+	return nil
+}
+
+// ldapFixture is a tenant of example.com connected to an in-process directory
+type ldapFixture struct {
+	service    LDAPService
+	directory  *ldapDirectory
+	tenants    *fakeTenantRepository
+	users      *fakeUserService
+	tenant     *models.Tenant
+	connection *models.LDAPConnection
+}
+
+func newLDAPFixture(t *testing.T, update *models.LDAPConnectionUpdate) *ldapFixture {
+	t.Helper()
+	f := &ldapFixture{directory: newLDAPDirectory(), tenants: newFakeTenantRepository()}
+	f.users = newFakeUserService(f.tenants)
+	f.tenant = f.tenants.addTenant(t, "example.com")
+
+	repo := &fakeLDAPConnectionRepository{connections: make(map[uuid.UUID]*models.LDAPConnection), tenants: f.tenants}
+	f.service = NewLDAPService(repo, f.tenants, f.users, ldapTestEncryptionKey, f.directory.dial)
+
+	url, bindDN, bindPassword, userSearchBase := "ldap://ldap.example.com", ldapTestBindDN, ldapTestBindPassword, ldapTestUsers
+	update.URL, update.BindDN, update.BindPassword, update.UserSearchBase = &url, &bindDN, &bindPassword, &userSearchBase
+	var err error
+	f.connection, err = f.service.SaveConnection(f.tenant.ID, update)
+	if err != nil {
+		t.Fatalf("SaveConnection: %v", err)
+	}
+	return f
+}
+
+func TestLDAPAuthenticateBindsAsUser(t *testing.T) {
+	f := newLDAPFixture(t, &models.LDAPConnectionUpdate{})
+
+	user, tenantID, err := f.service.Authenticate(" Jane@Example.com ", "jane-secret")
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	if tenantID != f.tenant.ID {
+		t.Errorf("tenant = %s, want %s", tenantID, f.tenant.ID)
+	}
+	if user.Email != "jane@example.com" || user.Name != "Jane Doe" {
+		t.Errorf("user = %s %q, want jane@example.com \"Jane Doe\"", user.Email, user.Name)
+	}
+	if roles := f.users.roles(user.ID, f.tenant.ID); !slices.Equal(roles, []string{ssoMemberRole}) {
+		t.Errorf("roles in tenant = %v, want [%s]", roles, ssoMemberRole)
+	}
+}
+
+func TestLDAPAuthenticateRejectsBadCredentials(t *testing.T) {
+	f := newLDAPFixture(t, &models.LDAPConnectionUpdate{})
+
+	tests := []struct {
+		name     string
+		email    string
+		password string
+	}{
+		{name: "wrong password", email: "jane@example.com", password: "john-secret"},
+		{name: "empty password", email: "jane@example.com", password: ""},
+		{name: "unknown user", email: "nobody@example.com", password: "jane-secret"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := f.service.Authenticate(tt.email, tt.password); !errors.Is(err, ErrInvalidLDAPCredentials) {
+				t.Fatalf("Authenticate error = %v, want %v", err, ErrInvalidLDAPCredentials)
+			}
+		})
+	}
+	if len(f.users.users) != 0 {
+		t.Errorf("created %d users, want none", len(f.users.users))
+	}
+}
+
+func TestLDAPAuthenticateOnlyForConfiguredDomains(t *testing.T) {
+	f := newLDAPFixture(t, &models.LDAPConnectionUpdate{})
+
+	if _, _, err := f.service.Authenticate("jane@other.example", "jane-secret"); !errors.Is(err, ErrLDAPNotConfigured) {
+		t.Fatalf("Authenticate error = %v, want %v", err, ErrLDAPNotConfigured)
+	}
+	if f.directory.dials != 0 {
+		t.Errorf("dialled the directory %d times, want 0", f.directory.dials)
+	}
+}
+
+func TestLDAPAuthenticateEscapesFilterValues(t *testing.T) {
+	// A filter on the local part makes the login's characters reach the filter unquoted by
+	// the domain lookup
+	userFilter := "(&(objectClass=person)(uid={username}))"
+	f := newLDAPFixture(t, &models.LDAPConnectionUpdate{UserFilter: &userFilter})
+
+	tests := []struct {
+		email string
+		want  string
+	}{
+		{email: "j*@example.com", want: `(uid=j\2a)`},
+		{email: "*)(objectclass=*@example.com", want: `(uid=\2a\29\28objectclass=\2a)`},
+		{email: `jane\@example.com`, want: `(uid=jane\5c)`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.email, func(t *testing.T) {
+			// Jane's password would be accepted if the value matched her entry as a pattern
+			if _, _, err := f.service.Authenticate(tt.email, "jane-secret"); !errors.Is(err, ErrInvalidLDAPCredentials) {
+				t.Fatalf("Authenticate error = %v, want %v", err, ErrInvalidLDAPCredentials)
+			}
+			filter := f.directory.filters[len(f.directory.filters)-1]
+			if !strings.Contains(filter, tt.want) {
+				t.Errorf("search filter = %s, want it to contain %s", filter, tt.want)
+			}
+		})
+	}
+}
+
+func TestLDAPAuthenticateRejectsAmbiguousFilter(t *testing.T) {
+	// A filter matching more than one entry must not sign anyone in
+	userFilter := "(&(objectClass=person)(|(mail={email})(displayName=*)))"
+	f := newLDAPFixture(t, &models.LDAPConnectionUpdate{UserFilter: &userFilter})
+
+	if _, _, err := f.service.Authenticate("jane@example.com", "jane-secret"); !errors.Is(err, ErrInvalidLDAPCredentials) {
+		t.Fatalf("Authenticate error = %v, want %v", err, ErrInvalidLDAPCredentials)
+	}
+}
+
+func TestLDAPAuthenticateSyncsGroupRoles(t *testing.T) {
+	groupSearchBase, groupFilter := ldapTestGroups, "(&(objectClass=groupOfNames)(member={dn}))"
+	groupRoles := models.LDAPGroupRoles{
+		"admins": {models.TenantRoleAdmin},
+		"cn=engineering,ou=groups,dc=example,dc=com": {models.TenantRoleMember},
+	}
+	f := newLDAPFixture(t, &models.LDAPConnectionUpdate{
+		GroupSearchBase: &groupSearchBase,
+		GroupFilter:     &groupFilter,
+		GroupRoles:      &groupRoles,
+	})
+
+	// Groups are matched by CN and by DN, and the user's roles follow their groups
+	user, _, err := f.service.Authenticate("jane@example.com", "jane-secret")
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	want := []string{models.TenantRoleAdmin, models.TenantRoleMember}
+	if roles := f.users.roles(user.ID, f.tenant.ID); !slices.Equal(roles, want) {
+		t.Fatalf("roles after first login = %v, want %v", roles, want)
+	}
+
+	// The group search runs as the service account, not as the user who just bound
+	groupSearch := f.directory.filters[len(f.directory.filters)-1]
+	if groupSearch != "(&(objectClass=groupOfNames)(member=uid=jane,ou=users,dc=example,dc=com))" {
+		t.Errorf("group search filter = %s", groupSearch)
+	}
+
+	f.directory.removeMember("cn=admins,ou=groups,dc=example,dc=com", ldapTestJaneDN)
+	if _, _, err := f.service.Authenticate("jane@example.com", "jane-secret"); err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	if roles := f.users.roles(user.ID, f.tenant.ID); !slices.Equal(roles, []string{models.TenantRoleMember}) {
+		t.Fatalf("roles after leaving admins = %v, want [%s]", roles, models.TenantRoleMember)
+	}
+}
+
+func TestLDAPAuthenticateKeepsRolesWithoutGroupMapping(t *testing.T) {
+	f := newLDAPFixture(t, &models.LDAPConnectionUpdate{})
+	user, _, err := f.service.Authenticate("jane@example.com", "jane-secret")
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	f.tenants.access[f.tenant.ID][user.ID].Roles = []string{models.TenantRoleAdmin}
+
+	if _, _, err := f.service.Authenticate("jane@example.com", "jane-secret"); err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	if roles := f.users.roles(user.ID, f.tenant.ID); !slices.Equal(roles, []string{models.TenantRoleAdmin}) {
+		t.Fatalf("roles = %v, want them left at [%s]", roles, models.TenantRoleAdmin)
+	}
+}
+
+func TestLDAPTestConnection(t *testing.T) {
+	f := newLDAPFixture(t, &models.LDAPConnectionUpdate{})
+	if err := f.service.TestConnection(f.tenant.ID); err != nil {
+		t.Fatalf("TestConnection: %v", err)
+	}
+
+	wrongPassword := "wrong"
+	if _, err := f.service.SaveConnection(f.tenant.ID, &models.LDAPConnectionUpdate{BindPassword: &wrongPassword}); err != nil {
+		t.Fatalf("SaveConnection: %v", err)
+	}
+	if err := f.service.TestConnection(f.tenant.ID); err == nil || !strings.Contains(err.Error(), "bind failed") {
+		t.Fatalf("TestConnection error = %v, want a failed bind", err)
+	}
+}
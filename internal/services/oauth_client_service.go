@@ -0,0 +1,201 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	jwtmanager "identity-service/internal/auth/jwt"
+	"identity-service/internal/models"
+	"identity-service/internal/repositories"
+	"identity-service/pkg/utils"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	clientIDLength     = 24
+	clientSecretLength = 48
+)
+
+var ErrInvalidClientCredentials = errors.New("invalid client credentials")
+
+// OAuthClientService manages the applications registered against the authorization server
+type OAuthClientService interface {
+	CreateClient(tenantID uuid.UUID, req *models.OAuthClientCreate) (*models.OAuthClient, string, error)
+	GetClient(tenantID, id uuid.UUID) (*models.OAuthClient, error)
+	GetClientByClientID(clientID string) (*models.OAuthClient, error)
+	ListClients(tenantID uuid.UUID) ([]*models.OAuthClient, error)
+	UpdateClient(tenantID, id uuid.UUID, update *models.OAuthClientUpdate) (*models.OAuthClient, error)
+	DeleteClient(tenantID, id uuid.UUID) error
+	AuthenticateClient(clientID, clientSecret string) (*models.OAuthClient, error)
+}
+
+type oauthClientService struct {
+	repo       repositories.OAuthClientRepository
+	keyManager *jwtmanager.KeyManager
+}
+
+func NewOAuthClientService(repo repositories.OAuthClientRepository, keyManager *jwtmanager.KeyManager) OAuthClientService {
+	return &oauthClientService{
+		repo:       repo,
+		keyManager: keyManager,
+	}
+}
+
+// CreateClient registers a client. The plain client secret is only returned here; confidential
+// clients must store it, as only its hash is kept.
+func (s *oauthClientService) CreateClient(tenantID uuid.UUID, req *models.OAuthClientCreate) (*models.OAuthClient, string, error) {
+	grantTypes := req.GrantTypes
+	if len(grantTypes) == 0 {
+		grantTypes = []string{models.GrantTypeAuthorizationCode, models.GrantTypeRefreshToken}
+	}
+
+	client := &models.OAuthClient{
+		ID:                     uuid.New(),
+		TenantID:               tenantID,
+		ClientID:               utils.GenerateRandomString(clientIDLength),
+		Name:                   req.Name,
+		Public:                 req.Public,
+		RedirectURIs:           req.RedirectURIs,
+		AllowedScopes:          req.AllowedScopes,
+		GrantTypes:             grantTypes,
+		SigningAlgorithm:       req.SigningAlgorithm,
+		TokenExchangeAudiences: req.TokenExchangeAudiences,
+		CreatedAt:              time.Now(),
+		UpdatedAt:              time.Now(),
+	}
+	if err := s.validateClient(client); err != nil {
+		return nil, "", err
+	}
+
+	var secret string
+	if !client.Public {
+		secret = utils.GenerateRandomString(clientSecretLength)
+		hash, err := utils.HashPassword(secret)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to hash client secret: %v", err)
+		}
+		client.ClientSecretHash = hash
+	}
+
+	if err := s.repo.CreateClient(client); err != nil {
+		return nil, "", err
+	}
+	return client, secret, nil
+}
+
+func (s *oauthClientService) GetClient(tenantID, id uuid.UUID) (*models.OAuthClient, error) {
+	return s.repo.GetClient(tenantID, id)
+}
+
+func (s *oauthClientService) GetClientByClientID(clientID string) (*models.OAuthClient, error) {
+	return s.repo.GetClientByClientID(clientID)
+}
+
+func (s *oauthClientService) ListClients(tenantID uuid.UUID) ([]*models.OAuthClient, error) {
+	return s.repo.ListClients(tenantID)
+}
+
+func (s *oauthClientService) UpdateClient(tenantID, id uuid.UUID, update *models.OAuthClientUpdate) (*models.OAuthClient, error) {
+	client, err := s.repo.GetClient(tenantID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if update.Name != nil {
+		client.Name = *update.Name
+	}
+	if update.RedirectURIs != nil {
+		client.RedirectURIs = *update.RedirectURIs
+	}
+	if update.AllowedScopes != nil {
+		client.AllowedScopes = *update.AllowedScopes
+	}
+	if update.GrantTypes != nil {
+		client.GrantTypes = *update.GrantTypes
+	}
+	if update.SigningAlgorithm != nil {
+		client.SigningAlgorithm = *update.SigningAlgorithm
+	}
+	if update.TokenExchangeAudiences != nil {
+		client.TokenExchangeAudiences = *update.TokenExchangeAudiences
+	}
+	if err := s.validateClient(client); err != nil {
+		return nil, err
+	}
+
+	client.UpdatedAt = time.Now()
+	if err := s.repo.UpdateClient(client); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+func (s *oauthClientService) DeleteClient(tenantID, id uuid.UUID) error {
+	return s.repo.DeleteClient(tenantID, id)
+}
+
+// AuthenticateClient looks up a client and checks its secret. Public clients have no secret
+// and authenticate by client ID alone.
+func (s *oauthClientService) AuthenticateClient(clientID, clientSecret string) (*models.OAuthClient, error) {
+	if clientID == "" {
+		return nil, ErrInvalidClientCredentials
+	}
+
+	client, err := s.repo.GetClientByClientID(clientID)
+	if err != nil {
+		return nil, ErrInvalidClientCredentials
+	}
+
+	if client.Public {
+		if clientSecret != "" {
+			return nil, ErrInvalidClientCredentials
+		}
+		return client, nil
+	}
+
+	if clientSecret == "" || !utils.CheckPasswordHash(clientSecret, client.ClientSecretHash) {
+		return nil, ErrInvalidClientCredentials
+	}
+	return client, nil
+}
+
+// validateClient checks redirect URIs and grant types against RFC 6749 section 3.1.2, and
+// that the client's signing algorithm has keys
+func (s *oauthClientService) validateClient(client *models.OAuthClient) error {
+	if client.SigningAlgorithm != "" && !s.keyManager.SupportsAlgorithm(client.SigningAlgorithm) {
+		return fmt.Errorf("unsupported signing algorithm %q", client.SigningAlgorithm)
+	}
+
+	for _, redirectURI := range client.RedirectURIs {
+		parsed, err := url.Parse(redirectURI)
+		if err != nil || !parsed.IsAbs() || parsed.Fragment != "" {
+			return fmt.Errorf("invalid redirect URI %q: must be absolute and have no fragment", redirectURI)
+		}
+	}
+
+	for _, grantType := range client.GrantTypes {
+		switch grantType {
+		case models.GrantTypeAuthorizationCode, models.GrantTypeRefreshToken, models.GrantTypeDeviceCode:
+		case models.GrantTypeClientCredentials, models.GrantTypeTokenExchange:
+			if client.Public {
+				return fmt.Errorf("public clients cannot use the %s grant", grantType)
+			}
+		default:
+			return fmt.Errorf("unsupported grant type %q", grantType)
+		}
+	}
+
+	for _, audience := range client.TokenExchangeAudiences {
+		if strings.TrimSpace(audience) == "" {
+			return errors.New("token exchange audiences must not be empty")
+		}
+	}
+
+	if client.HasGrantType(models.GrantTypeAuthorizationCode) && len(client.RedirectURIs) == 0 {
+		return errors.New("at least one redirect URI is required for the authorization_code grant")
+	}
+	return nil
+}
@@ -0,0 +1,255 @@
+package services
+
+import (
+	"crypto/rand"
+	"errors"
+	"identity-service/config"
+	"identity-service/internal/models"
+	"identity-service/pkg/utils"
+	"math/big"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	deviceCodeLength   = 43
+	deviceCodeTTL      = 10 * time.Minute
+	devicePollInterval = 5 // seconds
+	deviceSlowDownStep = 5 // seconds added to the interval on every slow_down
+	userCodeLength     = 8
+	// userCodeAlphabet has no vowels, so codes never spell words, and no easily confused
+	// characters (RFC 8628 section 6.1)
+	userCodeAlphabet = "BCDFGHJKLMNPQRSTVWXZ"
+)
+
+var (
+	ErrInvalidUserCode    = errors.New("invalid or expired user code")
+	ErrDeviceTenantDenied = errors.New("user is not a member of this application's tenant")
+	ErrDeviceTenantClient = errors.New("devices can only be approved for the application's own tenant")
+)
+
+// AuthorizeDevice issues a device code and user code to a client on an input-constrained
+// device (RFC 8628 section 3.1)
+func (s *oauthServerService) AuthorizeDevice(req *models.DeviceAuthorizationRequest) (*models.DeviceAuthorizationResponse, error) {
+	client, err := s.clientService.AuthenticateClient(req.ClientID, req.ClientSecret)
+	if err != nil {
+		return nil, NewOAuthError(OAuthErrInvalidClient, "client authentication failed")
+	}
+	if !client.HasGrantType(models.GrantTypeDeviceCode) {
+		return nil, NewOAuthError(OAuthErrUnauthorizedClient, "client may not use the device authorization grant")
+	}
+
+	scope, err := resolveScope(req.Scope, client.AllowedScopes)
+	if err != nil {
+		return nil, err
+	}
+
+	// Expired codes are removed so their user codes can be issued again
+	now := time.Now()
+	if err := s.deviceRepo.DeleteExpiredDeviceCodes(now); err != nil {
+		return nil, NewOAuthError(OAuthErrServerError, "")
+	}
+
+	userCode, err := generateUserCode()
+	if err != nil {
+		return nil, NewOAuthError(OAuthErrServerError, "")
+	}
+	deviceCode := utils.GenerateRandomString(deviceCodeLength)
+	if err := s.deviceRepo.CreateDeviceCode(&models.DeviceCode{
+		ID:             uuid.New(),
+		DeviceCodeHash: hashAuthorizationCode(deviceCode),
+		UserCode:       userCode,
+		ClientID:       client.ClientID,
+		Scope:          scope,
+		Status:         models.DeviceCodePending,
+		Interval:       devicePollInterval,
+		ExpiresAt:      now.Add(deviceCodeTTL),
+		CreatedAt:      now,
+	}); err != nil {
+		return nil, NewOAuthError(OAuthErrServerError, "")
+	}
+
+	verificationURI := config.AuthServer.DeviceVerificationURL
+	complete, err := url.Parse(verificationURI)
+	if err != nil {
+		return nil, NewOAuthError(OAuthErrServerError, "")
+	}
+	query := complete.Query()
+	query.Set("user_code", formatUserCode(userCode))
+	complete.RawQuery = query.Encode()
+
+	return &models.DeviceAuthorizationResponse{
+		DeviceCode:              deviceCode,
+		UserCode:                formatUserCode(userCode),
+		VerificationURI:         verificationURI,
+		VerificationURIComplete: complete.String(),
+		ExpiresIn:               int(deviceCodeTTL.Seconds()),
+		Interval:                devicePollInterval,
+	}, nil
+}
+
+// LookupDeviceCode returns the pending authorization a user code belongs to, so the user can
+// check which application is asking before approving it
+func (s *oauthServerService) LookupDeviceCode(userCode string) (*models.DeviceVerification, error) {
+	code, err := s.deviceRepo.GetPendingDeviceCode(normalizeUserCode(userCode))
+	if err != nil {
+		return nil, ErrInvalidUserCode
+	}
+
+	client, err := s.clientService.GetClientByClientID(code.ClientID)
+	if err != nil {
+		return nil, ErrInvalidUserCode
+	}
+
+	return &models.DeviceVerification{
+		UserCode:   formatUserCode(code.UserCode),
+		ClientID:   client.ClientID,
+		ClientName: client.Name,
+		Scope:      code.Scope,
+	}, nil
+}
+
+// DecideDevice approves or denies a pending device authorization for a user who signed in at authTime
+func (s *oauthServerService) DecideDevice(user *models.User, decision *models.DeviceDecision, authTime time.Time) error {
+	code, err := s.deviceRepo.GetPendingDeviceCode(normalizeUserCode(decision.UserCode))
+	if err != nil {
+		return ErrInvalidUserCode
+	}
+
+	client, err := s.clientService.GetClientByClientID(code.ClientID)
+	if err != nil {
+		return ErrInvalidUserCode
+	}
+
+	status := models.DeviceCodeDenied
+	if decision.Approve {
+		status = models.DeviceCodeApproved
+		// Clients are tenant-scoped, so as in Authorize tokens are only issued for the client's
+		// tenant, to its members
+		if decision.TenantID != nil && *decision.TenantID != client.TenantID {
+			return ErrDeviceTenantClient
+		}
+		if _, err := s.tenantRepo.GetUserTenantAccess(user.ID, client.TenantID); err != nil {
+			return ErrDeviceTenantDenied
+		}
+	}
+
+	if err := s.deviceRepo.DecideDeviceCode(code.ID, status, user.ID, client.TenantID, authTime); err != nil {
+		return ErrInvalidUserCode
+	}
+	return nil
+}
+
+// exchangeDeviceCode answers a device polling the token endpoint (RFC 8628 section 3.4)
+func (s *oauthServerService) exchangeDeviceCode(ctx *gin.Context, client *models.OAuthClient, req *models.OAuthTokenRequest) (*models.OAuthTokenResponse, error) {
+	if !client.HasGrantType(models.GrantTypeDeviceCode) {
+		return nil, NewOAuthError(OAuthErrUnauthorizedClient, "")
+	}
+	if req.DeviceCode == "" {
+		return nil, NewOAuthError(OAuthErrInvalidRequest, "device_code is required")
+	}
+
+	deviceCodeHash := hashAuthorizationCode(req.DeviceCode)
+	code, err := s.deviceRepo.GetDeviceCodeByHash(deviceCodeHash)
+	if err != nil || code.ClientID != client.ClientID {
+		return nil, NewOAuthError(OAuthErrInvalidGrant, "invalid device code")
+	}
+
+	now := time.Now()
+	if !now.Before(code.ExpiresAt) {
+		return nil, NewOAuthError(OAuthErrExpiredToken, "")
+	}
+
+	// Devices polling faster than the interval must wait longer from then on
+	interval := code.Interval
+	tooSoon := code.LastPolledAt != nil && now.Sub(*code.LastPolledAt) < time.Duration(interval)*time.Second
+	if tooSoon {
+		interval += deviceSlowDownStep
+	}
+	if err := s.deviceRepo.RecordPoll(code.ID, now, interval); err != nil {
+		return nil, NewOAuthError(OAuthErrServerError, "")
+	}
+	if tooSoon {
+		return nil, NewOAuthError(OAuthErrSlowDown, "")
+	}
+
+	switch code.Status {
+	case models.DeviceCodePending:
+		return nil, NewOAuthError(OAuthErrAuthorizationPending, "")
+	case models.DeviceCodeDenied:
+		return nil, NewOAuthError(OAuthErrAccessDenied, "")
+	case models.DeviceCodeApproved:
+	default:
+		return nil, NewOAuthError(OAuthErrInvalidGrant, "device code has already been used")
+	}
+
+	// The code is spent before tokens are issued so it can never be replayed
+	code, err = s.deviceRepo.ConsumeDeviceCode(deviceCodeHash)
+	if err != nil || code.UserID == nil || code.TenantID == nil {
+		return nil, NewOAuthError(OAuthErrInvalidGrant, "device code has already been used")
+	}
+
+	user, err := s.userService.GetUser(*code.UserID)
+	if err != nil {
+		return nil, NewOAuthError(OAuthErrInvalidGrant, "user no longer exists")
+	}
+
+	session, err := s.authService.CreateClientSession(ctx, user, *code.TenantID, client.ClientID, code.Scope)
+	if err != nil {
+		return nil, NewOAuthError(OAuthErrServerError, "")
+	}
+	resp := s.sessionResponse(client, session)
+
+	if containsScope(strings.Fields(code.Scope), models.ScopeOpenID) {
+		authTime := session.CreatedAt
+		if code.AuthTime != nil {
+			authTime = *code.AuthTime
+		}
+		idToken, err := s.oidcService.IssueIDToken(user, client, *code.TenantID, code.Scope, "", authTime)
+		if err != nil {
+			return nil, NewOAuthError(OAuthErrServerError, "")
+		}
+		resp.IDToken = idToken
+	}
+
+	return resp, nil
+}
+
+// generateUserCode returns a random user code without its separator
+func generateUserCode() (string, error) {
+	alphabetSize := big.NewInt(int64(len(userCodeAlphabet)))
+	code := make([]byte, userCodeLength)
+	for i := range code {
+		n, err := rand.Int(rand.Reader, alphabetSize)
+		if err != nil {
+			return "", err
+		}
+		code[i] = userCodeAlphabet[n.Int64()]
+	}
+	return string(code), nil
+}
+
+// formatUserCode splits a user code in two halves for readability, e.g. BDFH-JKLM
+func formatUserCode(code string) string {
+	if len(code) != userCodeLength {
+		return code
+	}
+	return code[:userCodeLength/2] + "-" + code[userCodeLength/2:]
+}
+
+// normalizeUserCode accepts user codes typed in any case and with any separators
+func normalizeUserCode(input string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' {
+			r -= 'a' - 'A'
+		}
+		if r < 'A' || r > 'Z' {
+			return -1
+		}
+		return r
+	}, input)
+}
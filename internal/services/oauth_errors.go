@@ -0,0 +1,62 @@
+package services
+
+import "net/http"
+
+// Error codes defined by RFC 6749 sections 4.1.2.1 and 5.2
+const (
+	OAuthErrInvalidRequest          = "invalid_request"
+	OAuthErrInvalidClient           = "invalid_client"
+	OAuthErrInvalidGrant            = "invalid_grant"
+	OAuthErrUnauthorizedClient      = "unauthorized_client"
+	OAuthErrUnsupportedGrantType    = "unsupported_grant_type"
+	OAuthErrInvalidScope            = "invalid_scope"
+	OAuthErrAccessDenied            = "access_denied"
+	OAuthErrUnsupportedResponseType = "unsupported_response_type"
+	OAuthErrServerError             = "server_error"
+	// RFC 7009 section 2.2.1
+	OAuthErrUnsupportedTokenType = "unsupported_token_type"
+	// RFC 8628 section 3.5
+	OAuthErrAuthorizationPending = "authorization_pending"
+	OAuthErrSlowDown             = "slow_down"
+	OAuthErrExpiredToken         = "expired_token"
+	// RFC 8693 section 2.2.2
+	OAuthErrInvalidTarget = "invalid_target"
+)
+
+// OAuthError is an error that is reported to OAuth clients in the RFC 6749 format
+type OAuthError struct {
+	Code        string
+	Description string
+	StatusCode  int
+}
+
+func (e *OAuthError) Error() string {
+	if e.Description == "" {
+		return e.Code
+	}
+	return e.Code + ": " + e.Description
+}
+
+// NewOAuthError creates an OAuth error with the status code RFC 6749 prescribes for it
+func NewOAuthError(code, description string) *OAuthError {
+	status := http.StatusBadRequest
+	switch code {
+	case OAuthErrInvalidClient:
+		status = http.StatusUnauthorized
+	case OAuthErrServerError:
+		status = http.StatusInternalServerError
+	}
+	return &OAuthError{
+		Code:        code,
+		Description: description,
+		StatusCode:  status,
+	}
+}
+
+// AsOAuthError converts any error into an OAuth error, hiding the details of unexpected failures
+func AsOAuthError(err error) *OAuthError {
+	if oauthErr, ok := err.(*OAuthError); ok {
+		return oauthErr
+	}
+	return NewOAuthError(OAuthErrServerError, "")
+}
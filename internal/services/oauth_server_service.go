@@ -0,0 +1,436 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"identity-service/internal/auth"
+	"identity-service/internal/models"
+	"identity-service/internal/repositories"
+	"identity-service/pkg/utils"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	authorizationCodeLength = 43
+	authorizationCodeTTL    = 5 * time.Minute
+)
+
+// ErrScopeNotGranted is returned when a refresh requests scopes beyond the original grant
+var ErrScopeNotGranted = errors.New("requested scope exceeds the original grant")
+
+// OAuthServerService implements the authorization server side of RFC 6749 for registered clients
+type OAuthServerService interface {
+	// LookupClient resolves the client and redirect URI of an authorization request. Errors
+	// returned here must be shown to the user and never redirected to the client.
+	LookupClient(clientID, redirectURI string) (*models.OAuthClient, string, error)
+	// ValidateAuthorizeRequest checks the remaining request parameters and normalizes scope and
+	// code challenge method in place. Errors are reported to the client's redirect URI.
+	ValidateAuthorizeRequest(client *models.OAuthClient, req *models.AuthorizeRequest) error
+	// Authorize issues an authorization code for a user who signed in at authTime
+	Authorize(user *models.User, client *models.OAuthClient, req *models.AuthorizeRequest, authTime time.Time) (string, error)
+	// Exchange handles a token request for any of the supported grants
+	Exchange(ctx *gin.Context, req *models.OAuthTokenRequest) (*models.OAuthTokenResponse, error)
+	// Introspect describes a token to an authenticated client of the same tenant
+	Introspect(req *models.IntrospectionRequest) (*models.IntrospectionResponse, error)
+	// Revoke ends the session of a token issued to the requesting client
+	Revoke(req *models.RevocationRequest) error
+	// AuthorizeDevice starts the device authorization grant for a client
+	AuthorizeDevice(req *models.DeviceAuthorizationRequest) (*models.DeviceAuthorizationResponse, error)
+	// LookupDeviceCode describes the pending device authorization a user code belongs to
+	LookupDeviceCode(userCode string) (*models.DeviceVerification, error)
+	// DecideDevice approves or denies a device for a user who signed in at authTime
+	DecideDevice(user *models.User, decision *models.DeviceDecision, authTime time.Time) error
+}
+
+type oauthServerService struct {
+	clientService OAuthClientService
+	authService   AuthService
+	oidcService   OIDCService
+	userService   UserService
+	codeRepo      repositories.AuthorizationCodeRepository
+	deviceRepo    repositories.DeviceCodeRepository
+	tenantRepo    repositories.TenantRepository
+}
+
+func NewOAuthServerService(
+	clientService OAuthClientService,
+	authService AuthService,
+	oidcService OIDCService,
+	userService UserService,
+	codeRepo repositories.AuthorizationCodeRepository,
+	deviceRepo repositories.DeviceCodeRepository,
+	tenantRepo repositories.TenantRepository,
+) OAuthServerService {
+	return &oauthServerService{
+		clientService: clientService,
+		authService:   authService,
+		oidcService:   oidcService,
+		userService:   userService,
+		codeRepo:      codeRepo,
+		deviceRepo:    deviceRepo,
+		tenantRepo:    tenantRepo,
+	}
+}
+
+func (s *oauthServerService) LookupClient(clientID, redirectURI string) (*models.OAuthClient, string, error) {
+	if clientID == "" {
+		return nil, "", NewOAuthError(OAuthErrInvalidRequest, "client_id is required")
+	}
+
+	client, err := s.clientService.GetClientByClientID(clientID)
+	if err != nil {
+		return nil, "", NewOAuthError(OAuthErrInvalidClient, "unknown client")
+	}
+
+	// The redirect URI may only be omitted when the client registered exactly one
+	if redirectURI == "" {
+		if len(client.RedirectURIs) != 1 {
+			return nil, "", NewOAuthError(OAuthErrInvalidRequest, "redirect_uri is required")
+		}
+		return client, client.RedirectURIs[0], nil
+	}
+
+	for _, allowed := range client.RedirectURIs {
+		if allowed == redirectURI {
+			return client, redirectURI, nil
+		}
+	}
+	return nil, "", NewOAuthError(OAuthErrInvalidRequest, "redirect_uri is not registered for this client")
+}
+
+func (s *oauthServerService) ValidateAuthorizeRequest(client *models.OAuthClient, req *models.AuthorizeRequest) error {
+	if req.ResponseType != "code" {
+		return NewOAuthError(OAuthErrUnsupportedResponseType, "only the code response type is supported")
+	}
+
+	if !client.HasGrantType(models.GrantTypeAuthorizationCode) {
+		return NewOAuthError(OAuthErrUnauthorizedClient, "client may not use the authorization_code grant")
+	}
+
+	scope, err := resolveScope(req.Scope, client.AllowedScopes)
+	if err != nil {
+		return err
+	}
+	req.Scope = scope
+
+	// Public clients cannot keep a secret, so PKCE is what binds the code to them
+	if req.CodeChallenge == "" {
+		if client.Public {
+			return NewOAuthError(OAuthErrInvalidRequest, "code_challenge is required for public clients")
+		}
+		return nil
+	}
+	if err := auth.ValidateCodeChallenge(req.CodeChallenge); err != nil {
+		return NewOAuthError(OAuthErrInvalidRequest, err.Error())
+	}
+	method, err := auth.NormalizeCodeChallengeMethod(req.CodeChallengeMethod)
+	if err != nil {
+		return NewOAuthError(OAuthErrInvalidRequest, err.Error())
+	}
+	req.CodeChallengeMethod = method
+
+	return nil
+}
+
+func (s *oauthServerService) Authorize(user *models.User, client *models.OAuthClient, req *models.AuthorizeRequest, authTime time.Time) (string, error) {
+	if err := s.ValidateAuthorizeRequest(client, req); err != nil {
+		return "", err
+	}
+
+	// Clients are tenant-scoped, so only members of the client's tenant may sign in to it
+	if _, err := s.tenantRepo.GetUserTenantAccess(user.ID, client.TenantID); err != nil {
+		return "", NewOAuthError(OAuthErrAccessDenied, "user is not a member of this application's tenant")
+	}
+
+	code := utils.GenerateRandomString(authorizationCodeLength)
+	authCode := &models.AuthorizationCode{
+		ID:                  uuid.New(),
+		CodeHash:            hashAuthorizationCode(code),
+		ClientID:            client.ClientID,
+		UserID:              user.ID,
+		TenantID:            client.TenantID,
+		RedirectURI:         req.RedirectURI,
+		Scope:               req.Scope,
+		CodeChallenge:       req.CodeChallenge,
+		CodeChallengeMethod: req.CodeChallengeMethod,
+		Nonce:               req.Nonce,
+		AuthTime:            authTime,
+		ExpiresAt:           time.Now().Add(authorizationCodeTTL),
+		CreatedAt:           time.Now(),
+	}
+	if err := s.codeRepo.CreateCode(authCode); err != nil {
+		return "", NewOAuthError(OAuthErrServerError, "")
+	}
+
+	return code, nil
+}
+
+func (s *oauthServerService) Exchange(ctx *gin.Context, req *models.OAuthTokenRequest) (*models.OAuthTokenResponse, error) {
+	if req.GrantType == "" {
+		return nil, NewOAuthError(OAuthErrInvalidRequest, "grant_type is required")
+	}
+
+	client, err := s.clientService.AuthenticateClient(req.ClientID, req.ClientSecret)
+	if err != nil {
+		return nil, NewOAuthError(OAuthErrInvalidClient, "client authentication failed")
+	}
+
+	switch req.GrantType {
+	case models.GrantTypeAuthorizationCode:
+		return s.exchangeAuthorizationCode(ctx, client, req)
+	case models.GrantTypeRefreshToken:
+		return s.exchangeRefreshToken(ctx, client, req)
+	case models.GrantTypeClientCredentials:
+		return s.exchangeClientCredentials(client, req)
+	case models.GrantTypeDeviceCode:
+		return s.exchangeDeviceCode(ctx, client, req)
+	case models.GrantTypeTokenExchange:
+		return s.exchangeToken(client, req)
+	default:
+		return nil, NewOAuthError(OAuthErrUnsupportedGrantType, "")
+	}
+}
+
+func (s *oauthServerService) exchangeAuthorizationCode(ctx *gin.Context, client *models.OAuthClient, req *models.OAuthTokenRequest) (*models.OAuthTokenResponse, error) {
+	if !client.HasGrantType(models.GrantTypeAuthorizationCode) {
+		return nil, NewOAuthError(OAuthErrUnauthorizedClient, "")
+	}
+	if req.Code == "" {
+		return nil, NewOAuthError(OAuthErrInvalidRequest, "code is required")
+	}
+
+	// The code is spent before any further checks so it can never be replayed
+	code, err := s.codeRepo.ConsumeCode(hashAuthorizationCode(req.Code))
+	if err != nil {
+		return nil, NewOAuthError(OAuthErrInvalidGrant, "invalid or expired code")
+	}
+	if code.ClientID != client.ClientID {
+		return nil, NewOAuthError(OAuthErrInvalidGrant, "code was issued to another client")
+	}
+	if code.RedirectURI != "" && code.RedirectURI != req.RedirectURI {
+		return nil, NewOAuthError(OAuthErrInvalidGrant, "redirect_uri does not match the authorization request")
+	}
+	if code.CodeChallenge != "" {
+		if err := auth.VerifyCodeVerifier(req.CodeVerifier, code.CodeChallenge, code.CodeChallengeMethod); err != nil {
+			return nil, NewOAuthError(OAuthErrInvalidGrant, err.Error())
+		}
+	} else if req.CodeVerifier != "" {
+		return nil, NewOAuthError(OAuthErrInvalidGrant, "code_verifier was not expected")
+	}
+
+	user, err := s.userService.GetUser(code.UserID)
+	if err != nil {
+		return nil, NewOAuthError(OAuthErrInvalidGrant, "user no longer exists")
+	}
+
+	session, err := s.authService.CreateClientSession(ctx, user, code.TenantID, client.ClientID, code.Scope)
+	if err != nil {
+		return nil, NewOAuthError(OAuthErrServerError, "")
+	}
+	resp := s.sessionResponse(client, session)
+
+	// OpenID Connect requests also receive an id_token
+	if containsScope(strings.Fields(code.Scope), models.ScopeOpenID) {
+		idToken, err := s.oidcService.IssueIDToken(user, client, code.TenantID, code.Scope, code.Nonce, code.AuthTime)
+		if err != nil {
+			return nil, NewOAuthError(OAuthErrServerError, "")
+		}
+		resp.IDToken = idToken
+	}
+
+	return resp, nil
+}
+
+func (s *oauthServerService) exchangeRefreshToken(ctx *gin.Context, client *models.OAuthClient, req *models.OAuthTokenRequest) (*models.OAuthTokenResponse, error) {
+	if !client.HasGrantType(models.GrantTypeRefreshToken) {
+		return nil, NewOAuthError(OAuthErrUnauthorizedClient, "")
+	}
+	if req.RefreshToken == "" {
+		return nil, NewOAuthError(OAuthErrInvalidRequest, "refresh_token is required")
+	}
+
+	session, err := s.authService.RefreshClientSession(ctx, req.RefreshToken, client.ClientID, req.Scope)
+	if errors.Is(err, ErrScopeNotGranted) {
+		return nil, NewOAuthError(OAuthErrInvalidScope, err.Error())
+	}
+	if err != nil {
+		return nil, NewOAuthError(OAuthErrInvalidGrant, "invalid refresh token")
+	}
+
+	return s.sessionResponse(client, session), nil
+}
+
+func (s *oauthServerService) exchangeClientCredentials(client *models.OAuthClient, req *models.OAuthTokenRequest) (*models.OAuthTokenResponse, error) {
+	if client.Public || !client.HasGrantType(models.GrantTypeClientCredentials) {
+		return nil, NewOAuthError(OAuthErrUnauthorizedClient, "")
+	}
+
+	scope, err := resolveScope(req.Scope, client.AllowedScopes)
+	if err != nil {
+		return nil, err
+	}
+
+	accessToken, err := s.authService.IssueClientToken(client, scope)
+	if err != nil {
+		return nil, NewOAuthError(OAuthErrServerError, "")
+	}
+
+	// No refresh token: the client can always authenticate again (RFC 6749 section 4.4.3)
+	return &models.OAuthTokenResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(AccessTokenTTL.Seconds()),
+		Scope:       scope,
+	}, nil
+}
+
+// Introspect reports whether a token is active (RFC 7662) to a confidential client. Tokens
+// that fail verification, belong to an ended session or were issued in another tenant are
+// reported inactive.
+func (s *oauthServerService) Introspect(req *models.IntrospectionRequest) (*models.IntrospectionResponse, error) {
+	client, err := s.clientService.AuthenticateClient(req.ClientID, req.ClientSecret)
+	if err != nil {
+		return nil, NewOAuthError(OAuthErrInvalidClient, "client authentication failed")
+	}
+	// Public clients cannot authenticate, so anyone could introspect with their client ID
+	if client.Public {
+		return nil, NewOAuthError(OAuthErrInvalidClient, "public clients cannot introspect tokens")
+	}
+	if req.Token == "" {
+		return nil, NewOAuthError(OAuthErrInvalidRequest, "token is required")
+	}
+
+	inactive := &models.IntrospectionResponse{Active: false}
+	claims, err := s.authService.InspectToken(req.Token)
+	if err != nil || claims.TenantID != client.TenantID {
+		return inactive, nil
+	}
+
+	resp := &models.IntrospectionResponse{
+		Active:   true,
+		Scope:    claims.Scope,
+		ClientID: claims.ClientID,
+		Subject:  claims.UserID.String(),
+		Tenant:   claims.TenantID.String(),
+	}
+	if claims.SessionID == uuid.Nil {
+		// Client credentials tokens are issued to the client itself
+		resp.Subject = claims.Subject
+	}
+	if len(claims.Audience) > 0 {
+		resp.Audience = claims.Audience
+	}
+	resp.Actor = claims.Actor
+	switch claims.TokenType {
+	case "access":
+		resp.TokenType = models.TokenTypeHintAccessToken
+	case "refresh":
+		resp.TokenType = models.TokenTypeHintRefreshToken
+	}
+	if claims.ExpiresAt != nil {
+		resp.ExpiresAt = claims.ExpiresAt.Unix()
+	}
+	if claims.IssuedAt != nil {
+		resp.IssuedAt = claims.IssuedAt.Unix()
+	}
+
+	return resp, nil
+}
+
+// Revoke invalidates the session behind an access or refresh token, which revokes both
+// tokens of the session (RFC 7009). Unknown or already invalid tokens are not an error.
+func (s *oauthServerService) Revoke(req *models.RevocationRequest) error {
+	client, err := s.clientService.AuthenticateClient(req.ClientID, req.ClientSecret)
+	if err != nil {
+		return NewOAuthError(OAuthErrInvalidClient, "client authentication failed")
+	}
+	if req.Token == "" {
+		return NewOAuthError(OAuthErrInvalidRequest, "token is required")
+	}
+
+	claims, err := s.authService.InspectToken(req.Token)
+	if err != nil {
+		return nil
+	}
+	if claims.ClientID != client.ClientID {
+		return NewOAuthError(OAuthErrUnauthorizedClient, "token was issued to another client")
+	}
+
+	// Client credentials and delegated tokens are self-contained and expire on their own.
+	// Revoking a delegated token must not end the session of the user it acts for.
+	if claims.SessionID == uuid.Nil {
+		return NewOAuthError(OAuthErrUnsupportedTokenType, "client credentials tokens cannot be revoked")
+	}
+	if claims.Actor != nil {
+		return NewOAuthError(OAuthErrUnsupportedTokenType, "delegated tokens cannot be revoked")
+	}
+
+	if err := s.authService.EndSession(claims.SessionID); err != nil {
+		return NewOAuthError(OAuthErrServerError, "")
+	}
+	return nil
+}
+
+func (s *oauthServerService) sessionResponse(client *models.OAuthClient, session *models.Session) *models.OAuthTokenResponse {
+	resp := &models.OAuthTokenResponse{
+		AccessToken: session.AccessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(AccessTokenTTL.Seconds()),
+		Scope:       session.AccessScope,
+	}
+	if client.HasGrantType(models.GrantTypeRefreshToken) {
+		resp.RefreshToken = session.RefreshToken
+	}
+	return resp
+}
+
+// resolveScope checks a space-delimited scope against the allowed scopes. An empty request is
+// granted every allowed scope.
+func resolveScope(requested string, allowed []string) (string, error) {
+	if requested == "" {
+		return strings.Join(allowed, " "), nil
+	}
+
+	scopes := strings.Fields(requested)
+	for _, scope := range scopes {
+		if !containsScope(allowed, scope) {
+			return "", NewOAuthError(OAuthErrInvalidScope, "scope "+scope+" is not allowed for this client")
+		}
+	}
+	return strings.Join(scopes, " "), nil
+}
+
+// narrowScope returns the requested subset of a granted scope, or the granted scope when none is requested
+func narrowScope(requested, granted string) (string, error) {
+	if requested == "" {
+		return granted, nil
+	}
+
+	grantedScopes := strings.Fields(granted)
+	scopes := strings.Fields(requested)
+	for _, scope := range scopes {
+		if !containsScope(grantedScopes, scope) {
+			return "", ErrScopeNotGranted
+		}
+	}
+	return strings.Join(scopes, " "), nil
+}
+
+func containsScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func hashAuthorizationCode(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
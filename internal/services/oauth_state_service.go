@@ -0,0 +1,58 @@
+package services
+
+import (
+	"errors"
+	"identity-service/internal/models"
+	"identity-service/internal/repositories"
+	"identity-service/pkg/utils"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// oauthStateTTL bounds how long a user may take to complete the provider login
+const oauthStateTTL = 10 * time.Minute
+
+var ErrInvalidOAuthState = errors.New("invalid or expired OAuth state")
+
+// OAuthStateService keeps track of in-flight provider logins
+type OAuthStateService interface {
+	CreateState(state *models.OAuthState) error
+	ConsumeState(provider string, state string) (*models.OAuthState, error)
+}
+
+type oauthStateService struct {
+	repo repositories.OAuthStateRepository
+}
+
+func NewOAuthStateService(repo repositories.OAuthStateRepository) OAuthStateService {
+	return &oauthStateService{
+		repo: repo,
+	}
+}
+
+func (s *oauthStateService) CreateState(state *models.OAuthState) error {
+	now := time.Now()
+	state.ID = uuid.New()
+	state.State = utils.GenerateRandomState()
+	state.CreatedAt = now
+	state.ExpiresAt = now.Add(oauthStateTTL)
+	return s.repo.CreateState(state)
+}
+
+func (s *oauthStateService) ConsumeState(provider string, state string) (*models.OAuthState, error) {
+	if state == "" {
+		return nil, ErrInvalidOAuthState
+	}
+
+	oauthState, err := s.repo.ConsumeState(state)
+	if err != nil {
+		return nil, ErrInvalidOAuthState
+	}
+
+	if oauthState.Provider != provider || time.Now().After(oauthState.ExpiresAt) {
+		return nil, ErrInvalidOAuthState
+	}
+
+	return oauthState, nil
+}
@@ -0,0 +1,144 @@
+package services
+
+import (
+	"errors"
+	"identity-service/config"
+	jwtmanager "identity-service/internal/auth/jwt"
+	"identity-service/internal/models"
+	"identity-service/internal/repositories"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+)
+
+// idTokenTTL is how long an id_token may be presented to the client that requested it
+const idTokenTTL = time.Hour
+
+var ErrInvalidAccessToken = errors.New("invalid access token")
+
+// IDTokenClaims are the claims of an OpenID Connect id_token (OpenID Connect Core 1.0 section 2)
+type IDTokenClaims struct {
+	AuthTime      int64     `json:"auth_time"`
+	Nonce         string    `json:"nonce,omitempty"`
+	Email         string    `json:"email,omitempty"`
+	EmailVerified *bool     `json:"email_verified,omitempty"`
+	Name          string    `json:"name,omitempty"`
+	Groups        []string  `json:"groups,omitempty"`
+	Tenant        uuid.UUID `json:"tenant"`
+	jwt.RegisteredClaims
+}
+
+// OIDCService issues id_tokens and serves userinfo claims on top of the OAuth 2.0 authorization server
+type OIDCService interface {
+	IssueIDToken(user *models.User, client *models.OAuthClient, tenantID uuid.UUID, scope, nonce string, authTime time.Time) (string, error)
+	UserInfo(accessToken string) (map[string]interface{}, error)
+}
+
+type oidcService struct {
+	userService UserService
+	sessionRepo repositories.SessionRepository
+	groupRepo   repositories.GroupRepository
+	keyManager  *jwtmanager.KeyManager
+}
+
+func NewOIDCService(userService UserService, sessionRepo repositories.SessionRepository, groupRepo repositories.GroupRepository, keyManager *jwtmanager.KeyManager) OIDCService {
+	return &oidcService{
+		userService: userService,
+		sessionRepo: sessionRepo,
+		groupRepo:   groupRepo,
+		keyManager:  keyManager,
+	}
+}
+
+// IssueIDToken signs an id_token with the client's signing algorithm, or the default one
+func (s *oidcService) IssueIDToken(user *models.User, client *models.OAuthClient, tenantID uuid.UUID, scope, nonce string, authTime time.Time) (string, error) {
+	now := time.Now()
+	claims := IDTokenClaims{
+		AuthTime: authTime.Unix(),
+		Nonce:    nonce,
+		Tenant:   tenantID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    config.AuthServer.Issuer,
+			Subject:   user.ID.String(),
+			Audience:  jwt.ClaimStrings{client.ClientID},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(idTokenTTL)),
+		},
+	}
+
+	scopes := strings.Fields(scope)
+	if containsScope(scopes, models.ScopeEmail) {
+		claims.Email = user.Email
+		claims.EmailVerified = &user.EmailVerified
+	}
+	if containsScope(scopes, models.ScopeProfile) {
+		claims.Name = user.Name
+	}
+	if containsScope(scopes, models.ScopeGroups) {
+		groups, err := memberGroupNames(s.groupRepo, user.ID, tenantID)
+		if err != nil {
+			return "", err
+		}
+		claims.Groups = groups
+	}
+
+	if client.SigningAlgorithm != "" {
+		return s.keyManager.SignTokenWithAlgorithm(claims, client.SigningAlgorithm)
+	}
+	return s.keyManager.SignToken(claims)
+}
+
+// UserInfo returns the claims about the user an access token was issued for, limited to
+// the token's granted scopes (OpenID Connect Core 1.0 section 5.3)
+func (s *oidcService) UserInfo(accessToken string) (map[string]interface{}, error) {
+	claims := &Claims{}
+	if err := s.keyManager.VerifyToken(accessToken, claims); err != nil {
+		return nil, ErrInvalidAccessToken
+	}
+	if claims.TokenType != "access" {
+		return nil, ErrInvalidAccessToken
+	}
+	// Delegated tokens are for the services named in their audience, not for this one
+	if !claims.VerifyAudience(config.AuthServer.Issuer, false) {
+		return nil, ErrInvalidAccessToken
+	}
+
+	scopes := strings.Fields(claims.Scope)
+	if !containsScope(scopes, models.ScopeOpenID) {
+		return nil, ErrInvalidAccessToken
+	}
+
+	// The session must still exist, so revoked sessions lose access immediately
+	if _, err := s.sessionRepo.GetSession(claims.SessionID); err != nil {
+		return nil, ErrInvalidAccessToken
+	}
+
+	user, err := s.userService.GetUser(claims.UserID)
+	if err != nil {
+		return nil, ErrInvalidAccessToken
+	}
+
+	info := map[string]interface{}{
+		"sub":    user.ID.String(),
+		"tenant": claims.TenantID,
+	}
+	if containsScope(scopes, models.ScopeEmail) {
+		info["email"] = user.Email
+		info["email_verified"] = user.EmailVerified
+	}
+	if containsScope(scopes, models.ScopeProfile) {
+		info["name"] = user.Name
+		info["updated_at"] = user.UpdatedAt.Unix()
+	}
+	if containsScope(scopes, models.ScopeGroups) {
+		groups, err := memberGroupNames(s.groupRepo, user.ID, claims.TenantID)
+		if err != nil {
+			return nil, err
+		}
+		info["groups"] = groups
+	}
+
+	return info, nil
+}
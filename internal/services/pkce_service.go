@@ -1,16 +1,19 @@
 package services
 
 import (
+	"errors"
+	"identity-service/internal/auth"
 	"identity-service/internal/models"
 	"identity-service/internal/repositories"
 
 	"github.com/google/uuid"
 )
 
+var ErrInvalidAuthorizationCode = errors.New("invalid or expired code")
+
 type PKCEService interface {
 	CreateChallenge(challenge *models.PKCEChallenge) error
-	GetChallenge(id uuid.UUID) (*models.PKCEChallenge, error)
-	MarkChallengeAsUsed(id uuid.UUID) error
+	ConsumeChallenge(id uuid.UUID, codeVerifier string) (*models.PKCEChallenge, error)
 }
 
 type pkceService struct {
@@ -27,10 +30,17 @@ func (s *pkceService) CreateChallenge(challenge *models.PKCEChallenge) error {
 	return s.repo.CreateChallenge(challenge)
 }
 
-func (s *pkceService) GetChallenge(id uuid.UUID) (*models.PKCEChallenge, error) {
-	return s.repo.GetChallenge(id)
-}
+// ConsumeChallenge redeems the code and checks the verifier. The code is spent even when
+// the verifier is wrong, so a leaked code cannot be brute-forced.
+func (s *pkceService) ConsumeChallenge(id uuid.UUID, codeVerifier string) (*models.PKCEChallenge, error) {
+	challenge, err := s.repo.ConsumeChallenge(id)
+	if err != nil {
+		return nil, ErrInvalidAuthorizationCode
+	}
+
+	if err := auth.VerifyCodeVerifier(codeVerifier, challenge.CodeChallenge, challenge.CodeChallengeMethod); err != nil {
+		return nil, err
+	}
 
-func (s *pkceService) MarkChallengeAsUsed(id uuid.UUID) error {
-	return s.repo.MarkChallengeAsUsed(id)
+	return challenge, nil
 }
@@ -0,0 +1,62 @@
+package services
+
+import (
+	"errors"
+	"identity-service/internal/models"
+	"strings"
+)
+
+var ErrRedirectURINotAllowed = errors.New("redirect_uri is not allowed")
+
+// RedirectService decides where users are sent after a successful login
+type RedirectService interface {
+	ValidateRedirectURI(redirectURI string, tenant *models.Tenant) error
+	ResolveRedirectURI(redirectURI string, tenant *models.Tenant) string
+}
+
+type redirectService struct {
+	allowedURIs []string
+	defaultURI  string
+}
+
+// NewRedirectService creates a redirect service with the deployment-wide allowlist.
+// Tenants can extend the allowlist through their AllowedRedirectURIs.
+func NewRedirectService(allowedURIs []string, defaultURI string) RedirectService {
+	return &redirectService{
+		allowedURIs: allowedURIs,
+		defaultURI:  defaultURI,
+	}
+}
+
+// ValidateRedirectURI checks the URI against the global and tenant allowlists.
+// Matching is exact: no prefix, wildcard or case-insensitive comparison is done.
+func (s *redirectService) ValidateRedirectURI(redirectURI string, tenant *models.Tenant) error {
+	for _, allowed := range s.allowedURIs {
+		if allowed == redirectURI {
+			return nil
+		}
+	}
+
+	if tenant != nil {
+		for _, allowed := range tenant.AllowedRedirectURIs {
+			if allowed == redirectURI {
+				return nil
+			}
+		}
+	}
+
+	return ErrRedirectURINotAllowed
+}
+
+// ResolveRedirectURI returns the validated redirect URI, or the default one if none was requested
+func (s *redirectService) ResolveRedirectURI(redirectURI string, tenant *models.Tenant) string {
+	if redirectURI != "" {
+		return redirectURI
+	}
+
+	slug := ""
+	if tenant != nil {
+		slug = tenant.Slug
+	}
+	return strings.ReplaceAll(s.defaultURI, "{tenant}", slug)
+}
@@ -0,0 +1,737 @@
+package services
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"identity-service/config"
+	"identity-service/internal/models"
+	"identity-service/internal/repositories"
+	"log"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Audit log actions recorded for relation namespaces
+const (
+	auditRelationNamespaceSaved   = "relation.namespace_saved"
+	auditRelationNamespaceDeleted = "relation.namespace_deleted"
+)
+
+const (
+	// relationPurgeTick is how often deleted tuples past their retention are purged
+	relationPurgeTick = time.Hour
+	// maxRelationDepth bounds how deeply sets of subjects and rewrites are followed
+	maxRelationDepth = 25
+	// maxRelationLookups bounds the tuple queries of one check, expansion or listing
+	maxRelationLookups = 1000
+	// maxNamespaceRelations bounds the relations of a namespace
+	maxNamespaceRelations = 64
+	// maxTupleReadLimit bounds a page of tuples read
+	maxTupleReadLimit = 1000
+)
+
+var (
+	ErrInvalidRelationship     = errors.New("invalid relationship")
+	ErrInvalidNamespace        = errors.New("invalid namespace configuration")
+	ErrUnknownNamespace        = errors.New("unknown namespace")
+	ErrUnknownRelation         = errors.New("unknown relation")
+	ErrNamespaceInUse          = errors.New("namespace is used by relation tuples")
+	ErrInvalidConsistencyToken = errors.New("invalid consistency token")
+	ErrSnapshotExpired         = errors.New("the snapshot of the consistency token is no longer available")
+	ErrRelationLimit           = errors.New("the relationships are too deep or too many to evaluate")
+)
+
+// RelationService answers relationship-based authorization questions from tuples such as
+// doc:123#viewer@user:abc, stored per tenant and interpreted by the tenant's namespace
+// configurations. Every write advances the tenant's revision; reads are evaluated at a revision
+// and return its consistency token, which later requests can pass to read at least as fresh a
+// revision or exactly that one.
+type RelationService interface {
+	ListNamespaces(tenantID uuid.UUID) ([]models.RelationNamespace, error)
+	GetNamespace(tenantID uuid.UUID, name string) (*models.RelationNamespace, error)
+	// SaveNamespace creates or replaces the configuration of a namespace. Relations still used
+	// by tuples cannot be removed.
+	SaveNamespace(tenantID uuid.UUID, name string, request *models.RelationNamespaceRequest, clientID string) (*models.RelationNamespace, error)
+	// DeleteNamespace deletes a namespace no tuples use
+	DeleteNamespace(tenantID uuid.UUID, name string, clientID string) error
+
+	// Write deletes and adds tuples in a new revision, returning its consistency token
+	Write(tenantID uuid.UUID, write *models.RelationWrite) (string, error)
+	// Read returns a page of the tuples matching the filter
+	Read(tenantID uuid.UUID, filter *models.RelationFilter, consistency *models.RelationConsistency, page, limit int) ([]models.Relationship, string, error)
+	// Check reports whether the subject has the relation to the object
+	Check(tenantID uuid.UUID, check *models.RelationCheck) (bool, string, error)
+	// Expand returns the subjects with the relation to the object
+	Expand(tenantID uuid.UUID, expand *models.RelationExpand) (*models.RelationTree, string, error)
+	// ListObjects returns the IDs of the objects of a namespace the subject has the relation to
+	ListObjects(tenantID uuid.UUID, list *models.RelationListObjects) ([]string, string, error)
+
+	// PurgeDeletedTuples removes tuples deleted longer ago than the snapshot retention
+	PurgeDeletedTuples() error
+	StartPurge()
+}
+
+type relationService struct {
+	repo         repositories.RelationRepository
+	securityRepo repositories.SecurityRepository
+}
+
+func NewRelationService(repo repositories.RelationRepository, securityRepo repositories.SecurityRepository) RelationService {
+	return &relationService{
+		repo:         repo,
+		securityRepo: securityRepo,
+	}
+}
+
+func (s *relationService) ListNamespaces(tenantID uuid.UUID) ([]models.RelationNamespace, error) {
+	return s.repo.ListNamespaces(tenantID)
+}
+
+func (s *relationService) GetNamespace(tenantID uuid.UUID, name string) (*models.RelationNamespace, error) {
+	return s.repo.GetNamespace(tenantID, name)
+}
+
+func (s *relationService) SaveNamespace(tenantID uuid.UUID, name string, request *models.RelationNamespaceRequest, clientID string) (*models.RelationNamespace, error) {
+	if !models.ValidRelationName(name) {
+		return nil, fmt.Errorf("%w: name must be up to 64 lowercase letters, digits or underscores, starting with a letter", ErrInvalidNamespace)
+	}
+	if err := validateRelations(request.Relations); err != nil {
+		return nil, err
+	}
+
+	namespace, err := s.repo.GetNamespace(tenantID, name)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		namespace = &models.RelationNamespace{
+			ID:        uuid.New(),
+			TenantID:  tenantID,
+			Name:      name,
+			Relations: request.Relations,
+		}
+		if err := s.repo.CreateNamespace(namespace); err != nil {
+			return nil, err
+		}
+	case err != nil:
+		return nil, err
+	default:
+		for relation := range namespace.Relations {
+			if _, kept := request.Relations[relation]; kept {
+				continue
+			}
+			count, err := s.repo.CountTuples(tenantID, name, relation)
+			if err != nil {
+				return nil, err
+			}
+			if count > 0 {
+				return nil, fmt.Errorf("%w: %d tuples use relation %s", ErrNamespaceInUse, count, relation)
+			}
+		}
+		namespace.Relations = request.Relations
+		if err := s.repo.SaveNamespace(namespace); err != nil {
+			return nil, err
+		}
+	}
+
+	relations := make([]string, 0, len(namespace.Relations))
+	for relation := range namespace.Relations {
+		relations = append(relations, relation)
+	}
+	sort.Strings(relations)
+	s.audit(tenantID, auditRelationNamespaceSaved, name, map[string]string{
+		"clientId":  clientID,
+		"relations": strings.Join(relations, " "),
+	})
+	return namespace, nil
+}
+
+func (s *relationService) DeleteNamespace(tenantID uuid.UUID, name string, clientID string) error {
+	if _, err := s.repo.GetNamespace(tenantID, name); err != nil {
+		return err
+	}
+	count, err := s.repo.CountTuples(tenantID, name, "")
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return fmt.Errorf("%w: %d tuples", ErrNamespaceInUse, count)
+	}
+
+	if err := s.repo.DeleteNamespace(tenantID, name); err != nil {
+		return err
+	}
+	s.audit(tenantID, auditRelationNamespaceDeleted, name, map[string]string{"clientId": clientID})
+	return nil
+}
+
+func (s *relationService) Write(tenantID uuid.UUID, write *models.RelationWrite) (string, error) {
+	if len(write.Writes) == 0 && len(write.Deletes) == 0 {
+		return "", fmt.Errorf("%w: nothing to write or delete", ErrInvalidRelationship)
+	}
+	namespaces, err := s.namespaces(tenantID)
+	if err != nil {
+		return "", err
+	}
+
+	writes := make([]models.RelationTuple, 0, len(write.Writes))
+	for _, relationship := range write.Writes {
+		tuple, err := parseRelationship(relationship)
+		if err != nil {
+			return "", err
+		}
+		// Tuples must mean something to the namespaces, unlike those being deleted
+		if err := checkRelation(namespaces, tuple.Namespace, tuple.Relation); err != nil {
+			return "", fmt.Errorf("%w in %s", err, relationship)
+		}
+		if tuple.SubjectRelation != "" {
+			if err := checkRelation(namespaces, tuple.SubjectNamespace, tuple.SubjectRelation); err != nil {
+				return "", fmt.Errorf("%w in %s", err, relationship)
+			}
+		}
+		writes = append(writes, *tuple)
+	}
+	deletes := make([]models.RelationTuple, 0, len(write.Deletes))
+	for _, relationship := range write.Deletes {
+		tuple, err := parseRelationship(relationship)
+		if err != nil {
+			return "", err
+		}
+		deletes = append(deletes, *tuple)
+	}
+
+	revision, err := s.repo.WriteTuples(tenantID, writes, deletes)
+	if err != nil {
+		return "", err
+	}
+	return relationToken(tenantID, revision), nil
+}
+
+func (s *relationService) Read(tenantID uuid.UUID, filter *models.RelationFilter, consistency *models.RelationConsistency, page, limit int) ([]models.Relationship, string, error) {
+	limit = min(limit, maxTupleReadLimit)
+	query := &repositories.TupleQuery{
+		Namespace: filter.Namespace,
+		Relation:  filter.Relation,
+		Offset:    (page - 1) * limit,
+		Limit:     limit,
+	}
+	if filter.Object != "" {
+		object, ok := models.ParseRelationObject(filter.Object)
+		if !ok || (filter.Namespace != "" && filter.Namespace != object.Namespace) {
+			return nil, "", fmt.Errorf("%w: object %q", ErrInvalidRelationship, filter.Object)
+		}
+		query.Namespace, query.ObjectID = object.Namespace, object.ObjectID
+	}
+	if filter.Subject != "" {
+		subject, ok := models.ParseRelationSubject(filter.Subject)
+		if !ok {
+			return nil, "", fmt.Errorf("%w: subject %q", ErrInvalidRelationship, filter.Subject)
+		}
+		query.SubjectNamespace, query.SubjectObjectID, query.SubjectRelation = subject.Namespace, subject.ObjectID, &subject.Relation
+	}
+
+	revision, err := s.snapshot(tenantID, consistency)
+	if err != nil {
+		return nil, "", err
+	}
+	tuples, err := s.repo.FindTuples(tenantID, revision, query)
+	if err != nil {
+		return nil, "", err
+	}
+
+	relationships := make([]models.Relationship, 0, len(tuples))
+	for i := range tuples {
+		relationships = append(relationships, tuples[i].Relationship())
+	}
+	return relationships, relationToken(tenantID, revision), nil
+}
+
+func (s *relationService) Check(tenantID uuid.UUID, check *models.RelationCheck) (bool, string, error) {
+	evaluator, err := s.evaluator(tenantID, &check.Consistency)
+	if err != nil {
+		return false, "", err
+	}
+	userset, err := evaluator.userset(check.Object, check.Relation)
+	if err != nil {
+		return false, "", err
+	}
+	subject, ok := models.ParseRelationSubject(check.Subject)
+	if !ok {
+		return false, "", fmt.Errorf("%w: subject %q", ErrInvalidRelationship, check.Subject)
+	}
+
+	allowed, err := evaluator.check(userset, subject, 0)
+	if err != nil {
+		return false, "", err
+	}
+	return allowed, relationToken(tenantID, evaluator.revision), nil
+}
+
+func (s *relationService) Expand(tenantID uuid.UUID, expand *models.RelationExpand) (*models.RelationTree, string, error) {
+	evaluator, err := s.evaluator(tenantID, &expand.Consistency)
+	if err != nil {
+		return nil, "", err
+	}
+	userset, err := evaluator.userset(expand.Object, expand.Relation)
+	if err != nil {
+		return nil, "", err
+	}
+
+	tree, err := evaluator.expand(userset, 0)
+	if err != nil {
+		return nil, "", err
+	}
+	return tree, relationToken(tenantID, evaluator.revision), nil
+}
+
+func (s *relationService) ListObjects(tenantID uuid.UUID, list *models.RelationListObjects) ([]string, string, error) {
+	evaluator, err := s.evaluator(tenantID, &list.Consistency)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := checkRelation(evaluator.namespaces, list.Namespace, list.Relation); err != nil {
+		return nil, "", err
+	}
+	subject, ok := models.ParseRelationSubject(list.Subject)
+	if !ok {
+		return nil, "", fmt.Errorf("%w: subject %q", ErrInvalidRelationship, list.Subject)
+	}
+
+	reached, err := evaluator.reachable(subject)
+	if err != nil {
+		return nil, "", err
+	}
+	objects := []string{}
+	for userset := range reached {
+		if userset.Namespace == list.Namespace && userset.Relation == list.Relation {
+			objects = append(objects, userset.ObjectID)
+		}
+	}
+	sort.Strings(objects)
+	return objects, relationToken(tenantID, evaluator.revision), nil
+}
+
+func (s *relationService) PurgeDeletedTuples() error {
+	return s.repo.PurgeDeletedTuples(time.Now().Add(-config.Relation.SnapshotRetention))
+}
+
+// StartPurge purges deleted tuples in the background until the process exits
+func (s *relationService) StartPurge() {
+	ticker := time.NewTicker(relationPurgeTick)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := s.PurgeDeletedTuples(); err != nil {
+			log.Printf("Failed to purge deleted relation tuples: %v", err)
+		}
+	}
+}
+
+func (s *relationService) audit(tenantID uuid.UUID, action, namespace string, details map[string]string) {
+	recordAudit(s.securityRepo, tenantID, uuid.Nil, action, "relation-namespaces/"+namespace, details)
+}
+
+// namespaces returns the tenant's namespaces by name
+func (s *relationService) namespaces(tenantID uuid.UUID) (map[string]*models.RelationNamespace, error) {
+	list, err := s.repo.ListNamespaces(tenantID)
+	if err != nil {
+		return nil, err
+	}
+	namespaces := make(map[string]*models.RelationNamespace, len(list))
+	for i := range list {
+		namespaces[list[i].Name] = &list[i]
+	}
+	return namespaces, nil
+}
+
+// snapshot returns the revision to evaluate at: the latest, unless the consistency asks for
+// the exact snapshot of a token
+func (s *relationService) snapshot(tenantID uuid.UUID, consistency *models.RelationConsistency) (int64, error) {
+	if consistency.AtLeastAsFresh != "" && consistency.AtExactSnapshot != "" {
+		return 0, fmt.Errorf("%w: give atLeastAsFresh or atExactSnapshot, not both", ErrInvalidConsistencyToken)
+	}
+	current, err := s.repo.GetRevision(tenantID)
+	if err != nil {
+		return 0, err
+	}
+
+	token := consistency.AtLeastAsFresh + consistency.AtExactSnapshot
+	if token == "" {
+		return current.Revision, nil
+	}
+	revision, err := parseRelationToken(tenantID, token)
+	if err != nil {
+		return 0, err
+	}
+	if revision > current.Revision {
+		return 0, ErrInvalidConsistencyToken
+	}
+	if consistency.AtExactSnapshot == "" {
+		return current.Revision, nil
+	}
+	if revision < current.OldestRevision {
+		return 0, ErrSnapshotExpired
+	}
+	return revision, nil
+}
+
+func (s *relationService) evaluator(tenantID uuid.UUID, consistency *models.RelationConsistency) (*relationEvaluator, error) {
+	revision, err := s.snapshot(tenantID, consistency)
+	if err != nil {
+		return nil, err
+	}
+	namespaces, err := s.namespaces(tenantID)
+	if err != nil {
+		return nil, err
+	}
+	return &relationEvaluator{
+		repo:       s.repo,
+		tenantID:   tenantID,
+		revision:   revision,
+		namespaces: namespaces,
+		visited:    make(map[models.RelationSubject]bool),
+	}, nil
+}
+
+// relationEvaluator evaluates one request against the tenant's tuples at a revision
+type relationEvaluator struct {
+	repo       repositories.RelationRepository
+	tenantID   uuid.UUID
+	revision   int64
+	namespaces map[string]*models.RelationNamespace
+	visited    map[models.RelationSubject]bool
+	lookups    int
+}
+
+// userset parses an object and checks the relation is configured for it
+func (e *relationEvaluator) userset(object, relation string) (models.RelationSubject, error) {
+	userset, ok := models.ParseRelationObject(object)
+	if !ok {
+		return userset, fmt.Errorf("%w: object %q", ErrInvalidRelationship, object)
+	}
+	if err := checkRelation(e.namespaces, userset.Namespace, relation); err != nil {
+		return userset, err
+	}
+	userset.Relation = relation
+	return userset, nil
+}
+
+// rewrite returns the configuration of the userset's relation, if the namespace has one
+func (e *relationEvaluator) rewrite(userset models.RelationSubject) (models.RelationRewrite, bool) {
+	namespace, ok := e.namespaces[userset.Namespace]
+	if !ok {
+		return models.RelationRewrite{}, false
+	}
+	rewrite, ok := namespace.Relations[userset.Relation]
+	return rewrite, ok
+}
+
+func (e *relationEvaluator) lookup() error {
+	e.lookups++
+	if e.lookups > maxRelationLookups {
+		return ErrRelationLimit
+	}
+	return nil
+}
+
+func (e *relationEvaluator) find(query *repositories.TupleQuery) ([]models.RelationTuple, error) {
+	if err := e.lookup(); err != nil {
+		return nil, err
+	}
+	return e.repo.FindTuples(e.tenantID, e.revision, query)
+}
+
+// check reports whether the subject is in the userset: named by one of its tuples, in a set of
+// subjects one names, or given the relation by its rewrite
+func (e *relationEvaluator) check(userset, subject models.RelationSubject, depth int) (bool, error) {
+	if userset == subject {
+		return true, nil
+	}
+	if depth > maxRelationDepth {
+		return false, ErrRelationLimit
+	}
+	// Everything reachable from a userset already visited is being, or has been, checked
+	if e.visited[userset] {
+		return false, nil
+	}
+	e.visited[userset] = true
+	rewrite, ok := e.rewrite(userset)
+	if !ok {
+		return false, nil
+	}
+
+	if err := e.lookup(); err != nil {
+		return false, err
+	}
+	found, err := e.repo.HasTuple(e.tenantID, e.revision, &models.RelationTuple{
+		Namespace:        userset.Namespace,
+		ObjectID:         userset.ObjectID,
+		Relation:         userset.Relation,
+		SubjectNamespace: subject.Namespace,
+		SubjectObjectID:  subject.ObjectID,
+		SubjectRelation:  subject.Relation,
+	})
+	if err != nil || found {
+		return found, err
+	}
+
+	tuples, err := e.find(&repositories.TupleQuery{
+		Namespace:    userset.Namespace,
+		ObjectID:     userset.ObjectID,
+		Relation:     userset.Relation,
+		UsersetsOnly: true,
+	})
+	if err != nil {
+		return false, err
+	}
+	for i := range tuples {
+		if found, err := e.check(tuples[i].Subject(), subject, depth+1); err != nil || found {
+			return found, err
+		}
+	}
+
+	for _, relation := range rewrite.ComputedUsersets {
+		computed := models.RelationSubject{Namespace: userset.Namespace, ObjectID: userset.ObjectID, Relation: relation}
+		if found, err := e.check(computed, subject, depth+1); err != nil || found {
+			return found, err
+		}
+	}
+
+	for _, rule := range rewrite.TupleToUsersets {
+		tuples, err := e.find(&repositories.TupleQuery{
+			Namespace: userset.Namespace,
+			ObjectID:  userset.ObjectID,
+			Relation:  rule.Tupleset,
+		})
+		if err != nil {
+			return false, err
+		}
+		for i := range tuples {
+			related := models.RelationSubject{Namespace: tuples[i].SubjectNamespace, ObjectID: tuples[i].SubjectObjectID, Relation: rule.ComputedUserset}
+			if found, err := e.check(related, subject, depth+1); err != nil || found {
+				return found, err
+			}
+		}
+	}
+	return false, nil
+}
+
+// expand lists the subjects the userset's tuples name and the usersets its tuple-to-userset
+// rules reach, expanding its computed usersets as children
+func (e *relationEvaluator) expand(userset models.RelationSubject, depth int) (*models.RelationTree, error) {
+	if depth > maxRelationDepth {
+		return nil, ErrRelationLimit
+	}
+	e.visited[userset] = true
+	tree := &models.RelationTree{
+		Object:   models.RelationSubject{Namespace: userset.Namespace, ObjectID: userset.ObjectID}.String(),
+		Relation: userset.Relation,
+		Subjects: []string{},
+	}
+	rewrite, ok := e.rewrite(userset)
+	if !ok {
+		return tree, nil
+	}
+
+	tuples, err := e.find(&repositories.TupleQuery{
+		Namespace: userset.Namespace,
+		ObjectID:  userset.ObjectID,
+		Relation:  userset.Relation,
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i := range tuples {
+		tree.Subjects = append(tree.Subjects, tuples[i].Subject().String())
+	}
+
+	for _, rule := range rewrite.TupleToUsersets {
+		tuples, err := e.find(&repositories.TupleQuery{
+			Namespace: userset.Namespace,
+			ObjectID:  userset.ObjectID,
+			Relation:  rule.Tupleset,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for i := range tuples {
+			related := models.RelationSubject{Namespace: tuples[i].SubjectNamespace, ObjectID: tuples[i].SubjectObjectID, Relation: rule.ComputedUserset}
+			tree.Subjects = append(tree.Subjects, related.String())
+		}
+	}
+
+	for _, relation := range rewrite.ComputedUsersets {
+		computed := models.RelationSubject{Namespace: userset.Namespace, ObjectID: userset.ObjectID, Relation: relation}
+		if e.visited[computed] {
+			continue
+		}
+		child, err := e.expand(computed, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		tree.Children = append(tree.Children, *child)
+	}
+	return tree, nil
+}
+
+// reachable returns the usersets the subject is in, searching from the subject towards the
+// objects: through the tuples naming it or a userset it is in, the computed usersets including
+// those, and the tuple-to-userset rules following tuples to objects related to them
+func (e *relationEvaluator) reachable(subject models.RelationSubject) (map[models.RelationSubject]bool, error) {
+	reached := make(map[models.RelationSubject]bool)
+	var queue []models.RelationSubject
+	var reach func(userset models.RelationSubject)
+	reach = func(userset models.RelationSubject) {
+		if reached[userset] {
+			return
+		}
+		reached[userset] = true
+		queue = append(queue, userset)
+		if namespace, ok := e.namespaces[userset.Namespace]; ok {
+			for relation, rewrite := range namespace.Relations {
+				if slices.Contains(rewrite.ComputedUsersets, userset.Relation) {
+					reach(models.RelationSubject{Namespace: userset.Namespace, ObjectID: userset.ObjectID, Relation: relation})
+				}
+			}
+		}
+	}
+	if subject.Relation != "" {
+		reach(subject)
+	} else {
+		queue = append(queue, subject)
+	}
+
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+
+		tuples, err := e.find(&repositories.TupleQuery{
+			SubjectNamespace: current.Namespace,
+			SubjectObjectID:  current.ObjectID,
+			SubjectRelation:  &current.Relation,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for i := range tuples {
+			reach(models.RelationSubject{Namespace: tuples[i].Namespace, ObjectID: tuples[i].ObjectID, Relation: tuples[i].Relation})
+		}
+
+		if current.Relation == "" {
+			continue
+		}
+		for _, namespace := range e.namespaces {
+			for relation, rewrite := range namespace.Relations {
+				for _, rule := range rewrite.TupleToUsersets {
+					if rule.ComputedUserset != current.Relation {
+						continue
+					}
+					tuples, err := e.find(&repositories.TupleQuery{
+						Namespace:        namespace.Name,
+						Relation:         rule.Tupleset,
+						SubjectNamespace: current.Namespace,
+						SubjectObjectID:  current.ObjectID,
+					})
+					if err != nil {
+						return nil, err
+					}
+					for i := range tuples {
+						reach(models.RelationSubject{Namespace: namespace.Name, ObjectID: tuples[i].ObjectID, Relation: relation})
+					}
+				}
+			}
+		}
+	}
+	return reached, nil
+}
+
+// validateRelations checks relation names and that rewrites refer to relations of the
+// namespace. The computed usersets of tuple-to-userset rules are relations of the objects
+// followed to, which may be in any namespace.
+func validateRelations(relations map[string]models.RelationRewrite) error {
+	if len(relations) == 0 || len(relations) > maxNamespaceRelations {
+		return fmt.Errorf("%w: a namespace has 1 to %d relations", ErrInvalidNamespace, maxNamespaceRelations)
+	}
+	for name, rewrite := range relations {
+		if !models.ValidRelationName(name) {
+			return fmt.Errorf("%w: relation %q must be up to 64 lowercase letters, digits or underscores, starting with a letter", ErrInvalidNamespace, name)
+		}
+		for _, computed := range rewrite.ComputedUsersets {
+			if _, ok := relations[computed]; !ok || computed == name {
+				return fmt.Errorf("%w: relation %s computes unknown relation %q", ErrInvalidNamespace, name, computed)
+			}
+		}
+		for _, rule := range rewrite.TupleToUsersets {
+			if _, ok := relations[rule.Tupleset]; !ok {
+				return fmt.Errorf("%w: relation %s follows unknown relation %q", ErrInvalidNamespace, name, rule.Tupleset)
+			}
+			if !models.ValidRelationName(rule.ComputedUserset) {
+				return fmt.Errorf("%w: relation %s computes invalid relation %q", ErrInvalidNamespace, name, rule.ComputedUserset)
+			}
+		}
+	}
+	return nil
+}
+
+// checkRelation returns ErrUnknownNamespace or ErrUnknownRelation unless the namespace is
+// configured with the relation
+func checkRelation(namespaces map[string]*models.RelationNamespace, namespace, relation string) error {
+	configured, ok := namespaces[namespace]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownNamespace, namespace)
+	}
+	if _, ok := configured.Relations[relation]; !ok {
+		return fmt.Errorf("%w: %s#%s", ErrUnknownRelation, namespace, relation)
+	}
+	return nil
+}
+
+// parseRelationship parses the object and subject of a relationship into a tuple
+func parseRelationship(relationship models.Relationship) (*models.RelationTuple, error) {
+	object, ok := models.ParseRelationObject(relationship.Object)
+	if !ok {
+		return nil, fmt.Errorf("%w: object %q", ErrInvalidRelationship, relationship.Object)
+	}
+	if !models.ValidRelationName(relationship.Relation) {
+		return nil, fmt.Errorf("%w: relation %q", ErrInvalidRelationship, relationship.Relation)
+	}
+	subject, ok := models.ParseRelationSubject(relationship.Subject)
+	if !ok {
+		return nil, fmt.Errorf("%w: subject %q", ErrInvalidRelationship, relationship.Subject)
+	}
+	return &models.RelationTuple{
+		Namespace:        object.Namespace,
+		ObjectID:         object.ObjectID,
+		Relation:         relationship.Relation,
+		SubjectNamespace: subject.Namespace,
+		SubjectObjectID:  subject.ObjectID,
+		SubjectRelation:  subject.Relation,
+	}, nil
+}
+
+// relationToken encodes a revision of the tenant's tuples as an opaque consistency token
+func relationToken(tenantID uuid.UUID, revision int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(tenantID.String() + "." + strconv.FormatInt(revision, 10)))
+}
+
+// parseRelationToken returns the revision of a consistency token issued for the tenant
+func parseRelationToken(tenantID uuid.UUID, token string) (int64, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return 0, ErrInvalidConsistencyToken
+	}
+	tokenTenant, revision, ok := strings.Cut(string(decoded), ".")
+	if !ok || tokenTenant != tenantID.String() {
+		return 0, ErrInvalidConsistencyToken
+	}
+	parsed, err := strconv.ParseInt(revision, 10, 64)
+	if err != nil || parsed < 0 {
+		return 0, ErrInvalidConsistencyToken
+	}
+	return parsed, nil
+}
@@ -0,0 +1,304 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"identity-service/internal/models"
+	"identity-service/internal/repositories"
+	"net"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Audit log actions recorded for roles
+const (
+	auditRoleCreated   = "role.created"
+	auditRoleUpdated   = "role.updated"
+	auditRoleDeleted   = "role.deleted"
+	auditRolesAssigned = "member.roles_assigned"
+)
+
+var (
+	ErrInvalidRoleName   = errors.New("role name must be up to 50 letters, digits or ._:- characters")
+	ErrBuiltinRoleName   = errors.New("role name is taken by a built-in role")
+	ErrRoleExists        = errors.New("a role with this name already exists")
+	ErrInvalidPermission = errors.New("invalid permission")
+	ErrInvalidConditions = errors.New("invalid role conditions")
+	ErrRoleInUse         = errors.New("role is held by members or groups of the tenant")
+	ErrUnknownRole       = errors.New("unknown role")
+
+	roleNamePattern = regexp.MustCompile(`^[A-Za-z0-9_.:-]{1,50}$`)
+)
+
+// builtinRoleDescriptions describes the built-in roles when they are listed
+var builtinRoleDescriptions = map[string]string{
+	models.TenantRoleOwner:  "Full access, including deleting the tenant and changing its plan",
+	models.TenantRoleAdmin:  "Manages the tenant, its members and its security settings",
+	models.TenantRoleMember: "Views the tenant and its members",
+	models.TenantRoleViewer: "Views the tenant",
+}
+
+// RoleService manages the custom roles a tenant defines alongside the built-in roles
+type RoleService interface {
+	// ListRoles returns the built-in roles followed by the tenant's custom roles
+	ListRoles(tenantID uuid.UUID) ([]models.TenantRole, error)
+	GetRole(tenantID, roleID uuid.UUID) (*models.TenantRole, error)
+	CreateRole(tenantID uuid.UUID, request *models.TenantRoleRequest, actorID uuid.UUID) (*models.TenantRole, error)
+	UpdateRole(tenantID, roleID uuid.UUID, update *models.TenantRoleUpdate, actorID uuid.UUID) (*models.TenantRole, error)
+	// DeleteRole deletes a custom role, unless members or groups of the tenant hold it
+	DeleteRole(tenantID, roleID, actorID uuid.UUID) error
+	// RolePermissions returns the permissions roles grant in the tenant under any conditions, or
+	// ErrUnknownRole if one of them is neither built in nor defined by the tenant
+	RolePermissions(tenantID uuid.UUID, roles []string) ([]string, error)
+	// RecordAssignment audits giving a member of the tenant roles
+	RecordAssignment(tenantID, userID uuid.UUID, roles []string, actorID uuid.UUID)
+}
+
+type roleService struct {
+	repo         repositories.RoleRepository
+	securityRepo repositories.SecurityRepository
+}
+
+func NewRoleService(repo repositories.RoleRepository, securityRepo repositories.SecurityRepository) RoleService {
+	return &roleService{
+		repo:         repo,
+		securityRepo: securityRepo,
+	}
+}
+
+func (s *roleService) ListRoles(tenantID uuid.UUID) ([]models.TenantRole, error) {
+	custom, err := s.repo.ListRoles(tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	roles := make([]models.TenantRole, 0, len(models.BuiltinRoles)+len(custom))
+	for _, name := range []string{models.TenantRoleOwner, models.TenantRoleAdmin, models.TenantRoleMember, models.TenantRoleViewer} {
+		roles = append(roles, models.TenantRole{
+			Name:        name,
+			Description: builtinRoleDescriptions[name],
+			Permissions: models.BuiltinRoles[name],
+			BuiltIn:     true,
+		})
+	}
+	return append(roles, custom...), nil
+}
+
+func (s *roleService) GetRole(tenantID, roleID uuid.UUID) (*models.TenantRole, error) {
+	return s.repo.GetRole(tenantID, roleID)
+}
+
+func (s *roleService) CreateRole(tenantID uuid.UUID, request *models.TenantRoleRequest, actorID uuid.UUID) (*models.TenantRole, error) {
+	name := strings.TrimSpace(request.Name)
+	if !roleNamePattern.MatchString(name) {
+		return nil, ErrInvalidRoleName
+	}
+	for builtin := range models.BuiltinRoles {
+		if strings.EqualFold(name, builtin) {
+			return nil, ErrBuiltinRoleName
+		}
+	}
+	existing, err := s.repo.ListRoles(tenantID)
+	if err != nil {
+		return nil, err
+	}
+	for _, role := range existing {
+		if strings.EqualFold(name, role.Name) {
+			return nil, ErrRoleExists
+		}
+	}
+	permissions, err := normalizePermissions(request.Permissions)
+	if err != nil {
+		return nil, err
+	}
+	conditions, err := normalizeConditions(request.Conditions)
+	if err != nil {
+		return nil, err
+	}
+
+	role := &models.TenantRole{
+		ID:          uuid.New(),
+		TenantID:    tenantID,
+		Name:        name,
+		Description: request.Description,
+		Permissions: permissions,
+		Conditions:  conditions,
+	}
+	if err := s.repo.CreateRole(role); err != nil {
+		return nil, err
+	}
+	s.audit(tenantID, actorID, auditRoleCreated, role, map[string]string{
+		"name":        role.Name,
+		"permissions": strings.Join(role.Permissions, " "),
+		"conditions":  describeConditions(role.Conditions),
+	})
+	return role, nil
+}
+
+func (s *roleService) UpdateRole(tenantID, roleID uuid.UUID, update *models.TenantRoleUpdate, actorID uuid.UUID) (*models.TenantRole, error) {
+	role, err := s.repo.GetRole(tenantID, roleID)
+	if err != nil {
+		return nil, err
+	}
+
+	details := map[string]string{"name": role.Name}
+	if update.Description != nil {
+		role.Description = *update.Description
+		details["description"] = role.Description
+	}
+	if update.Permissions != nil {
+		permissions, err := normalizePermissions(update.Permissions)
+		if err != nil {
+			return nil, err
+		}
+		details["previousPermissions"] = strings.Join(role.Permissions, " ")
+		details["permissions"] = strings.Join(permissions, " ")
+		role.Permissions = permissions
+	}
+	if update.Conditions != nil {
+		conditions, err := normalizeConditions(update.Conditions)
+		if err != nil {
+			return nil, err
+		}
+		details["conditions"] = describeConditions(conditions)
+		role.Conditions = conditions
+	}
+	if err := s.repo.SaveRole(role); err != nil {
+		return nil, err
+	}
+	s.audit(tenantID, actorID, auditRoleUpdated, role, details)
+	return role, nil
+}
+
+func (s *roleService) DeleteRole(tenantID, roleID, actorID uuid.UUID) error {
+	role, err := s.repo.GetRole(tenantID, roleID)
+	if err != nil {
+		return err
+	}
+	members, err := s.repo.CountRoleMembers(tenantID, role.Name)
+	if err != nil {
+		return err
+	}
+	groups, err := s.repo.CountRoleGroups(tenantID, role.Name)
+	if err != nil {
+		return err
+	}
+	if members > 0 || groups > 0 {
+		return fmt.Errorf("%w: %d members, %d groups", ErrRoleInUse, members, groups)
+	}
+
+	if err := s.repo.DeleteRole(tenantID, roleID); err != nil {
+		return err
+	}
+	s.audit(tenantID, actorID, auditRoleDeleted, role, map[string]string{
+		"name":        role.Name,
+		"permissions": strings.Join(role.Permissions, " "),
+	})
+	return nil
+}
+
+func (s *roleService) RolePermissions(tenantID uuid.UUID, roles []string) ([]string, error) {
+	var custom []string
+	for _, role := range roles {
+		if _, ok := models.BuiltinRoles[role]; !ok {
+			custom = append(custom, role)
+		}
+	}
+
+	var customRoles []models.TenantRole
+	if len(custom) > 0 {
+		var err error
+		customRoles, err = s.repo.GetRolesByName(tenantID, custom)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, name := range custom {
+		found := false
+		for _, role := range customRoles {
+			found = found || role.Name == name
+		}
+		if !found {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownRole, name)
+		}
+	}
+
+	return models.EffectivePermissions(roles, nil, customRoles), nil
+}
+
+func (s *roleService) RecordAssignment(tenantID, userID uuid.UUID, roles []string, actorID uuid.UUID) {
+	recordAudit(s.securityRepo, tenantID, actorID, auditRolesAssigned, "users/"+userID.String(), map[string]string{
+		"userId": userID.String(),
+		"roles":  strings.Join(roles, " "),
+	})
+}
+
+func (s *roleService) audit(tenantID, actorID uuid.UUID, action string, role *models.TenantRole, details map[string]string) {
+	recordAudit(s.securityRepo, tenantID, actorID, action, "roles/"+role.ID.String(), details)
+}
+
+// normalizePermissions checks every permission is known and removes duplicates, sorting the
+// rest
+func normalizePermissions(permissions []string) ([]string, error) {
+	seen := make(map[string]bool)
+	normalized := make([]string, 0, len(permissions))
+	for _, permission := range permissions {
+		permission = strings.TrimSpace(permission)
+		if !models.ValidPermission(permission) {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidPermission, permission)
+		}
+		if !seen[permission] {
+			seen[permission] = true
+			normalized = append(normalized, permission)
+		}
+	}
+	sort.Strings(normalized)
+	return normalized, nil
+}
+
+// normalizeConditions checks the IP ranges parse and drops empty plans, returning nil for
+// conditions that restrict nothing
+func normalizeConditions(conditions *models.RoleConditions) (*models.RoleConditions, error) {
+	if conditions.Empty() {
+		return nil, nil
+	}
+
+	normalized := &models.RoleConditions{ResourceOwner: conditions.ResourceOwner}
+	for _, plan := range conditions.Plans {
+		if plan = strings.TrimSpace(plan); plan != "" {
+			normalized.Plans = append(normalized.Plans, plan)
+		}
+	}
+	for _, ipRange := range conditions.IPRanges {
+		ipRange = strings.TrimSpace(ipRange)
+		if _, _, err := net.ParseCIDR(ipRange); err != nil && net.ParseIP(ipRange) == nil {
+			return nil, fmt.Errorf("%w: %q is not an IP address or CIDR", ErrInvalidConditions, ipRange)
+		}
+		normalized.IPRanges = append(normalized.IPRanges, ipRange)
+	}
+	if normalized.Empty() {
+		return nil, nil
+	}
+	return normalized, nil
+}
+
+// describeConditions summarizes conditions for the audit log
+func describeConditions(conditions *models.RoleConditions) string {
+	if conditions.Empty() {
+		return "none"
+	}
+	var parts []string
+	if conditions.ResourceOwner {
+		parts = append(parts, "resource owner")
+	}
+	if len(conditions.Plans) > 0 {
+		parts = append(parts, "plans "+strings.Join(conditions.Plans, ","))
+	}
+	if len(conditions.IPRanges) > 0 {
+		parts = append(parts, "IPs "+strings.Join(conditions.IPRanges, ","))
+	}
+	return strings.Join(parts, "; ")
+}
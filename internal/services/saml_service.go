@@ -0,0 +1,409 @@
+package services
+
+import (
+	"context"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"identity-service/config"
+	"identity-service/internal/models"
+	"identity-service/internal/repositories"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/crewjam/saml"
+	"github.com/crewjam/saml/samlsp"
+	"github.com/google/uuid"
+	dsig "github.com/russellhaering/goxmldsig"
+)
+
+const (
+	// ssoFeature is the tenant feature enterprise plans get for single sign-on and provisioning
+	ssoFeature = "sso"
+	// ssoMemberRole is given to users who join a tenant through its identity provider
+	ssoMemberRole = models.TenantRoleMember
+	// samlMetadataTimeout bounds fetching IdP metadata from a URL
+	samlMetadataTimeout = 10 * time.Second
+	// samlMaxMetadataSize bounds the IdP metadata document we accept
+	samlMaxMetadataSize = 1 << 20
+)
+
+var (
+	ErrSSONotEnabled         = errors.New("single sign-on is not enabled for this tenant")
+	ErrSAMLNotConfigured     = errors.New("SAML is not configured for this tenant")
+	ErrInvalidIdPMetadata    = errors.New("invalid identity provider metadata")
+	ErrIdPInitiatedDisabled  = errors.New("IdP-initiated login is disabled for this tenant")
+	ErrInvalidSAMLResponse   = errors.New("invalid SAML response")
+	ErrSAMLEmailNotInDomain  = errors.New("asserted email is not in the tenant's verified domain")
+	ErrSAMLMissingAttributes = errors.New("SAML assertion has no email")
+)
+
+// SAMLService lets enterprise tenants sign their users in through a SAML 2.0 identity
+// provider. This service acts as the service provider, with one entity per tenant.
+type SAMLService interface {
+	GetConnection(tenantID uuid.UUID) (*models.SAMLConnection, error)
+	SaveConnection(tenantID uuid.UUID, update *models.SAMLConnectionUpdate) (*models.SAMLConnection, error)
+	DeleteConnection(tenantID uuid.UUID) error
+	Metadata(tenantID uuid.UUID) ([]byte, error)
+	AuthnRequestURL(tenantID uuid.UUID, relayState string) (string, error)
+	Authenticate(tenantID uuid.UUID, r *http.Request, relayState string) (*models.User, error)
+}
+
+type samlService struct {
+	repo        repositories.SAMLConnectionRepository
+	tenantRepo  repositories.TenantRepository
+	userService UserService
+	key         *rsa.PrivateKey
+	cert        *x509.Certificate
+	httpClient  *http.Client
+}
+
+// NewSAMLService creates a SAML service provider that signs AuthnRequests with key and
+// publishes cert in its metadata
+func NewSAMLService(
+	repo repositories.SAMLConnectionRepository,
+	tenantRepo repositories.TenantRepository,
+	userService UserService,
+	key *rsa.PrivateKey,
+	cert *x509.Certificate,
+) SAMLService {
+	return &samlService{
+		repo:        repo,
+		tenantRepo:  tenantRepo,
+		userService: userService,
+		key:         key,
+		cert:        cert,
+		httpClient:  &http.Client{Timeout: samlMetadataTimeout},
+	}
+}
+
+func (s *samlService) GetConnection(tenantID uuid.UUID) (*models.SAMLConnection, error) {
+	return s.repo.GetConnection(tenantID)
+}
+
+// SaveConnection creates or updates the tenant's identity provider. Metadata given by URL
+// is fetched again on every save, so saving refreshes a rotated IdP certificate.
+func (s *samlService) SaveConnection(tenantID uuid.UUID, update *models.SAMLConnectionUpdate) (*models.SAMLConnection, error) {
+	tenant, err := s.tenantRepo.GetTenantByID(tenantID)
+	if err != nil {
+		return nil, err
+	}
+	if !tenant.HasFeatures()[ssoFeature] {
+		return nil, ErrSSONotEnabled
+	}
+
+	connection, err := s.repo.GetConnection(tenantID)
+	if err != nil {
+		connection = &models.SAMLConnection{
+			ID:       uuid.New(),
+			TenantID: tenantID,
+			Enabled:  true,
+		}
+	}
+
+	if update.IdPMetadataURL != nil {
+		connection.IdPMetadataURL = *update.IdPMetadataURL
+	}
+	if update.IdPMetadata != nil {
+		connection.IdPMetadata = *update.IdPMetadata
+		connection.IdPMetadataURL = ""
+	}
+	if update.AttributeMapping != nil {
+		connection.AttributeMapping = *update.AttributeMapping
+	}
+	if update.AllowIdPInitiated != nil {
+		connection.AllowIdPInitiated = *update.AllowIdPInitiated
+	}
+	if update.Enabled != nil {
+		connection.Enabled = *update.Enabled
+	}
+
+	if connection.IdPMetadataURL != "" {
+		data, err := s.fetchMetadata(connection.IdPMetadataURL)
+		if err != nil {
+			return nil, err
+		}
+		connection.IdPMetadata = string(data)
+	}
+
+	idp, err := parseIdPMetadata(connection.IdPMetadata)
+	if err != nil {
+		return nil, err
+	}
+	connection.IdPEntityID = idp.EntityID
+
+	if err := s.repo.SaveConnection(connection); err != nil {
+		return nil, err
+	}
+	return connection, nil
+}
+
+func (s *samlService) DeleteConnection(tenantID uuid.UUID) error {
+	return s.repo.DeleteConnection(tenantID)
+}
+
+// Metadata returns the tenant's SP metadata. It is available before the IdP is configured,
+// since the IdP needs it first.
+func (s *samlService) Metadata(tenantID uuid.UUID) ([]byte, error) {
+	if _, err := s.tenantRepo.GetTenantByID(tenantID); err != nil {
+		return nil, err
+	}
+
+	metadata := s.serviceProvider(tenantID, nil).Metadata()
+	// Only the HTTP-POST binding is supported at the ACS
+	for i := range metadata.SPSSODescriptors {
+		descriptor := &metadata.SPSSODescriptors[i]
+		acs := descriptor.AssertionConsumerServices[:0]
+		for _, endpoint := range descriptor.AssertionConsumerServices {
+			if endpoint.Binding == saml.HTTPPostBinding {
+				acs = append(acs, endpoint)
+			}
+		}
+		descriptor.AssertionConsumerServices = acs
+	}
+
+	data, err := xml.MarshalIndent(metadata, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), data...), nil
+}
+
+// AuthnRequestURL returns the IdP URL carrying a signed AuthnRequest over the HTTP-Redirect
+// binding. The request ID is derived from the relay state, which the ACS gets back.
+func (s *samlService) AuthnRequestURL(tenantID uuid.UUID, relayState string) (string, error) {
+	connection, idp, err := s.enabledConnection(tenantID)
+	if err != nil {
+		return "", err
+	}
+
+	sp := s.serviceProvider(connection.TenantID, idp)
+	location := sp.GetSSOBindingLocation(saml.HTTPRedirectBinding)
+	if location == "" {
+		return "", ErrInvalidIdPMetadata
+	}
+
+	req, err := sp.MakeAuthenticationRequest(location, saml.HTTPRedirectBinding, saml.HTTPPostBinding)
+	if err != nil {
+		return "", err
+	}
+	req.ID = samlRequestID(relayState)
+
+	target, err := req.Redirect(relayState, sp)
+	if err != nil {
+		return "", err
+	}
+	return target.String(), nil
+}
+
+// Authenticate validates a SAML response posted to the tenant's ACS and returns the user it
+// asserts, creating the user and their tenant membership on first login. An empty relay
+// state means the response is IdP-initiated.
+func (s *samlService) Authenticate(tenantID uuid.UUID, r *http.Request, relayState string) (*models.User, error) {
+	connection, idp, err := s.enabledConnection(tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	sp := s.serviceProvider(tenantID, idp)
+	var requestIDs []string
+	if relayState != "" {
+		requestIDs = []string{samlRequestID(relayState)}
+	} else {
+		if !connection.AllowIdPInitiated {
+			return nil, ErrIdPInitiatedDisabled
+		}
+		sp.AllowIDPInitiated = true
+	}
+
+	// Only the HTTP-POST binding is advertised, so artifacts are not resolved
+	if err := r.ParseForm(); err != nil || r.Form.Get("SAMLart") != "" {
+		return nil, ErrInvalidSAMLResponse
+	}
+
+	// ParseResponse checks the signature, issuer, audience, recipient, destination and validity window
+	assertion, err := sp.ParseResponse(r, requestIDs)
+	if err != nil {
+		var invalid *saml.InvalidResponseError
+		if errors.As(err, &invalid) {
+			err = invalid.PrivateErr
+		}
+		log.Printf("Rejected SAML response for tenant %s: %v", tenantID, err)
+		return nil, ErrInvalidSAMLResponse
+	}
+
+	nameID := ""
+	if assertion.Subject != nil && assertion.Subject.NameID != nil {
+		nameID = assertion.Subject.NameID.Value
+	}
+	email := nameID
+	if connection.AttributeMapping.Email != "" {
+		email = assertionAttribute(assertion, connection.AttributeMapping.Email)
+	}
+	email = strings.ToLower(strings.TrimSpace(email))
+	if email == "" || !strings.Contains(email, "@") {
+		return nil, ErrSAMLMissingAttributes
+	}
+	name := assertionAttribute(assertion, connection.AttributeMapping.Name)
+	if name == "" {
+		name = email
+	}
+
+	// Users are matched by email, so a tenant's IdP may only assert addresses in a domain
+	// the tenant has proven it owns
+	tenant, err := s.tenantRepo.GetTenantByID(tenantID)
+	if err != nil {
+		return nil, err
+	}
+	if !emailInVerifiedDomain(tenant, email) {
+		return nil, ErrSAMLEmailNotInDomain
+	}
+
+	user, err := s.userService.CreateOrUpdateUser(&models.OAuthUser{
+		ID:            nameID,
+		Email:         email,
+		VerifiedEmail: true,
+		Name:          name,
+		Provider:      models.SAMLProvider,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.tenantRepo.GetUserTenantAccess(user.ID, tenantID); err != nil {
+		if err := s.userService.AddUserToTenant(user.ID, tenantID, []string{ssoMemberRole}); err != nil {
+			return nil, err
+		}
+	}
+
+	return user, nil
+}
+
+// emailInVerifiedDomain reports whether email is an address in the tenant's verified domain
+func emailInVerifiedDomain(tenant *models.Tenant, email string) bool {
+	at := strings.LastIndex(email, "@")
+	return tenant.DomainVerified && at >= 0 && strings.EqualFold(email[at+1:], tenant.Domain)
+}
+
+// enabledConnection returns the tenant's connection and parsed IdP metadata, if SSO is
+// still part of the tenant's plan
+func (s *samlService) enabledConnection(tenantID uuid.UUID) (*models.SAMLConnection, *saml.EntityDescriptor, error) {
+	tenant, err := s.tenantRepo.GetTenantByID(tenantID)
+	if err != nil {
+		return nil, nil, ErrSAMLNotConfigured
+	}
+	if !tenant.HasFeatures()[ssoFeature] {
+		return nil, nil, ErrSSONotEnabled
+	}
+
+	connection, err := s.repo.GetConnection(tenantID)
+	if err != nil || !connection.Enabled {
+		return nil, nil, ErrSAMLNotConfigured
+	}
+
+	idp, err := parseIdPMetadata(connection.IdPMetadata)
+	if err != nil {
+		return nil, nil, err
+	}
+	return connection, idp, nil
+}
+
+// serviceProvider describes this service as the SP of a tenant. Each tenant is its own
+// entity, identified by its metadata URL.
+func (s *samlService) serviceProvider(tenantID uuid.UUID, idp *saml.EntityDescriptor) *saml.ServiceProvider {
+	base := fmt.Sprintf("%s/api/auth/saml/%s", config.AuthServer.Issuer, tenantID)
+	metadataURL, _ := url.Parse(base + "/metadata")
+	acsURL, _ := url.Parse(base + "/acs")
+
+	return &saml.ServiceProvider{
+		EntityID:          metadataURL.String(),
+		Key:               s.key,
+		Certificate:       s.cert,
+		MetadataURL:       *metadataURL,
+		AcsURL:            *acsURL,
+		IDPMetadata:       idp,
+		AuthnNameIDFormat: saml.UnspecifiedNameIDFormat,
+		SignatureMethod:   dsig.RSASHA256SignatureMethod,
+	}
+}
+
+// fetchMetadata downloads IdP metadata. Only HTTPS is allowed, since the metadata carries
+// the certificate we trust assertions from.
+func (s *samlService) fetchMetadata(metadataURL string) ([]byte, error) {
+	parsed, err := url.Parse(metadataURL)
+	if err != nil || parsed.Scheme != "https" || parsed.Host == "" {
+		return nil, fmt.Errorf("%w: metadata URL must be an https URL", ErrInvalidIdPMetadata)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), samlMetadataTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, parsed.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidIdPMetadata, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: metadata URL returned %d", ErrInvalidIdPMetadata, resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, samlMaxMetadataSize))
+}
+
+// parseIdPMetadata parses metadata XML and checks it describes an IdP we can send users to
+func parseIdPMetadata(metadata string) (*saml.EntityDescriptor, error) {
+	if metadata == "" {
+		return nil, fmt.Errorf("%w: metadata or metadata URL is required", ErrInvalidIdPMetadata)
+	}
+	idp, err := samlsp.ParseMetadata([]byte(metadata))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidIdPMetadata, err)
+	}
+	if idp.EntityID == "" || len(idp.IDPSSODescriptors) == 0 {
+		return nil, fmt.Errorf("%w: no IDPSSODescriptor", ErrInvalidIdPMetadata)
+	}
+
+	for _, descriptor := range idp.IDPSSODescriptors {
+		for _, endpoint := range descriptor.SingleSignOnServices {
+			if endpoint.Binding == saml.HTTPRedirectBinding {
+				return idp, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("%w: no HTTP-Redirect SingleSignOnService", ErrInvalidIdPMetadata)
+}
+
+// samlRequestID derives the AuthnRequest ID from the relay state. IDs must be XML NCNames,
+// which the base64url relay state is once prefixed.
+func samlRequestID(relayState string) string {
+	return "id-" + relayState
+}
+
+// assertionAttribute returns the first value of an attribute, matched by name or friendly name
+func assertionAttribute(assertion *saml.Assertion, name string) string {
+	if name == "" {
+		return ""
+	}
+	for _, statement := range assertion.AttributeStatements {
+		for _, attribute := range statement.Attributes {
+			if attribute.Name != name && attribute.FriendlyName != name {
+				continue
+			}
+			for _, value := range attribute.Values {
+				if value.Value != "" {
+					return strings.TrimSpace(value.Value)
+				}
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,292 @@
+package services
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html"
+	"identity-service/config"
+	"identity-service/internal/auth"
+	"identity-service/internal/models"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/crewjam/saml"
+	"github.com/crewjam/saml/logger"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const samlTestRelayState = "c2FtbC10ZXN0LXJlbGF5"
+
+var samlResponseField = regexp.MustCompile(`name="SAMLResponse" value="([^"]*)"`)
+
+type fakeSAMLConnectionRepository struct {
+	connections map[uuid.UUID]*models.SAMLConnection
+}
+
+func (r *fakeSAMLConnectionRepository) GetConnection(tenantID uuid.UUID) (*models.SAMLConnection, error) {
+	connection, ok := r.connections[tenantID]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return connection, nil
+}
+
+func (r *fakeSAMLConnectionRepository) SaveConnection(connection *models.SAMLConnection) error {
+	r.connections[connection.TenantID] = connection
+	return nil
+}
+
+func (r *fakeSAMLConnectionRepository) DeleteConnection(tenantID uuid.UUID) error {
+	delete(r.connections, tenantID)
+	return nil
+}
+
+// samlServiceProviders gives the in-process IdP the metadata of the tenant's SP, with the
+// entity ID overridden when set
+type samlServiceProviders struct {
+	metadata *saml.EntityDescriptor
+	entityID string
+}
+
+func (p *samlServiceProviders) GetServiceProvider(r *http.Request, serviceProviderID string) (*saml.EntityDescriptor, error) {
+	metadata := *p.metadata
+	if p.entityID != "" {
+		metadata.EntityID = p.entityID
+	}
+	return &metadata, nil
+}
+
+// samlSessions signs every request in as the same IdP user
+type samlSessions struct {
+	email string
+	name  string
+}
+
+func (p *samlSessions) GetSession(w http.ResponseWriter, r *http.Request, req *saml.IdpAuthnRequest) *saml.Session {
+	now := saml.TimeNow()
+	return &saml.Session{
+		ID:             uuid.NewString(),
+		CreateTime:     now,
+		ExpireTime:     now.Add(time.Hour),
+		NameID:         p.email,
+		UserEmail:      p.email,
+		UserCommonName: p.name,
+	}
+}
+
+// samlFixture is a tenant whose connection trusts an identity provider running in-process
+type samlFixture struct {
+	service    SAMLService
+	tenants    *fakeTenantRepository
+	users      *fakeUserService
+	tenant     *models.Tenant
+	idp        *saml.IdentityProvider
+	providers  *samlServiceProviders
+	sessions   *samlSessions
+	connection *models.SAMLConnection
+}
+
+func newSAMLFixture(t *testing.T) *samlFixture {
+	t.Helper()
+	config.LoadAuthServerConfig()
+
+	spKey, spCert := samlKeyPair(t, "sp.example.com")
+	idpKey, idpCert := samlKeyPair(t, "idp.example.com")
+
+	f := &samlFixture{
+		tenants:   newFakeTenantRepository(),
+		providers: &samlServiceProviders{},
+		sessions:  &samlSessions{email: "jane@example.com", name: "Jane Doe"},
+	}
+	f.users = newFakeUserService(f.tenants)
+	f.tenant = f.tenants.addTenant(t, "example.com")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.idp.Handler().ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+	metadataURL, _ := url.Parse(server.URL + "/saml/metadata")
+	ssoURL, _ := url.Parse(server.URL + "/saml/sso")
+	f.idp = &saml.IdentityProvider{
+		Key:                     idpKey,
+		Certificate:             idpCert,
+		Logger:                  logger.DefaultLogger,
+		MetadataURL:             *metadataURL,
+		SSOURL:                  *ssoURL,
+		ServiceProviderProvider: f.providers,
+		SessionProvider:         f.sessions,
+	}
+
+	repo := &fakeSAMLConnectionRepository{connections: make(map[uuid.UUID]*models.SAMLConnection)}
+	f.service = NewSAMLService(repo, f.tenants, f.users, spKey, spCert)
+
+	idpMetadata, err := xml.Marshal(f.idp.Metadata())
+	if err != nil {
+		t.Fatalf("marshal IdP metadata: %v", err)
+	}
+	metadata := string(idpMetadata)
+	f.connection, err = f.service.SaveConnection(f.tenant.ID, &models.SAMLConnectionUpdate{IdPMetadata: &metadata})
+	if err != nil {
+		t.Fatalf("SaveConnection: %v", err)
+	}
+
+	spMetadata, err := f.service.Metadata(f.tenant.ID)
+	if err != nil {
+		t.Fatalf("Metadata: %v", err)
+	}
+	f.providers.metadata = &saml.EntityDescriptor{}
+	if err := xml.Unmarshal(spMetadata, f.providers.metadata); err != nil {
+		t.Fatalf("parse SP metadata: %v", err)
+	}
+	return f
+}
+
+func samlKeyPair(t *testing.T, commonName string) (*rsa.PrivateKey, *x509.Certificate) {
+	t.Helper()
+	key, cert, err := auth.GenerateSAMLKeyPair(commonName, 2048)
+	if err != nil {
+		t.Fatalf("GenerateSAMLKeyPair: %v", err)
+	}
+	return key, cert
+}
+
+// login sends the user to the IdP with an AuthnRequest and returns the SAML response the IdP
+// posts back
+func (f *samlFixture) login(t *testing.T) string {
+	t.Helper()
+	target, err := f.service.AuthnRequestURL(f.tenant.ID, samlTestRelayState)
+	if err != nil {
+		t.Fatalf("AuthnRequestURL: %v", err)
+	}
+	resp, err := http.Get(target)
+	if err != nil {
+		t.Fatalf("request IdP: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("IdP returned %d: %s", resp.StatusCode, body)
+	}
+
+	match := samlResponseField.FindSubmatch(body)
+	if match == nil {
+		t.Fatalf("IdP response has no SAMLResponse: %s", body)
+	}
+	return html.UnescapeString(string(match[1]))
+}
+
+// acs posts a SAML response to the tenant's assertion consumer service
+func (f *samlFixture) acs(samlResponse string) (*models.User, error) {
+	form := url.Values{"SAMLResponse": {samlResponse}, "RelayState": {samlTestRelayState}}
+	acsURL := fmt.Sprintf("%s/api/auth/saml/%s/acs", config.AuthServer.Issuer, f.tenant.ID)
+	req := httptest.NewRequest(http.MethodPost, acsURL, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return f.service.Authenticate(f.tenant.ID, req, samlTestRelayState)
+}
+
+func TestSAMLAuthenticateAcceptsSignedResponse(t *testing.T) {
+	f := newSAMLFixture(t)
+	f.connection.AttributeMapping = models.SAMLAttributeMapping{Email: "eduPersonPrincipalName", Name: "cn"}
+
+	user, err := f.acs(f.login(t))
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	if user.Email != "jane@example.com" || user.Name != "Jane Doe" || !user.EmailVerified {
+		t.Errorf("user = %s %q verified=%v, want jane@example.com \"Jane Doe\" verified", user.Email, user.Name, user.EmailVerified)
+	}
+	if roles := f.users.roles(user.ID, f.tenant.ID); len(roles) != 1 || roles[0] != ssoMemberRole {
+		t.Errorf("roles in tenant = %v, want [%s]", roles, ssoMemberRole)
+	}
+}
+
+func TestSAMLAuthenticateRejectsResponseSignedByAnotherKey(t *testing.T) {
+	f := newSAMLFixture(t)
+	// The connection still pins the certificate from the IdP's original metadata
+	f.idp.Key, f.idp.Certificate = samlKeyPair(t, "idp.example.com")
+
+	if _, err := f.acs(f.login(t)); !errors.Is(err, ErrInvalidSAMLResponse) {
+		t.Fatalf("Authenticate error = %v, want %v", err, ErrInvalidSAMLResponse)
+	}
+}
+
+func TestSAMLAuthenticateRejectsTamperedResponse(t *testing.T) {
+	f := newSAMLFixture(t)
+	response, err := base64.StdEncoding.DecodeString(f.login(t))
+	if err != nil {
+		t.Fatalf("decode SAML response: %v", err)
+	}
+	// Add a status message to the signed response, leaving it otherwise valid
+	success := `<samlp:StatusCode Value="urn:oasis:names:tc:SAML:2.0:status:Success"/>`
+	if !strings.Contains(string(response), success) {
+		t.Fatalf("SAML response has no success status: %s", response)
+	}
+	tampered := strings.Replace(string(response), success, success+"<samlp:StatusMessage>ok</samlp:StatusMessage>", 1)
+
+	if _, err := f.acs(base64.StdEncoding.EncodeToString([]byte(tampered))); !errors.Is(err, ErrInvalidSAMLResponse) {
+		t.Fatalf("Authenticate error = %v, want %v", err, ErrInvalidSAMLResponse)
+	}
+}
+
+func TestSAMLAuthenticateRejectsWrongAudience(t *testing.T) {
+	f := newSAMLFixture(t)
+	// The IdP issues the assertion to another service provider
+	f.providers.entityID = "https://other.example.com/saml/metadata"
+
+	if _, err := f.acs(f.login(t)); !errors.Is(err, ErrInvalidSAMLResponse) {
+		t.Fatalf("Authenticate error = %v, want %v", err, ErrInvalidSAMLResponse)
+	}
+}
+
+func TestSAMLAuthenticateRejectsExpiredResponse(t *testing.T) {
+	f := newSAMLFixture(t)
+	response := f.login(t)
+
+	// The response arrives after the assertion's validity window
+	now := saml.TimeNow
+	saml.TimeNow = func() time.Time { return now().Add(time.Hour) }
+	defer func() { saml.TimeNow = now }()
+
+	if _, err := f.acs(response); !errors.Is(err, ErrInvalidSAMLResponse) {
+		t.Fatalf("Authenticate error = %v, want %v", err, ErrInvalidSAMLResponse)
+	}
+}
+
+func TestSAMLAuthenticateRejectsEmailOutsideVerifiedDomain(t *testing.T) {
+	tests := []struct {
+		name   string
+		email  string
+		tenant func(tenant *models.Tenant)
+	}{
+		{name: "other domain", email: "jane@attacker.example", tenant: func(*models.Tenant) {}},
+		{name: "domain not verified", email: "jane@example.com", tenant: func(tenant *models.Tenant) {
+			tenant.DomainVerified = false
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newSAMLFixture(t)
+			f.sessions.email = tt.email
+			tt.tenant(f.tenant)
+
+			if _, err := f.acs(f.login(t)); !errors.Is(err, ErrSAMLEmailNotInDomain) {
+				t.Fatalf("Authenticate error = %v, want %v", err, ErrSAMLEmailNotInDomain)
+			}
+			if len(f.users.users) != 0 {
+				t.Errorf("created %d users, want none", len(f.users.users))
+			}
+		})
+	}
+}
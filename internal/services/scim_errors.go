@@ -0,0 +1,49 @@
+package services
+
+import "net/http"
+
+// Error types defined by RFC 7644 section 3.12
+const (
+	SCIMErrInvalidFilter = "invalidFilter"
+	SCIMErrUniqueness    = "uniqueness"
+	SCIMErrMutability    = "mutability"
+	SCIMErrInvalidSyntax = "invalidSyntax"
+	SCIMErrInvalidPath   = "invalidPath"
+	SCIMErrNoTarget      = "noTarget"
+	SCIMErrInvalidValue  = "invalidValue"
+)
+
+// SCIMError is an error that is reported to SCIM clients in the RFC 7644 format
+type SCIMError struct {
+	Status   int
+	ScimType string
+	Detail   string
+}
+
+func (e *SCIMError) Error() string {
+	if e.ScimType == "" {
+		return e.Detail
+	}
+	return e.ScimType + ": " + e.Detail
+}
+
+// NewSCIMError creates a SCIM error with the status code RFC 7644 prescribes for its type
+func NewSCIMError(scimType, detail string) *SCIMError {
+	status := http.StatusBadRequest
+	if scimType == SCIMErrUniqueness {
+		status = http.StatusConflict
+	}
+	return &SCIMError{
+		Status:   status,
+		ScimType: scimType,
+		Detail:   detail,
+	}
+}
+
+// AsSCIMError converts any error into a SCIM error, hiding the details of unexpected failures
+func AsSCIMError(err error) *SCIMError {
+	if scimErr, ok := err.(*SCIMError); ok {
+		return scimErr
+	}
+	return &SCIMError{Status: http.StatusInternalServerError, Detail: "internal error"}
+}
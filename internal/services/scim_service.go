@@ -0,0 +1,740 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"identity-service/config"
+	"identity-service/internal/models"
+	"identity-service/internal/repositories"
+	"identity-service/internal/scim"
+	"identity-service/pkg/utils"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	// scimTokenPrefix makes SCIM tokens recognizable, e.g. to secret scanners
+	scimTokenPrefix = "scim_"
+	scimTokenLength = 48
+)
+
+var ErrInvalidSCIMToken = errors.New("invalid SCIM token")
+
+// scimUserColumns are the user attributes that can be filtered on
+var scimUserColumns = map[string]scim.Column{
+	"id":                {Expr: "users.id::text", Type: scim.CaseExactColumn},
+	"username":          {Expr: "users.email", Type: scim.StringColumn},
+	"emails":            {Expr: "users.email", Type: scim.StringColumn},
+	"emails.value":      {Expr: "users.email", Type: scim.StringColumn},
+	"displayname":       {Expr: "users.name", Type: scim.StringColumn},
+	"name.formatted":    {Expr: "users.name", Type: scim.StringColumn},
+	"externalid":        {Expr: "user_tenant_access.external_id", Type: scim.CaseExactColumn},
+	"active":            {Expr: "user_tenant_access.active", Type: scim.BooleanColumn},
+	"roles":             {Expr: "user_tenant_access.roles", Type: scim.StringArrayColumn},
+	"roles.value":       {Expr: "user_tenant_access.roles", Type: scim.StringArrayColumn},
+	"meta.created":      {Expr: "user_tenant_access.created_at", Type: scim.DateTimeColumn},
+	"meta.lastmodified": {Expr: "user_tenant_access.updated_at", Type: scim.DateTimeColumn},
+}
+
+// scimGroupColumns are the group attributes that can be filtered on
+var scimGroupColumns = map[string]scim.Column{
+	"id":                {Expr: "scim_groups.id::text", Type: scim.CaseExactColumn},
+	"displayname":       {Expr: "scim_groups.display_name", Type: scim.StringColumn},
+	"externalid":        {Expr: "scim_groups.external_id", Type: scim.CaseExactColumn},
+	"meta.created":      {Expr: "scim_groups.created_at", Type: scim.DateTimeColumn},
+	"meta.lastmodified": {Expr: "scim_groups.updated_at", Type: scim.DateTimeColumn},
+}
+
+// SCIMService lets a tenant's identity provider provision its users and groups over SCIM 2.0.
+// SCIM users are the tenant's members: deactivating one suspends their membership, and
+// deleting one removes it, without touching the user's other tenants. Members of a SCIM
+// group hold the group's display name as a role in the tenant. Writes take the roles the
+// request's token may grant; other roles cannot be given, directly or through a group named
+// after them, and roles a user already holds outside them are left alone.
+type SCIMService interface {
+	CreateToken(tenantID uuid.UUID, req *models.SCIMTokenCreate) (*models.SCIMToken, string, error)
+	ListTokens(tenantID uuid.UUID) ([]*models.SCIMToken, error)
+	RevokeToken(tenantID, id uuid.UUID) error
+	AuthenticateToken(token string) (*models.SCIMToken, error)
+
+	ListUsers(tenantID uuid.UUID, filter string, startIndex, count int) (*scim.ListResponse, error)
+	GetUser(tenantID, id uuid.UUID) (*scim.User, error)
+	CreateUser(tenantID uuid.UUID, user *scim.User, grantable []string) (*scim.User, error)
+	ReplaceUser(tenantID, id uuid.UUID, user *scim.User, grantable []string) (*scim.User, error)
+	PatchUser(tenantID, id uuid.UUID, patch *scim.PatchRequest, grantable []string) (*scim.User, error)
+	DeleteUser(tenantID, id uuid.UUID) error
+
+	ListGroups(tenantID uuid.UUID, filter string, startIndex, count int) (*scim.ListResponse, error)
+	GetGroup(tenantID, id uuid.UUID) (*scim.Group, error)
+	CreateGroup(tenantID uuid.UUID, group *scim.Group, grantable []string) (*scim.Group, error)
+	ReplaceGroup(tenantID, id uuid.UUID, group *scim.Group, grantable []string) (*scim.Group, error)
+	PatchGroup(tenantID, id uuid.UUID, patch *scim.PatchRequest, grantable []string) (*scim.Group, error)
+	DeleteGroup(tenantID, id uuid.UUID) error
+}
+
+type scimService struct {
+	repo       repositories.SCIMRepository
+	tenantRepo repositories.TenantRepository
+	userRepo   repositories.UserRepository
+	roles      RoleService
+}
+
+func NewSCIMService(
+	repo repositories.SCIMRepository,
+	tenantRepo repositories.TenantRepository,
+	userRepo repositories.UserRepository,
+	roles RoleService,
+) SCIMService {
+	return &scimService{
+		repo:       repo,
+		tenantRepo: tenantRepo,
+		userRepo:   userRepo,
+		roles:      roles,
+	}
+}
+
+// CreateToken issues a bearer token for the tenant's identity provider, which may grant
+// req.Roles. Callers resolve the default roles and check the creator may grant them. The token
+// is only returned here; just its hash is stored.
+func (s *scimService) CreateToken(tenantID uuid.UUID, req *models.SCIMTokenCreate) (*models.SCIMToken, string, error) {
+	tenant, err := s.tenantRepo.GetTenantByID(tenantID)
+	if err != nil {
+		return nil, "", err
+	}
+	if !tenant.HasFeatures()[ssoFeature] {
+		return nil, "", ErrSSONotEnabled
+	}
+
+	secret := scimTokenPrefix + utils.GenerateRandomString(scimTokenLength)
+	token := &models.SCIMToken{
+		ID:        uuid.New(),
+		TenantID:  tenantID,
+		Name:      req.Name,
+		TokenHash: hashSCIMToken(secret),
+		Roles:     append([]string{}, req.Roles...),
+		CreatedAt: time.Now(),
+	}
+	if err := s.repo.CreateToken(token); err != nil {
+		return nil, "", err
+	}
+	return token, secret, nil
+}
+
+func (s *scimService) ListTokens(tenantID uuid.UUID) ([]*models.SCIMToken, error) {
+	return s.repo.ListTokens(tenantID)
+}
+
+func (s *scimService) RevokeToken(tenantID, id uuid.UUID) error {
+	return s.repo.RevokeToken(tenantID, id)
+}
+
+// AuthenticateToken returns the unrevoked token, as long as provisioning is still part of
+// the tenant's plan
+func (s *scimService) AuthenticateToken(secret string) (*models.SCIMToken, error) {
+	if !strings.HasPrefix(secret, scimTokenPrefix) {
+		return nil, ErrInvalidSCIMToken
+	}
+	token, err := s.repo.GetTokenByHash(hashSCIMToken(secret))
+	if err != nil || token.RevokedAt != nil {
+		return nil, ErrInvalidSCIMToken
+	}
+
+	tenant, err := s.tenantRepo.GetTenantByID(token.TenantID)
+	if err != nil {
+		return nil, ErrInvalidSCIMToken
+	}
+	if !tenant.HasFeatures()[ssoFeature] {
+		return nil, ErrSSONotEnabled
+	}
+
+	if err := s.repo.TouchToken(token.ID, time.Now()); err != nil {
+		log.Printf("Failed to record use of SCIM token %s: %v", token.ID, err)
+	}
+	return token, nil
+}
+
+func (s *scimService) ListUsers(tenantID uuid.UUID, filter string, startIndex, count int) (*scim.ListResponse, error) {
+	where, args, err := scimFilterSQL(filter, scimUserColumns)
+	if err != nil {
+		return nil, err
+	}
+
+	members, total, err := s.repo.ListMembers(tenantID, where, args, startIndex-1, count)
+	if err != nil {
+		return nil, err
+	}
+
+	groups, err := s.groupsByName(tenantID, members...)
+	if err != nil {
+		return nil, err
+	}
+	resources := make([]interface{}, 0, len(members))
+	for _, member := range members {
+		resources = append(resources, scimUserResource(member, groups))
+	}
+	return scim.NewListResponse(resources, total, startIndex), nil
+}
+
+func (s *scimService) GetUser(tenantID, id uuid.UUID) (*scim.User, error) {
+	member, err := s.getMember(tenantID, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.userResource(member)
+}
+
+// CreateUser adds a user to the tenant, creating the account if the email is new. Only
+// addresses in the tenant's verified domain can be provisioned, since accounts are shared
+// between tenants and matched by email.
+func (s *scimService) CreateUser(tenantID uuid.UUID, resource *scim.User, grantable []string) (*scim.User, error) {
+	tenant, err := s.tenantRepo.GetTenantByID(tenantID)
+	if err != nil {
+		return nil, err
+	}
+	email, err := scimUserEmail(resource, tenant)
+	if err != nil {
+		return nil, err
+	}
+
+	if tenant.MaxUsers != nil {
+		_, active, err := s.repo.ListMembers(tenantID, "user_tenant_access.active", nil, 0, 0)
+		if err != nil {
+			return nil, err
+		}
+		if active >= int64(*tenant.MaxUsers) {
+			return nil, &SCIMError{Status: http.StatusForbidden, Detail: "the tenant has reached its user limit"}
+		}
+	}
+
+	user, err := s.userRepo.GetUserByEmail(email)
+	if err != nil {
+		user = &models.User{
+			Email:         email,
+			EmailVerified: true,
+			Name:          email,
+			Status:        "active",
+			Role:          models.RoleUser,
+			CreatedAt:     time.Now(),
+			UpdatedAt:     time.Now(),
+		}
+		if err := s.userRepo.CreateUser(user); err != nil {
+			return nil, fmt.Errorf("failed to create user: %v", err)
+		}
+		// Personal tenant is automatically created by database trigger
+	} else if _, err := s.repo.GetMember(tenantID, user.ID); err == nil {
+		return nil, NewSCIMError(SCIMErrUniqueness, "a user with this userName already exists")
+	}
+
+	member := &models.UserTenantAccess{
+		UserID:   user.ID,
+		TenantID: tenantID,
+		Roles:    []string{ssoMemberRole},
+		Active:   true,
+	}
+	if err := s.repo.CreateMember(member); err != nil {
+		return nil, err
+	}
+	member.User = *user
+
+	if err := s.applyUser(tenant, member, resource, grantable); err != nil {
+		return nil, err
+	}
+	return s.userResource(member)
+}
+
+// ReplaceUser sets the user's attributes. Roles are left alone if the request has none, as
+// identity providers that do not manage roles omit them.
+func (s *scimService) ReplaceUser(tenantID, id uuid.UUID, resource *scim.User, grantable []string) (*scim.User, error) {
+	tenant, err := s.tenantRepo.GetTenantByID(tenantID)
+	if err != nil {
+		return nil, err
+	}
+	member, err := s.getMember(tenantID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	email, err := scimUserEmail(resource, tenant)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.EqualFold(email, member.User.Email) {
+		if _, err := s.userRepo.GetUserByEmail(email); err == nil {
+			return nil, NewSCIMError(SCIMErrUniqueness, "a user with this userName already exists")
+		}
+		member.User.Email = email
+	}
+
+	if err := s.applyUser(tenant, member, resource, grantable); err != nil {
+		return nil, err
+	}
+	return s.userResource(member)
+}
+
+func (s *scimService) PatchUser(tenantID, id uuid.UUID, patch *scim.PatchRequest, grantable []string) (*scim.User, error) {
+	current, err := s.GetUser(tenantID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	var patched scim.User
+	if err := applySCIMPatch(current, patch, &patched); err != nil {
+		return nil, err
+	}
+	// Removing every role is explicit in a patch, unlike in a replace
+	if patched.Roles == nil {
+		patched.Roles = []scim.MultiValued{}
+	}
+	return s.ReplaceUser(tenantID, id, &patched, grantable)
+}
+
+// DeleteUser removes the user from the tenant and ends their sessions in it
+func (s *scimService) DeleteUser(tenantID, id uuid.UUID) error {
+	err := s.repo.DeleteMember(tenantID, id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return scimNotFound("User")
+	}
+	return err
+}
+
+func (s *scimService) ListGroups(tenantID uuid.UUID, filter string, startIndex, count int) (*scim.ListResponse, error) {
+	where, args, err := scimFilterSQL(filter, scimGroupColumns)
+	if err != nil {
+		return nil, err
+	}
+
+	groups, total, err := s.repo.ListGroups(tenantID, where, args, startIndex-1, count)
+	if err != nil {
+		return nil, err
+	}
+
+	resources := make([]interface{}, 0, len(groups))
+	for _, group := range groups {
+		resource, err := s.groupResource(group)
+		if err != nil {
+			return nil, err
+		}
+		resources = append(resources, resource)
+	}
+	return scim.NewListResponse(resources, total, startIndex), nil
+}
+
+func (s *scimService) GetGroup(tenantID, id uuid.UUID) (*scim.Group, error) {
+	group, err := s.getGroup(tenantID, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.groupResource(group)
+}
+
+func (s *scimService) CreateGroup(tenantID uuid.UUID, resource *scim.Group, grantable []string) (*scim.Group, error) {
+	name, err := s.groupName(tenantID, resource, "", grantable)
+	if err != nil {
+		return nil, err
+	}
+	memberIDs, err := s.memberIDs(tenantID, resource.Members)
+	if err != nil {
+		return nil, err
+	}
+
+	group := &models.SCIMGroup{
+		ID:          uuid.New(),
+		TenantID:    tenantID,
+		DisplayName: name,
+		ExternalID:  resource.ExternalID,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+	if err := s.repo.CreateGroup(group); err != nil {
+		return nil, err
+	}
+	if err := s.repo.SetGroupMembers(tenantID, group.DisplayName, memberIDs); err != nil {
+		return nil, err
+	}
+	return s.groupResource(group)
+}
+
+func (s *scimService) ReplaceGroup(tenantID, id uuid.UUID, resource *scim.Group, grantable []string) (*scim.Group, error) {
+	group, err := s.getGroup(tenantID, id)
+	if err != nil {
+		return nil, err
+	}
+	name, err := s.groupName(tenantID, resource, group.DisplayName, grantable)
+	if err != nil {
+		return nil, err
+	}
+	memberIDs, err := s.memberIDs(tenantID, resource.Members)
+	if err != nil {
+		return nil, err
+	}
+
+	previousName := group.DisplayName
+	group.DisplayName = name
+	group.ExternalID = resource.ExternalID
+	if err := s.repo.SaveGroup(group, previousName); err != nil {
+		return nil, err
+	}
+	if err := s.repo.SetGroupMembers(tenantID, group.DisplayName, memberIDs); err != nil {
+		return nil, err
+	}
+	return s.groupResource(group)
+}
+
+func (s *scimService) PatchGroup(tenantID, id uuid.UUID, patch *scim.PatchRequest, grantable []string) (*scim.Group, error) {
+	current, err := s.GetGroup(tenantID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	var patched scim.Group
+	if err := applySCIMPatch(current, patch, &patched); err != nil {
+		return nil, err
+	}
+	return s.ReplaceGroup(tenantID, id, &patched, grantable)
+}
+
+// DeleteGroup deletes the group and takes its role away from the members
+func (s *scimService) DeleteGroup(tenantID, id uuid.UUID) error {
+	group, err := s.getGroup(tenantID, id)
+	if err != nil {
+		return err
+	}
+	return s.repo.DeleteGroup(group)
+}
+
+// applyUser saves the attributes of a user resource to the membership and its user. Roles
+// outside grantable can be kept but not added.
+func (s *scimService) applyUser(tenant *models.Tenant, member *models.UserTenantAccess, resource *scim.User, grantable []string) error {
+	if name := scimUserName(resource); name != "" {
+		member.User.Name = name
+	}
+	member.ExternalID = resource.ExternalID
+
+	if resource.Roles != nil {
+		// Roles that come from groups are managed through the groups
+		groups, err := s.groupsByName(tenant.ID, member)
+		if err != nil {
+			return err
+		}
+		requested := make([]string, 0, len(resource.Roles))
+		for _, role := range resource.Roles {
+			if role.Value != "" && groups[role.Value] == nil && !slices.Contains(requested, role.Value) {
+				requested = append(requested, role.Value)
+			}
+		}
+		roles := make([]string, 0, len(requested))
+		for _, role := range member.Roles {
+			// Nor can roles the token cannot grant be taken away
+			if groups[role] != nil || (!slices.Contains(grantable, role) && !slices.Contains(requested, role)) {
+				roles = append(roles, role)
+			}
+		}
+		for _, role := range requested {
+			if !slices.Contains(grantable, role) && !slices.Contains(member.Roles, role) {
+				return scimRoleNotGrantable(role)
+			}
+			roles = append(roles, role)
+		}
+		member.Roles = roles
+	}
+
+	deactivated := false
+	if resource.Active != nil {
+		deactivated = member.Active && !*resource.Active
+		member.Active = *resource.Active
+	}
+
+	if err := s.repo.SaveMember(member); err != nil {
+		return err
+	}
+	if deactivated {
+		return s.repo.RevokeMemberTokens(tenant.ID, member.UserID)
+	}
+	return nil
+}
+
+func (s *scimService) getMember(tenantID, userID uuid.UUID) (*models.UserTenantAccess, error) {
+	member, err := s.repo.GetMember(tenantID, userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, scimNotFound("User")
+	}
+	return member, err
+}
+
+func (s *scimService) getGroup(tenantID, id uuid.UUID) (*models.SCIMGroup, error) {
+	group, err := s.repo.GetGroup(tenantID, id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, scimNotFound("Group")
+	}
+	return group, err
+}
+
+// groupsByName returns the SCIM groups among the members' roles, by display name
+func (s *scimService) groupsByName(tenantID uuid.UUID, members ...*models.UserTenantAccess) (map[string]*models.SCIMGroup, error) {
+	var roles []string
+	for _, member := range members {
+		roles = append(roles, member.Roles...)
+	}
+	groups, err := s.repo.GetGroupsByName(tenantID, roles)
+	if err != nil {
+		return nil, err
+	}
+
+	byName := make(map[string]*models.SCIMGroup, len(groups))
+	for _, group := range groups {
+		byName[group.DisplayName] = group
+	}
+	return byName, nil
+}
+
+func (s *scimService) userResource(member *models.UserTenantAccess) (*scim.User, error) {
+	groups, err := s.groupsByName(member.TenantID, member)
+	if err != nil {
+		return nil, err
+	}
+	return scimUserResource(member, groups), nil
+}
+
+func (s *scimService) groupResource(group *models.SCIMGroup) (*scim.Group, error) {
+	members, err := s.repo.GetGroupMembers(group.TenantID, group.DisplayName)
+	if err != nil {
+		return nil, err
+	}
+
+	resource := &scim.Group{
+		Schemas:     []string{scim.SchemaGroup},
+		ID:          group.ID.String(),
+		ExternalID:  group.ExternalID,
+		DisplayName: group.DisplayName,
+		Members:     make([]scim.Member, 0, len(members)),
+		Meta: &scim.Meta{
+			ResourceType: "Group",
+			Created:      group.CreatedAt,
+			LastModified: group.UpdatedAt,
+			Location:     scimLocation("Groups", group.ID),
+		},
+	}
+	for _, member := range members {
+		resource.Members = append(resource.Members, scim.Member{
+			Value:   member.UserID.String(),
+			Ref:     scimLocation("Users", member.UserID),
+			Display: member.User.Name,
+		})
+	}
+	return resource, nil
+}
+
+// groupName validates a group's display name, which must be unique in the tenant. Members
+// hold the name as a role, so a group named after a role the token cannot grant is refused,
+// including when it already exists.
+func (s *scimService) groupName(tenantID uuid.UUID, resource *scim.Group, currentName string, grantable []string) (string, error) {
+	name := strings.TrimSpace(resource.DisplayName)
+	if name == "" {
+		return "", NewSCIMError(SCIMErrInvalidValue, "displayName is required")
+	}
+	if !slices.Contains(grantable, name) {
+		_, err := s.roles.RolePermissions(tenantID, []string{name})
+		if err == nil {
+			return "", scimRoleNotGrantable(name)
+		}
+		if !errors.Is(err, ErrUnknownRole) {
+			return "", err
+		}
+	}
+	if name == currentName {
+		return name, nil
+	}
+
+	existing, err := s.repo.GetGroupsByName(tenantID, []string{name})
+	if err != nil {
+		return "", err
+	}
+	if len(existing) > 0 {
+		return "", NewSCIMError(SCIMErrUniqueness, "a group with this displayName already exists")
+	}
+	return name, nil
+}
+
+// memberIDs returns the users of group members, which must all belong to the tenant
+func (s *scimService) memberIDs(tenantID uuid.UUID, members []scim.Member) ([]uuid.UUID, error) {
+	ids := make([]uuid.UUID, 0, len(members))
+	for _, member := range members {
+		id, err := uuid.Parse(member.Value)
+		if err != nil {
+			return nil, NewSCIMError(SCIMErrInvalidValue, fmt.Sprintf("unknown member %q", member.Value))
+		}
+		if !slices.Contains(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return ids, nil
+	}
+
+	_, found, err := s.repo.ListMembers(tenantID, "user_tenant_access.user_id IN ?", []interface{}{ids}, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	if found != int64(len(ids)) {
+		return nil, NewSCIMError(SCIMErrInvalidValue, "group members must be users of the tenant")
+	}
+	return ids, nil
+}
+
+// scimUserResource represents a membership as a SCIM user. Roles that come from groups are
+// listed as groups rather than roles.
+func scimUserResource(member *models.UserTenantAccess, groups map[string]*models.SCIMGroup) *scim.User {
+	active := member.Active
+	lastModified := member.UpdatedAt
+	if member.User.UpdatedAt.After(lastModified) {
+		lastModified = member.User.UpdatedAt
+	}
+
+	resource := &scim.User{
+		Schemas:     []string{scim.SchemaUser},
+		ID:          member.UserID.String(),
+		ExternalID:  member.ExternalID,
+		UserName:    member.User.Email,
+		Name:        &scim.Name{Formatted: member.User.Name},
+		DisplayName: member.User.Name,
+		Emails:      []scim.MultiValued{{Value: member.User.Email, Type: "work", Primary: true}},
+		Active:      &active,
+		Meta: &scim.Meta{
+			ResourceType: "User",
+			Created:      member.CreatedAt,
+			LastModified: lastModified,
+			Location:     scimLocation("Users", member.UserID),
+		},
+	}
+	for _, role := range member.Roles {
+		if group := groups[role]; group != nil {
+			resource.Groups = append(resource.Groups, scim.GroupRef{
+				Value:   group.ID.String(),
+				Ref:     scimLocation("Groups", group.ID),
+				Display: group.DisplayName,
+			})
+		} else {
+			resource.Roles = append(resource.Roles, scim.MultiValued{Value: role})
+		}
+	}
+	return resource
+}
+
+// scimUserEmail returns the user's email, which is their userName
+func scimUserEmail(resource *scim.User, tenant *models.Tenant) (string, error) {
+	email := strings.ToLower(strings.TrimSpace(resource.UserName))
+	if !strings.Contains(email, "@") {
+		return "", NewSCIMError(SCIMErrInvalidValue, "userName must be an email address")
+	}
+	if !emailInVerifiedDomain(tenant, email) {
+		return "", NewSCIMError(SCIMErrInvalidValue, "userName is not in the tenant's verified domain")
+	}
+	return email, nil
+}
+
+// scimUserName returns the name to store for a user resource
+func scimUserName(resource *scim.User) string {
+	if name := strings.TrimSpace(resource.DisplayName); name != "" {
+		return name
+	}
+	if resource.Name == nil {
+		return ""
+	}
+	if name := strings.TrimSpace(resource.Name.Formatted); name != "" {
+		return name
+	}
+	return strings.TrimSpace(resource.Name.GivenName + " " + resource.Name.FamilyName)
+}
+
+// applySCIMPatch applies the operations to the JSON form of current and decodes the result
+// into patched
+func applySCIMPatch(current interface{}, patch *scim.PatchRequest, patched interface{}) error {
+	data, err := json.Marshal(current)
+	if err != nil {
+		return err
+	}
+	var resource map[string]interface{}
+	if err := json.Unmarshal(data, &resource); err != nil {
+		return err
+	}
+
+	for _, operation := range patch.Operations {
+		if err := scim.ApplyPatch(resource, operation); err != nil {
+			switch {
+			case errors.Is(err, scim.ErrNoTarget):
+				return NewSCIMError(SCIMErrNoTarget, err.Error())
+			case errors.Is(err, scim.ErrInvalidFilter):
+				return NewSCIMError(SCIMErrInvalidFilter, err.Error())
+			default:
+				return NewSCIMError(SCIMErrInvalidPath, err.Error())
+			}
+		}
+	}
+
+	// Some identity providers send booleans as strings, e.g. "False"
+	if key, ok := findSCIMKey(resource, "active"); ok {
+		if value, ok := resource[key].(string); ok {
+			resource[key] = strings.EqualFold(value, "true")
+		}
+	}
+
+	if data, err = json.Marshal(resource); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, patched); err != nil {
+		return NewSCIMError(SCIMErrInvalidValue, err.Error())
+	}
+	return nil
+}
+
+func findSCIMKey(resource map[string]interface{}, name string) (string, bool) {
+	for key := range resource {
+		if strings.EqualFold(key, name) {
+			return key, true
+		}
+	}
+	return "", false
+}
+
+// scimFilterSQL translates a SCIM filter into a SQL condition over the given columns
+func scimFilterSQL(filter string, columns map[string]scim.Column) (string, []interface{}, error) {
+	if filter == "" {
+		return "", nil, nil
+	}
+	parsed, err := scim.ParseFilter(filter)
+	if err != nil {
+		return "", nil, NewSCIMError(SCIMErrInvalidFilter, err.Error())
+	}
+	where, args, err := scim.ToSQL(parsed, columns)
+	if err != nil {
+		return "", nil, NewSCIMError(SCIMErrInvalidFilter, err.Error())
+	}
+	return where, args, nil
+}
+
+func scimLocation(resourceType string, id uuid.UUID) string {
+	return fmt.Sprintf("%s/scim/v2/%s/%s", config.AuthServer.Issuer, resourceType, id)
+}
+
+func scimRoleNotGrantable(role string) *SCIMError {
+	return &SCIMError{Status: http.StatusForbidden, Detail: fmt.Sprintf("the SCIM token cannot grant the role %q", role)}
+}
+
+func scimNotFound(resourceType string) *SCIMError {
+	return &SCIMError{Status: http.StatusNotFound, Detail: resourceType + " not found"}
+}
+
+// hashSCIMToken hashes a token for lookup. The tokens are random, so a fast hash suffices.
+func hashSCIMToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,771 @@
+package services
+
+import (
+	"errors"
+	"identity-service/config"
+	"identity-service/internal/models"
+	"identity-service/internal/repositories"
+	"identity-service/internal/scim"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// fakeSCIMRepository keeps one tenant's members and groups in memory. Members are looked up
+// by user ID; the SQL filters of ListMembers are not evaluated, except for the user IDs
+// memberIDs asks about.
+type fakeSCIMRepository struct {
+	repositories.SCIMRepository
+	members map[uuid.UUID]*models.UserTenantAccess
+	groups  map[uuid.UUID]*models.SCIMGroup
+}
+
+func newFakeSCIMRepository() *fakeSCIMRepository {
+	return &fakeSCIMRepository{
+		members: make(map[uuid.UUID]*models.UserTenantAccess),
+		groups:  make(map[uuid.UUID]*models.SCIMGroup),
+	}
+}
+
+func (r *fakeSCIMRepository) ListMembers(tenantID uuid.UUID, filter string, args []interface{}, offset, limit int) ([]*models.UserTenantAccess, int64, error) {
+	var members []*models.UserTenantAccess
+	for _, member := range r.members {
+		if filter == "user_tenant_access.user_id IN ?" && !slices.Contains(args[0].([]uuid.UUID), member.UserID) {
+			continue
+		}
+		members = append(members, member)
+	}
+	return members, int64(len(members)), nil
+}
+
+func (r *fakeSCIMRepository) GetMember(tenantID, userID uuid.UUID) (*models.UserTenantAccess, error) {
+	member, ok := r.members[userID]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return member, nil
+}
+
+func (r *fakeSCIMRepository) CreateMember(access *models.UserTenantAccess) error {
+	r.members[access.UserID] = access
+	return nil
+}
+
+func (r *fakeSCIMRepository) SaveMember(access *models.UserTenantAccess) error {
+	r.members[access.UserID] = access
+	return nil
+}
+
+func (r *fakeSCIMRepository) RevokeMemberTokens(tenantID, userID uuid.UUID) error {
+	return nil
+}
+
+func (r *fakeSCIMRepository) GetGroup(tenantID, id uuid.UUID) (*models.SCIMGroup, error) {
+	group, ok := r.groups[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return group, nil
+}
+
+func (r *fakeSCIMRepository) GetGroupsByName(tenantID uuid.UUID, names []string) ([]*models.SCIMGroup, error) {
+	var groups []*models.SCIMGroup
+	for _, group := range r.groups {
+		if slices.Contains(names, group.DisplayName) {
+			groups = append(groups, group)
+		}
+	}
+	return groups, nil
+}
+
+func (r *fakeSCIMRepository) CreateGroup(group *models.SCIMGroup) error {
+	r.groups[group.ID] = group
+	return nil
+}
+
+func (r *fakeSCIMRepository) SaveGroup(group *models.SCIMGroup, previousName string) error {
+	for _, member := range r.members {
+		for i, role := range member.Roles {
+			if role == previousName {
+				member.Roles[i] = group.DisplayName
+			}
+		}
+	}
+	r.groups[group.ID] = group
+	return nil
+}
+
+func (r *fakeSCIMRepository) GetGroupMembers(tenantID uuid.UUID, role string) ([]*models.UserTenantAccess, error) {
+	var members []*models.UserTenantAccess
+	for _, member := range r.members {
+		if slices.Contains(member.Roles, role) {
+			members = append(members, member)
+		}
+	}
+	return members, nil
+}
+
+func (r *fakeSCIMRepository) SetGroupMembers(tenantID uuid.UUID, role string, userIDs []uuid.UUID) error {
+	for _, member := range r.members {
+		held := slices.Contains(member.Roles, role)
+		wanted := slices.Contains(userIDs, member.UserID)
+		switch {
+		case held && !wanted:
+			member.Roles = slices.DeleteFunc(member.Roles, func(r string) bool { return r == role })
+		case wanted && !held:
+			member.Roles = append(member.Roles, role)
+		}
+	}
+	return nil
+}
+
+// fakeUserRepository keeps users in memory by email
+type fakeUserRepository struct {
+	repositories.UserRepository
+	users map[string]*models.User
+}
+
+func (r *fakeUserRepository) GetUserByEmail(email string) (*models.User, error) {
+	user, ok := r.users[strings.ToLower(email)]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return user, nil
+}
+
+func (r *fakeUserRepository) CreateUser(user *models.User) error {
+	user.ID = uuid.New()
+	r.users[strings.ToLower(user.Email)] = user
+	return nil
+}
+
+type scimFixture struct {
+	service SCIMService
+	repo    *fakeSCIMRepository
+	tenant  *models.Tenant
+}
+
+func newSCIMFixture(t *testing.T) *scimFixture {
+	t.Helper()
+	config.LoadAuthServerConfig()
+	tenants := newFakeTenantRepository()
+	f := &scimFixture{
+		repo:   newFakeSCIMRepository(),
+		tenant: tenants.addTenant(t, "example.com"),
+	}
+	roles := &fakeRoleService{custom: map[string][]string{"support": {models.PermMembersRead}}}
+	f.service = NewSCIMService(f.repo, tenants, &fakeUserRepository{users: make(map[string]*models.User)}, roles)
+	return f
+}
+
+// createUser provisions a user with the member role and returns their ID
+func (f *scimFixture) createUser(t *testing.T, email string) uuid.UUID {
+	t.Helper()
+	user, err := f.service.CreateUser(f.tenant.ID, &scim.User{UserName: email}, nil)
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	return uuid.MustParse(user.ID)
+}
+
+func scimRoles(values ...string) []scim.MultiValued {
+	roles := make([]scim.MultiValued, 0, len(values))
+	for _, value := range values {
+		roles = append(roles, scim.MultiValued{Value: value})
+	}
+	return roles
+}
+
+// assertRoleNotGrantable checks err refuses a role the token cannot grant
+func assertRoleNotGrantable(t *testing.T, err error) {
+	t.Helper()
+	var scimErr *SCIMError
+	if !errors.As(err, &scimErr) || scimErr.Status != http.StatusForbidden {
+		t.Fatalf("error = %v, want 403 for a role the token cannot grant", err)
+	}
+}
+
+func TestSCIMUserRolesAreLimitedToTheToken(t *testing.T) {
+	grantable := []string{models.TenantRoleMember, models.TenantRoleViewer}
+
+	tests := []struct {
+		name  string
+		roles []string
+	}{
+		{name: "owner", roles: []string{models.TenantRoleOwner}},
+		{name: "built-in role not granted", roles: []string{models.TenantRoleAdmin}},
+		{name: "custom role not granted", roles: []string{"support"}},
+		{name: "unknown role", roles: []string{"superuser"}},
+		{name: "one of several", roles: []string{models.TenantRoleViewer, models.TenantRoleOwner}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newSCIMFixture(t)
+			_, err := f.service.CreateUser(f.tenant.ID, &scim.User{UserName: "jane@example.com", Roles: scimRoles(tt.roles...)}, grantable)
+			assertRoleNotGrantable(t, err)
+
+			id := f.createUser(t, "john@example.com")
+			_, err = f.service.ReplaceUser(f.tenant.ID, id, &scim.User{UserName: "john@example.com", Roles: scimRoles(tt.roles...)}, grantable)
+			assertRoleNotGrantable(t, err)
+			if roles := f.repo.members[id].Roles; !slices.Equal(roles, []string{models.TenantRoleMember}) {
+				t.Errorf("roles after refused replace = %v, want [member]", roles)
+			}
+		})
+	}
+}
+
+func TestSCIMUserRolesWithinTheToken(t *testing.T) {
+	f := newSCIMFixture(t)
+	grantable := []string{models.TenantRoleMember, models.TenantRoleViewer, "support"}
+
+	user, err := f.service.CreateUser(f.tenant.ID, &scim.User{UserName: "jane@example.com", Roles: scimRoles("viewer", "support")}, grantable)
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	id := uuid.MustParse(user.ID)
+	if roles := f.repo.members[id].Roles; !slices.Equal(roles, []string{"viewer", "support"}) {
+		t.Errorf("roles = %v, want [viewer support]", roles)
+	}
+}
+
+func TestSCIMKeepsRolesOutsideTheToken(t *testing.T) {
+	f := newSCIMFixture(t)
+	grantable := []string{models.TenantRoleMember, models.TenantRoleViewer}
+	id := f.createUser(t, "jane@example.com")
+	// Given owner in the tenant rather than by the identity provider
+	f.repo.members[id].Roles = []string{models.TenantRoleOwner, models.TenantRoleMember}
+
+	// The identity provider can change the roles it manages without taking owner away
+	if _, err := f.service.ReplaceUser(f.tenant.ID, id, &scim.User{UserName: "jane@example.com", Roles: scimRoles("viewer")}, grantable); err != nil {
+		t.Fatalf("ReplaceUser: %v", err)
+	}
+	if roles := f.repo.members[id].Roles; !slices.Equal(roles, []string{models.TenantRoleOwner, models.TenantRoleViewer}) {
+		t.Errorf("roles = %v, want [owner viewer]", roles)
+	}
+
+	// Sending back a role the user already holds is not granting it
+	if _, err := f.service.ReplaceUser(f.tenant.ID, id, &scim.User{UserName: "jane@example.com", Roles: scimRoles("owner", "viewer")}, grantable); err != nil {
+		t.Fatalf("ReplaceUser with held role: %v", err)
+	}
+}
+
+func TestSCIMGroupsNamedAfterRolesAreLimitedToTheToken(t *testing.T) {
+	grantable := []string{models.TenantRoleMember, models.TenantRoleViewer}
+
+	for _, name := range []string{models.TenantRoleOwner, models.TenantRoleAdmin, "support"} {
+		t.Run(name, func(t *testing.T) {
+			f := newSCIMFixture(t)
+			id := f.createUser(t, "jane@example.com")
+			members := []scim.Member{{Value: id.String()}}
+
+			_, err := f.service.CreateGroup(f.tenant.ID, &scim.Group{DisplayName: name, Members: members}, grantable)
+			assertRoleNotGrantable(t, err)
+
+			group, err := f.service.CreateGroup(f.tenant.ID, &scim.Group{DisplayName: "engineering", Members: members}, grantable)
+			if err != nil {
+				t.Fatalf("CreateGroup: %v", err)
+			}
+			groupID := uuid.MustParse(group.ID)
+			_, err = f.service.ReplaceGroup(f.tenant.ID, groupID, &scim.Group{DisplayName: name, Members: members}, grantable)
+			assertRoleNotGrantable(t, err)
+			_, err = f.service.PatchGroup(f.tenant.ID, groupID, &scim.PatchRequest{Operations: []scim.PatchOperation{
+				{Op: "replace", Path: "displayName", Value: name},
+			}}, grantable)
+			assertRoleNotGrantable(t, err)
+
+			if roles := f.repo.members[id].Roles; slices.Contains(roles, name) {
+				t.Errorf("roles = %v, want no %s", roles, name)
+			}
+		})
+	}
+}
+
+func TestSCIMGroupNamedAfterGrantableRole(t *testing.T) {
+	f := newSCIMFixture(t)
+	id := f.createUser(t, "jane@example.com")
+
+	_, err := f.service.CreateGroup(f.tenant.ID, &scim.Group{DisplayName: "support", Members: []scim.Member{{Value: id.String()}}}, []string{"support"})
+	if err != nil {
+		t.Fatalf("CreateGroup: %v", err)
+	}
+	if roles := f.repo.members[id].Roles; !slices.Contains(roles, "support") {
+		t.Errorf("roles = %v, want support", roles)
+	}
+}
+
+// assertSCIMErrorType checks err is a SCIM error of the type
+func assertSCIMErrorType(t *testing.T, err error, scimType string) {
+	t.Helper()
+	var scimErr *SCIMError
+	if !errors.As(err, &scimErr) || scimErr.ScimType != scimType || scimErr.Status != http.StatusBadRequest {
+		t.Fatalf("error = %v, want a 400 %s error", err, scimType)
+	}
+}
+
+func TestSCIMFilterSQL(t *testing.T) {
+	modified := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		filter    string
+		wantWhere string
+		wantArgs  []interface{}
+	}{
+		{name: "no filter"},
+		{
+			name:      "string equality ignores case",
+			filter:    `userName eq "Jane@Example.com"`,
+			wantWhere: "LOWER(users.email) = LOWER(?)",
+			wantArgs:  []interface{}{"Jane@Example.com"},
+		},
+		{
+			name:      "attribute with schema URN",
+			filter:    `urn:ietf:params:scim:schemas:core:2.0:User:userName eq "jane@example.com"`,
+			wantWhere: "LOWER(users.email) = LOWER(?)",
+			wantArgs:  []interface{}{"jane@example.com"},
+		},
+		{
+			name:      "attribute and operator case",
+			filter:    `USERNAME NE "jane@example.com"`,
+			wantWhere: "LOWER(users.email) <> LOWER(?)",
+			wantArgs:  []interface{}{"jane@example.com"},
+		},
+		{
+			name:      "case exact equality",
+			filter:    `externalId eq "00u1"`,
+			wantWhere: "user_tenant_access.external_id = ?",
+			wantArgs:  []interface{}{"00u1"},
+		},
+		{
+			name:      "starts with",
+			filter:    `userName sw "jane"`,
+			wantWhere: "users.email ILIKE ?",
+			wantArgs:  []interface{}{"jane%"},
+		},
+		{
+			name:      "contains escapes LIKE wildcards",
+			filter:    `displayName co "50%_off"`,
+			wantWhere: "users.name ILIKE ?",
+			wantArgs:  []interface{}{`%50\%\_off%`},
+		},
+		{
+			name:      "present",
+			filter:    `userName pr`,
+			wantWhere: "(users.email IS NOT NULL AND users.email <> '')",
+		},
+		{
+			name:      "boolean",
+			filter:    `active eq true`,
+			wantWhere: "user_tenant_access.active = ?",
+			wantArgs:  []interface{}{true},
+		},
+		{
+			name:      "string array",
+			filter:    `roles eq "admin"`,
+			wantWhere: "? = ANY(user_tenant_access.roles)",
+			wantArgs:  []interface{}{"admin"},
+		},
+		{
+			name:      "date",
+			filter:    `meta.lastModified gt "2024-01-02T03:04:05Z"`,
+			wantWhere: "user_tenant_access.updated_at > ?",
+			wantArgs:  []interface{}{modified},
+		},
+		{
+			name:      "value path",
+			filter:    `emails[value ew "@example.com"]`,
+			wantWhere: "users.email ILIKE ?",
+			wantArgs:  []interface{}{"%@example.com"},
+		},
+		{
+			name:      "and binds tighter than or",
+			filter:    `userName eq "a" or userName eq "b" and active eq true`,
+			wantWhere: "(LOWER(users.email) = LOWER(?) OR (LOWER(users.email) = LOWER(?) AND user_tenant_access.active = ?))",
+			wantArgs:  []interface{}{"a", "b", true},
+		},
+		{
+			name:      "grouping",
+			filter:    `(userName eq "a" or userName eq "b") and active eq true`,
+			wantWhere: "((LOWER(users.email) = LOWER(?) OR LOWER(users.email) = LOWER(?)) AND user_tenant_access.active = ?)",
+			wantArgs:  []interface{}{"a", "b", true},
+		},
+		{
+			name:      "not",
+			filter:    `not (active eq false)`,
+			wantWhere: "NOT (user_tenant_access.active = ?)",
+			wantArgs:  []interface{}{false},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			where, args, err := scimFilterSQL(tt.filter, scimUserColumns)
+			if err != nil {
+				t.Fatalf("scimFilterSQL: %v", err)
+			}
+			if where != tt.wantWhere {
+				t.Errorf("where = %q, want %q", where, tt.wantWhere)
+			}
+			if !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("args = %#v, want %#v", args, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestSCIMFilterSQLRejectsInvalidFilters(t *testing.T) {
+	tests := []struct {
+		name    string
+		filter  string
+		columns map[string]scim.Column
+	}{
+		{name: "missing value", filter: `userName eq`},
+		{name: "unknown operator", filter: `userName like "jane"`},
+		{name: "unterminated string", filter: `userName eq "jane`},
+		{name: "unclosed group", filter: `(userName eq "jane"`},
+		{name: "trailing token", filter: `userName eq "jane" )`},
+		{name: "missing operand", filter: `userName eq "jane" and`},
+		{name: "unquoted string", filter: `userName eq jane`},
+		{name: "attribute not filterable", filter: `password eq "secret"`},
+		{name: "user attribute on groups", filter: `userName eq "jane"`, columns: scimGroupColumns},
+		{name: "boolean compared with string", filter: `active eq "yes"`},
+		{name: "ordering a boolean", filter: `active gt true`},
+		{name: "invalid date", filter: `meta.created gt "yesterday"`},
+		{name: "ordering a string array", filter: `roles gt "admin"`},
+		{name: "string compared with number", filter: `userName eq 5`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			columns := tt.columns
+			if columns == nil {
+				columns = scimUserColumns
+			}
+			_, _, err := scimFilterSQL(tt.filter, columns)
+			assertSCIMErrorType(t, err, SCIMErrInvalidFilter)
+		})
+	}
+}
+
+func TestApplySCIMPatchToUser(t *testing.T) {
+	active := true
+	current := scim.User{
+		UserName: "jane@example.com",
+		Name:     &scim.Name{GivenName: "Jane", FamilyName: "Doe"},
+		Emails:   []scim.MultiValued{{Value: "jane@example.com", Type: "work", Primary: true}},
+		Active:   &active,
+		Roles:    scimRoles("member"),
+	}
+
+	tests := []struct {
+		name       string
+		operations []scim.PatchOperation
+		check      func(t *testing.T, patched *scim.User)
+	}{
+		{
+			name:       "replace attribute",
+			operations: []scim.PatchOperation{{Op: "replace", Path: "displayName", Value: "Jane D."}},
+			check: func(t *testing.T, patched *scim.User) {
+				if patched.DisplayName != "Jane D." {
+					t.Errorf("displayName = %q, want Jane D.", patched.DisplayName)
+				}
+			},
+		},
+		{
+			name:       "op and path case",
+			operations: []scim.PatchOperation{{Op: "Replace", Path: "USERNAME", Value: "j.doe@example.com"}},
+			check: func(t *testing.T, patched *scim.User) {
+				if patched.UserName != "j.doe@example.com" {
+					t.Errorf("userName = %q, want j.doe@example.com", patched.UserName)
+				}
+			},
+		},
+		{
+			name:       "sub-attribute",
+			operations: []scim.PatchOperation{{Op: "replace", Path: "name.familyName", Value: "Smith"}},
+			check: func(t *testing.T, patched *scim.User) {
+				if patched.Name.GivenName != "Jane" || patched.Name.FamilyName != "Smith" {
+					t.Errorf("name = %+v, want Jane Smith", patched.Name)
+				}
+			},
+		},
+		{
+			name: "no path",
+			operations: []scim.PatchOperation{{Op: "replace", Value: map[string]interface{}{
+				"displayName": "Jane D.",
+				"active":      false,
+			}}},
+			check: func(t *testing.T, patched *scim.User) {
+				if patched.DisplayName != "Jane D." || patched.Active == nil || *patched.Active {
+					t.Errorf("displayName = %q, active = %v, want Jane D. and inactive", patched.DisplayName, patched.Active)
+				}
+			},
+		},
+		{
+			name:       "boolean sent as string",
+			operations: []scim.PatchOperation{{Op: "replace", Path: "active", Value: "False"}},
+			check: func(t *testing.T, patched *scim.User) {
+				if patched.Active == nil || *patched.Active {
+					t.Errorf("active = %v, want false", patched.Active)
+				}
+			},
+		},
+		{
+			name:       "filtered sub-attribute",
+			operations: []scim.PatchOperation{{Op: "replace", Path: `emails[type eq "work"].value`, Value: "j.doe@example.com"}},
+			check: func(t *testing.T, patched *scim.User) {
+				if len(patched.Emails) != 1 || patched.Emails[0].Value != "j.doe@example.com" || !patched.Emails[0].Primary {
+					t.Errorf("emails = %+v, want the primary work email changed", patched.Emails)
+				}
+			},
+		},
+		{
+			name:       "filtered sub-attribute that is missing",
+			operations: []scim.PatchOperation{{Op: "add", Path: `emails[type eq "home"].value`, Value: "jane@home.example"}},
+			check: func(t *testing.T, patched *scim.User) {
+				if len(patched.Emails) != 2 || patched.Emails[1].Type != "home" || patched.Emails[1].Value != "jane@home.example" {
+					t.Errorf("emails = %+v, want a home email added", patched.Emails)
+				}
+			},
+		},
+		{
+			name:       "remove filtered element",
+			operations: []scim.PatchOperation{{Op: "remove", Path: `emails[type eq "work"]`}},
+			check: func(t *testing.T, patched *scim.User) {
+				if len(patched.Emails) != 0 {
+					t.Errorf("emails = %+v, want none", patched.Emails)
+				}
+			},
+		},
+		{
+			name: "add values skips those present",
+			operations: []scim.PatchOperation{{Op: "add", Path: "roles", Value: []interface{}{
+				map[string]interface{}{"value": "member"},
+				map[string]interface{}{"value": "viewer"},
+			}}},
+			check: func(t *testing.T, patched *scim.User) {
+				if !slices.Equal(scimRoleValues(patched.Roles), []string{"member", "viewer"}) {
+					t.Errorf("roles = %v, want [member viewer]", scimRoleValues(patched.Roles))
+				}
+			},
+		},
+		{
+			name:       "remove values",
+			operations: []scim.PatchOperation{{Op: "remove", Path: "roles", Value: []interface{}{map[string]interface{}{"value": "member"}}}},
+			check: func(t *testing.T, patched *scim.User) {
+				if len(patched.Roles) != 0 {
+					t.Errorf("roles = %v, want none", scimRoleValues(patched.Roles))
+				}
+			},
+		},
+		{
+			name:       "remove attribute",
+			operations: []scim.PatchOperation{{Op: "remove", Path: "name"}},
+			check: func(t *testing.T, patched *scim.User) {
+				if patched.Name != nil {
+					t.Errorf("name = %+v, want none", patched.Name)
+				}
+			},
+		},
+		{
+			name: "operations apply in order",
+			operations: []scim.PatchOperation{
+				{Op: "replace", Path: "displayName", Value: "First"},
+				{Op: "replace", Path: "displayName", Value: "Second"},
+			},
+			check: func(t *testing.T, patched *scim.User) {
+				if patched.DisplayName != "Second" {
+					t.Errorf("displayName = %q, want Second", patched.DisplayName)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var patched scim.User
+			if err := applySCIMPatch(current, &scim.PatchRequest{Operations: tt.operations}, &patched); err != nil {
+				t.Fatalf("applySCIMPatch: %v", err)
+			}
+			tt.check(t, &patched)
+		})
+	}
+}
+
+func TestApplySCIMPatchToGroupMembers(t *testing.T) {
+	current := scim.Group{DisplayName: "engineering", Members: []scim.Member{{Value: "a"}, {Value: "b"}}}
+
+	tests := []struct {
+		name      string
+		operation scim.PatchOperation
+		want      []string
+	}{
+		{
+			name:      "add",
+			operation: scim.PatchOperation{Op: "add", Path: "members", Value: []interface{}{map[string]interface{}{"value": "c"}}},
+			want:      []string{"a", "b", "c"},
+		},
+		{
+			name:      "remove listed values",
+			operation: scim.PatchOperation{Op: "remove", Path: "members", Value: []interface{}{map[string]interface{}{"value": "a"}}},
+			want:      []string{"b"},
+		},
+		{
+			name:      "remove by filter",
+			operation: scim.PatchOperation{Op: "remove", Path: `members[value eq "b"]`},
+			want:      []string{"a"},
+		},
+		{
+			name:      "replace",
+			operation: scim.PatchOperation{Op: "replace", Path: "members", Value: []interface{}{map[string]interface{}{"value": "d"}}},
+			want:      []string{"d"},
+		},
+		{
+			name:      "remove all",
+			operation: scim.PatchOperation{Op: "remove", Path: "members"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var patched scim.Group
+			if err := applySCIMPatch(current, &scim.PatchRequest{Operations: []scim.PatchOperation{tt.operation}}, &patched); err != nil {
+				t.Fatalf("applySCIMPatch: %v", err)
+			}
+			var members []string
+			for _, member := range patched.Members {
+				members = append(members, member.Value)
+			}
+			if !slices.Equal(members, tt.want) {
+				t.Errorf("members = %v, want %v", members, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplySCIMPatchErrors(t *testing.T) {
+	current := scim.User{
+		UserName: "jane@example.com",
+		Emails:   []scim.MultiValued{{Value: "jane@example.com", Type: "work"}},
+	}
+
+	tests := []struct {
+		name      string
+		operation scim.PatchOperation
+		scimType  string
+	}{
+		{name: "unknown op", operation: scim.PatchOperation{Op: "move", Path: "displayName"}, scimType: SCIMErrInvalidPath},
+		{name: "remove without path", operation: scim.PatchOperation{Op: "remove"}, scimType: SCIMErrNoTarget},
+		{name: "no path and value not an object", operation: scim.PatchOperation{Op: "replace", Value: "Jane"}, scimType: SCIMErrInvalidPath},
+		{name: "invalid filter in path", operation: scim.PatchOperation{Op: "replace", Path: `emails[type eq].value`, Value: "x"}, scimType: SCIMErrInvalidPath},
+		{name: "unclosed filter in path", operation: scim.PatchOperation{Op: "replace", Path: `emails[type eq "work".value`, Value: "x"}, scimType: SCIMErrInvalidPath},
+		{name: "text after filter", operation: scim.PatchOperation{Op: "replace", Path: `emails[type eq "work"]value`, Value: "x"}, scimType: SCIMErrInvalidPath},
+		{name: "remove unmatched element", operation: scim.PatchOperation{Op: "remove", Path: `emails[type eq "home"]`}, scimType: SCIMErrNoTarget},
+		{name: "unmatched filter that cannot create", operation: scim.PatchOperation{Op: "replace", Path: `emails[type co "home"].value`, Value: "x"}, scimType: SCIMErrNoTarget},
+		{name: "value of the wrong type", operation: scim.PatchOperation{Op: "replace", Path: "userName", Value: 5}, scimType: SCIMErrInvalidValue},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var patched scim.User
+			err := applySCIMPatch(current, &scim.PatchRequest{Operations: []scim.PatchOperation{tt.operation}}, &patched)
+			assertSCIMErrorType(t, err, tt.scimType)
+		})
+	}
+}
+
+func TestSCIMParsePagination(t *testing.T) {
+	tests := []struct {
+		name       string
+		startIndex string
+		count      string
+		wantStart  int
+		wantCount  int
+		wantErr    bool
+	}{
+		{name: "defaults", wantStart: 1, wantCount: scim.DefaultCount},
+		{name: "given", startIndex: "11", count: "10", wantStart: 11, wantCount: 10},
+		{name: "start below one", startIndex: "0", count: "10", wantStart: 1, wantCount: 10},
+		{name: "negative start", startIndex: "-5", wantStart: 1, wantCount: scim.DefaultCount},
+		{name: "zero count", count: "0", wantStart: 1, wantCount: 0},
+		{name: "negative count", count: "-1", wantStart: 1, wantCount: 0},
+		{name: "count capped", count: "1000", wantStart: 1, wantCount: scim.MaxCount},
+		{name: "invalid start", startIndex: "first", wantErr: true},
+		{name: "invalid count", count: "ten", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, count, err := scim.ParsePagination(tt.startIndex, tt.count)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParsePagination(%q, %q) succeeded, want an error", tt.startIndex, tt.count)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParsePagination: %v", err)
+			}
+			if start != tt.wantStart || count != tt.wantCount {
+				t.Errorf("ParsePagination = %d, %d, want %d, %d", start, count, tt.wantStart, tt.wantCount)
+			}
+		})
+	}
+}
+
+func TestSCIMNewListResponse(t *testing.T) {
+	tests := []struct {
+		name      string
+		resources []interface{}
+		total     int64
+		start     int
+		wantItems int
+	}{
+		{name: "empty", total: 0, start: 1},
+		{name: "first page", resources: []interface{}{"a", "b"}, total: 5, start: 1, wantItems: 2},
+		{name: "later page", resources: []interface{}{"e"}, total: 5, start: 5, wantItems: 1},
+		{name: "past the end", resources: nil, total: 5, start: 11},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := scim.NewListResponse(tt.resources, tt.total, tt.start)
+			if !slices.Equal(response.Schemas, []string{scim.SchemaListResponse}) {
+				t.Errorf("schemas = %v, want the list response schema", response.Schemas)
+			}
+			if response.TotalResults != tt.total || response.StartIndex != tt.start || response.ItemsPerPage != tt.wantItems {
+				t.Errorf("totalResults, startIndex, itemsPerPage = %d, %d, %d, want %d, %d, %d",
+					response.TotalResults, response.StartIndex, response.ItemsPerPage, tt.total, tt.start, tt.wantItems)
+			}
+			// Resources is always a JSON array, never null
+			if response.Resources == nil || len(response.Resources) != tt.wantItems {
+				t.Errorf("resources = %#v, want %d", response.Resources, tt.wantItems)
+			}
+		})
+	}
+}
+
+func TestSCIMListUsersPagination(t *testing.T) {
+	f := newSCIMFixture(t)
+	for _, email := range []string{"a@example.com", "b@example.com", "c@example.com"} {
+		f.createUser(t, email)
+	}
+
+	response, err := f.service.ListUsers(f.tenant.ID, "", 2, 1)
+	if err != nil {
+		t.Fatalf("ListUsers: %v", err)
+	}
+	if response.TotalResults != 3 || response.StartIndex != 2 {
+		t.Errorf("totalResults, startIndex = %d, %d, want 3, 2", response.TotalResults, response.StartIndex)
+	}
+
+	_, err = f.service.ListUsers(f.tenant.ID, `userName eq`, 1, 10)
+	assertSCIMErrorType(t, err, SCIMErrInvalidFilter)
+}
+
+func scimRoleValues(roles []scim.MultiValued) []string {
+	values := make([]string, 0, len(roles))
+	for _, role := range roles {
+		values = append(values, role.Value)
+	}
+	return values
+}
@@ -13,29 +13,31 @@ import (
 // TenantService defines the interface for tenant-related operations
 type TenantService interface {
 	ListTenants(page, limit int, search string, filter map[string]string) ([]*models.Tenant, int64, error)
-	CreateTenant(tenant *models.Tenant) (*models.Tenant, error)
+	CreateTenant(tenant *models.Tenant, ownerID uuid.UUID) (*models.Tenant, error)
 	GetTenant(id uuid.UUID) (*models.Tenant, error)
 	GetTenantByID(id uuid.UUID) (*models.Tenant, error)
+	GetTenantBySlug(slug string) (*models.Tenant, error)
 	UpdateTenant(id uuid.UUID, updates *models.TenantUpdate) (*models.Tenant, error)
 	DeleteTenant(id uuid.UUID) error
 	GetTenantSettings(id uuid.UUID) (*models.TenantSettings, error)
 	UpdateTenantSettings(id uuid.UUID, settings *models.TenantSettings) (*models.TenantSettings, error)
-	GetTenantMembers(id uuid.UUID, page, limit int, search string, filter map[string]string) ([]*models.User, int64, error)
+	// GetTenantMembers returns a page of the tenant's members with the tenant's groups they are in
+	GetTenantMembers(id uuid.UUID, page, limit int, search string, filter map[string]string) ([]*models.TenantMember, int64, error)
 	GetTenantFeatures(id uuid.UUID) (*models.TenantFeatures, error)
 	UpdateTenantFeatures(id uuid.UUID, features *models.TenantFeatures) (*models.TenantFeatures, error)
 	SwitchTenant(userID, tenantID uuid.UUID) error
 	UpgradeTenant(id uuid.UUID, upgrade *models.TenantUpgrade) error
-	CreateTenantInvite(id uuid.UUID, invite *models.TenantInvite) (*models.TenantInvite, error)
-	DeleteTenantInvite(tenantID, inviteID uuid.UUID) error
 }
 
 type tenantService struct {
 	tenantRepo repositories.TenantRepository
+	groupRepo  repositories.GroupRepository
 }
 
-func NewTenantService(tenantRepo repositories.TenantRepository) TenantService {
+func NewTenantService(tenantRepo repositories.TenantRepository, groupRepo repositories.GroupRepository) TenantService {
 	return &tenantService{
 		tenantRepo: tenantRepo,
+		groupRepo:  groupRepo,
 	}
 }
 
@@ -43,7 +45,9 @@ func (s *tenantService) ListTenants(page, limit int, search string, filter map[s
 	return s.tenantRepo.ListTenants(page, limit, search, filter)
 }
 
-func (s *tenantService) CreateTenant(tenant *models.Tenant) (*models.Tenant, error) {
+// CreateTenant creates a tenant with ownerID as its owner and first member
+func (s *tenantService) CreateTenant(tenant *models.Tenant, ownerID uuid.UUID) (*models.Tenant, error) {
+	tenant.OwnerID = &ownerID
 	if err := s.tenantRepo.CreateTenant(tenant); err != nil {
 		return nil, err
 	}
@@ -97,6 +101,9 @@ func (s *tenantService) UpdateTenant(id uuid.UUID, updates *models.TenantUpdate)
 	if updates.SubscriptionExpiresAt != nil {
 		tenant.SubscriptionExpiresAt = updates.SubscriptionExpiresAt
 	}
+	if updates.AllowedRedirectURIs != nil {
+		tenant.AllowedRedirectURIs = *updates.AllowedRedirectURIs
+	}
 
 	if err := s.tenantRepo.UpdateTenant(tenant); err != nil {
 		return nil, err
@@ -139,17 +146,32 @@ func (s *tenantService) UpdateTenantSettings(id uuid.UUID, settings *models.Tena
 	return settings, nil
 }
 
-func (s *tenantService) GetTenantMembers(id uuid.UUID, page, limit int, search string, filter map[string]string) ([]*models.User, int64, error) {
+func (s *tenantService) GetTenantMembers(id uuid.UUID, page, limit int, search string, filter map[string]string) ([]*models.TenantMember, int64, error) {
 	accesses, total, err := s.tenantRepo.GetTenantMembers(id, page, limit, search, filter)
 	if err != nil {
 		return nil, 0, err
 	}
 
-	users := make([]*models.User, len(accesses))
+	userIDs := make([]uuid.UUID, len(accesses))
+	for i, access := range accesses {
+		userIDs[i] = access.UserID
+	}
+	groups, err := s.groupRepo.GetMemberGroups(userIDs)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	members := make([]*models.TenantMember, len(accesses))
 	for i, access := range accesses {
-		users[i] = &access.User
+		member := &models.TenantMember{User: &access.User, Groups: []models.MemberGroup{}}
+		for _, group := range groups {
+			if group.UserID == access.UserID && group.TenantID == id {
+				member.Groups = append(member.Groups, group)
+			}
+		}
+		members[i] = member
 	}
-	return users, total, nil
+	return members, total, nil
 }
 
 func (s *tenantService) GetTenantFeatures(id uuid.UUID) (*models.TenantFeatures, error) {
@@ -187,6 +209,10 @@ func (s *tenantService) GetTenantByID(id uuid.UUID) (*models.Tenant, error) {
 	return s.tenantRepo.GetTenantByID(id)
 }
 
+func (s *tenantService) GetTenantBySlug(slug string) (*models.Tenant, error) {
+	return s.tenantRepo.GetTenantBySlug(slug)
+}
+
 func (s *tenantService) SwitchTenant(userID, tenantID uuid.UUID) error {
 	// Verify user has access to tenant
 	access, err := s.tenantRepo.GetUserTenantAccess(userID, tenantID)
@@ -208,11 +234,3 @@ func (s *tenantService) UpgradeTenant(id uuid.UUID, upgrade *models.TenantUpgrad
 
 	return s.tenantRepo.UpdateTenant(tenant)
 }
-
-func (s *tenantService) CreateTenantInvite(tenantID uuid.UUID, invite *models.TenantInvite) (*models.TenantInvite, error) {
-	return s.tenantRepo.CreateTenantInvite(tenantID, invite)
-}
-
-func (s *tenantService) DeleteTenantInvite(tenantID, inviteID uuid.UUID) error {
-	return s.tenantRepo.DeleteTenantInvite(tenantID, inviteID)
-}
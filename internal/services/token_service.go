@@ -53,12 +53,8 @@ func (s *tokenService) GenerateToken(userID string, tenantID string) (string, er
 		Audience:  jwt.ClaimStrings{tenantID},
 	}
 
-	// Create token with RS256 algorithm
-	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
-	token.Header["kid"] = s.keyManager.GetCurrentKeyID()
-
-	// Sign token
-	tokenString, err := token.SignedString(s.keyManager.GetCurrentPrivateKey())
+	// Sign token with the current key
+	tokenString, err := s.keyManager.SignToken(claims)
 	if err != nil {
 		return "", err
 	}
@@ -85,41 +81,8 @@ func (s *tokenService) GenerateToken(userID string, tenantID string) (string, er
 }
 
 func (s *tokenService) ValidateToken(tokenString string) (*models.JWTToken, error) {
-	// First parse without validation to get the key ID
-	var (
-		err         error
-		parsedToken *jwt.Token
-		ok          bool
-		keyID       string
-	)
-
-	// Initial parse to get the key ID
-	parsedToken, err = jwt.Parse(tokenString, nil)
-	if err != nil {
-		return nil, jwt.ErrSignatureInvalid
-	}
-
-	// Get key ID from token header
-	if keyID, ok = parsedToken.Header["kid"].(string); !ok {
-		return nil, jwt.ErrSignatureInvalid
-	}
-
-	// Get the key pair for this key ID
-	keyPair := s.keyManager.GetKeyPairByID(keyID)
-	if keyPair == nil {
-		return nil, jwt.ErrSignatureInvalid
-	}
-
-	// Parse and validate token
-	validatedToken, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		var isRSA bool
-		if _, isRSA = token.Method.(*jwt.SigningMethodRSA); !isRSA {
-			return nil, jwt.ErrSignatureInvalid
-		}
-		return keyPair.PublicKey, nil
-	})
-
-	if err != nil || !validatedToken.Valid {
+	// Verify the signature with the key the token was signed with
+	if err := s.keyManager.VerifyToken(tokenString, &jwt.RegisteredClaims{}); err != nil {
 		return nil, jwt.ErrSignatureInvalid
 	}
 
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"identity-service/internal/models"
 	"identity-service/internal/repositories"
+	"log"
 	"time"
 
 	"github.com/google/uuid"
@@ -24,7 +25,11 @@ type UserService interface {
 	GetUserTenants(id uuid.UUID) ([]*models.Tenant, error)
 	AddUserToTenant(userID uuid.UUID, tenantID uuid.UUID, roles []string) error
 	RemoveUserFromTenant(userID uuid.UUID, tenantID uuid.UUID) error
+	// GetUserTenantRoles returns the roles the user holds directly in the tenant, or
+	// gorm.ErrRecordNotFound if they are not a member
+	GetUserTenantRoles(userID uuid.UUID, tenantID uuid.UUID) ([]string, error)
 	UpdateUserRole(userID uuid.UUID, tenantID uuid.UUID, role string) error
+	UpdateUserRoles(userID uuid.UUID, tenantID uuid.UUID, roles []string) error
 	CreateOrUpdateUser(oauthUser *models.OAuthUser) (*models.User, error)
 	UpdatePassword(userID uuid.UUID, currentPassword, newPassword string) error
 	VerifyPassword(userID uuid.UUID, password string) error
@@ -33,12 +38,14 @@ type UserService interface {
 type userService struct {
 	userRepo   repositories.UserRepository
 	tenantRepo repositories.TenantRepository
+	autoJoin   AutoJoinService
 }
 
-func NewUserService(userRepo repositories.UserRepository, tenantRepo repositories.TenantRepository) UserService {
+func NewUserService(userRepo repositories.UserRepository, tenantRepo repositories.TenantRepository, autoJoin AutoJoinService) UserService {
 	return &userService{
 		userRepo:   userRepo,
 		tenantRepo: tenantRepo,
+		autoJoin:   autoJoin,
 	}
 }
 
@@ -48,7 +55,11 @@ func (s *userService) ListUsers() ([]*models.User, error) {
 }
 
 func (s *userService) CreateUser(user *models.User) error {
-	return s.userRepo.CreateUser(user)
+	if err := s.userRepo.CreateUser(user); err != nil {
+		return err
+	}
+	s.applyAutoJoin(user)
+	return nil
 }
 
 func (s *userService) GetUser(id uuid.UUID) (*models.User, error) {
@@ -109,10 +120,22 @@ func (s *userService) RemoveUserFromTenant(userID uuid.UUID, tenantID uuid.UUID)
 	return s.userRepo.RemoveUserFromTenant(userID, tenantID)
 }
 
+func (s *userService) GetUserTenantRoles(userID uuid.UUID, tenantID uuid.UUID) ([]string, error) {
+	access, err := s.tenantRepo.GetUserTenantAccess(userID, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	return access.Roles, nil
+}
+
 func (s *userService) UpdateUserRole(userID uuid.UUID, tenantID uuid.UUID, role string) error {
 	return s.userRepo.UpdateUserRole(userID, tenantID, role)
 }
 
+func (s *userService) UpdateUserRoles(userID uuid.UUID, tenantID uuid.UUID, roles []string) error {
+	return s.userRepo.UpdateUserRoles(userID, tenantID, roles)
+}
+
 func (s *userService) UpdatePassword(userID uuid.UUID, currentPassword, newPassword string) error {
 	// Get user credentials
 	cred, err := s.userRepo.GetUserCredentials(userID)
@@ -153,12 +176,13 @@ func (s *userService) CreateOrUpdateUser(oauthUser *models.OAuthUser) (*models.U
 	if err != nil {
 		// Create new user
 		user = &models.User{
-			Email:     oauthUser.Email,
-			Name:      oauthUser.Name,
-			Status:    "active",
-			Role:      "user",
-			CreatedAt: time.Now(),
-			UpdatedAt: time.Now(),
+			Email:         oauthUser.Email,
+			EmailVerified: oauthUser.VerifiedEmail,
+			Name:          oauthUser.Name,
+			Status:        "active",
+			Role:          "user",
+			CreatedAt:     time.Now(),
+			UpdatedAt:     time.Now(),
 		}
 		if err := s.userRepo.CreateUser(user); err != nil {
 			return nil, fmt.Errorf("failed to create user: %v", err)
@@ -178,15 +202,29 @@ func (s *userService) CreateOrUpdateUser(oauthUser *models.OAuthUser) (*models.U
 		CreatedAt: time.Now(),
 	}
 
+	// The provider has confirmed the address belongs to the user
+	if oauthUser.VerifiedEmail {
+		user.EmailVerified = true
+	}
+
 	// Update user's last login time
 	user.LastLoginAt = time.Now()
 	if err := s.userRepo.UpdateUser(user); err != nil {
 		return nil, fmt.Errorf("failed to update user: %v", err)
 	}
 
+	s.applyAutoJoin(user)
 	return user, nil
 }
 
+// applyAutoJoin adds the user to the tenant that verified their email domain if its policy
+// asks for it. Signing up or in does not fail when this does.
+func (s *userService) applyAutoJoin(user *models.User) {
+	if err := s.autoJoin.Evaluate(user); err != nil {
+		log.Printf("Failed to apply auto-join policy for user %s: %v", user.ID, err)
+	}
+}
+
 func (s *userService) VerifyPassword(userID uuid.UUID, password string) error {
 	cred, err := s.userRepo.GetUserCredentials(userID)
 	if err != nil {